@@ -246,7 +246,7 @@ resource "aws_iam_role_policy_attachment" "detector_lambda_athena" {
 # S3 permissions for detector Lambda (Athena results bucket)
 resource "aws_iam_policy" "detector_lambda_s3" {
   name        = "${var.basename}-detector-lambda-s3-policy"
-  description = "S3 permissions for detector Lambda to access Athena results"
+  description = "S3 permissions for detector Lambda to access Athena results and alert states"
 
   policy = jsonencode({
     Version = "2012-10-17"
@@ -274,6 +274,26 @@ resource "aws_iam_policy" "detector_lambda_s3" {
           aws_securitylake_data_lake.main.s3_bucket_arn,
           "${aws_securitylake_data_lake.main.s3_bucket_arn}/*"
         ]
+      },
+      {
+        # States of open alerts for deduplication
+        Effect = "Allow"
+        Action = [
+          "s3:GetObject",
+          "s3:PutObject"
+        ]
+        Resource = "${aws_s3_bucket.converter_state.arn}/alerts/*"
+      },
+      {
+        # GetObject of a missing state returns NoSuchKey instead of AccessDenied
+        Effect   = "Allow"
+        Action   = "s3:ListBucket"
+        Resource = aws_s3_bucket.converter_state.arn
+        Condition = {
+          StringLike = {
+            "s3:prefix" = ["alerts/*"]
+          }
+        }
       }
     ]
   })
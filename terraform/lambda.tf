@@ -11,7 +11,7 @@ resource "null_resource" "build_converter" {
   provisioner "local-exec" {
     command = <<-EOT
       cd ${path.module}/lambda/converter
-      GOOS=linux GOARCH=arm64 CGO_ENABLED=0 go build -o bootstrap main.go types.go s3_interface.go convert.go arrow_writer.go
+      GOOS=linux GOARCH=arm64 CGO_ENABLED=0 go build -o bootstrap .
     EOT
     environment = {
       PAGER = ""
@@ -117,6 +117,24 @@ resource "aws_iam_policy" "converter_lambda_s3" {
           "s3:PutObject"
         ]
         Resource = "${aws_securitylake_data_lake.main.s3_bucket_arn}/*"
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "s3:GetObject",
+          "s3:PutObject"
+        ]
+        Resource = "${aws_s3_bucket.converter_state.arn}/*"
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "s3:ListBucket"
+        ]
+        Resource = [
+          aws_s3_bucket.converter_state.arn,
+          aws_s3_bucket.raw_logs.arn # reprocess by prefix
+        ]
       }
     ]
   })
@@ -141,9 +159,23 @@ resource "aws_lambda_function" "converter" {
 
   environment {
     variables = {
-      SECURITY_LAKE_BUCKET = replace(aws_securitylake_data_lake.main.s3_bucket_arn, "arn:aws:s3:::", "")
-      AWS_ACCOUNT_ID       = data.aws_caller_identity.current.account_id
-      CUSTOM_LOG_SOURCE    = aws_securitylake_custom_log_source.google_workspace.source_name
+      SECURITY_LAKE_BUCKET   = replace(aws_securitylake_data_lake.main.s3_bucket_arn, "arn:aws:s3:::", "")
+      AWS_ACCOUNT_ID         = data.aws_caller_identity.current.account_id
+      CUSTOM_LOG_SOURCE      = aws_securitylake_custom_log_source.google_workspace.source_name
+      CONVERTER_STATE_BUCKET = aws_s3_bucket.converter_state.id
+
+      GEOIP_CITY_DB          = var.geoip_city_database_key != "" ? "s3://${aws_s3_bucket.converter_state.id}/${var.geoip_city_database_key}" : ""
+      GEOIP_ASN_DB           = var.geoip_asn_database_key != "" ? "s3://${aws_s3_bucket.converter_state.id}/${var.geoip_asn_database_key}" : ""
+
+      CUSTOM_LOG_SOURCE_VERSION = aws_securitylake_custom_log_source.google_workspace.source_version
+      ADDITIONAL_LOG_SOURCES    = join(",", var.converter_additional_log_sources)
+      OCSF_VERSION              = var.ocsf_version
+
+      EVENT_CATALOG_PATH        = join(",", [for key in var.event_catalog_keys : "s3://${aws_s3_bucket.converter_state.id}/${key}"])
+      CLASSIFICATION_RULES_PATH = join(",", [for key in var.classification_rules_keys : "s3://${aws_s3_bucket.converter_state.id}/${key}"])
+
+      ENRICH_USER_DIRECTORY = contains(keys(var.enrichment_data_keys), "user_directory") ? "s3://${aws_s3_bucket.converter_state.id}/${var.enrichment_data_keys["user_directory"]}" : ""
+      ENRICH_IP_INTEL       = contains(keys(var.enrichment_data_keys), "ip_intel") ? "s3://${aws_s3_bucket.converter_state.id}/${var.enrichment_data_keys["ip_intel"]}" : ""
     }
   }
 
@@ -164,6 +196,283 @@ resource "aws_lambda_event_source_mapping" "converter_sqs" {
   event_source_arn = aws_sqs_queue.raw_logs.arn
   function_name    = aws_lambda_function.converter.arn
   batch_size       = 1
+
+  # Only failed messages are retried and eventually moved to the DLQ
+  function_response_types = ["ReportBatchItemFailures"]
+}
+
+###########################################
+# Detector Lambda (scheduled detection)
+###########################################
+
+# Archive detector source files for trigger detection
+data "archive_file" "detector_source" {
+  type        = "zip"
+  source_dir  = "${path.module}/lambda/detector"
+  output_path = "${path.module}/lambda/detector_source.zip"
+  excludes    = ["*.zip", "go.sum", "bootstrap", "*_test.go", "testdata"]
+}
+
+# Build detector Lambda binary. localsql (DuckDB) is only imported by tests and ocsf-detect,
+# so the function builds without cgo.
+resource "null_resource" "build_detector" {
+  triggers = {
+    source_hash = data.archive_file.detector_source.output_base64sha256
+  }
+
+  provisioner "local-exec" {
+    command = <<-EOT
+      mkdir -p ${path.module}/lambda/detector-bin
+      cd ${path.module}/lambda/detector
+      GOOS=linux GOARCH=arm64 CGO_ENABLED=0 go build -o ../detector-bin/bootstrap .
+    EOT
+    environment = {
+      PAGER = ""
+    }
+  }
+}
+
+# Archive file for detector Lambda
+data "archive_file" "detector_lambda_zip" {
+  type        = "zip"
+  source_dir  = "${path.module}/lambda/detector-bin"
+  output_path = "${path.module}/lambda/detector.zip"
+
+  depends_on = [null_resource.build_detector]
+}
+
+# Note: IAM resources for detector Lambda are defined in iam.tf
+
+# Detector Lambda function
+resource "aws_lambda_function" "detector" {
+  filename         = data.archive_file.detector_lambda_zip.output_path
+  function_name    = "${var.basename}-detector"
+  role             = aws_iam_role.detector_lambda.arn
+  handler          = "bootstrap"
+  source_code_hash = data.archive_file.detector_lambda_zip.output_base64sha256
+  runtime          = "provided.al2"
+  architectures    = ["arm64"]
+  # Rules run one by one, each waiting for its Athena query
+  timeout     = 300
+  memory_size = 256
+
+  environment {
+    variables = {
+      ATHENA_WORKGROUP         = aws_athena_workgroup.main.name
+      ATHENA_MAX_BYTES_SCANNED = tostring(var.detector_max_bytes_scanned)
+      DETECTOR_STATE_BUCKET    = aws_s3_bucket.converter_state.id
+      ALERTS_TOPIC_ARN         = aws_sns_topic.alerts.arn
+      DETECTOR_SCHEDULE        = "5m" # interval of detector_schedule
+      DETECTION_DELAY          = var.detection_delay
+    }
+  }
+
+  depends_on = [
+    aws_iam_role_policy_attachment.detector_lambda_basic,
+    aws_iam_role_policy_attachment.detector_lambda_athena,
+    aws_iam_role_policy_attachment.detector_lambda_s3,
+    aws_iam_role_policy_attachment.detector_lambda_sns,
+  ]
+
+  tags = merge(local.common_tags, {
+    Name = "${var.basename}-detector"
+    Type = "lambda-function"
+  })
+}
+
+# EventBridge rule for 5-minute interval execution. Rules are due by their own intervals.
+resource "aws_cloudwatch_event_rule" "detector_schedule" {
+  name                = "${var.basename}-detector-schedule"
+  description         = "Trigger detector Lambda every 5 minutes"
+  schedule_expression = "rate(5 minutes)"
+
+  tags = merge(local.common_tags, {
+    Name = "${var.basename}-detector-schedule"
+    Type = "eventbridge-rule"
+  })
+}
+
+# EventBridge target
+resource "aws_cloudwatch_event_target" "detector_target" {
+  rule      = aws_cloudwatch_event_rule.detector_schedule.name
+  target_id = "${var.basename}-detector-target"
+  arn       = aws_lambda_function.detector.arn
+}
+
+# Permission for EventBridge to invoke detector Lambda
+resource "aws_lambda_permission" "detector_eventbridge" {
+  statement_id  = "AllowExecutionFromEventBridge"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.detector.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.detector_schedule.arn
+}
+
+###########################################
+# Alert Router Lambda (alerts SNS topic)
+###########################################
+
+# The alert router is deployed only with a router.yaml, as the function fails to start
+# without the secrets of its destinations. Secrets are SecureString SSM parameters under
+# alert_router_secrets_path, read by the function at start.
+locals {
+  alert_router_count = var.alert_router_config_file != "" ? 1 : 0
+}
+
+# Build alert-router Lambda binary with its router.yaml
+resource "null_resource" "build_alert_router" {
+  count = local.alert_router_count
+
+  triggers = {
+    source_hash = data.archive_file.detector_source.output_base64sha256
+    config_hash = filesha256("${path.module}/${var.alert_router_config_file}")
+  }
+
+  provisioner "local-exec" {
+    command = <<-EOT
+      mkdir -p ${path.module}/lambda/alert-router
+      cp ${path.module}/${var.alert_router_config_file} ${path.module}/lambda/alert-router/router.yaml
+      cd ${path.module}/lambda/detector
+      GOOS=linux GOARCH=arm64 CGO_ENABLED=0 go build -o ../alert-router/bootstrap ./cmd/alert-router
+    EOT
+    environment = {
+      PAGER = ""
+    }
+  }
+}
+
+# Archive file for alert-router Lambda
+data "archive_file" "alert_router_lambda_zip" {
+  count = local.alert_router_count
+
+  type        = "zip"
+  source_dir  = "${path.module}/lambda/alert-router"
+  output_path = "${path.module}/lambda/alert-router.zip"
+
+  depends_on = [null_resource.build_alert_router]
+}
+
+# IAM Role for alert-router Lambda
+resource "aws_iam_role" "alert_router_lambda" {
+  count = local.alert_router_count
+
+  name = "${var.basename}-alert-router-lambda-role"
+
+  assume_role_policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action = "sts:AssumeRole"
+        Effect = "Allow"
+        Principal = {
+          Service = "lambda.amazonaws.com"
+        }
+      }
+    ]
+  })
+
+  tags = merge(local.common_tags, {
+    Name = "${var.basename}-alert-router-lambda-role"
+    Type = "lambda-role"
+  })
+}
+
+# Basic Lambda execution role. Deliveries are logged to stdout, and destinations are outside
+# of AWS.
+resource "aws_iam_role_policy_attachment" "alert_router_lambda_basic" {
+  count = local.alert_router_count
+
+  policy_arn = "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"
+  role       = aws_iam_role.alert_router_lambda[0].name
+}
+
+# SSM read permissions for secrets of destinations. SecureString parameters encrypted with
+# the AWS managed key (aws/ssm) are decrypted through SSM without a KMS permission.
+resource "aws_iam_policy" "alert_router_lambda_ssm" {
+  count = local.alert_router_count
+
+  name        = "${var.basename}-alert-router-lambda-ssm-policy"
+  description = "SSM permissions for alert-router Lambda to read secrets of destinations"
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Effect = "Allow"
+        Action = [
+          "ssm:GetParametersByPath"
+        ]
+        Resource = [
+          "arn:aws:ssm:${var.aws_region}:${local.account_id}:parameter${var.alert_router_secrets_path}",
+          "arn:aws:ssm:${var.aws_region}:${local.account_id}:parameter${var.alert_router_secrets_path}/*"
+        ]
+      }
+    ]
+  })
+
+  tags = merge(local.common_tags, {
+    Name = "${var.basename}-alert-router-lambda-ssm-policy"
+  })
+}
+
+resource "aws_iam_role_policy_attachment" "alert_router_lambda_ssm" {
+  count = local.alert_router_count
+
+  policy_arn = aws_iam_policy.alert_router_lambda_ssm[0].arn
+  role       = aws_iam_role.alert_router_lambda[0].name
+}
+
+# Alert router Lambda function
+resource "aws_lambda_function" "alert_router" {
+  count = local.alert_router_count
+
+  filename         = data.archive_file.alert_router_lambda_zip[0].output_path
+  function_name    = "${var.basename}-alert-router"
+  role             = aws_iam_role.alert_router_lambda[0].arn
+  handler          = "bootstrap"
+  source_code_hash = data.archive_file.alert_router_lambda_zip[0].output_base64sha256
+  runtime          = "provided.al2"
+  architectures    = ["arm64"]
+  # Deliveries are retried with backoff within the invocation
+  timeout     = 60
+  memory_size = 128
+
+  environment {
+    variables = {
+      ROUTER_CONFIG       = "router.yaml"
+      ROUTER_SECRETS_PATH = var.alert_router_secrets_path
+    }
+  }
+
+  depends_on = [
+    aws_iam_role_policy_attachment.alert_router_lambda_basic,
+    aws_iam_role_policy_attachment.alert_router_lambda_ssm,
+  ]
+
+  tags = merge(local.common_tags, {
+    Name = "${var.basename}-alert-router"
+    Type = "lambda-function"
+  })
+}
+
+# Subscription of alert-router Lambda to the alerts topic
+resource "aws_sns_topic_subscription" "alerts_router" {
+  count = local.alert_router_count
+
+  topic_arn = aws_sns_topic.alerts.arn
+  protocol  = "lambda"
+  endpoint  = aws_lambda_function.alert_router[0].arn
+}
+
+# Permission for SNS to invoke alert-router Lambda
+resource "aws_lambda_permission" "alert_router_sns" {
+  count = local.alert_router_count
+
+  statement_id  = "AllowExecutionFromAlertsSNS"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.alert_router[0].function_name
+  principal     = "sns.amazonaws.com"
+  source_arn    = aws_sns_topic.alerts.arn
 }
 
 
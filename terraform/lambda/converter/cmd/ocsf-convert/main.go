@@ -0,0 +1,371 @@
+// Command ocsf-convert runs the converter locally without S3 and AWS credentials.
+//
+//	go run ./cmd/ocsf-convert convert [-out out] <file or directory>...
+//	go run ./cmd/ocsf-convert dump <parquet file or directory>...
+//
+// convert writes Parquet files under the output directory in the same layout as the Security
+// Lake bucket (ext/{source}/{version}/region=.../accountId=.../eventDay=.../), together with
+// quarantined records and conversion reports as written to the state bucket. dump prints
+// records of Parquet files as JSON lines.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-converter/session"
+	"seccamp2025-b1-converter/validator"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s convert [flags] <file or directory>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s dump [flags] <parquet file or directory>...\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "convert":
+		err = runConvert(os.Args[2:])
+	case "dump":
+		err = runDump(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runConvert(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	outDir := flags.String("out", "out", "output directory")
+	source := flags.String("source", "google-workspace", "custom log source name")
+	sourceVersion := flags.String("source-version", core.DefaultSourceVersion, "custom log source version")
+	byHour := flags.Bool("hourly", false, "also partition output by eventHour= below eventDay=")
+	region := flags.String("region", "ap-northeast-1", "region of records and output partitions")
+	accountID := flags.String("account", "000000000000", "account ID of records and output partitions")
+	ocsfVersion := flags.String("ocsf-version", core.DefaultOCSFVersion, "OCSF version in metadata.version")
+	productName := flags.String("product-name", core.DefaultProductName, "product name in metadata.product")
+	productVendor := flags.String("product-vendor", core.DefaultProductVendor, "product vendor in metadata.product")
+	catalog := flags.String("catalog", "", "comma separated event catalog override files")
+	classification := flags.String("classification", "", "comma separated classification rule files")
+	geoipCity := flags.String("geoip-city", "", "MaxMind City database (static prefix table if empty)")
+	geoipASN := flags.String("geoip-asn", "", "MaxMind ASN database")
+	userDirectory := flags.String("user-directory", "", "user directory for enrichment (CSV or JSON)")
+	ipIntel := flags.String("ip-intel", "", "IP intelligence for enrichment (CSV or JSON)")
+	validation := flags.String("validation", "", `OCSF validation mode: "" (known deviations ignored), "strict" or "off"`)
+	sessionTimeout := flags.Duration("session-timeout", session.DefaultConfig().InactivityTimeout, "session inactivity timeout (0 disables sessionization)")
+	verbose := flags.Bool("v", false, "print progress logs")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	setupLogger(*verbose)
+
+	ctx := context.Background()
+	converter, err := newConverter(ctx, converterConfig{
+		region:         *region,
+		accountID:      *accountID,
+		ocsfVersion:    *ocsfVersion,
+		productName:    *productName,
+		productVendor:  *productVendor,
+		catalog:        splitList(*catalog),
+		classification: splitList(*classification),
+		geoipCity:      *geoipCity,
+		geoipASN:       *geoipASN,
+		userDirectory:  *userDirectory,
+		ipIntel:        *ipIntel,
+		validation:     *validation,
+	})
+	if err != nil {
+		return err
+	}
+
+	var sessionizer *session.Sessionizer
+	if *sessionTimeout > 0 {
+		cfg := session.DefaultConfig()
+		cfg.InactivityTimeout = *sessionTimeout
+		sessionizer = session.New(cfg, session.NewState())
+	}
+
+	inputs, err := inputFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	layout := core.SourceLayout{Name: *source, Version: *sourceVersion}
+
+	failed := 0
+	for _, input := range inputs {
+		if err := convertFile(ctx, converter, sessionizer, layout, *byHour, input, *outDir); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", input.path, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to convert %d of %d files", failed, len(inputs))
+	}
+	return nil
+}
+
+type converterConfig struct {
+	region, accountID                       string
+	ocsfVersion, productName, productVendor string
+	catalog, classification                 []string
+	geoipCity, geoipASN                     string
+	userDirectory, ipIntel                  string
+	validation                              string
+}
+
+// newConverter builds the converter in the same way as the Lambda handler, from local files
+func newConverter(ctx context.Context, cfg converterConfig) (*core.Converter, error) {
+	catalog, err := core.LoadEventCatalog(ctx, nil, cfg.catalog...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load event catalog: %w", err)
+	}
+	classifier, err := core.LoadResourceClassifier(ctx, nil, cfg.classification...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load classification rules: %w", err)
+	}
+	opts := core.ConvertOptions{
+		Catalog:       catalog,
+		Classifier:    classifier,
+		OCSFVersion:   cfg.ocsfVersion,
+		ProductName:   cfg.productName,
+		ProductVendor: cfg.productVendor,
+	}
+	if cfg.geoipCity != "" {
+		if opts.GeoIP, err = core.NewGeoIPResolver(ctx, nil, cfg.geoipCity, cfg.geoipASN); err != nil {
+			return nil, fmt.Errorf("failed to load GeoIP database: %w", err)
+		}
+	}
+	enrichers, err := core.LoadEnrichers(ctx, nil, cfg.userDirectory, cfg.ipIntel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load enrichers: %w", err)
+	}
+	v, err := core.NewValidator(cfg.validation)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Converter{
+		Region:    cfg.region,
+		AccountID: cfg.accountID,
+		Options:   opts,
+		Enrichers: enrichers,
+		Validator: v,
+	}, nil
+}
+
+// inputFile is a file to convert. key is the path relative to the directory given in the
+// arguments, which is used as the source object key in output names.
+type inputFile struct {
+	path string
+	key  string
+}
+
+// inputFiles expands directories in args into files with a supported input extension
+func inputFiles(args []string) ([]inputFile, error) {
+	var files []inputFile
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, inputFile{path: arg, key: filepath.Base(arg)})
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if !slices.ContainsFunc(core.InputExtensions, func(ext string) bool { return strings.HasSuffix(path, ext) }) {
+				return nil
+			}
+			rel, err := filepath.Rel(arg, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, inputFile{path: path, key: filepath.ToSlash(rel)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// convertFile converts a file and writes Parquet files, quarantined records and the report
+// under outDir with the same keys as the Lambda handler
+func convertFile(ctx context.Context, converter *core.Converter, sessionizer *session.Sessionizer, layout core.SourceLayout, byHour bool, input inputFile, outDir string) error {
+	data, err := os.ReadFile(input.path)
+	if err != nil {
+		return err
+	}
+	sourceHash := core.ContentHash(data)
+
+	result, err := converter.Convert(ctx, input.key, data)
+	if err != nil {
+		return err
+	}
+	if sessionizer != nil && len(result.Logs) > 0 {
+		core.ApplySessions(sessionizer, result.Logs)
+	}
+
+	report := &core.ConversionReport{
+		SourceKey:   input.key,
+		ContentHash: sourceHash,
+		Format:      result.Format,
+		Parsed:      result.Parsed,
+		Converted:   len(result.Logs),
+		Quarantined: len(result.Quarantined),
+		Outputs:     []string{},
+	}
+	for _, p := range core.PartitionLogs(result.Logs, byHour) {
+		parquetData, err := core.GenerateParquet(p.Logs)
+		if err != nil {
+			return fmt.Errorf("failed to generate parquet file: %w", err)
+		}
+		key := core.BuildSecurityLakeKey(layout, p.Key, input.key, sourceHash)
+		if err := writeOutput(outDir, key, parquetData); err != nil {
+			return err
+		}
+		report.Outputs = append(report.Outputs, key)
+	}
+
+	if len(result.Quarantined) > 0 {
+		var lines []byte
+		for _, record := range result.Quarantined {
+			line, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to encode quarantine record: %w", err)
+			}
+			lines = append(append(lines, line...), '\n')
+		}
+		report.Quarantine = core.QuarantineKey(input.key, sourceHash)
+		if err := writeOutput(outDir, report.Quarantine, lines); err != nil {
+			return err
+		}
+	}
+
+	report.ProcessedAt = time.Now().UTC()
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode conversion report: %w", err)
+	}
+	if err := writeOutput(outDir, core.ReportKey(input.key, sourceHash), raw); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %s, parsed %d, converted %d, quarantined %d, %d parquet files\n",
+		input.path, result.Format, report.Parsed, report.Converted, report.Quarantined, len(report.Outputs))
+	return nil
+}
+
+func writeOutput(outDir, key string, data []byte) error {
+	path := filepath.Join(outDir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func runDump(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	withPath := flags.Bool("path", false, "add the source file path to each record as _path")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	files, err := parquetFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		records, err := validator.ReadParquet(context.Background(), data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, record := range records {
+			if *withPath {
+				record["_path"] = path
+			}
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parquetFiles expands directories in args into *.parquet files
+func parquetFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".parquet") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// setupLogger sends logs of the conversion to stderr, only warnings unless verbose
+func setupLogger(verbose bool) {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
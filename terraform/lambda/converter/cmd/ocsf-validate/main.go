@@ -0,0 +1,120 @@
+// Command ocsf-validate checks Parquet files written by the converter against the OCSF schema.
+//
+//	go run ./cmd/ocsf-validate [-strict] [-class web_resources_activity] <file or directory>...
+//
+// Directories are walked for *.parquet files. The exit status is 1 if any record is invalid.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"seccamp2025-b1-converter/validator"
+)
+
+func main() {
+	className := flag.String("class", "web_resources_activity", "OCSF class name of records")
+	strict := flag.Bool("strict", false, "report known deviations of the converter as violations")
+	maxReport := flag.Int("max", 20, "maximum number of invalid records reported per file (0 for all)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file or directory>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var ignore []validator.Deviation
+	if !*strict {
+		ignore = validator.KnownDeviations
+	}
+	v, err := validator.New(validator.DefaultSchema(), *className, ignore...)
+	if err != nil {
+		log.Fatalf("Failed to create validator: %v", err)
+	}
+
+	files, err := parquetFiles(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to list parquet files: %v", err)
+	}
+
+	var total, invalid int
+	for _, path := range files {
+		n, bad, err := validateFile(context.Background(), v, path, *maxReport)
+		if err != nil {
+			log.Fatalf("Failed to validate %s: %v", path, err)
+		}
+		total += n
+		invalid += bad
+	}
+
+	fmt.Printf("Validated %d records in %d files: %d invalid\n", total, len(files), invalid)
+	if invalid > 0 {
+		os.Exit(1)
+	}
+}
+
+// parquetFiles expands directories in args into *.parquet files
+func parquetFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".parquet") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// validateFile prints violations of records in the file and returns the number of records
+// and invalid records
+func validateFile(ctx context.Context, v *validator.Validator, path string, maxReport int) (int, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	records, err := validator.ReadParquet(ctx, data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	invalid := 0
+	for i, record := range records {
+		violations := v.Validate(record)
+		if len(violations) == 0 {
+			continue
+		}
+		invalid++
+		if maxReport == 0 || invalid <= maxReport {
+			fmt.Printf("%s:%d: %s\n", path, i+1, violations)
+		}
+	}
+	if maxReport > 0 && invalid > maxReport {
+		fmt.Printf("%s: %d more invalid records are not shown\n", path, invalid-maxReport)
+	}
+	return len(records), invalid, nil
+}
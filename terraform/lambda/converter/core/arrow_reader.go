@@ -0,0 +1,24 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"seccamp2025-b1-converter/validator"
+)
+
+// ReadParquet decodes a Parquet file written by GenerateParquet into records. Fields which are
+// not written to Parquet, such as metadata.extension, are left empty.
+func ReadParquet(ctx context.Context, data []byte) ([]OCSFWebResourceActivity, error) {
+	records, err := validator.ReadParquet(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]OCSFWebResourceActivity, len(records))
+	for i, record := range records {
+		if err := validator.FromRecord(record, &logs[i]); err != nil {
+			return nil, fmt.Errorf("failed to decode row %d: %w", i, err)
+		}
+	}
+	return logs, nil
+}
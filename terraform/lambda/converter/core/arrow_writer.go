@@ -1,4 +1,4 @@
-package main
+package core
 
 import (
 	"bytes"
@@ -37,6 +37,8 @@ func buildOCSFSchema() *arrow.Schema {
 				arrow.Field{Name: "uid", Type: arrow.BinaryTypes.String},
 				arrow.Field{Name: "created_time", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
 				arrow.Field{Name: "exp_time", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+				arrow.Field{Name: "is_new_ip", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+				arrow.Field{Name: "is_new_device", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
 			), Nullable: true},
 			arrow.Field{Name: "app_name", Type: arrow.BinaryTypes.String, Nullable: true},
 			arrow.Field{Name: "app_uid", Type: arrow.BinaryTypes.String, Nullable: true},
@@ -74,6 +76,13 @@ func buildOCSFSchema() *arrow.Schema {
 				arrow.Field{Name: "city", Type: arrow.BinaryTypes.String, Nullable: true},
 				arrow.Field{Name: "country", Type: arrow.BinaryTypes.String, Nullable: true},
 				arrow.Field{Name: "region", Type: arrow.BinaryTypes.String, Nullable: true},
+				arrow.Field{Name: "lat", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+				arrow.Field{Name: "long", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+				arrow.Field{Name: "isp", Type: arrow.BinaryTypes.String, Nullable: true},
+			), Nullable: true},
+			arrow.Field{Name: "autonomous_system", Type: arrow.StructOf(
+				arrow.Field{Name: "number", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+				arrow.Field{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
 			), Nullable: true},
 		)},
 		{Name: "web_resources", Type: arrow.ListOf(arrow.StructOf(
@@ -83,6 +92,7 @@ func buildOCSFSchema() *arrow.Schema {
 			arrow.Field{Name: "url_string", Type: arrow.BinaryTypes.String, Nullable: true},
 			arrow.Field{Name: "data", Type: arrow.StructOf(
 				arrow.Field{Name: "classification", Type: arrow.BinaryTypes.String, Nullable: true},
+				arrow.Field{Name: "sensitivity", Type: arrow.BinaryTypes.String, Nullable: true},
 			), Nullable: true},
 		)), Nullable: true},
 		{Name: "metadata", Type: arrow.StructOf(
@@ -92,6 +102,11 @@ func buildOCSFSchema() *arrow.Schema {
 			arrow.Field{Name: "processed", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
 			arrow.Field{Name: "product_name", Type: arrow.BinaryTypes.String, Nullable: true},
 			arrow.Field{Name: "version", Type: arrow.BinaryTypes.String, Nullable: true},
+			arrow.Field{Name: "product", Type: arrow.StructOf(
+				arrow.Field{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+				arrow.Field{Name: "vendor_name", Type: arrow.BinaryTypes.String, Nullable: true},
+			), Nullable: true},
+			arrow.Field{Name: "uid", Type: arrow.BinaryTypes.String, Nullable: true},
 		), Nullable: true},
 		{Name: "observables", Type: arrow.ListOf(arrow.StructOf(
 			arrow.Field{Name: "name", Type: arrow.BinaryTypes.String},
@@ -101,10 +116,11 @@ func buildOCSFSchema() *arrow.Schema {
 		{Name: "aws_region", Type: arrow.BinaryTypes.String},
 		{Name: "account_id", Type: arrow.BinaryTypes.String},
 		{Name: "event_hour", Type: arrow.BinaryTypes.String},
+		{Name: "disposition_id", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
 	}, nil)
 }
 
-func generateOCSFParquetFileArrow(logs []OCSFWebResourceActivity) ([]byte, error) {
+func GenerateParquet(logs []OCSFWebResourceActivity) ([]byte, error) {
 	schema := buildOCSFSchema()
 	mem := memory.NewGoAllocator()
 	
@@ -187,6 +203,8 @@ func generateOCSFParquetFileArrow(logs []OCSFWebResourceActivity) ([]byte, error
 			} else {
 				sessionBuilder.FieldBuilder(2).(*array.Int64Builder).AppendNull()
 			}
+			sessionBuilder.FieldBuilder(3).(*array.BooleanBuilder).Append(log.Actor.Session.IsNewIP)
+			sessionBuilder.FieldBuilder(4).(*array.BooleanBuilder).Append(log.Actor.Session.IsNewDevice)
 		} else {
 			sessionBuilder.AppendNull()
 		}
@@ -287,26 +305,37 @@ func generateOCSFParquetFileArrow(logs []OCSFWebResourceActivity) ([]byte, error
 		}
 		
 		// SrcEndpoint.Location (nullable)
+		location := log.SrcEndpoint.Location
 		locationBuilder := srcEndpointBuilder.FieldBuilder(2).(*array.StructBuilder)
-		if log.SrcEndpoint.Location.Country != "" || log.SrcEndpoint.Location.Region != "" || log.SrcEndpoint.Location.City != "" {
+		if location != (OCSFLocation{}) {
 			locationBuilder.Append(true)
-			if log.SrcEndpoint.Location.Country != "" {
-				locationBuilder.FieldBuilder(0).(*array.StringBuilder).Append(log.SrcEndpoint.Location.Country)
-			} else {
-				locationBuilder.FieldBuilder(0).(*array.StringBuilder).AppendNull()
-			}
-			if log.SrcEndpoint.Location.Region != "" {
-				locationBuilder.FieldBuilder(1).(*array.StringBuilder).Append(log.SrcEndpoint.Location.Region)
+			appendOptionalString(locationBuilder.FieldBuilder(0).(*array.StringBuilder), location.City)
+			appendOptionalString(locationBuilder.FieldBuilder(1).(*array.StringBuilder), location.Country)
+			appendOptionalString(locationBuilder.FieldBuilder(2).(*array.StringBuilder), location.Region)
+			if location.Lat != 0 || location.Long != 0 {
+				locationBuilder.FieldBuilder(3).(*array.Float64Builder).Append(location.Lat)
+				locationBuilder.FieldBuilder(4).(*array.Float64Builder).Append(location.Long)
 			} else {
-				locationBuilder.FieldBuilder(1).(*array.StringBuilder).AppendNull()
+				locationBuilder.FieldBuilder(3).(*array.Float64Builder).AppendNull()
+				locationBuilder.FieldBuilder(4).(*array.Float64Builder).AppendNull()
 			}
-			if log.SrcEndpoint.Location.City != "" {
-				locationBuilder.FieldBuilder(2).(*array.StringBuilder).Append(log.SrcEndpoint.Location.City)
+			appendOptionalString(locationBuilder.FieldBuilder(5).(*array.StringBuilder), location.ISP)
+		} else {
+			locationBuilder.AppendNull()
+		}
+
+		// SrcEndpoint.AutonomousSystem (nullable)
+		asBuilder := srcEndpointBuilder.FieldBuilder(3).(*array.StructBuilder)
+		if as := log.SrcEndpoint.AutonomousSystem; as != (OCSFAutonomousSystem{}) {
+			asBuilder.Append(true)
+			if as.Number != 0 {
+				asBuilder.FieldBuilder(0).(*array.Int64Builder).Append(int64(as.Number))
 			} else {
-				locationBuilder.FieldBuilder(2).(*array.StringBuilder).AppendNull()
+				asBuilder.FieldBuilder(0).(*array.Int64Builder).AppendNull()
 			}
+			appendOptionalString(asBuilder.FieldBuilder(1).(*array.StringBuilder), as.Name)
 		} else {
-			locationBuilder.AppendNull()
+			asBuilder.AppendNull()
 		}
 
 		// WebResources (list of structs)
@@ -339,9 +368,10 @@ func generateOCSFParquetFileArrow(logs []OCSFWebResourceActivity) ([]byte, error
 				
 				// Data struct
 				dataBuilder := webResourcesValueBuilder.FieldBuilder(4).(*array.StructBuilder)
-				if resource.Data.Classification != "" {
+				if resource.Data != (OCSFWebResourceData{}) {
 					dataBuilder.Append(true)
-					dataBuilder.FieldBuilder(0).(*array.StringBuilder).Append(resource.Data.Classification)
+					appendOptionalString(dataBuilder.FieldBuilder(0).(*array.StringBuilder), resource.Data.Classification)
+					appendOptionalString(dataBuilder.FieldBuilder(1).(*array.StringBuilder), resource.Data.Sensitivity)
 				} else {
 					dataBuilder.AppendNull()
 				}
@@ -396,6 +426,18 @@ func generateOCSFParquetFileArrow(logs []OCSFWebResourceActivity) ([]byte, error
 			metadataBuilder.FieldBuilder(5).(*array.StringBuilder).AppendNull()
 		}
 
+		productBuilder := metadataBuilder.FieldBuilder(6).(*array.StructBuilder)
+		if product := log.Metadata.Product; product != (OCSFProduct{}) {
+			productBuilder.Append(true)
+			appendOptionalString(productBuilder.FieldBuilder(0).(*array.StringBuilder), product.Name)
+			appendOptionalString(productBuilder.FieldBuilder(1).(*array.StringBuilder), product.VendorName)
+		} else {
+			productBuilder.AppendNull()
+		}
+
+		// uniqueQualifier of the source log, to join records to it
+		appendOptionalString(metadataBuilder.FieldBuilder(7).(*array.StringBuilder), log.Metadata.UID)
+
 		// Observables (list of structs)
 		observablesBuilder := recordBuilder.Field(16).(*array.ListBuilder)
 		if len(log.Observables) > 0 {
@@ -415,6 +457,12 @@ func generateOCSFParquetFileArrow(logs []OCSFWebResourceActivity) ([]byte, error
 		recordBuilder.Field(17).(*array.StringBuilder).Append(log.Region)
 		recordBuilder.Field(18).(*array.StringBuilder).Append(log.AccountID)
 		recordBuilder.Field(19).(*array.StringBuilder).Append(log.EventHour)
+
+		if log.DispositionID != 0 {
+			recordBuilder.Field(20).(*array.Int64Builder).Append(int64(log.DispositionID))
+		} else {
+			recordBuilder.Field(20).(*array.Int64Builder).AppendNull()
+		}
 	}
 
 	// Build the record
@@ -442,4 +490,13 @@ func generateOCSFParquetFileArrow(logs []OCSFWebResourceActivity) ([]byte, error
 	}
 
 	return buf.Bytes(), nil
-}
\ No newline at end of file
+}
+
+// appendOptionalString appends value or null if it is empty
+func appendOptionalString(builder *array.StringBuilder, value string) {
+	if value != "" {
+		builder.Append(value)
+	} else {
+		builder.AppendNull()
+	}
+}
@@ -0,0 +1,266 @@
+package core
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+//go:embed catalog/events.json
+var defaultEventCatalogJSON []byte
+
+// ResourceExtraction declares which event parameters are used to build web_resources
+type ResourceExtraction struct {
+	UID         []string `json:"uid,omitempty"`
+	Name        []string `json:"name,omitempty"`
+	Type        []string `json:"type,omitempty"`
+	Visibility  []string `json:"visibility,omitempty"`
+	Owner       []string `json:"owner,omitempty"`
+	DefaultType string   `json:"default_type,omitempty"`
+}
+
+// EventMapping defines how a Google Workspace event is mapped to OCSF. Empty Application,
+// EventType or EventName work as wildcard, and zero values are inherited from less specific
+// entries (global default -> application default -> event type default -> event). Fields
+// listed in Unset by their JSON names are cleared instead, so that an entry can set a field
+// to zero or remove the resource extraction.
+type EventMapping struct {
+	Application   string              `json:"application,omitempty"`
+	EventType     string              `json:"event_type,omitempty"`
+	EventName     string              `json:"event_name,omitempty"`
+	ClassUID      int                 `json:"class_uid,omitempty"`
+	CategoryUID   int                 `json:"category_uid,omitempty"`
+	ActivityID    int                 `json:"activity_id,omitempty"`
+	Operation     string              `json:"operation,omitempty"`
+	SeverityID    int                 `json:"severity_id,omitempty"`
+	StatusID      int                 `json:"status_id,omitempty"`
+	DispositionID int                 `json:"disposition_id,omitempty"`
+	UserTypeID    int                 `json:"user_type_id,omitempty"`
+	Service       string              `json:"service,omitempty"`
+	Resource      *ResourceExtraction `json:"resource,omitempty"`
+	Unset         []string            `json:"unset,omitempty"`
+}
+
+// field returns a pointer to the inheritable field of the JSON name, or nil if unknown
+func (x *EventMapping) field(name string) any {
+	switch name {
+	case "class_uid":
+		return &x.ClassUID
+	case "category_uid":
+		return &x.CategoryUID
+	case "activity_id":
+		return &x.ActivityID
+	case "operation":
+		return &x.Operation
+	case "severity_id":
+		return &x.SeverityID
+	case "status_id":
+		return &x.StatusID
+	case "disposition_id":
+		return &x.DispositionID
+	case "user_type_id":
+		return &x.UserTypeID
+	case "service":
+		return &x.Service
+	case "resource":
+		return &x.Resource
+	}
+	return nil
+}
+
+// merge clears fields of x listed in Unset of override, and then overwrites them with
+// non-zero fields of override. Unset of the result keeps the fields still cleared, so that a
+// merged catalog entry clears them over less specific entries too.
+func (x EventMapping) merge(override EventMapping) EventMapping {
+	for _, name := range override.Unset {
+		reflect.ValueOf(x.field(name)).Elem().SetZero()
+	}
+	if override.ClassUID != 0 {
+		x.ClassUID = override.ClassUID
+	}
+	if override.CategoryUID != 0 {
+		x.CategoryUID = override.CategoryUID
+	}
+	if override.ActivityID != 0 {
+		x.ActivityID = override.ActivityID
+	}
+	if override.Operation != "" {
+		x.Operation = override.Operation
+	}
+	if override.SeverityID != 0 {
+		x.SeverityID = override.SeverityID
+	}
+	if override.StatusID != 0 {
+		x.StatusID = override.StatusID
+	}
+	if override.DispositionID != 0 {
+		x.DispositionID = override.DispositionID
+	}
+	if override.UserTypeID != 0 {
+		x.UserTypeID = override.UserTypeID
+	}
+	if override.Service != "" {
+		x.Service = override.Service
+	}
+	if override.Resource != nil {
+		x.Resource = override.Resource
+	}
+
+	var unset []string
+	for _, name := range append(slices.Clone(x.Unset), override.Unset...) {
+		if reflect.ValueOf(x.field(name)).Elem().IsZero() && !slices.Contains(unset, name) {
+			unset = append(unset, name)
+		}
+	}
+	x.Unset = unset
+	return x
+}
+
+type catalogKey struct {
+	application string
+	eventType   string
+	eventName   string
+}
+
+// EventCatalog is a set of EventMapping keyed by (applicationName, eventType, eventName)
+type EventCatalog struct {
+	entries map[catalogKey]EventMapping
+	// byName indexes keys of entries by application and event name regardless of event type.
+	// It refers to keys, so that overrides merged into an entry are also found by name.
+	byName map[catalogKey]catalogKey
+}
+
+type eventCatalogFile struct {
+	Events []EventMapping `json:"events"`
+}
+
+// NewEventCatalog builds a catalog from JSON data. Later data overrides entries of earlier
+// data with the same key, so it can be used to apply override files to the default catalog.
+func NewEventCatalog(data ...[]byte) (*EventCatalog, error) {
+	catalog := &EventCatalog{
+		entries: map[catalogKey]EventMapping{},
+		byName:  map[catalogKey]catalogKey{},
+	}
+
+	for i, raw := range data {
+		var file eventCatalogFile
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse event catalog #%d: %w", i, err)
+		}
+		for _, entry := range file.Events {
+			for _, name := range entry.Unset {
+				if entry.field(name) == nil {
+					return nil, fmt.Errorf("event catalog #%d: unknown field %q to unset", i, name)
+				}
+			}
+			catalog.add(entry)
+		}
+	}
+
+	if _, ok := catalog.entries[catalogKey{}]; !ok {
+		return nil, fmt.Errorf("event catalog has no global default entry")
+	}
+
+	return catalog, nil
+}
+
+func (x *EventCatalog) add(entry EventMapping) {
+	key := catalogKey{entry.Application, entry.EventType, entry.EventName}
+	if current, ok := x.entries[key]; ok {
+		entry = current.merge(entry)
+		entry.Application, entry.EventType, entry.EventName = key.application, key.eventType, key.eventName
+	}
+	x.entries[key] = entry
+
+	if entry.EventName != "" {
+		nameKey := catalogKey{application: entry.Application, eventName: entry.EventName}
+		if _, ok := x.byName[nameKey]; !ok || entry.EventType == "" {
+			x.byName[nameKey] = key
+		}
+	}
+}
+
+// Entries returns number of entries in the catalog
+func (x *EventCatalog) Entries() int {
+	return len(x.entries)
+}
+
+// Lookup returns the resolved mapping of an event. The most specific entry is searched in
+// the following order and merged onto application and global defaults:
+// (app, type, name), (app, *, name), (*, type, name), (*, *, name)
+func (x *EventCatalog) Lookup(application, eventType, eventName string) EventMapping {
+	resolved := x.entries[catalogKey{}]
+	if appDefault, ok := x.entries[catalogKey{application: application}]; ok {
+		resolved = resolved.merge(appDefault)
+	}
+	if typeDefault, ok := x.entries[catalogKey{application: application, eventType: eventType}]; ok && eventType != "" {
+		resolved = resolved.merge(typeDefault)
+	}
+
+	if eventName != "" {
+		candidates := []struct {
+			byName bool
+			key    catalogKey
+		}{
+			{false, catalogKey{application, eventType, eventName}},
+			{true, catalogKey{application: application, eventName: eventName}},
+			{false, catalogKey{eventType: eventType, eventName: eventName}},
+			{true, catalogKey{eventName: eventName}},
+		}
+		for _, c := range candidates {
+			key, ok := c.key, true
+			if c.byName {
+				key, ok = x.byName[c.key]
+			}
+			if entry, found := x.entries[key]; ok && found {
+				resolved = resolved.merge(entry)
+				break
+			}
+		}
+	}
+
+	resolved.Application, resolved.EventType, resolved.EventName = application, eventType, eventName
+	resolved.Unset = nil
+	if resolved.Operation == "" {
+		resolved.Operation = eventName
+		if resolved.Operation == "" {
+			resolved.Operation = eventType
+		}
+	}
+	return resolved
+}
+
+var (
+	defaultEventCatalogOnce sync.Once
+	defaultEventCatalog     *EventCatalog
+)
+
+// DefaultEventCatalog returns the catalog embedded in the binary
+func DefaultEventCatalog() *EventCatalog {
+	defaultEventCatalogOnce.Do(func() {
+		catalog, err := NewEventCatalog(defaultEventCatalogJSON)
+		if err != nil {
+			panic(fmt.Sprintf("embedded event catalog is invalid: %v", err))
+		}
+		defaultEventCatalog = catalog
+	})
+	return defaultEventCatalog
+}
+
+// LoadEventCatalog returns the embedded catalog with overrides from given JSON files (local
+// path or s3://bucket/key) applied
+func LoadEventCatalog(ctx context.Context, client ObjectGetter, overrideLocations ...string) (*EventCatalog, error) {
+	data := [][]byte{defaultEventCatalogJSON}
+	for _, location := range overrideLocations {
+		raw, err := readLocation(ctx, client, location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read event catalog override: %w", err)
+		}
+		data = append(data, raw)
+	}
+	return NewEventCatalog(data...)
+}
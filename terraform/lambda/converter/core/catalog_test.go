@@ -0,0 +1,282 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type schemaMappingRow struct {
+	application string
+	eventType   string
+	eventName   string
+	activityID  int
+	operation   string
+	severityID  int
+}
+
+var (
+	schemaAppHeading = regexp.MustCompile(`^#### .*\(applicationName: "([a-z]+)"\)`)
+	leadingNumber    = regexp.MustCompile(`^\d+`)
+)
+
+func splitMarkdownRow(line string) []string {
+	cells := strings.Split(strings.Trim(strings.TrimSpace(line), "|"), "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+func parseLeadingInt(t *testing.T, cell string) int {
+	n, err := strconv.Atoi(leadingNumber.FindString(cell))
+	require.NoError(t, err, "cell %q", cell)
+	return n
+}
+
+// loadSchemaMappingRows reads mapping tables of section 2.2 in schema.md
+func loadSchemaMappingRows(t *testing.T) []schemaMappingRow {
+	f, err := os.Open("../schema.md")
+	require.NoError(t, err)
+	defer f.Close()
+
+	var rows []schemaMappingRow
+	application := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := schemaAppHeading.FindStringSubmatch(line); m != nil {
+			application = m[1]
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			application = ""
+			continue
+		}
+		if application == "" || !strings.HasPrefix(line, "|") {
+			continue
+		}
+
+		cells := splitMarkdownRow(line)
+		if len(cells) < 5 || cells[0] == "eventType" || strings.HasPrefix(cells[0], "---") {
+			continue
+		}
+		rows = append(rows, schemaMappingRow{
+			application: application,
+			eventType:   cells[0],
+			eventName:   cells[1],
+			activityID:  parseLeadingInt(t, cells[2]),
+			operation:   cells[3],
+			severityID:  parseLeadingInt(t, cells[4]),
+		})
+	}
+	require.NoError(t, scanner.Err())
+	return rows
+}
+
+func TestEventCatalog_SchemaRoundTrip(t *testing.T) {
+	rows := loadSchemaMappingRows(t)
+	require.Greater(t, len(rows), 30, "schema.md mapping tables should be parsed")
+
+	catalog := DefaultEventCatalog()
+	for _, row := range rows {
+		t.Run(row.application+"/"+row.eventType+"/"+row.eventName, func(t *testing.T) {
+			mapping := catalog.Lookup(row.application, row.eventType, row.eventName)
+			assert.Equal(t, row.activityID, mapping.ActivityID, "activity_id")
+			assert.Equal(t, row.operation, mapping.Operation, "api.operation")
+			assert.Equal(t, row.severityID, mapping.SeverityID, "severity_id")
+			assert.Equal(t, 6001, mapping.ClassUID)
+			assert.Equal(t, 6, mapping.CategoryUID)
+
+			// Section 2.4: administrative event types are operated by admin users
+			if strings.HasSuffix(row.eventType, "_SETTINGS") {
+				assert.Equal(t, 2, mapping.UserTypeID, "user type_id")
+			} else {
+				assert.Equal(t, 1, mapping.UserTypeID, "user type_id")
+			}
+		})
+	}
+}
+
+func TestEventCatalog_StatusAndDisposition(t *testing.T) {
+	// Section 3.3 of schema.md
+	testCases := []struct {
+		application   string
+		eventType     string
+		eventName     string
+		statusID      int
+		dispositionID int
+	}{
+		{"login", "login", "login_success", 1, 1},
+		{"login", "login", "login_failure", 2, 2},
+		{"drive", "access", "access_denied", 2, 2},
+		{"drive", "access", "view", 1, 1},
+		{"drive", "access", "edit", 1, 1},
+		{"drive", "access", "download", 1, 1},
+		{"login", "login", "suspicious_login", 1, 3},
+	}
+
+	catalog := DefaultEventCatalog()
+	for _, tc := range testCases {
+		t.Run(tc.eventName, func(t *testing.T) {
+			mapping := catalog.Lookup(tc.application, tc.eventType, tc.eventName)
+			assert.Equal(t, tc.statusID, mapping.StatusID)
+			assert.Equal(t, tc.dispositionID, mapping.DispositionID)
+		})
+	}
+}
+
+func TestEventCatalog_Fallback(t *testing.T) {
+	catalog := DefaultEventCatalog()
+
+	t.Run("event type differs from catalog", func(t *testing.T) {
+		// logcore emits calendar events with type "event_change"
+		mapping := catalog.Lookup("calendar", "event_change", "create_event")
+		assert.Equal(t, 1, mapping.ActivityID)
+		assert.Equal(t, "Google Calendar API", mapping.Service)
+	})
+
+	t.Run("generic event name in any application", func(t *testing.T) {
+		mapping := catalog.Lookup("gmail", "access", "permission_denied")
+		assert.Equal(t, 2, mapping.StatusID)
+		assert.Equal(t, "Gmail API", mapping.Service)
+	})
+
+	t.Run("unknown event", func(t *testing.T) {
+		mapping := catalog.Lookup("unknown_app", "something", "happened")
+		assert.Equal(t, 99, mapping.ActivityID)
+		assert.Equal(t, 1, mapping.SeverityID)
+		assert.Equal(t, 1, mapping.StatusID)
+		assert.Equal(t, "happened", mapping.Operation)
+		assert.Equal(t, "Google Workspace API", mapping.Service)
+		require.NotNil(t, mapping.Resource)
+	})
+
+	t.Run("substring is not matched", func(t *testing.T) {
+		// "access_denied" used to be mapped as Read/Success by substring matching of "access"
+		mapping := catalog.Lookup("drive", "access", "access_denied")
+		assert.Equal(t, 2, mapping.StatusID)
+	})
+}
+
+func TestLoadEventCatalog_Override(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "override.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"events":[
+		{"application":"drive","event_type":"access","event_name":"download","severity_id":3},
+		{"application":"chat","service":"Google Chat API"},
+		{"application":"chat","event_type":"message","event_name":"message_posted","activity_id":1,"operation":"post_message"}
+	]}`), 0o600))
+
+	catalog, err := LoadEventCatalog(context.Background(), nil, path)
+	require.NoError(t, err)
+
+	download := catalog.Lookup("drive", "access", "download")
+	assert.Equal(t, 3, download.SeverityID)
+	assert.Equal(t, 7, download.ActivityID, "fields not in override are kept")
+
+	chat := catalog.Lookup("chat", "message", "message_posted")
+	assert.Equal(t, 1, chat.ActivityID)
+	assert.Equal(t, "post_message", chat.Operation)
+	assert.Equal(t, "Google Chat API", chat.Service)
+
+	_, err = LoadEventCatalog(context.Background(), nil, filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestLoadEventCatalog_S3(t *testing.T) {
+	mockS3 := new(mockObjectGetter)
+	mockS3.On("GetObject", mock.Anything, &s3.GetObjectInput{
+		Bucket: aws.String("state-bucket"),
+		Key:    aws.String("catalog/override.json"),
+	}).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader(`{"events":[{"application":"drive","event_type":"access","event_name":"download","severity_id":3}]}`)),
+	}, nil)
+
+	catalog, err := LoadEventCatalog(context.Background(), mockS3, "s3://state-bucket/catalog/override.json")
+	require.NoError(t, err)
+	assert.Equal(t, 3, catalog.Lookup("drive", "access", "download").SeverityID)
+	mockS3.AssertExpectations(t)
+}
+
+func TestEventCatalog_OverrideFoundByName(t *testing.T) {
+	catalog, err := NewEventCatalog(defaultEventCatalogJSON,
+		[]byte(`{"events":[{"application":"drive","event_type":"access","event_name":"download","severity_id":3}]}`))
+	require.NoError(t, err)
+
+	// Fallback by name for an event type not in the catalog sees the override
+	mapping := catalog.Lookup("drive", "unknown_type", "download")
+	assert.Equal(t, 3, mapping.SeverityID)
+	assert.Equal(t, 7, mapping.ActivityID)
+}
+
+func TestEventCatalog_Unset(t *testing.T) {
+	catalog, err := NewEventCatalog(defaultEventCatalogJSON, []byte(`{"events":[
+		{"application":"drive","event_type":"access","event_name":"download","unset":["severity_id","resource"]},
+		{"application":"drive","event_type":"access","event_name":"view","unset":["resource"]},
+		{"application":"drive","event_type":"access","event_name":"view","resource":{"uid":["doc_id"]}}
+	]}`))
+	require.NoError(t, err)
+
+	download := catalog.Lookup("drive", "access", "download")
+	assert.Zero(t, download.SeverityID)
+	assert.Nil(t, download.Resource, "not inherited from the application default")
+	assert.Equal(t, 7, download.ActivityID)
+	assert.Empty(t, download.Unset)
+
+	// A later override sets the field again
+	view := catalog.Lookup("drive", "access", "view")
+	require.NotNil(t, view.Resource)
+	assert.Equal(t, []string{"doc_id"}, view.Resource.UID)
+
+	_, err = NewEventCatalog(defaultEventCatalogJSON, []byte(`{"events":[{"application":"drive","unset":["unknown"]}]}`))
+	assert.ErrorContains(t, err, `unknown field "unknown"`)
+}
+
+func TestNewEventCatalog_RequiresDefault(t *testing.T) {
+	_, err := NewEventCatalog([]byte(`{"events":[{"application":"drive","activity_id":2}]}`))
+	assert.Error(t, err)
+
+	_, err = NewEventCatalog([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestConvertToOCSF_UsesCatalog(t *testing.T) {
+	gwLog := &GoogleWorkspaceLog{}
+	gwLog.ID.Time = "2024-08-12T10:15:30Z"
+	gwLog.ID.ApplicationName = "admin"
+	gwLog.Events = append(gwLog.Events, struct {
+		Type       string `json:"type"`
+		Name       string `json:"name"`
+		Parameters []struct {
+			Name       string      `json:"name"`
+			Value      interface{} `json:"value"`
+			IntValue   *int64      `json:"intValue,omitempty"`
+			BoolValue  *bool       `json:"boolValue,omitempty"`
+			MultiValue []string    `json:"multiValue,omitempty"`
+		} `json:"parameters,omitempty"`
+	}{Type: "SECURITY_SETTINGS", Name: "CHANGE_2SV_SETTING"})
+
+	ocsf, err := ConvertToOCSF(gwLog, "ap-northeast-1", "123456789012")
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, ocsf.ActivityID)
+	assert.Equal(t, 600103, ocsf.TypeUID)
+	assert.Equal(t, 4, ocsf.SeverityID)
+	assert.Equal(t, 1, ocsf.StatusID)
+	assert.Equal(t, 1, ocsf.DispositionID)
+	assert.Equal(t, 2, ocsf.Actor.User.TypeID)
+	assert.Equal(t, "change_2sv", ocsf.API.Operation)
+	assert.Equal(t, "Google Admin API", ocsf.API.Service.Name)
+}
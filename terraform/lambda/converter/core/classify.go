@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+//go:embed classification/rules.json
+var defaultClassificationRulesJSON []byte
+
+// Classification levels of web resources ordered by sensitivity
+const (
+	ClassificationPublic       = "public"
+	ClassificationInternal     = "internal"
+	ClassificationConfidential = "confidential"
+	ClassificationRestricted   = "restricted"
+)
+
+var classificationLevels = map[string]int{
+	ClassificationPublic:       1,
+	ClassificationInternal:     2,
+	ClassificationConfidential: 3,
+	ClassificationRestricted:   4,
+}
+
+// ClassificationLevel returns order of the classification (higher is more sensitive), or 0 if unknown
+func ClassificationLevel(classification string) int {
+	return classificationLevels[classification]
+}
+
+// ResourceAttributes are attributes of a web resource used for classification
+type ResourceAttributes struct {
+	// Path is the document title including folder path, e.g. "経理/決算書/2024年度.pdf"
+	Path    string
+	DocType string
+	// Visibility is the "visibility" event parameter, e.g. "private", "domain", "anyone_with_link"
+	Visibility string
+	// OwnerDomain is the domain of the "owner" event parameter
+	OwnerDomain string
+	// OrgDomain is the ownerDomain of the log, used to decide if the owner is external
+	OrgDomain string
+}
+
+// ClassificationRule matches resource attributes. All specified conditions must match, and
+// values in each condition are OR-ed. A rule without classification only adds its label.
+type ClassificationRule struct {
+	ID             string   `json:"id"`
+	PathPrefix     []string `json:"path_prefix,omitempty"`
+	PathContains   []string `json:"path_contains,omitempty"`
+	DocType        []string `json:"doc_type,omitempty"`
+	Visibility     []string `json:"visibility,omitempty"`
+	OwnerDomain    []string `json:"owner_domain,omitempty"`
+	ExternalOwner  *bool    `json:"external_owner,omitempty"`
+	Classification string   `json:"classification,omitempty"`
+	Label          string   `json:"label,omitempty"`
+}
+
+func (x ClassificationRule) match(attr ResourceAttributes) bool {
+	if len(x.PathPrefix) > 0 || len(x.PathContains) > 0 {
+		pathMatched := slices.ContainsFunc(x.PathPrefix, func(p string) bool { return strings.HasPrefix(attr.Path, p) }) ||
+			slices.ContainsFunc(x.PathContains, func(s string) bool { return strings.Contains(attr.Path, s) })
+		if !pathMatched {
+			return false
+		}
+	}
+	if len(x.DocType) > 0 && !slices.Contains(x.DocType, attr.DocType) {
+		return false
+	}
+	if len(x.Visibility) > 0 && !slices.Contains(x.Visibility, attr.Visibility) {
+		return false
+	}
+	if len(x.OwnerDomain) > 0 && !slices.Contains(x.OwnerDomain, attr.OwnerDomain) {
+		return false
+	}
+	if x.ExternalOwner != nil {
+		if attr.OwnerDomain == "" || attr.OrgDomain == "" {
+			return false
+		}
+		if external := !strings.EqualFold(attr.OwnerDomain, attr.OrgDomain); external != *x.ExternalOwner {
+			return false
+		}
+	}
+	return true
+}
+
+// ResourceClassifier classifies web resources by rules. The most sensitive classification of
+// matched rules is used, and labels of all matched rules are joined as sensitivity label.
+type ResourceClassifier struct {
+	defaultClassification string
+	rules                 []ClassificationRule
+}
+
+type classificationRulesFile struct {
+	DefaultClassification string               `json:"default_classification,omitempty"`
+	Rules                 []ClassificationRule `json:"rules"`
+}
+
+// NewResourceClassifier builds a classifier from JSON data. Rules of later data are
+// evaluated after earlier ones, and a rule with the same ID replaces the earlier rule.
+func NewResourceClassifier(data ...[]byte) (*ResourceClassifier, error) {
+	classifier := &ResourceClassifier{defaultClassification: ClassificationInternal}
+
+	for i, raw := range data {
+		var file classificationRulesFile
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse classification rules #%d: %w", i, err)
+		}
+		if file.DefaultClassification != "" {
+			if ClassificationLevel(file.DefaultClassification) == 0 {
+				return nil, fmt.Errorf("unknown default classification %q in rules #%d", file.DefaultClassification, i)
+			}
+			classifier.defaultClassification = file.DefaultClassification
+		}
+
+		for _, rule := range file.Rules {
+			if rule.ID == "" {
+				return nil, fmt.Errorf("classification rule without id in rules #%d", i)
+			}
+			if rule.Classification != "" && ClassificationLevel(rule.Classification) == 0 {
+				return nil, fmt.Errorf("classification rule %s has unknown classification %q", rule.ID, rule.Classification)
+			}
+			if rule.Classification == "" && rule.Label == "" {
+				return nil, fmt.Errorf("classification rule %s requires classification or label", rule.ID)
+			}
+
+			if idx := slices.IndexFunc(classifier.rules, func(r ClassificationRule) bool { return r.ID == rule.ID }); idx >= 0 {
+				classifier.rules[idx] = rule
+			} else {
+				classifier.rules = append(classifier.rules, rule)
+			}
+		}
+	}
+
+	return classifier, nil
+}
+
+// Classify returns classification and sensitivity label of the resource. Label is a comma
+// separated list of labels of matched rules, or empty if no rule has matched.
+func (x *ResourceClassifier) Classify(attr ResourceAttributes) (classification, label string) {
+	classification = x.defaultClassification
+	var labels []string
+	matched := false
+
+	for _, rule := range x.rules {
+		if !rule.match(attr) {
+			continue
+		}
+		if rule.Classification != "" {
+			if !matched || ClassificationLevel(rule.Classification) > ClassificationLevel(classification) {
+				classification = rule.Classification
+			}
+			matched = true
+		}
+		labels = appendUnique(labels, rule.Label)
+	}
+
+	return classification, strings.Join(labels, ",")
+}
+
+var (
+	defaultResourceClassifierOnce sync.Once
+	defaultResourceClassifier     *ResourceClassifier
+)
+
+// DefaultResourceClassifier returns the classifier with rules embedded in the binary
+func DefaultResourceClassifier() *ResourceClassifier {
+	defaultResourceClassifierOnce.Do(func() {
+		classifier, err := NewResourceClassifier(defaultClassificationRulesJSON)
+		if err != nil {
+			panic(fmt.Sprintf("embedded classification rules are invalid: %v", err))
+		}
+		defaultResourceClassifier = classifier
+	})
+	return defaultResourceClassifier
+}
+
+// LoadResourceClassifier returns the embedded rules with rules from given JSON files (local
+// path or s3://bucket/key) added
+func LoadResourceClassifier(ctx context.Context, client ObjectGetter, ruleLocations ...string) (*ResourceClassifier, error) {
+	data := [][]byte{defaultClassificationRulesJSON}
+	for _, location := range ruleLocations {
+		raw, err := readLocation(ctx, client, location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read classification rules: %w", err)
+		}
+		data = append(data, raw)
+	}
+	return NewResourceClassifier(data...)
+}
+
+// domainOf returns domain part of an email address
+func domainOf(email string) string {
+	if _, domain, ok := strings.Cut(email, "@"); ok {
+		return strings.ToLower(domain)
+	}
+	return ""
+}
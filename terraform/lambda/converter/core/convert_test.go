@@ -1,4 +1,4 @@
-package main
+package core
 
 import (
 	"encoding/json"
@@ -9,7 +9,7 @@ import (
 
 func TestConvertToOCSF_WebResourcesPopulated(t *testing.T) {
 	// Read test data
-	data, err := os.ReadFile("testdata/drive.jsonl")
+	data, err := os.ReadFile("testdata/web_resources.jsonl")
 	if err != nil {
 		t.Fatalf("Failed to read test data: %v", err)
 	}
@@ -21,12 +21,14 @@ func TestConvertToOCSF_WebResourcesPopulated(t *testing.T) {
 
 	// Test cases for different log types
 	testCases := []struct {
-		name                  string
-		lineIndex             int
-		expectWebResources    bool
-		expectedResourceName  string
-		expectedResourceID    string
-		expectedResourceType  string
+		name                 string
+		lineIndex            int
+		expectWebResources   bool
+		expectedResourceName string
+		expectedResourceID   string
+		expectedResourceType string
+		// expectedClassification is the classification of the rule matching the resource
+		expectedClassification string
 	}{
 		{
 			name:                 "Drive access log should have web resources",
@@ -35,6 +37,8 @@ func TestConvertToOCSF_WebResourcesPopulated(t *testing.T) {
 			expectedResourceName: "教材/数学/教科書.pdf",
 			expectedResourceID:   "1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE1000",
 			expectedResourceType: "document",
+			// 教材/ is courseware
+			expectedClassification: "internal",
 		},
 		{
 			name:               "Login log should not have web resources",
@@ -42,12 +46,22 @@ func TestConvertToOCSF_WebResourcesPopulated(t *testing.T) {
 			expectWebResources: false,
 		},
 		{
-			name:                 "Another drive access log should have web resources",
-			lineIndex:            2, // Third line is a drive access log
-			expectWebResources:   true,
-			expectedResourceName: "教材/数学/問題集.pdf",
-			expectedResourceID:   "1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE1001",
-			expectedResourceType: "document",
+			name:                   "Another drive access log should have web resources",
+			lineIndex:              2, // Third line is a drive access log
+			expectWebResources:     true,
+			expectedResourceName:   "教材/数学/問題集.pdf",
+			expectedResourceID:     "1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE1001",
+			expectedResourceType:   "document",
+			expectedClassification: "internal",
+		},
+		{
+			name:                   "Drive access log of a financial statement should be restricted",
+			lineIndex:              3, // Fourth line is a drive access log under 経理/決算書/
+			expectWebResources:     true,
+			expectedResourceName:   "経理/決算書/2024年度.xlsx",
+			expectedResourceID:     "1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE1002",
+			expectedResourceType:   "document",
+			expectedClassification: "restricted",
 		},
 		{
 			name:               "Calendar log should not have web resources",
@@ -77,28 +91,28 @@ func TestConvertToOCSF_WebResourcesPopulated(t *testing.T) {
 				} else {
 					// Verify the first web resource
 					resource := ocsf.WebResources[0]
-					
+
 					if resource.Name != tc.expectedResourceName {
 						t.Errorf("Expected resource name %q, got %q", tc.expectedResourceName, resource.Name)
 					}
-					
+
 					if resource.UID != tc.expectedResourceID {
 						t.Errorf("Expected resource ID %q, got %q", tc.expectedResourceID, resource.UID)
 					}
-					
+
 					if resource.Type != tc.expectedResourceType {
 						t.Errorf("Expected resource type %q, got %q", tc.expectedResourceType, resource.Type)
 					}
-					
+
 					// Check URL construction
 					expectedURL := "https://docs.google.com/document/d/" + tc.expectedResourceID
 					if resource.URLString != expectedURL {
 						t.Errorf("Expected URL %q, got %q", expectedURL, resource.URLString)
 					}
-					
+
 					// Check classification
-					if resource.Data.Classification != "internal" {
-						t.Errorf("Expected classification 'internal', got %q", resource.Data.Classification)
+					if resource.Data.Classification != tc.expectedClassification {
+						t.Errorf("Expected classification %q, got %q", tc.expectedClassification, resource.Data.Classification)
 					}
 				}
 			} else {
@@ -232,4 +246,36 @@ func TestWebResourceURLGeneration(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestConvertToOCSF_MetadataOptions(t *testing.T) {
+	gwLog := &GoogleWorkspaceLog{}
+	gwLog.ID.Time = "2024-08-12T10:15:30Z"
+	gwLog.ID.ApplicationName = "drive"
+
+	ocsf, err := ConvertToOCSF(gwLog, "ap-northeast-1", "123456789012")
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+	if ocsf.Metadata.Version != DefaultOCSFVersion || ocsf.Metadata.Product != (OCSFProduct{Name: "Google Workspace", VendorName: "Google"}) {
+		t.Errorf("Unexpected default metadata: version=%q product=%+v", ocsf.Metadata.Version, ocsf.Metadata.Product)
+	}
+
+	ocsf, err = ConvertToOCSFWithOptions(gwLog, "ap-northeast-1", "123456789012", ConvertOptions{
+		OCSFVersion:   "1.1.0",
+		ProductName:   "Workspace Audit",
+		ProductVendor: "Muhai Academy",
+	})
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+	if ocsf.Metadata.Version != "1.1.0" {
+		t.Errorf("Expected OCSF version 1.1.0, got %q", ocsf.Metadata.Version)
+	}
+	if ocsf.Metadata.Product.Name != "Workspace Audit" || ocsf.Metadata.ProductName != "Workspace Audit" {
+		t.Errorf("Expected product name Workspace Audit, got %+v / %q", ocsf.Metadata.Product, ocsf.Metadata.ProductName)
+	}
+	if ocsf.Metadata.Product.VendorName != "Muhai Academy" {
+		t.Errorf("Expected vendor Muhai Academy, got %q", ocsf.Metadata.Product.VendorName)
+	}
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"seccamp2025-b1-converter/validator"
+)
+
+// ErrAccountIDRequired is returned by Converter.Convert if records are found but the
+// account ID of the output is not configured
+var ErrAccountIDRequired = errors.New("account ID is required to convert records")
+
+// Converter runs the conversion pipeline of a source file: decode the input format, convert
+// records to OCSF, then enrich and validate them. It does not access S3, so that the Lambda
+// handler and the local CLI share the same conversion.
+type Converter struct {
+	Region    string
+	AccountID string
+	Options   ConvertOptions
+	Enrichers EnricherChain
+	Validator *validator.Validator // validation is disabled if nil
+}
+
+// Result is the outcome of converting a source file
+type Result struct {
+	Format string
+	Parsed int // number of records decoded from the input
+	Logs   []OCSFWebResourceActivity
+	// Quarantined are records which could not be decoded, converted or validated. SourceKey
+	// is set, and SourceBucket is left to the caller.
+	Quarantined []QuarantineRecord
+}
+
+// Convert converts a source file. key is the object key or path of the file, which is used to
+// detect the input format. data can be gzip compressed.
+func (x *Converter) Convert(ctx context.Context, key string, data []byte) (*Result, error) {
+	data, err := DecompressInput(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", key, err)
+	}
+	records, quarantined, format, err := DecodeInput(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s as %s: %w", key, format, err)
+	}
+	slog.Info("Parsed Google Workspace logs", "count", len(records), "file", key, "format", format)
+
+	result := &Result{Format: format, Parsed: len(records), Quarantined: quarantined}
+	if len(records) > 0 && x.AccountID == "" {
+		return nil, ErrAccountIDRequired
+	}
+
+	for _, record := range records {
+		ocsfLog, err := ConvertToOCSFWithOptions(&record.Log, x.Region, x.AccountID, x.Options)
+		if err != nil {
+			slog.Error("Failed to convert log to OCSF format", "line", record.LineNumber, "error", err)
+			result.Quarantined = append(result.Quarantined, QuarantineRecord{
+				LineNumber: record.LineNumber,
+				Stage:      QuarantineStageConvert,
+				Reason:     err.Error(),
+				Raw:        record.Raw,
+			})
+			continue
+		}
+		// Enrichment failure does not drop the record, it is kept without the context
+		if err := x.Enrichers.Enrich(ctx, ocsfLog); err != nil {
+			slog.Warn("Failed to enrich OCSF log", "line", record.LineNumber, "error", err)
+		}
+		if violations := x.validate(ocsfLog); len(violations) > 0 {
+			slog.Warn("OCSF log violates the schema", "line", record.LineNumber, "violations", violations.String())
+			result.Quarantined = append(result.Quarantined, QuarantineRecord{
+				LineNumber: record.LineNumber,
+				Stage:      QuarantineStageValidate,
+				Reason:     violations.String(),
+				Rules:      violations.Rules(),
+				Raw:        record.Raw,
+			})
+			continue
+		}
+		result.Logs = append(result.Logs, *ocsfLog)
+	}
+
+	for i := range result.Quarantined {
+		result.Quarantined[i].SourceKey = key
+	}
+	if len(records) > 0 {
+		slog.Info("Converted logs to OCSF format", "converted", len(result.Logs), "total", len(records))
+	}
+	return result, nil
+}
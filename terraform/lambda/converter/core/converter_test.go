@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+
+	"seccamp2025-b1-converter/validator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConverter_Convert(t *testing.T) {
+	// The login event has no web resource, which violates the class in strict mode
+	data := inputTestLog1 + "\ninvalid json line\n" + inputTestLog2 + "\n"
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	v, err := validator.New(validator.DefaultSchema(), OCSFClassName, validator.Deviation{Path: "observables.type_id"})
+	require.NoError(t, err)
+	converter := &Converter{Region: "ap-northeast-1", AccountID: "123456789012", Validator: v}
+
+	result, err := converter.Convert(context.Background(), "logs/a.jsonl.gz", buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, InputFormatJSONL, result.Format)
+	assert.Equal(t, 2, result.Parsed)
+	require.Len(t, result.Logs, 1)
+	assert.Equal(t, "123456789012", result.Logs[0].AccountID)
+
+	require.Len(t, result.Quarantined, 2)
+	assert.Equal(t, QuarantineStageParse, result.Quarantined[0].Stage)
+	assert.Equal(t, 2, result.Quarantined[0].LineNumber)
+	assert.Equal(t, QuarantineStageValidate, result.Quarantined[1].Stage)
+	assert.Equal(t, 3, result.Quarantined[1].LineNumber)
+	for _, record := range result.Quarantined {
+		assert.Equal(t, "logs/a.jsonl.gz", record.SourceKey)
+		assert.Empty(t, record.SourceBucket)
+	}
+}
+
+func TestConverter_AccountIDRequired(t *testing.T) {
+	converter := &Converter{Region: "ap-northeast-1"}
+
+	_, err := converter.Convert(context.Background(), "logs/a.jsonl", []byte(inputTestLog1))
+	assert.ErrorIs(t, err, ErrAccountIDRequired)
+
+	// Files without records do not need the account
+	result, err := converter.Convert(context.Background(), "logs/a.jsonl", []byte("\n"))
+	require.NoError(t, err)
+	assert.Zero(t, result.Parsed)
+}
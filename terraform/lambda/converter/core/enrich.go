@@ -0,0 +1,275 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Enricher adds context that is not included in the original log to a converted OCSF record.
+// Enrichers run in order after ConvertToOCSF.
+type Enricher interface {
+	Name() string
+	Enrich(ctx context.Context, ocsf *OCSFWebResourceActivity) error
+}
+
+// EnricherChain runs enrichers in order
+type EnricherChain []Enricher
+
+// Enrich applies all enrichers to the record. All enrichers are run even if some of them
+// fail, and the errors are joined.
+func (x EnricherChain) Enrich(ctx context.Context, ocsf *OCSFWebResourceActivity) error {
+	var errs []string
+	for _, enricher := range x {
+		if err := enricher.Enrich(ctx, ocsf); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", enricher.Name(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("enrichment failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// readTable reads a JSON array of objects or a CSV file with header as rows keyed by column
+// name. Format is chosen by file extension, and JSON is assumed if unknown.
+func readTable(location string, data []byte) ([]map[string]string, error) {
+	if strings.EqualFold(path.Ext(location), ".csv") {
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV header of %s: %w", location, err)
+		}
+		for i := range header {
+			header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+		}
+
+		var rows []map[string]string
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CSV %s: %w", location, err)
+			}
+			row := map[string]string{}
+			for i, value := range record {
+				if i < len(header) {
+					row[header[i]] = strings.TrimSpace(value)
+				}
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+
+	var raw []map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON %s: %w", location, err)
+	}
+	rows := make([]map[string]string, 0, len(raw))
+	for _, obj := range raw {
+		row := map[string]string{}
+		for k, v := range obj {
+			switch v := v.(type) {
+			case string:
+				row[k] = v
+			case []any:
+				values := make([]string, 0, len(v))
+				for _, item := range v {
+					values = append(values, fmt.Sprintf("%v", item))
+				}
+				row[k] = strings.Join(values, ";")
+			case nil:
+			default:
+				row[k] = fmt.Sprintf("%v", v)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// splitList splits ";" or "," separated values and drops empty ones
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ',' }) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// appendUnique appends values that are not in the slice yet
+func appendUnique(slice []string, values ...string) []string {
+	for _, v := range values {
+		if v != "" && !slices.Contains(slice, v) {
+			slice = append(slice, v)
+		}
+	}
+	return slice
+}
+
+// DirectoryUser is an entry of the user directory
+type DirectoryUser struct {
+	Email      string
+	Name       string
+	Role       string
+	Department string
+	Groups     []string
+}
+
+// userDirectoryEnricher sets name and groups of the actor from a user directory export.
+// Role and department are also added to groups so that they can be used for grouping.
+type userDirectoryEnricher struct {
+	users map[string]DirectoryUser
+}
+
+// newUserDirectoryEnricher builds the enricher from a JSON or CSV export with columns
+// email, name, role, department and groups (";" separated)
+func newUserDirectoryEnricher(location string, data []byte) (*userDirectoryEnricher, error) {
+	rows, err := readTable(location, data)
+	if err != nil {
+		return nil, err
+	}
+
+	enricher := &userDirectoryEnricher{users: map[string]DirectoryUser{}}
+	for i, row := range rows {
+		email := strings.ToLower(strings.TrimSpace(row["email"]))
+		if email == "" {
+			return nil, fmt.Errorf("user directory %s: entry #%d has no email", location, i+1)
+		}
+		enricher.users[email] = DirectoryUser{
+			Email:      email,
+			Name:       row["name"],
+			Role:       row["role"],
+			Department: row["department"],
+			Groups:     splitList(row["groups"]),
+		}
+	}
+	return enricher, nil
+}
+
+func (x *userDirectoryEnricher) Name() string { return "user_directory" }
+
+func (x *userDirectoryEnricher) Enrich(ctx context.Context, ocsf *OCSFWebResourceActivity) error {
+	user, ok := x.users[strings.ToLower(ocsf.Actor.User.EmailAddr)]
+	if !ok {
+		return nil
+	}
+
+	if user.Name != "" {
+		ocsf.Actor.User.Name = user.Name
+	}
+	ocsf.Actor.User.Groups = appendUnique(ocsf.Actor.User.Groups, user.Role, user.Department)
+	ocsf.Actor.User.Groups = appendUnique(ocsf.Actor.User.Groups, user.Groups...)
+	if user.Role != "" {
+		ocsf.Metadata.Labels = appendUnique(ocsf.Metadata.Labels, "user_role:"+user.Role)
+	}
+	return nil
+}
+
+type ipIntelEntry struct {
+	prefix netip.Prefix
+	tags   []string
+}
+
+// ipIntelEnricher tags source IP address by CIDR based intelligence list (e.g. "vpn", "tor",
+// "known_attacker"). Tags are added to metadata.labels as "ip_intel:<tag>".
+type ipIntelEnricher struct {
+	entries []ipIntelEntry
+}
+
+// newIPIntelEnricher builds the enricher from a JSON or CSV list with columns cidr and
+// tags (";" separated). A single address without prefix length is also accepted.
+func newIPIntelEnricher(location string, data []byte) (*ipIntelEnricher, error) {
+	rows, err := readTable(location, data)
+	if err != nil {
+		return nil, err
+	}
+
+	enricher := &ipIntelEnricher{}
+	for i, row := range rows {
+		cidr := strings.TrimSpace(row["cidr"])
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, fmt.Errorf("IP intelligence %s: entry #%d has invalid cidr %q: %w", location, i+1, cidr, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		tags := splitList(row["tags"])
+		if len(tags) == 0 {
+			return nil, fmt.Errorf("IP intelligence %s: entry #%d (%s) has no tags", location, i+1, cidr)
+		}
+		enricher.entries = append(enricher.entries, ipIntelEntry{prefix: prefix.Masked(), tags: tags})
+	}
+	return enricher, nil
+}
+
+func (x *ipIntelEnricher) Name() string { return "ip_intel" }
+
+func (x *ipIntelEnricher) Enrich(ctx context.Context, ocsf *OCSFWebResourceActivity) error {
+	addr, err := netip.ParseAddr(ocsf.SrcEndpoint.IP)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+
+	var tags []string
+	for _, entry := range x.entries {
+		if entry.prefix.Contains(addr) {
+			tags = appendUnique(tags, entry.tags...)
+		}
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		ocsf.Metadata.Labels = appendUnique(ocsf.Metadata.Labels, "ip_intel:"+tag)
+	}
+	return nil
+}
+
+// LoadEnrichers builds the enricher chain from data locations (local path or s3://bucket/key).
+// Empty location disables the enricher.
+func LoadEnrichers(ctx context.Context, client ObjectGetter, userDirectory, ipIntel string) (EnricherChain, error) {
+	builders := []struct {
+		location string
+		build    func(location string, data []byte) (Enricher, error)
+	}{
+		{userDirectory, func(location string, data []byte) (Enricher, error) {
+			return newUserDirectoryEnricher(location, data)
+		}},
+		{ipIntel, func(location string, data []byte) (Enricher, error) {
+			return newIPIntelEnricher(location, data)
+		}},
+	}
+
+	var chain EnricherChain
+	for _, b := range builders {
+		if b.location == "" {
+			continue
+		}
+		data, err := readLocation(ctx, client, b.location)
+		if err != nil {
+			return nil, err
+		}
+		enricher, err := b.build(b.location, data)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, enricher)
+	}
+	return chain, nil
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestEnricher[T any](t *testing.T, path string, build func(string, []byte) (T, error)) T {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	enricher, err := build(path, data)
+	require.NoError(t, err)
+	return enricher
+}
+
+func TestUserDirectoryEnricher(t *testing.T) {
+	for _, path := range []string{"testdata/enrich/users.csv", "testdata/enrich/users.json"} {
+		t.Run(path, func(t *testing.T) {
+			enricher := loadTestEnricher(t, path, newUserDirectoryEnricher)
+
+			ocsf := &OCSFWebResourceActivity{}
+			ocsf.Actor.User.EmailAddr = "tanaka.hiroshi@muhaijuku.com"
+			require.NoError(t, enricher.Enrich(context.Background(), ocsf))
+
+			assert.Equal(t, "田中 宏", ocsf.Actor.User.Name)
+			assert.Equal(t, []string{"cfo", "経理部", "executives", "finance"}, ocsf.Actor.User.Groups)
+			assert.Contains(t, ocsf.Metadata.Labels, "user_role:cfo")
+
+			unknown := &OCSFWebResourceActivity{}
+			unknown.Actor.User.EmailAddr = "external@example.com"
+			require.NoError(t, enricher.Enrich(context.Background(), unknown))
+			assert.Empty(t, unknown.Actor.User.Name)
+			assert.Empty(t, unknown.Actor.User.Groups)
+		})
+	}
+
+	_, err := newUserDirectoryEnricher("users.csv", []byte("email,name\n,no email\n"))
+	assert.Error(t, err)
+}
+
+func TestIPIntelEnricher(t *testing.T) {
+	enricher := loadTestEnricher(t, "testdata/enrich/ip_intel.csv", newIPIntelEnricher)
+
+	testCases := []struct {
+		ip       string
+		expected []string
+	}{
+		{"10.1.2.3", []string{"ip_intel:vpn"}},
+		{"198.51.100.20", []string{"ip_intel:known_attacker", "ip_intel:tor"}},
+		{"198.51.100.21", []string{"ip_intel:known_attacker"}},
+		{"210.160.1.1", nil},
+		{"", nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.ip, func(t *testing.T) {
+			ocsf := &OCSFWebResourceActivity{}
+			ocsf.SrcEndpoint.IP = tc.ip
+			require.NoError(t, enricher.Enrich(context.Background(), ocsf))
+			assert.Equal(t, tc.expected, ocsf.Metadata.Labels)
+		})
+	}
+
+	_, err := newIPIntelEnricher("intel.csv", []byte("cidr,tags\nnot-a-cidr,tor\n"))
+	assert.Error(t, err)
+	_, err = newIPIntelEnricher("intel.csv", []byte("cidr,tags\n10.0.0.0/8,\n"))
+	assert.Error(t, err)
+}
+
+type failingEnricher struct{}
+
+func (failingEnricher) Name() string { return "failing" }
+func (failingEnricher) Enrich(ctx context.Context, ocsf *OCSFWebResourceActivity) error {
+	return errors.New("lookup failed")
+}
+
+func TestEnricherChain(t *testing.T) {
+	directory := loadTestEnricher(t, "testdata/enrich/users.csv", newUserDirectoryEnricher)
+	intel := loadTestEnricher(t, "testdata/enrich/ip_intel.csv", newIPIntelEnricher)
+	chain := EnricherChain{failingEnricher{}, directory, intel}
+
+	ocsf := &OCSFWebResourceActivity{}
+	ocsf.Actor.User.EmailAddr = "kobayashi.akira@muhaijuku.com"
+	ocsf.SrcEndpoint.IP = "10.0.0.1"
+
+	err := chain.Enrich(context.Background(), ocsf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failing")
+	assert.Equal(t, "小林 明", ocsf.Actor.User.Name, "later enrichers run after a failure")
+	assert.Contains(t, ocsf.Metadata.Labels, "ip_intel:vpn")
+
+	assert.NoError(t, EnricherChain(nil).Enrich(context.Background(), ocsf))
+}
+
+func TestLoadEnrichers(t *testing.T) {
+	data, err := os.ReadFile("testdata/enrich/ip_intel.csv")
+	require.NoError(t, err)
+
+	mockS3 := new(mockObjectGetter)
+	mockS3.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return aws.ToString(input.Bucket) == "state-bucket" && aws.ToString(input.Key) == "enrich/ip_intel.csv"
+	})).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader(string(data))),
+	}, nil)
+
+	chain, err := LoadEnrichers(context.Background(), mockS3,
+		"testdata/enrich/users.json", "s3://state-bucket/enrich/ip_intel.csv")
+	require.NoError(t, err)
+	require.Len(t, chain, 2)
+	assert.Equal(t, "user_directory", chain[0].Name())
+	assert.Equal(t, "ip_intel", chain[1].Name())
+
+	chain, err = LoadEnrichers(context.Background(), nil, "", "")
+	require.NoError(t, err)
+	assert.Empty(t, chain)
+
+	_, err = LoadEnrichers(context.Background(), nil, "testdata/enrich/missing.csv", "")
+	assert.Error(t, err)
+}
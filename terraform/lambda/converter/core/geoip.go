@@ -0,0 +1,175 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoLocation is geographical and network information of an IP address
+type GeoLocation struct {
+	City    string
+	Region  string
+	Country string
+	Lat     float64
+	Long    float64
+	ASN     int
+	ASOrg   string
+	ISP     string
+}
+
+// GeoIPResolver resolves an IP address to its location. ok is false if the address is not found.
+type GeoIPResolver interface {
+	Lookup(ip string) (loc GeoLocation, ok bool)
+}
+
+// applyTo sets location and autonomous system of the endpoint
+func (x GeoLocation) applyTo(endpoint *OCSFEndpoint) {
+	endpoint.Location = OCSFLocation{
+		City:    x.City,
+		Country: x.Country,
+		Region:  x.Region,
+		Lat:     x.Lat,
+		Long:    x.Long,
+		ISP:     x.ISP,
+	}
+	endpoint.AutonomousSystem = OCSFAutonomousSystem{
+		Number: x.ASN,
+		Name:   x.ASOrg,
+	}
+}
+
+// mmdbCityRecord is a subset of GeoIP2/GeoLite2 City database record
+type mmdbCityRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// mmdbASNRecord is a subset of GeoLite2 ASN and GeoIP2 ISP database record
+type mmdbASNRecord struct {
+	Number       int    `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+	ISP          string `maxminddb:"isp"`
+}
+
+// mmdbGeoIPResolver looks up MaxMind DB files. ASN database is optional; the city database
+// is also searched for ASN fields so that a single enterprise database can be used.
+type mmdbGeoIPResolver struct {
+	city *maxminddb.Reader
+	asn  *maxminddb.Reader
+}
+
+// newMMDBGeoIPResolver opens MaxMind DB from bytes. asnDB can be nil.
+func newMMDBGeoIPResolver(cityDB, asnDB []byte) (*mmdbGeoIPResolver, error) {
+	city, err := maxminddb.FromBytes(cityDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP city database: %w", err)
+	}
+	resolver := &mmdbGeoIPResolver{city: city, asn: city}
+
+	if asnDB != nil {
+		asn, err := maxminddb.FromBytes(asnDB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP ASN database: %w", err)
+		}
+		resolver.asn = asn
+	}
+	return resolver, nil
+}
+
+func (x *mmdbGeoIPResolver) Lookup(ip string) (GeoLocation, bool) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return GeoLocation{}, false
+	}
+
+	var city mmdbCityRecord
+	_, cityFound, err := x.city.LookupNetwork(addr, &city)
+	if err != nil {
+		return GeoLocation{}, false
+	}
+	var asn mmdbASNRecord
+	_, asnFound, err := x.asn.LookupNetwork(addr, &asn)
+	if err != nil {
+		asnFound = false
+	}
+	if !cityFound && !asnFound {
+		return GeoLocation{}, false
+	}
+
+	loc := GeoLocation{
+		City:    city.City.Names["en"],
+		Country: city.Country.ISOCode,
+		ASN:     asn.Number,
+		ASOrg:   asn.Organization,
+		ISP:     asn.ISP,
+	}
+	if len(city.Subdivisions) > 0 {
+		loc.Region = city.Subdivisions[0].Names["en"]
+		if loc.Region == "" {
+			loc.Region = city.Subdivisions[0].ISOCode
+		}
+	}
+	if city.Location.Latitude != nil && city.Location.Longitude != nil {
+		loc.Lat, loc.Long = *city.Location.Latitude, *city.Location.Longitude
+	}
+	if loc.ISP == "" {
+		loc.ISP = asn.Organization
+	}
+	return loc, true
+}
+
+// prefixGeoIPResolver resolves IP addresses by the static prefix table of test data.
+// It works as fallback of MMDB for private and documentation addresses.
+type prefixGeoIPResolver struct{}
+
+func (prefixGeoIPResolver) Lookup(ip string) (GeoLocation, bool) {
+	return lookupGeoIPPrefix(ip)
+}
+
+// chainGeoIPResolver returns the first location found by resolvers
+type chainGeoIPResolver []GeoIPResolver
+
+func (x chainGeoIPResolver) Lookup(ip string) (GeoLocation, bool) {
+	for _, resolver := range x {
+		if loc, ok := resolver.Lookup(ip); ok {
+			return loc, true
+		}
+	}
+	return GeoLocation{}, false
+}
+
+// NewGeoIPResolver builds a resolver from MaxMind DB locations (local path or s3://bucket/key)
+// with the static prefix table as fallback. asnLocation can be empty.
+func NewGeoIPResolver(ctx context.Context, client ObjectGetter, cityLocation, asnLocation string) (GeoIPResolver, error) {
+	cityDB, err := readLocation(ctx, client, cityLocation)
+	if err != nil {
+		return nil, err
+	}
+	var asnDB []byte
+	if asnLocation != "" {
+		if asnDB, err = readLocation(ctx, client, asnLocation); err != nil {
+			return nil, err
+		}
+	}
+
+	mmdb, err := newMMDBGeoIPResolver(cityDB, asnDB)
+	if err != nil {
+		return nil, err
+	}
+	return chainGeoIPResolver{mmdb, prefixGeoIPResolver{}}, nil
+}
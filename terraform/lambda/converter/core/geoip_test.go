@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testdata/geoip-test.mmdb contains the following networks with city and ASN fields:
+//   - 203.0.113.0/24: Osaka, JP, AS64500 "Example Osaka Net"
+//   - 198.51.100.0/24: New York, US, AS64501 "Example US Net"
+const testGeoIPDatabase = "testdata/geoip-test.mmdb"
+
+func newTestGeoIPResolver(t *testing.T) GeoIPResolver {
+	t.Helper()
+	resolver, err := NewGeoIPResolver(context.Background(), nil, testGeoIPDatabase, "")
+	require.NoError(t, err)
+	return resolver
+}
+
+func TestGeoIPResolver_MMDB(t *testing.T) {
+	resolver := newTestGeoIPResolver(t)
+
+	loc, ok := resolver.Lookup("203.0.113.25")
+	require.True(t, ok)
+	assert.Equal(t, "Osaka", loc.City)
+	assert.Equal(t, "Osaka", loc.Region)
+	assert.Equal(t, "JP", loc.Country)
+	assert.InDelta(t, 34.6937, loc.Lat, 0.0001)
+	assert.InDelta(t, 135.5023, loc.Long, 0.0001)
+	assert.Equal(t, 64500, loc.ASN)
+	assert.Equal(t, "Example Osaka Net", loc.ASOrg)
+	assert.Equal(t, "Example Osaka Net", loc.ISP, "ISP falls back to AS organization")
+
+	loc, ok = resolver.Lookup("198.51.100.20")
+	require.True(t, ok)
+	assert.Equal(t, "New York", loc.City)
+	assert.Equal(t, "US", loc.Country)
+}
+
+func TestGeoIPResolver_FallbackToPrefixTable(t *testing.T) {
+	resolver := newTestGeoIPResolver(t)
+
+	loc, ok := resolver.Lookup("10.1.2.3")
+	require.True(t, ok, "private address is resolved by prefix table")
+	assert.Equal(t, "Tokyo", loc.City)
+	assert.Equal(t, "JP", loc.Country)
+	assert.Zero(t, loc.ASN)
+
+	_, ok = resolver.Lookup("8.8.8.8")
+	assert.False(t, ok, "unknown address is not guessed")
+
+	_, ok = resolver.Lookup("not-an-ip")
+	assert.False(t, ok)
+}
+
+func TestLoadGeoIPDatabase_S3(t *testing.T) {
+	data, err := os.ReadFile(testGeoIPDatabase)
+	require.NoError(t, err)
+
+	mockS3 := new(mockObjectGetter)
+	mockS3.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return aws.ToString(input.Bucket) == "state-bucket" && aws.ToString(input.Key) == "geoip/City.mmdb"
+	})).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader(string(data))),
+	}, nil)
+
+	resolver, err := NewGeoIPResolver(context.Background(), mockS3, "s3://state-bucket/geoip/City.mmdb", "")
+	require.NoError(t, err)
+	loc, ok := resolver.Lookup("203.0.113.1")
+	require.True(t, ok)
+	assert.Equal(t, "Osaka", loc.City)
+
+	_, err = NewGeoIPResolver(context.Background(), mockS3, "s3://state-bucket", "")
+	assert.Error(t, err)
+	_, err = NewGeoIPResolver(context.Background(), nil, "testdata/missing.mmdb", "")
+	assert.Error(t, err)
+	_, err = NewGeoIPResolver(context.Background(), nil, "testdata/valid.jsonl", "")
+	assert.Error(t, err, "not a MaxMind DB")
+}
+
+func TestConvertToOCSF_GeoIP(t *testing.T) {
+	gwLog := &GoogleWorkspaceLog{}
+	gwLog.ID.Time = "2024-08-12T10:15:30Z"
+	gwLog.IPAddress = "198.51.100.20"
+
+	ocsf, err := ConvertToOCSFWithOptions(gwLog, "ap-northeast-1", "123456789012", ConvertOptions{
+		GeoIP: newTestGeoIPResolver(t),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "New York", ocsf.SrcEndpoint.Location.City)
+	assert.Equal(t, "New York", ocsf.SrcEndpoint.Location.Region)
+	assert.Equal(t, "US", ocsf.SrcEndpoint.Location.Country)
+	assert.InDelta(t, -74.0060, ocsf.SrcEndpoint.Location.Long, 0.0001)
+	assert.Equal(t, "Example US Net", ocsf.SrcEndpoint.Location.ISP)
+	assert.Equal(t, 64501, ocsf.SrcEndpoint.AutonomousSystem.Number)
+	assert.Equal(t, "Example US Net", ocsf.SrcEndpoint.AutonomousSystem.Name)
+
+	t.Run("without resolver uses prefix table", func(t *testing.T) {
+		ocsf, err := ConvertToOCSF(gwLog, "ap-northeast-1", "123456789012")
+		require.NoError(t, err)
+		assert.Equal(t, "San Francisco", ocsf.SrcEndpoint.Location.City)
+		assert.Equal(t, "US", ocsf.SrcEndpoint.Location.Country)
+	})
+}
+
+func TestGenerateParquet_GeoIPFields(t *testing.T) {
+	gwLog := &GoogleWorkspaceLog{}
+	gwLog.ID.Time = "2024-08-12T10:15:30Z"
+	gwLog.IPAddress = "203.0.113.5"
+
+	ocsf, err := ConvertToOCSFWithOptions(gwLog, "ap-northeast-1", "123456789012", ConvertOptions{
+		GeoIP: newTestGeoIPResolver(t),
+	})
+	require.NoError(t, err)
+
+	data, err := GenerateParquet([]OCSFWebResourceActivity{*ocsf})
+	require.NoError(t, err)
+	assert.NotEmpty(t, data)
+}
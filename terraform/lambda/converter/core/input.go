@@ -0,0 +1,366 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+// Input formats of raw log objects
+const (
+	// InputFormatJSONL is one GoogleWorkspaceLog object per line
+	InputFormatJSONL = "jsonl"
+	// InputFormatJSONObjects is one or more pretty-printed GoogleWorkspaceLog objects, each
+	// spanning several lines
+	InputFormatJSONObjects = "json_objects"
+	// InputFormatJSONArray is a JSON array of GoogleWorkspaceLog objects
+	InputFormatJSONArray = "json_array"
+	// InputFormatReportsPage is one or more Reports API response pages ({"items":[...]})
+	InputFormatReportsPage = "reports_page"
+	// InputFormatCSV is a CSV export with a header row, one event per row
+	InputFormatCSV = "csv"
+)
+
+// InputExtensions are file extensions of raw log objects, removed from output object names
+var InputExtensions = []string{".gz", ".jsonl", ".ndjson", ".json", ".csv"}
+
+// gzipMagic is the header of gzip compressed data
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// utf8BOM is the byte order mark added by some spreadsheet exports
+var utf8BOM = []byte("\ufeff")
+
+// DecompressInput returns the content of gzip compressed data, detected by the key suffix
+// or the gzip header, and the data as is otherwise
+func DecompressInput(key string, data []byte) ([]byte, error) {
+	if !strings.HasSuffix(key, ".gz") && !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	slog.Info("File is gzip compressed, decompressing", "file_key", key)
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer reader.Close()
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	return decompressed, nil
+}
+
+// DetectInputFormat decides the format by the key suffix, and by the content if the suffix
+// is not conclusive. A JSON object with an "items" array is a Reports API page, and a first
+// object spanning several lines means pretty-printed objects. Other objects are read as JSONL.
+func DetectInputFormat(key string, data []byte) string {
+	name := strings.ToLower(strings.TrimSuffix(key, ".gz"))
+	switch {
+	case strings.HasSuffix(name, ".csv"):
+		return InputFormatCSV
+	case strings.HasSuffix(name, ".jsonl"), strings.HasSuffix(name, ".ndjson"):
+		return InputFormatJSONL
+	}
+
+	content := bytes.TrimLeftFunc(bytes.TrimPrefix(data, utf8BOM), unicode.IsSpace)
+	switch {
+	case bytes.HasPrefix(content, []byte("[")):
+		return InputFormatJSONArray
+	case bytes.HasPrefix(content, []byte("{")):
+		var first json.RawMessage
+		if err := json.NewDecoder(bytes.NewReader(content)).Decode(&first); err != nil {
+			return InputFormatJSONL
+		}
+		var page struct {
+			Items json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(first, &page); err == nil && bytes.HasPrefix(page.Items, []byte("[")) {
+			return InputFormatReportsPage
+		}
+		if bytes.Contains(first, []byte("\n")) {
+			return InputFormatJSONObjects
+		}
+		return InputFormatJSONL
+	case len(content) > 0 && !strings.HasSuffix(name, ".json") && looksLikeCSV(content):
+		return InputFormatCSV
+	}
+	return InputFormatJSONL
+}
+
+// looksLikeCSV reports whether the first line is a CSV header including a time column
+func looksLikeCSV(content []byte) bool {
+	header, _, _ := bytes.Cut(content, []byte("\n"))
+	record, err := csv.NewReader(bytes.NewReader(header)).Read()
+	if err != nil || len(record) < 2 {
+		return false
+	}
+	for _, column := range record {
+		if csvColumnAliases[normalizeCSVColumn(column)] == csvFieldTime {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeInput decodes raw log records in the detected format. Records which can not be
+// decoded are returned as quarantine records as in decodeJSONLines.
+func DecodeInput(key string, data []byte) ([]LogRecord, []QuarantineRecord, string, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	format := DetectInputFormat(key, data)
+	slog.Info("Detected input format", "file_key", key, "format", format)
+
+	var records []LogRecord
+	var quarantined []QuarantineRecord
+	var err error
+	switch format {
+	case InputFormatJSONArray:
+		records, quarantined, err = decodeJSONArray(data)
+	case InputFormatReportsPage:
+		records, quarantined, err = decodeReportsPages(data)
+	case InputFormatCSV:
+		records, quarantined, err = decodeCSV(data)
+	default:
+		// decodeJSONLines streams JSON values, so it also reads pretty-printed objects
+		records, quarantined, err = decodeJSONLines(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, nil, format, err
+	}
+	return records, quarantined, format, nil
+}
+
+// decodeJSONItems decodes items into logs. LineNumber of a record is the 1-based position of
+// the item (offset by base for items of subsequent pages).
+func decodeJSONItems(items []json.RawMessage, base int) ([]LogRecord, []QuarantineRecord) {
+	var records []LogRecord
+	var quarantined []QuarantineRecord
+	for i, item := range items {
+		var gwLog GoogleWorkspaceLog
+		if err := json.Unmarshal(item, &gwLog); err != nil {
+			slog.Warn("Failed to parse JSON item", "item", base+i+1, "error", err)
+			quarantined = append(quarantined, QuarantineRecord{
+				LineNumber: base + i + 1,
+				Stage:      QuarantineStageParse,
+				Reason:     err.Error(),
+				Raw:        item,
+			})
+			continue
+		}
+		records = append(records, LogRecord{LineNumber: base + i + 1, Raw: item, Log: gwLog})
+	}
+	return records, quarantined
+}
+
+// decodeJSONArray reads a JSON array of logs
+func decodeJSONArray(data []byte) ([]LogRecord, []QuarantineRecord, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode JSON array: %w", err)
+	}
+	records, quarantined := decodeJSONItems(items, 0)
+	return records, quarantined, nil
+}
+
+// decodeReportsPages reads a Reports API response page, or a sequence of pages such as a
+// JSONL file with one page per line. Items are numbered across pages.
+func decodeReportsPages(data []byte) ([]LogRecord, []QuarantineRecord, error) {
+	var records []LogRecord
+	var quarantined []QuarantineRecord
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for pages := 0; ; pages++ {
+		var page struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := decoder.Decode(&page); err != nil {
+			if errors.Is(err, io.EOF) {
+				slog.Info("Reached end of Reports API pages", "pages", pages, "parsed_logs", len(records), "quarantined", len(quarantined))
+				return records, quarantined, nil
+			}
+			return nil, nil, fmt.Errorf("failed to decode Reports API page %d: %w", pages+1, err)
+		}
+		pageRecords, pageQuarantined := decodeJSONItems(page.Items, len(records)+len(quarantined))
+		records = append(records, pageRecords...)
+		quarantined = append(quarantined, pageQuarantined...)
+	}
+}
+
+// CSV fields mapped to GoogleWorkspaceLog. Other columns become event parameters.
+const (
+	csvFieldTime            = "time"
+	csvFieldUniqueQualifier = "unique_qualifier"
+	csvFieldApplication     = "application"
+	csvFieldCustomerID      = "customer_id"
+	csvFieldActorEmail      = "actor_email"
+	csvFieldActorProfileID  = "actor_profile_id"
+	csvFieldActorCallerType = "actor_caller_type"
+	csvFieldIPAddress       = "ip_address"
+	csvFieldOwnerDomain     = "owner_domain"
+	csvFieldEventType       = "event_type"
+	csvFieldEventName       = "event_name"
+)
+
+// csvColumnAliases maps normalized column names of Reports API flattened exports and admin
+// console / Vault exports to fields
+var csvColumnAliases = map[string]string{
+	"idtime": csvFieldTime, "time": csvFieldTime, "date": csvFieldTime, "timestamp": csvFieldTime,
+	"iduniquequalifier": csvFieldUniqueQualifier, "uniquequalifier": csvFieldUniqueQualifier,
+	"idapplicationname": csvFieldApplication, "applicationname": csvFieldApplication, "application": csvFieldApplication,
+	"idcustomerid": csvFieldCustomerID, "customerid": csvFieldCustomerID,
+	"actoremail": csvFieldActorEmail, "actor": csvFieldActorEmail, "user": csvFieldActorEmail, "email": csvFieldActorEmail,
+	"actorprofileid": csvFieldActorProfileID, "profileid": csvFieldActorProfileID,
+	"actorcallertype": csvFieldActorCallerType, "callertype": csvFieldActorCallerType,
+	"ipaddress": csvFieldIPAddress, "ip": csvFieldIPAddress,
+	"eventstype": csvFieldEventType, "eventtype": csvFieldEventType, "type": csvFieldEventType,
+	"eventsname": csvFieldEventName, "eventname": csvFieldEventName, "event": csvFieldEventName, "name": csvFieldEventName,
+	"ownerdomain": csvFieldOwnerDomain,
+}
+
+// normalizeCSVColumn lowercases a column name and removes separators, so that "id.time",
+// "IP address" and "ip_address" match the aliases
+func normalizeCSVColumn(column string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, column)
+}
+
+// csvParameterName returns the event parameter name of an unmapped column
+func csvParameterName(column string) string {
+	name := strings.ToLower(strings.TrimSpace(column))
+	name = strings.TrimPrefix(name, "events.parameters.")
+	name = strings.TrimPrefix(name, "parameters.")
+	return strings.Join(strings.Fields(name), "_")
+}
+
+// decodeCSV reads a CSV export with a header row. Each row is a log with a single event.
+// LineNumber of a record is the row number including the header.
+func decodeCSV(data []byte) ([]LogRecord, []QuarantineRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	fields := make([]string, len(header))
+	hasTime := false
+	for i, column := range header {
+		fields[i] = csvColumnAliases[normalizeCSVColumn(column)]
+		hasTime = hasTime || fields[i] == csvFieldTime
+	}
+	if !hasTime {
+		return nil, nil, fmt.Errorf("CSV header has no time column: %v", header)
+	}
+
+	var records []LogRecord
+	var quarantined []QuarantineRecord
+	for row := 2; ; row++ {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		raw := []byte(csvLine(values))
+		if err != nil {
+			slog.Warn("Failed to parse CSV row", "row", row, "error", err)
+			quarantined = append(quarantined, QuarantineRecord{
+				LineNumber: row,
+				Stage:      QuarantineStageParse,
+				Reason:     err.Error(),
+				Raw:        raw,
+			})
+			continue
+		}
+		if len(values) != len(header) {
+			quarantined = append(quarantined, QuarantineRecord{
+				LineNumber: row,
+				Stage:      QuarantineStageParse,
+				Reason:     fmt.Sprintf("row has %d columns, header has %d", len(values), len(header)),
+				Raw:        raw,
+			})
+			continue
+		}
+		records = append(records, LogRecord{
+			LineNumber: row,
+			Raw:        raw,
+			Log:        csvRowToLog(header, fields, values),
+		})
+	}
+
+	slog.Info("Reached end of CSV", "rows", len(records)+len(quarantined), "parsed_logs", len(records), "quarantined", len(quarantined))
+	return records, quarantined, nil
+}
+
+// csvRowToLog builds a log with a single event from a CSV row
+func csvRowToLog(header, fields, values []string) GoogleWorkspaceLog {
+	var gwLog GoogleWorkspaceLog
+	gwLog.Kind = "admin#reports#activity"
+	gwLog.Events = make([]struct {
+		Type       string `json:"type"`
+		Name       string `json:"name"`
+		Parameters []struct {
+			Name       string      `json:"name"`
+			Value      interface{} `json:"value"`
+			IntValue   *int64      `json:"intValue,omitempty"`
+			BoolValue  *bool       `json:"boolValue,omitempty"`
+			MultiValue []string    `json:"multiValue,omitempty"`
+		} `json:"parameters,omitempty"`
+	}, 1)
+	event := &gwLog.Events[0]
+
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		switch fields[i] {
+		case csvFieldTime:
+			gwLog.ID.Time = value
+		case csvFieldUniqueQualifier:
+			gwLog.ID.UniqueQualifier = value
+		case csvFieldApplication:
+			gwLog.ID.ApplicationName = strings.ToLower(value)
+		case csvFieldCustomerID:
+			gwLog.ID.CustomerID = value
+		case csvFieldActorEmail:
+			gwLog.Actor.Email = value
+		case csvFieldActorProfileID:
+			gwLog.Actor.ProfileID = value
+		case csvFieldActorCallerType:
+			gwLog.Actor.CallerType = value
+		case csvFieldIPAddress:
+			gwLog.IPAddress = value
+		case csvFieldOwnerDomain:
+			gwLog.OwnerDomain = value
+		case csvFieldEventType:
+			event.Type = value
+		case csvFieldEventName:
+			event.Name = value
+		default:
+			event.Parameters = append(event.Parameters, struct {
+				Name       string      `json:"name"`
+				Value      interface{} `json:"value"`
+				IntValue   *int64      `json:"intValue,omitempty"`
+				BoolValue  *bool       `json:"boolValue,omitempty"`
+				MultiValue []string    `json:"multiValue,omitempty"`
+			}{Name: csvParameterName(header[i]), Value: value})
+		}
+	}
+	return gwLog
+}
+
+// csvLine encodes a CSV row back into a single line for quarantine records
+func csvLine(values []string) string {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write(values)
+	writer.Flush()
+	return strings.TrimRight(buf.String(), "\r\n")
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	inputTestLog1 = `{"kind":"admin#reports#activity","id":{"time":"2024-08-12T10:15:30Z","uniqueQualifier":"1","applicationName":"drive","customerId":"C03az79cb"},"actor":{"email":"user@muhai-academy.com"},"ipAddress":"203.0.113.1","events":[{"type":"access","name":"view","parameters":[{"name":"doc_id","value":"doc-1"}]}]}`
+	inputTestLog2 = `{"kind":"admin#reports#activity","id":{"time":"2024-08-12T10:16:30Z","uniqueQualifier":"2","applicationName":"login","customerId":"C03az79cb"},"actor":{"email":"user@muhai-academy.com"},"ipAddress":"203.0.113.1","events":[{"type":"login","name":"login_success"}]}`
+)
+
+func TestDetectInputFormat(t *testing.T) {
+	testCases := []struct {
+		name string
+		key  string
+		data string
+		want string
+	}{
+		{"jsonl by suffix", "logs/a.jsonl", `[not sniffed]`, InputFormatJSONL},
+		{"gzip jsonl by suffix", "logs/a.jsonl.gz", `[not sniffed]`, InputFormatJSONL},
+		{"csv by suffix", "export/a.CSV", `{"items":[]}`, InputFormatCSV},
+		{"json array", "logs/a.json", "  \n[" + inputTestLog1 + "]", InputFormatJSONArray},
+		{"reports page", "logs/a.json", `{"kind":"admin#reports#activities","items":[` + inputTestLog1 + `]}`, InputFormatReportsPage},
+		{"pretty printed reports page", "logs/page", "{\n  \"items\": [\n" + inputTestLog1 + "\n  ]\n}", InputFormatReportsPage},
+		{"concatenated objects", "logs/a.json", inputTestLog1 + "\n" + inputTestLog2, InputFormatJSONL},
+		{"pretty printed object", "logs/a.json", "{\n  \"kind\": \"audit#activity\",\n  \"events\": []\n}\n", InputFormatJSONObjects},
+		{"invalid object", "logs/a.json", "{\"kind\": \n", InputFormatJSONL},
+		{"csv by header", "export/a", "Date,Event Name,User\n2024-08-12T10:15:30Z,view,user@muhai-academy.com", InputFormatCSV},
+		{"bom", "logs/a", "\ufeff[" + inputTestLog1 + "]", InputFormatJSONArray},
+		{"unknown", "logs/a", "plain text", InputFormatJSONL},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, DetectInputFormat(tc.key, []byte(tc.data)))
+		})
+	}
+}
+
+func TestDecodeInput_JSONArray(t *testing.T) {
+	data := "[" + inputTestLog1 + `,{"id":"broken"},` + inputTestLog2 + "]"
+	records, quarantined, format, err := DecodeInput("logs/a.json", []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, InputFormatJSONArray, format)
+	require.Len(t, records, 2)
+	assert.Equal(t, "1", records[0].Log.ID.UniqueQualifier)
+	assert.Equal(t, 3, records[1].LineNumber)
+	assert.JSONEq(t, inputTestLog2, string(records[1].Raw))
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, 2, quarantined[0].LineNumber)
+	assert.Equal(t, QuarantineStageParse, quarantined[0].Stage)
+
+	_, _, _, err = DecodeInput("logs/a.json", []byte("["+inputTestLog1))
+	assert.Error(t, err)
+}
+
+func TestDecodeInput_ReportsPages(t *testing.T) {
+	// Two pages, one per line as written by a paging export script
+	data := `{"kind":"admin#reports#activities","items":[` + inputTestLog1 + `],"nextPageToken":"token"}` + "\n" +
+		`{"kind":"admin#reports#activities","items":[` + inputTestLog2 + `]}`
+	records, quarantined, format, err := DecodeInput("logs/pages.json", []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, InputFormatReportsPage, format)
+	assert.Empty(t, quarantined)
+	require.Len(t, records, 2)
+	assert.Equal(t, "drive", records[0].Log.ID.ApplicationName)
+	assert.Equal(t, "login", records[1].Log.ID.ApplicationName)
+	assert.Equal(t, 2, records[1].LineNumber, "items are numbered across pages")
+}
+
+func TestDecodeInput_PrettyPrintedObjects(t *testing.T) {
+	var data bytes.Buffer
+	require.NoError(t, json.Indent(&data, []byte(inputTestLog1), "", "  "))
+	lines := bytes.Count(data.Bytes(), []byte("\n"))
+	data.WriteString("\n")
+	require.NoError(t, json.Indent(&data, []byte(inputTestLog2), "", "  "))
+
+	records, quarantined, format, err := DecodeInput("logs/export.json", data.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, InputFormatJSONObjects, format)
+	assert.Empty(t, quarantined)
+	require.Len(t, records, 2)
+	assert.Equal(t, "drive", records[0].Log.ID.ApplicationName)
+	assert.Equal(t, 1, records[0].LineNumber)
+	assert.Equal(t, "login", records[1].Log.ID.ApplicationName)
+	assert.Equal(t, lines+2, records[1].LineNumber)
+}
+
+func TestDecodeInput_CSV(t *testing.T) {
+	data := "\ufeffid.time,id.applicationName,actor.email,IP address,Event Type,Event Name,doc_id,events.parameters.doc_title,Visibility\n" +
+		"2024-08-12 10:15:30,Drive,user@muhai-academy.com,203.0.113.1,access,download,doc-1,\"経理/決算書/2024年度.xlsx\",anyone_with_link\n" +
+		"2024-08-12 10:16:30,drive,user@muhai-academy.com\n" +
+		"2024-08-12 10:17:30,login,user@muhai-academy.com,203.0.113.1,login,login_success,,,\n"
+
+	records, quarantined, format, err := DecodeInput("export/takeout.csv", []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, InputFormatCSV, format)
+	require.Len(t, records, 2)
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, 3, quarantined[0].LineNumber)
+
+	log := records[0].Log
+	assert.Equal(t, 2, records[0].LineNumber)
+	assert.Equal(t, "2024-08-12 10:15:30", log.ID.Time)
+	assert.Equal(t, "drive", log.ID.ApplicationName)
+	assert.Equal(t, "user@muhai-academy.com", log.Actor.Email)
+	assert.Equal(t, "203.0.113.1", log.IPAddress)
+	require.Len(t, log.Events, 1)
+	assert.Equal(t, "access", log.Events[0].Type)
+	assert.Equal(t, "download", log.Events[0].Name)
+	params := map[string]interface{}{}
+	for _, p := range log.Events[0].Parameters {
+		params[p.Name] = p.Value
+	}
+	assert.Equal(t, map[string]interface{}{
+		"doc_id":     "doc-1",
+		"doc_title":  "経理/決算書/2024年度.xlsx",
+		"visibility": "anyone_with_link",
+	}, params)
+
+	ocsf, err := ConvertToOCSF(&log, "ap-northeast-1", "123456789012")
+	require.NoError(t, err)
+	require.Len(t, ocsf.WebResources, 1)
+	assert.Equal(t, "doc-1", ocsf.WebResources[0].UID)
+	assert.Empty(t, records[1].Log.Events[0].Parameters)
+
+	_, _, _, err = DecodeInput("export/a.csv", []byte("user,event\nalice,view\n"))
+	assert.Error(t, err, "time column is required")
+}
+
+func TestDecompressInput(t *testing.T) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(inputTestLog1))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	// Compressed content is detected without the .gz suffix
+	data, err := DecompressInput("logs/a.json", buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, inputTestLog1, string(data))
+
+	data, err = DecompressInput("logs/a.jsonl", []byte(inputTestLog1))
+	require.NoError(t, err)
+	assert.Equal(t, inputTestLog1, string(data))
+
+	_, err = DecompressInput("logs/a.jsonl.gz", []byte(inputTestLog1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// mockObjectGetter implements ObjectGetter for testing
+type mockObjectGetter struct {
+	mock.Mock
+}
+
+func (m *mockObjectGetter) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*s3.GetObjectOutput), args.Error(1)
+}
+
+func TestReadLocation(t *testing.T) {
+	client := new(mockObjectGetter)
+	client.On("GetObject", mock.Anything, &s3.GetObjectInput{
+		Bucket: aws.String("state-bucket"),
+		Key:    aws.String("enrich/users.csv"),
+	}).Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("email\n"))}, nil)
+
+	data, err := readLocation(context.Background(), client, "s3://state-bucket/enrich/users.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "email\n", string(data))
+
+	data, err = readLocation(context.Background(), nil, "testdata/enrich/users.csv")
+	require.NoError(t, err)
+	assert.NotEmpty(t, data)
+
+	_, err = readLocation(context.Background(), nil, "s3://state-bucket/enrich/users.csv")
+	assert.Error(t, err, "S3 location without client")
+	_, err = readLocation(context.Background(), client, "s3://state-bucket")
+	assert.Error(t, err)
+}
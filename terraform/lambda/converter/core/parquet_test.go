@@ -1,6 +1,8 @@
-package main
+package core
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -80,7 +82,6 @@ func TestGoogleWorkspaceLogToOCSF_Conversion(t *testing.T) {
 }
 
 func TestGenerateOCSFParquetFile_ValidData(t *testing.T) {
-	handler := &Handler{}
 
 	timestamp1, _ := time.Parse(time.RFC3339, "2024-08-12T10:00:00Z")
 	timestamp2, _ := time.Parse(time.RFC3339, "2024-08-12T10:05:00Z")
@@ -94,45 +95,14 @@ func TestGenerateOCSFParquetFile_ValidData(t *testing.T) {
 			SeverityID:  1,
 			StatusID:    1,
 			Time:        timestamp1.UnixMilli(),
-			Actor: struct {
-				User struct {
-					Name       string   `parquet:"name"`
-					UID        string   `parquet:"uid"`
-					EmailAddr  string   `parquet:"email_addr"`
-					Domain     string   `parquet:"domain,optional"`
-					TypeID     int      `parquet:"type_id"`
-					Groups     []string `parquet:"groups,optional"`
-				} `parquet:"user"`
-				Session struct {
-					UID         string `parquet:"uid"`
-					CreatedTime int64  `parquet:"created_time,optional"`
-					ExpTime     int64  `parquet:"exp_time,optional"`
-				} `parquet:"session,optional"`
-				AppName string `parquet:"app_name,optional"`
-				AppUID  string `parquet:"app_uid,optional"`
-			}{
-				User: struct {
-					Name       string   `parquet:"name"`
-					UID        string   `parquet:"uid"`
-					EmailAddr  string   `parquet:"email_addr"`
-					Domain     string   `parquet:"domain,optional"`
-					TypeID     int      `parquet:"type_id"`
-					Groups     []string `parquet:"groups,optional"`
-				}{
+			Actor: OCSFActor{
+				User: OCSFUser{
 					EmailAddr: "alice@example.com",
 					UID:       "user001",
 					TypeID:    1,
 				},
 			},
-			SrcEndpoint: struct {
-				IP       string `parquet:"ip"`
-				Hostname string `parquet:"hostname,optional"`
-				Location struct {
-					City    string `parquet:"city,optional"`
-					Country string `parquet:"country,optional"`
-					Region  string `parquet:"region,optional"`
-				} `parquet:"location,optional"`
-			}{
+			SrcEndpoint: OCSFEndpoint{
 				IP: "192.168.1.100",
 			},
 			Region:    "ap-northeast-1",
@@ -147,45 +117,14 @@ func TestGenerateOCSFParquetFile_ValidData(t *testing.T) {
 			SeverityID:  1,
 			StatusID:    1,
 			Time:        timestamp2.UnixMilli(),
-			Actor: struct {
-				User struct {
-					Name       string   `parquet:"name"`
-					UID        string   `parquet:"uid"`
-					EmailAddr  string   `parquet:"email_addr"`
-					Domain     string   `parquet:"domain,optional"`
-					TypeID     int      `parquet:"type_id"`
-					Groups     []string `parquet:"groups,optional"`
-				} `parquet:"user"`
-				Session struct {
-					UID         string `parquet:"uid"`
-					CreatedTime int64  `parquet:"created_time,optional"`
-					ExpTime     int64  `parquet:"exp_time,optional"`
-				} `parquet:"session,optional"`
-				AppName string `parquet:"app_name,optional"`
-				AppUID  string `parquet:"app_uid,optional"`
-			}{
-				User: struct {
-					Name       string   `parquet:"name"`
-					UID        string   `parquet:"uid"`
-					EmailAddr  string   `parquet:"email_addr"`
-					Domain     string   `parquet:"domain,optional"`
-					TypeID     int      `parquet:"type_id"`
-					Groups     []string `parquet:"groups,optional"`
-				}{
+			Actor: OCSFActor{
+				User: OCSFUser{
 					EmailAddr: "bob@example.com",
 					UID:       "user002",
 					TypeID:    1,
 				},
 			},
-			SrcEndpoint: struct {
-				IP       string `parquet:"ip"`
-				Hostname string `parquet:"hostname,optional"`
-				Location struct {
-					City    string `parquet:"city,optional"`
-					Country string `parquet:"country,optional"`
-					Region  string `parquet:"region,optional"`
-				} `parquet:"location,optional"`
-			}{
+			SrcEndpoint: OCSFEndpoint{
 				IP: "192.168.1.101",
 			},
 			Region:    "ap-northeast-1",
@@ -194,7 +133,7 @@ func TestGenerateOCSFParquetFile_ValidData(t *testing.T) {
 		},
 	}
 
-	data, err := handler.generateOCSFParquetFile(logs)
+	data, err := GenerateParquet(logs)
 	require.NoError(t, err)
 	require.NotEmpty(t, data)
 
@@ -203,11 +142,10 @@ func TestGenerateOCSFParquetFile_ValidData(t *testing.T) {
 }
 
 func TestGenerateOCSFParquetFile_EmptyData(t *testing.T) {
-	handler := &Handler{}
 
 	logs := []OCSFWebResourceActivity{}
 
-	data, err := handler.generateOCSFParquetFile(logs)
+	data, err := GenerateParquet(logs)
 	require.NoError(t, err)
 	require.NotEmpty(t, data) // Even empty parquet files have metadata
 
@@ -216,7 +154,6 @@ func TestGenerateOCSFParquetFile_EmptyData(t *testing.T) {
 }
 
 func TestGenerateOCSFParquetFile_SchemaValidation(t *testing.T) {
-	handler := &Handler{}
 
 	// Test with maximum field lengths and edge cases
 	timestamp := time.Now()
@@ -229,57 +166,18 @@ func TestGenerateOCSFParquetFile_SchemaValidation(t *testing.T) {
 			SeverityID:  3,
 			StatusID:    2,
 			Time:        timestamp.UnixMilli(),
-			Actor: struct {
-				User struct {
-					Name       string   `parquet:"name"`
-					UID        string   `parquet:"uid"`
-					EmailAddr  string   `parquet:"email_addr"`
-					Domain     string   `parquet:"domain,optional"`
-					TypeID     int      `parquet:"type_id"`
-					Groups     []string `parquet:"groups,optional"`
-				} `parquet:"user"`
-				Session struct {
-					UID         string `parquet:"uid"`
-					CreatedTime int64  `parquet:"created_time,optional"`
-					ExpTime     int64  `parquet:"exp_time,optional"`
-				} `parquet:"session,optional"`
-				AppName string `parquet:"app_name,optional"`
-				AppUID  string `parquet:"app_uid,optional"`
-			}{
-				User: struct {
-					Name       string   `parquet:"name"`
-					UID        string   `parquet:"uid"`
-					EmailAddr  string   `parquet:"email_addr"`
-					Domain     string   `parquet:"domain,optional"`
-					TypeID     int      `parquet:"type_id"`
-					Groups     []string `parquet:"groups,optional"`
-				}{
+			Actor: OCSFActor{
+				User: OCSFUser{
 					EmailAddr: "user_with_special_chars@domain.com",
 					UID:       "very-long-id-that-tests-string-handling-in-parquet-format",
 					TypeID:    2,
 					Domain:    "domain.com",
 				},
 			},
-			SrcEndpoint: struct {
-				IP       string `parquet:"ip"`
-				Hostname string `parquet:"hostname,optional"`
-				Location struct {
-					City    string `parquet:"city,optional"`
-					Country string `parquet:"country,optional"`
-					Region  string `parquet:"region,optional"`
-				} `parquet:"location,optional"`
-			}{
+			SrcEndpoint: OCSFEndpoint{
 				IP: "255.255.255.255",
 			},
-			WebResources: []struct {
-				Name      string `parquet:"name,optional"`
-				UID       string `parquet:"uid,optional"`
-				Type      string `parquet:"type,optional"`
-				URLString string `parquet:"url_string,optional"`
-				Data      struct {
-					Classification string `parquet:"classification,optional"`
-				} `parquet:"data,optional"`
-			}{
+			WebResources: []OCSFWebResource{
 				{
 					Name: "documents/subfolder/very-long-filename-with-spaces and special chars.txt",
 					Type: "document",
@@ -291,10 +189,35 @@ func TestGenerateOCSFParquetFile_SchemaValidation(t *testing.T) {
 		},
 	}
 
-	data, err := handler.generateOCSFParquetFile(logs)
+	data, err := GenerateParquet(logs)
 	require.NoError(t, err)
 	require.NotEmpty(t, data)
 
 	// TODO: Add validation for Arrow-generated Parquet files
 	// For now, just verify the schema validation data was generated without error
 }
+
+func TestReadParquet_RoundTrip(t *testing.T) {
+	var logs []OCSFWebResourceActivity
+	for _, line := range []string{inputTestLog1, inputTestLog2} {
+		records, _, err := decodeJSONLines(strings.NewReader(line))
+		require.NoError(t, err)
+		ocsfLog, err := ConvertToOCSF(&records[0].Log, "ap-northeast-1", "123456789012")
+		require.NoError(t, err)
+		logs = append(logs, *ocsfLog)
+	}
+	logs[1].SrcEndpoint.Location = OCSFLocation{Country: "JP", City: "Tokyo", Lat: 35.69, Long: 139.69}
+	logs[1].Actor.Session = OCSFSession{UID: "s1", IsNewIP: true}
+
+	data, err := GenerateParquet(logs)
+	require.NoError(t, err)
+	got, err := ReadParquet(context.Background(), data)
+	require.NoError(t, err)
+
+	// The extension is not written to Parquet, and cloud.org is null without the name
+	for i := range logs {
+		logs[i].Metadata.Extension = nil
+		logs[i].Cloud.Org = OCSFOrg{}
+	}
+	assert.Equal(t, logs, got)
+}
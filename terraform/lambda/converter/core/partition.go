@@ -0,0 +1,142 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PartitionKey identifies a Security Lake partition that a set of OCSF records belongs to
+type PartitionKey struct {
+	Region    string
+	AccountID string
+	EventDay  string // YYYYMMDD derived from the event time (UTC)
+	EventHour string // HH derived from the event time (UTC), empty unless partitioned by hour
+}
+
+// Partition is a group of OCSF records which share the same PartitionKey
+type Partition struct {
+	Key  PartitionKey
+	Logs []OCSFWebResourceActivity
+}
+
+// eventDayOf returns the eventDay partition value (YYYYMMDD, UTC) for an OCSF record
+func eventDayOf(log *OCSFWebResourceActivity) string {
+	return time.UnixMilli(log.Time).UTC().Format("20060102")
+}
+
+// eventHourOf returns the eventHour partition value (HH, UTC) for an OCSF record
+func eventHourOf(log *OCSFWebResourceActivity) string {
+	return time.UnixMilli(log.Time).UTC().Format("15")
+}
+
+// PartitionLogs splits OCSF records by region, account and the day of the event itself, and
+// also by the hour of the event if byHour is set. Partitions are returned in a stable order
+// (by key) so that output is deterministic.
+func PartitionLogs(logs []OCSFWebResourceActivity, byHour bool) []Partition {
+	index := map[PartitionKey]int{}
+	var partitions []Partition
+
+	for _, log := range logs {
+		key := PartitionKey{
+			Region:    log.Region,
+			AccountID: log.AccountID,
+			EventDay:  eventDayOf(&log),
+		}
+		if byHour {
+			key.EventHour = eventHourOf(&log)
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(partitions)
+			index[key] = i
+			partitions = append(partitions, Partition{Key: key})
+		}
+		partitions[i].Logs = append(partitions[i].Logs, log)
+	}
+
+	sort.Slice(partitions, func(i, j int) bool {
+		a, b := partitions[i].Key, partitions[j].Key
+		if a.EventDay != b.EventDay {
+			return a.EventDay < b.EventDay
+		}
+		if a.EventHour != b.EventHour {
+			return a.EventHour < b.EventHour
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		return a.AccountID < b.AccountID
+	})
+
+	return partitions
+}
+
+// BaseObjectName converts a source object key into a flat file name without extensions
+func BaseObjectName(sourceKey string) string {
+	name := sourceKey
+	name = strings.TrimSuffix(name, ".gz")
+	for _, ext := range InputExtensions {
+		name = strings.TrimSuffix(name, ext)
+	}
+	name = strings.ReplaceAll(name, "/", "_")
+	return name
+}
+
+// ContentHash returns a short hex digest of the source object content
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// DefaultSourceVersion is the custom log source version used if not configured
+const DefaultSourceVersion = "1.0"
+
+// SourceLayout identifies the path prefix ext/{Name}/{Version}/ of a Security Lake custom
+// log source. Security Lake versions custom sources, so an upgrade writes to a new layout.
+type SourceLayout struct {
+	Name    string
+	Version string
+}
+
+func (x SourceLayout) String() string {
+	return x.Name + "/" + x.Version
+}
+
+// ParseSourceLayouts parses comma separated "name/version" layouts
+func ParseSourceLayouts(value string) ([]SourceLayout, error) {
+	var layouts []SourceLayout
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, version, ok := strings.Cut(item, "/")
+		if !ok || name == "" || version == "" || strings.Contains(version, "/") {
+			return nil, fmt.Errorf("invalid source layout %q, expected name/version", item)
+		}
+		layouts = append(layouts, SourceLayout{Name: name, Version: version})
+	}
+	return layouts, nil
+}
+
+// BuildSecurityLakeKey generates a Security Lake compliant key for custom log source.
+// Format: ext/{customSourceName}/{version}/region={region}/accountId={accountId}/eventDay={YYYYMMDD}/{objectName}.parquet
+// With an hour partition, eventHour={HH}/ follows eventDay. The object name only depends on
+// the source key and its content, so reprocessing the same source object overwrites the
+// previous output instead of adding a duplicate.
+func BuildSecurityLakeKey(layout SourceLayout, key PartitionKey, sourceKey, sourceHash string) string {
+	partition := fmt.Sprintf("region=%s/accountId=%s/eventDay=%s", key.Region, key.AccountID, key.EventDay)
+	if key.EventHour != "" {
+		partition += "/eventHour=" + key.EventHour
+	}
+	return fmt.Sprintf("ext/%s/%s/%s/%s_%s.parquet",
+		layout.Name,
+		layout.Version,
+		partition,
+		BaseObjectName(sourceKey),
+		sourceHash)
+}
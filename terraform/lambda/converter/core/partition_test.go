@@ -0,0 +1,108 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPartitionTestLog(ts string) OCSFWebResourceActivity {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		panic(err)
+	}
+	return OCSFWebResourceActivity{
+		Time:      t.UnixMilli(),
+		Region:    "ap-northeast-1",
+		AccountID: "123456789012",
+	}
+}
+
+func TestPartitionLogs_SplitByEventDay(t *testing.T) {
+	logs := []OCSFWebResourceActivity{
+		newPartitionTestLog("2024-08-13T00:00:01Z"),
+		newPartitionTestLog("2024-08-12T23:59:59Z"),
+		newPartitionTestLog("2024-08-12T10:00:00Z"),
+	}
+
+	partitions := PartitionLogs(logs, false)
+	require.Len(t, partitions, 2)
+
+	assert.Equal(t, "20240812", partitions[0].Key.EventDay)
+	assert.Len(t, partitions[0].Logs, 2)
+	assert.Equal(t, "20240813", partitions[1].Key.EventDay)
+	assert.Len(t, partitions[1].Logs, 1)
+	assert.Equal(t, "ap-northeast-1", partitions[1].Key.Region)
+	assert.Equal(t, "123456789012", partitions[1].Key.AccountID)
+}
+
+func TestPartitionLogs_SplitByEventHour(t *testing.T) {
+	logs := []OCSFWebResourceActivity{
+		newPartitionTestLog("2024-08-12T11:30:00Z"),
+		newPartitionTestLog("2024-08-12T10:59:59Z"),
+		newPartitionTestLog("2024-08-12T10:00:00Z"),
+	}
+
+	partitions := PartitionLogs(logs, true)
+	require.Len(t, partitions, 2)
+
+	assert.Equal(t, PartitionKey{Region: "ap-northeast-1", AccountID: "123456789012", EventDay: "20240812", EventHour: "10"}, partitions[0].Key)
+	assert.Len(t, partitions[0].Logs, 2)
+	assert.Equal(t, "11", partitions[1].Key.EventHour)
+	assert.Len(t, partitions[1].Logs, 1)
+
+	assert.Equal(t,
+		"ext/google-workspace/1.0/region=ap-northeast-1/accountId=123456789012/eventDay=20240812/eventHour=10/logs_data_abcd.parquet",
+		BuildSecurityLakeKey(SourceLayout{Name: "google-workspace", Version: "1.0"}, partitions[0].Key, "logs/data.jsonl", "abcd"))
+}
+
+func TestPartitionLogs_Empty(t *testing.T) {
+	assert.Empty(t, PartitionLogs(nil, false))
+}
+
+func TestBuildSecurityLakeKey_Deterministic(t *testing.T) {
+	key := PartitionKey{Region: "ap-northeast-1", AccountID: "123456789012", EventDay: "20240812"}
+	hash := ContentHash([]byte("raw log data"))
+
+	first := BuildSecurityLakeKey(SourceLayout{Name: "google-workspace", Version: "1.0"}, key, "logs/2024/08/12/data.jsonl.gz", hash)
+	second := BuildSecurityLakeKey(SourceLayout{Name: "google-workspace", Version: "1.0"}, key, "logs/2024/08/12/data.jsonl.gz", hash)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t,
+		"ext/google-workspace/1.0/region=ap-northeast-1/accountId=123456789012/eventDay=20240812/logs_2024_08_12_data_"+hash+".parquet",
+		first)
+}
+
+func TestContentHash(t *testing.T) {
+	assert.Equal(t, ContentHash([]byte("a")), ContentHash([]byte("a")))
+	assert.NotEqual(t, ContentHash([]byte("a")), ContentHash([]byte("b")))
+	assert.Len(t, ContentHash([]byte("a")), 16)
+}
+
+func TestBuildSecurityLakeKey_SourceVersion(t *testing.T) {
+	key := PartitionKey{Region: "ap-northeast-1", AccountID: "123456789012", EventDay: "20240812"}
+	got := BuildSecurityLakeKey(SourceLayout{Name: "google-workspace-v2", Version: "2.0"}, key, "logs/data.jsonl", "abcd")
+	assert.Equal(t,
+		"ext/google-workspace-v2/2.0/region=ap-northeast-1/accountId=123456789012/eventDay=20240812/logs_data_abcd.parquet",
+		got)
+}
+
+func TestParseSourceLayouts(t *testing.T) {
+	layouts, err := ParseSourceLayouts("google-workspace/1.0, google-workspace-v2/2.0,")
+	require.NoError(t, err)
+	assert.Equal(t, []SourceLayout{
+		{Name: "google-workspace", Version: "1.0"},
+		{Name: "google-workspace-v2", Version: "2.0"},
+	}, layouts)
+
+	layouts, err = ParseSourceLayouts("")
+	require.NoError(t, err)
+	assert.Empty(t, layouts)
+
+	for _, value := range []string{"google-workspace", "/1.0", "google-workspace/", "a/1.0/x"} {
+		_, err := ParseSourceLayouts(value)
+		assert.Error(t, err, value)
+	}
+}
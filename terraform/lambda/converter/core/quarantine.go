@@ -0,0 +1,181 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const (
+	// QuarantineStageParse means the line could not be decoded as JSON
+	QuarantineStageParse = "parse"
+	// QuarantineStageConvert means the record was decoded but could not be converted to OCSF
+	QuarantineStageConvert = "convert"
+	// QuarantineStageValidate means the converted record violates the OCSF schema
+	QuarantineStageValidate = "validate"
+
+	// maxConsecutiveParseErrors limits consecutive errors, because a file that fails on every
+	// line is most likely not in a supported format at all
+	maxConsecutiveParseErrors = 100
+)
+
+// QuarantineRecord keeps a raw record which could not be converted, so that it can be
+// investigated and replayed after a fix
+type QuarantineRecord struct {
+	SourceBucket string   `json:"source_bucket"`
+	SourceKey    string   `json:"source_key"`
+	LineNumber   int      `json:"line_number"`
+	Stage        string   `json:"stage"`
+	Reason       string   `json:"reason"`
+	Rules        []string `json:"rules,omitempty"` // violated validation rules, e.g. "required:metadata.version"
+	Raw          []byte   `json:"raw"`             // original bytes, base64 in JSON to keep invalid UTF-8 as is
+}
+
+// ConversionReport summarizes the result of converting a single source object
+type ConversionReport struct {
+	SourceBucket string    `json:"source_bucket"`
+	SourceKey    string    `json:"source_key"`
+	SourceETag   string    `json:"source_etag,omitempty"`
+	ContentHash  string    `json:"content_hash"`
+	Format       string    `json:"format"`
+	Parsed       int       `json:"parsed"`
+	Converted    int       `json:"converted"`
+	Quarantined  int       `json:"quarantined"`
+	Outputs      []string  `json:"outputs"`
+	Quarantine   string    `json:"quarantine,omitempty"`
+	ProcessedAt  time.Time `json:"processed_at"`
+}
+
+// LogRecord is a decoded Google Workspace log with its original bytes and position
+type LogRecord struct {
+	LineNumber int
+	Raw        []byte
+	Log        GoogleWorkspaceLog
+}
+
+// decodeJSONLines reads a stream of JSON values, one per line in JSONL but also values which
+// span lines such as pretty-printed or concatenated objects. Values which can not be decoded
+// are returned as quarantine records (without source information) instead of being dropped:
+// a syntax error skips to the end of the line of the error and decoding resumes there, and a
+// well-formed value which is not a log is quarantined as is. LineNumber of a record is the
+// line where its value starts.
+func decodeJSONLines(r io.Reader) ([]LogRecord, []QuarantineRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	var records []LogRecord
+	var quarantined []QuarantineRecord
+	lines := lineCounter{data: data}
+	errorCount := 0
+	quarantine := func(start, end int, reason string) error {
+		errorCount++
+		lineNum := lines.at(start)
+		slog.Warn("Failed to parse JSON at line", "line", lineNum, "error", reason, "error_count", errorCount)
+		if errorCount > maxConsecutiveParseErrors {
+			return fmt.Errorf("too many consecutive JSON parsing errors (%d)", errorCount)
+		}
+		quarantined = append(quarantined, QuarantineRecord{
+			LineNumber: lineNum,
+			Stage:      QuarantineStageParse,
+			Reason:     reason,
+			Raw:        bytes.Clone(data[start:end]),
+		})
+		return nil
+	}
+
+	// A decoder can not continue after a syntax error, so a new one starts after the skipped line
+	for offset := 0; offset < len(data); {
+		decoder := json.NewDecoder(bytes.NewReader(data[offset:]))
+		for {
+			start := skipSpace(data, offset+int(decoder.InputOffset()))
+			var raw json.RawMessage
+			err := decoder.Decode(&raw)
+			if errors.Is(err, io.EOF) {
+				offset = len(data)
+				break
+			}
+			if err != nil {
+				// The bad value ends at the end of the line of the error
+				end := len(data)
+				var syntaxErr *json.SyntaxError
+				if errors.As(err, &syntaxErr) {
+					pos := max(start, offset+int(syntaxErr.Offset)-1)
+					if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+						end = pos + i
+					}
+				}
+				if err := quarantine(start, start+len(bytes.TrimRight(data[start:end], " \t\r")), err.Error()); err != nil {
+					return nil, nil, err
+				}
+				offset = end
+				break
+			}
+
+			var gwLog GoogleWorkspaceLog
+			if err := json.Unmarshal(raw, &gwLog); err != nil {
+				if err := quarantine(start, start+len(raw), err.Error()); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			// Reset error count on successful parse
+			errorCount = 0
+			records = append(records, LogRecord{
+				LineNumber: lines.at(start),
+				Raw:        raw,
+				Log:        gwLog,
+			})
+
+			// Log progress for very large files
+			if len(records)%10000 == 0 {
+				slog.Info("Processing progress", "lines_processed", lines.at(start), "logs_parsed", len(records))
+			}
+		}
+	}
+
+	totalLines := lines.at(len(data))
+	if bytes.HasSuffix(data, []byte("\n")) {
+		totalLines--
+	}
+	slog.Info("Reached end of file", "total_lines", totalLines, "parsed_logs", len(records), "quarantined", len(quarantined))
+	return records, quarantined, nil
+}
+
+// skipSpace returns the position of the first non-space byte of data from pos
+func skipSpace(data []byte, pos int) int {
+	for pos < len(data) && (data[pos] == ' ' || data[pos] == '\t' || data[pos] == '\r' || data[pos] == '\n') {
+		pos++
+	}
+	return pos
+}
+
+// lineCounter returns 1-based line numbers of positions in data. Positions must not decrease
+// between calls, so that the whole data is scanned only once.
+type lineCounter struct {
+	data []byte
+	pos  int
+	line int
+}
+
+func (x *lineCounter) at(pos int) int {
+	pos = min(pos, len(x.data))
+	x.line += bytes.Count(x.data[x.pos:pos], []byte("\n"))
+	x.pos = pos
+	return x.line + 1
+}
+
+// QuarantineKey returns the key of quarantined records for a source object
+func QuarantineKey(sourceKey, sourceHash string) string {
+	return fmt.Sprintf("quarantine/%s_%s.jsonl", BaseObjectName(sourceKey), sourceHash)
+}
+
+// ReportKey returns the key of the conversion report for a source object
+func ReportKey(sourceKey, sourceHash string) string {
+	return fmt.Sprintf("reports/%s_%s.json", BaseObjectName(sourceKey), sourceHash)
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeJSONLines_QuarantinesInvalidLines(t *testing.T) {
+	data := `{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:30Z"}}
+
+invalid json line
+{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:31Z"}}`
+
+	records, quarantined, err := decodeJSONLines(strings.NewReader(data))
+	require.NoError(t, err)
+
+	require.Len(t, records, 2)
+	assert.Equal(t, 1, records[0].LineNumber)
+	assert.Equal(t, 4, records[1].LineNumber)
+	assert.Equal(t, "2024-08-12T10:15:31Z", records[1].Log.ID.Time)
+
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, 3, quarantined[0].LineNumber)
+	assert.Equal(t, QuarantineStageParse, quarantined[0].Stage)
+	assert.Equal(t, "invalid json line", string(quarantined[0].Raw))
+	assert.NotEmpty(t, quarantined[0].Reason)
+}
+
+func TestDecodeJSONLines_MultiLineValues(t *testing.T) {
+	data := `{
+  "kind": "audit#activity",
+  "id": {"time": "2024-08-12T10:15:30Z"}
+}{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:31Z"}}
+{"kind": "audit#activity",
+ "id": {"time": 123}}
+{"kind": "audit#activity", "id": {"time": "2024-08-12T10:15:32Z"}}
+{"kind": "audit#activity", "id":`
+
+	records, quarantined, err := decodeJSONLines(strings.NewReader(data))
+	require.NoError(t, err)
+
+	require.Len(t, records, 3)
+	assert.Equal(t, []int{1, 4, 7}, []int{records[0].LineNumber, records[1].LineNumber, records[2].LineNumber})
+	assert.Equal(t, "2024-08-12T10:15:30Z", records[0].Log.ID.Time)
+	assert.Equal(t, "2024-08-12T10:15:31Z", records[1].Log.ID.Time)
+	assert.Equal(t, "2024-08-12T10:15:32Z", records[2].Log.ID.Time)
+
+	// Only the values which really fail are quarantined, with their exact bytes
+	require.Len(t, quarantined, 2)
+	assert.Equal(t, 5, quarantined[0].LineNumber)
+	assert.Equal(t, "{\"kind\": \"audit#activity\",\n \"id\": {\"time\": 123}}", string(quarantined[0].Raw))
+	assert.Equal(t, 8, quarantined[1].LineNumber)
+	assert.Equal(t, `{"kind": "audit#activity", "id":`, string(quarantined[1].Raw))
+}
+
+func TestDecodeJSONLines_InvalidUTF8(t *testing.T) {
+	line := []byte("{\"kind\":\xff\xfe}")
+	data := append(append(line, '\n'), []byte(`{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:30Z"}}`)...)
+
+	records, quarantined, err := decodeJSONLines(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, 2, records[0].LineNumber)
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, line, quarantined[0].Raw)
+
+	// Raw bytes survive the JSON encoding of quarantine records
+	encoded, err := json.Marshal(quarantined[0])
+	require.NoError(t, err)
+	var decoded QuarantineRecord
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, line, decoded.Raw)
+}
+
+func TestDecodeJSONLines_TooManyErrors(t *testing.T) {
+	data := strings.Repeat("broken\n", maxConsecutiveParseErrors+1)
+	_, _, err := decodeJSONLines(strings.NewReader(data))
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-converter/session"
+)
+
+// deviceParameters are event parameters identifying the client device
+var deviceParameters = []string{"device_id", "device_type", "user_agent"}
+
+// extensionDeviceID is the metadata extension key to pass the device to the sessionizer
+const extensionDeviceID = "device_id"
+
+// sessionEventOf builds a sessionizer event from a converted record
+func sessionEventOf(log *OCSFWebResourceActivity) session.Event {
+	actor := log.Actor.User.EmailAddr
+	if actor == "" {
+		actor = log.Actor.User.UID
+	}
+	return session.Event{
+		Actor:  actor,
+		IP:     log.SrcEndpoint.IP,
+		Device: log.Metadata.Extension[extensionDeviceID],
+		Time:   time.UnixMilli(log.Time).UTC(),
+		Login:  log.Actor.AppUID == "login" && log.API.Operation == "login_success",
+	}
+}
+
+// ApplySessions sets actor.session of records by the sessionizer
+func ApplySessions(sessionizer *session.Sessionizer, logs []OCSFWebResourceActivity) {
+	timeout := sessionizer.Config().InactivityTimeout
+	events := make([]session.Event, len(logs))
+	for i := range logs {
+		events[i] = sessionEventOf(&logs[i])
+	}
+
+	for i, assignment := range sessionizer.AssignAll(events) {
+		logs[i].Actor.Session = OCSFSession{
+			UID:         assignment.UID,
+			CreatedTime: assignment.CreatedTime.UnixMilli(),
+			ExpTime:     events[i].Time.Add(timeout).UnixMilli(),
+			IsNewIP:     assignment.IsNewIP,
+			IsNewDevice: assignment.IsNewDevice,
+		}
+	}
+}
+
+// deviceOf returns the device identifier in event parameters, or empty if not found
+func deviceOf(log *GoogleWorkspaceLog) string {
+	for _, event := range log.Events {
+		for _, param := range event.Parameters {
+			for _, name := range deviceParameters {
+				if param.Name == name && param.Value != nil {
+					if v := strings.TrimSpace(fmt.Sprintf("%v", param.Value)); v != "" {
+						return v
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
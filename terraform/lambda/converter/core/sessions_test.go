@@ -0,0 +1,36 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertToOCSF_DeviceForSession(t *testing.T) {
+	gwLog := &GoogleWorkspaceLog{}
+	gwLog.ID.Time = "2024-08-12T10:15:30Z"
+	gwLog.Events = append(gwLog.Events, struct {
+		Type       string `json:"type"`
+		Name       string `json:"name"`
+		Parameters []struct {
+			Name       string      `json:"name"`
+			Value      interface{} `json:"value"`
+			IntValue   *int64      `json:"intValue,omitempty"`
+			BoolValue  *bool       `json:"boolValue,omitempty"`
+			MultiValue []string    `json:"multiValue,omitempty"`
+		} `json:"parameters,omitempty"`
+	}{Type: "login", Name: "login_success"})
+	gwLog.Events[0].Parameters = append(gwLog.Events[0].Parameters, struct {
+		Name       string      `json:"name"`
+		Value      interface{} `json:"value"`
+		IntValue   *int64      `json:"intValue,omitempty"`
+		BoolValue  *bool       `json:"boolValue,omitempty"`
+		MultiValue []string    `json:"multiValue,omitempty"`
+	}{Name: "device_id", Value: "device-123"})
+
+	ocsf, err := ConvertToOCSF(gwLog, "ap-northeast-1", "123456789012")
+	require.NoError(t, err)
+	assert.Empty(t, ocsf.Actor.Session.UID, "session is assigned by the sessionizer")
+	assert.Equal(t, "device-123", sessionEventOf(ocsf).Device)
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTimestamp is returned when an event time can not be parsed in any supported format
+var ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+// timestampLayouts are textual layouts accepted for event time. Fractional seconds are
+// accepted by time.Parse for all of them even if not included in the layout.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// parseEventTime parses event time of Google Workspace logs and other sources. It accepts
+// RFC3339 with or without fractional seconds and offsets, and epoch values in seconds,
+// milliseconds, microseconds or nanoseconds. Times without offset are treated as UTC.
+func parseEventTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%w: empty value", ErrInvalidTimestamp)
+	}
+
+	if isNumeric(value) {
+		return parseEpoch(value)
+	}
+
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: unsupported format %q", ErrInvalidTimestamp, value)
+}
+
+// parseEpoch parses epoch value and guesses its unit by number of integer digits
+func parseEpoch(value string) (time.Time, error) {
+	if strings.Contains(value, ".") {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(f, 0) {
+			return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidTimestamp, value)
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC(), nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidTimestamp, value)
+	}
+
+	switch digits := len(strings.TrimPrefix(value, "-")); {
+	case digits <= 10:
+		return time.Unix(n, 0).UTC(), nil
+	case digits <= 13:
+		return time.UnixMilli(n).UTC(), nil
+	case digits <= 16:
+		return time.UnixMicro(n).UTC(), nil
+	default:
+		return time.Unix(0, n).UTC(), nil
+	}
+}
+
+func isNumeric(value string) bool {
+	value = strings.TrimPrefix(value, "-")
+	if value == "" {
+		return false
+	}
+	dot := false
+	for _, c := range value {
+		switch {
+		case c == '.' && !dot:
+			dot = true
+		case c < '0' || c > '9':
+			return false
+		}
+	}
+	return true
+}
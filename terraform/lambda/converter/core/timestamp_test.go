@@ -0,0 +1,107 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEventTime(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected time.Time
+	}{
+		{
+			name:     "RFC3339",
+			value:    "2024-08-12T10:15:30Z",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 0, time.UTC),
+		},
+		{
+			name:     "RFC3339 with milliseconds",
+			value:    "2024-08-12T10:15:30.123Z",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 123000000, time.UTC),
+		},
+		{
+			name:     "RFC3339Nano",
+			value:    "2024-08-12T10:15:30.123456789Z",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 123456789, time.UTC),
+		},
+		{
+			name:     "RFC3339 with offset",
+			value:    "2024-08-12T19:15:30.5+09:00",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 500000000, time.UTC),
+		},
+		{
+			name:     "without offset",
+			value:    "2024-08-12T10:15:30",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 0, time.UTC),
+		},
+		{
+			name:     "space separated",
+			value:    "2024-08-12 10:15:30",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 0, time.UTC),
+		},
+		{
+			name:     "epoch seconds",
+			value:    "1723457730",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 0, time.UTC),
+		},
+		{
+			name:     "epoch seconds with fraction",
+			value:    "1723457730.25",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 250000000, time.UTC),
+		},
+		{
+			name:     "epoch milliseconds",
+			value:    "1723457730123",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 123000000, time.UTC),
+		},
+		{
+			name:     "epoch microseconds",
+			value:    "1723457730123456",
+			expected: time.Date(2024, 8, 12, 10, 15, 30, 123456000, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseEventTime(tc.value)
+			require.NoError(t, err)
+			assert.True(t, tc.expected.Equal(actual), "expected %s, got %s", tc.expected, actual)
+			assert.Equal(t, time.UTC, actual.Location())
+		})
+	}
+}
+
+func TestParseEventTime_Invalid(t *testing.T) {
+	for _, value := range []string{"", "invalid-timestamp", "2024-13-45T99:99:99Z", "12:34"} {
+		_, err := parseEventTime(value)
+		assert.True(t, errors.Is(err, ErrInvalidTimestamp), "value %q", value)
+	}
+}
+
+func TestConvertToOCSF_TimestampPrecision(t *testing.T) {
+	gwLog := &GoogleWorkspaceLog{}
+	gwLog.ID.Time = "2024-08-13T08:15:30.123456+09:00"
+
+	ocsf, err := ConvertToOCSF(gwLog, "ap-northeast-1", "123456789012")
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2024, 8, 12, 23, 15, 30, 123000000, time.UTC).UnixMilli(), ocsf.Time)
+	assert.Equal(t, "2024-08-12-23", ocsf.EventHour)
+	assert.Equal(t, "2024-08-13T08:15:30.123456+09:00", ocsf.Metadata.OriginalTime)
+}
+
+func TestConvertToOCSF_InvalidTimestamp(t *testing.T) {
+	gwLog := &GoogleWorkspaceLog{}
+	gwLog.ID.Time = "yesterday"
+
+	_, err := ConvertToOCSF(gwLog, "ap-northeast-1", "123456789012")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidTimestamp))
+	assert.Contains(t, err.Error(), "yesterday")
+}
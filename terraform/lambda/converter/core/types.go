@@ -0,0 +1,209 @@
+package core
+
+// GoogleWorkspaceLog represents the actual structure of Google Workspace audit logs
+type GoogleWorkspaceLog struct {
+	Kind string `json:"kind"`
+	ID struct {
+		Time             string `json:"time"`
+		UniqueQualifier  string `json:"uniqueQualifier"`
+		ApplicationName  string `json:"applicationName"`
+		CustomerID       string `json:"customerId"`
+	} `json:"id"`
+	Actor struct {
+		CallerType string `json:"callerType"`
+		Email      string `json:"email"`
+		ProfileID  string `json:"profileId"`
+	} `json:"actor"`
+	OwnerDomain string `json:"ownerDomain"`
+	IPAddress   string `json:"ipAddress"`
+	Events []struct {
+		Type       string `json:"type"`
+		Name       string `json:"name"`
+		Parameters []struct {
+			Name         string      `json:"name"`
+			Value        interface{} `json:"value"`
+			IntValue     *int64      `json:"intValue,omitempty"`
+			BoolValue    *bool       `json:"boolValue,omitempty"`
+			MultiValue   []string    `json:"multiValue,omitempty"`
+		} `json:"parameters,omitempty"`
+	} `json:"events"`
+}
+
+// OCSFWebResourceActivity represents the OCSF Web Resources Activity (Class ID: 6001) format
+type OCSFWebResourceActivity struct {
+	// Basic classification attributes (required)
+	CategoryUID int    `parquet:"category_uid"`     // 6 (Application Activity)
+	ClassUID    int    `parquet:"class_uid"`        // 6001 (Web Resources Activity)
+	TypeUID     int    `parquet:"type_uid"`         // class_uid * 100 + activity_id
+	ActivityID  int    `parquet:"activity_id"`      // 1=Create, 2=Read, 3=Update, 4=Delete, etc.
+	SeverityID  int    `parquet:"severity_id"`      // 1=Informational, 2=Low, 3=Medium, 4=High
+	Time        int64  `parquet:"time"`             // Unix timestamp in milliseconds
+	StartTime   int64  `parquet:"start_time,optional"`
+	EndTime     int64  `parquet:"end_time,optional"`
+	StatusID    int    `parquet:"status_id"`        // 1=Success, 2=Failure
+	DispositionID int  `parquet:"disposition_id,optional"` // 1=Allowed, 2=Blocked, 3=Quarantined
+	Confidence  int    `parquet:"confidence,optional"`
+
+	// Actor information
+	Actor OCSFActor `parquet:"actor"`
+
+	// API information
+	API OCSFAPI `parquet:"api"`
+
+	// Cloud environment
+	Cloud OCSFCloud `parquet:"cloud"`
+
+	// Source endpoint
+	SrcEndpoint OCSFEndpoint `parquet:"src_endpoint"`
+
+	// Web resources
+	WebResources []OCSFWebResource `parquet:"web_resources,optional"`
+
+	// Metadata
+	Metadata OCSFMetadata `parquet:"metadata,optional"`
+
+	// Observables
+	Observables []OCSFObservable `parquet:"observables,optional,list"`
+
+	// Partitioning fields
+	Region    string `parquet:"aws_region"`      // AWS region
+	AccountID string `parquet:"account_id"` // AWS account ID
+	EventHour string `parquet:"event_hour"` // YYYY-MM-DD-HH format
+}
+
+// OCSFActor represents the actor object
+type OCSFActor struct {
+	User    OCSFUser    `parquet:"user"`
+	Session OCSFSession `parquet:"session,optional"`
+	AppName string      `parquet:"app_name,optional"`
+	AppUID  string      `parquet:"app_uid,optional"`
+}
+
+// OCSFUser represents the user object
+type OCSFUser struct {
+	Name       string   `parquet:"name"`
+	UID        string   `parquet:"uid"`
+	EmailAddr  string   `parquet:"email_addr"`
+	Domain     string   `parquet:"domain,optional"`
+	TypeID     int      `parquet:"type_id"`      // 1=User, 2=Admin
+	Groups     []string `parquet:"groups,optional"`
+}
+
+// OCSFSession represents the session object
+type OCSFSession struct {
+	UID         string `parquet:"uid"`
+	CreatedTime int64  `parquet:"created_time,optional"`
+	ExpTime     int64  `parquet:"exp_time,optional"`
+	IsNewIP     bool   `parquet:"is_new_ip,optional"`     // first activity of the actor from the IP address
+	IsNewDevice bool   `parquet:"is_new_device,optional"` // first activity of the actor from the device
+}
+
+// OCSFAPI represents the api object
+type OCSFAPI struct {
+	Service   OCSFService  `parquet:"service"`
+	Operation string       `parquet:"operation"`
+	Request   OCSFRequest  `parquet:"request"`
+	Response  OCSFResponse `parquet:"response,optional"`
+}
+
+// OCSFService represents the service object
+type OCSFService struct {
+	Name    string `parquet:"name"`
+	Version string `parquet:"version,optional"`
+}
+
+// OCSFRequest represents the request object
+type OCSFRequest struct {
+	UID string `parquet:"uid"`
+}
+
+// OCSFResponse represents the response object
+type OCSFResponse struct {
+	Code    int    `parquet:"code"`
+	Message string `parquet:"message,optional"`
+}
+
+// OCSFCloud represents the cloud object
+type OCSFCloud struct {
+	Provider string      `parquet:"provider"`
+	Account  OCSFAccount `parquet:"account"`
+	Org      OCSFOrg     `parquet:"org,optional"`
+	Region   string      `parquet:"cloud_region,optional"`
+}
+
+// OCSFAccount represents the account object
+type OCSFAccount struct {
+	UID  string `parquet:"uid"`
+	Name string `parquet:"name,optional"`
+}
+
+// OCSFOrg represents the organization object
+type OCSFOrg struct {
+	Name string `parquet:"name"`
+	UID  string `parquet:"uid,optional"`
+}
+
+// OCSFEndpoint represents the network endpoint object
+type OCSFEndpoint struct {
+	IP               string                 `parquet:"ip"`
+	Hostname         string                 `parquet:"hostname,optional"`
+	Location         OCSFLocation           `parquet:"location,optional"`
+	AutonomousSystem OCSFAutonomousSystem   `parquet:"autonomous_system,optional"`
+}
+
+// OCSFLocation represents the geographical location object
+type OCSFLocation struct {
+	City    string  `parquet:"city,optional"`
+	Country string  `parquet:"country,optional"`
+	Region  string  `parquet:"region,optional"`
+	Lat     float64 `parquet:"lat,optional"`
+	Long    float64 `parquet:"long,optional"`
+	ISP     string  `parquet:"isp,optional"`
+}
+
+// OCSFAutonomousSystem represents the autonomous system object
+type OCSFAutonomousSystem struct {
+	Number int    `parquet:"number,optional"`
+	Name   string `parquet:"name,optional"`
+}
+
+// OCSFWebResource represents the web resource object
+type OCSFWebResource struct {
+	Name      string              `parquet:"name,optional"`
+	UID       string              `parquet:"uid,optional"`
+	Type      string              `parquet:"type,optional"`
+	URLString string              `parquet:"url_string,optional"`
+	Data      OCSFWebResourceData `parquet:"data,optional"`
+}
+
+// OCSFWebResourceData represents additional data of a web resource
+type OCSFWebResourceData struct {
+	Classification string `parquet:"classification,optional"` // public, internal, confidential, restricted
+	Sensitivity    string `parquet:"sensitivity,optional"`    // comma separated labels of matched rules, e.g. "finance,public_link"
+}
+
+// OCSFMetadata represents the metadata object
+type OCSFMetadata struct {
+	UID            string            `parquet:"uid,optional"`
+	CorrelationUID string            `parquet:"correlation_uid,optional"`
+	Labels         []string          `parquet:"labels,optional,list"`
+	OriginalTime   string            `parquet:"original_time,optional"`
+	Processed      int64             `parquet:"processed,optional"`
+	ProductName    string            `parquet:"product_name,optional"` // same as product.name, kept for existing queries
+	Version        string            `parquet:"version,optional"`      // OCSF schema version
+	Product        OCSFProduct       `parquet:"product,optional"`
+	Extension      map[string]string `parquet:"-"` // Maps are not supported in parquet-go, will need custom handling
+}
+
+// OCSFProduct represents the product object reporting the event
+type OCSFProduct struct {
+	Name       string `parquet:"name,optional"`
+	VendorName string `parquet:"vendor_name,optional"`
+}
+
+// OCSFObservable represents the observable object
+type OCSFObservable struct {
+	Name  string `parquet:"name"`
+	Type  string `parquet:"type"`
+	Value string `parquet:"value"`
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"fmt"
+	"log/slog"
+
+	"seccamp2025-b1-converter/validator"
+)
+
+// OCSFClassName is the OCSF class of records written by the converter
+const OCSFClassName = "web_resources_activity"
+
+// NewValidator creates the validator for the validation mode. "off" disables validation,
+// "strict" also reports the known deviations of the converter, and empty validates with the
+// known deviations ignored. Other modes return an error.
+func NewValidator(mode string) (*validator.Validator, error) {
+	switch mode {
+	case "off":
+		return nil, nil
+	case "strict":
+		return validator.New(validator.DefaultSchema(), OCSFClassName)
+	case "":
+		return validator.New(validator.DefaultSchema(), OCSFClassName, validator.KnownDeviations...)
+	}
+	return nil, fmt.Errorf("unknown OCSF validation mode %q", mode)
+}
+
+// validate returns OCSF schema violations of the record. Nothing is reported if the
+// validator is not configured.
+func (x *Converter) validate(log *OCSFWebResourceActivity) validator.Violations {
+	if x.Validator == nil {
+		return nil
+	}
+	record, err := validator.ToRecord(log)
+	if err != nil {
+		// The converted record is always a struct, so this is a programming error
+		slog.Error("Failed to build record for validation", "error", err)
+		return nil
+	}
+	return x.Validator.Validate(record)
+}
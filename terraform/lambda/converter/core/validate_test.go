@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"seccamp2025-b1-converter/validator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertedParquet_IsValidOCSF(t *testing.T) {
+	data, err := os.ReadFile("testdata/drive.jsonl")
+	require.NoError(t, err)
+	logs, quarantined, err := decodeJSONLines(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	require.Empty(t, quarantined)
+
+	var ocsfLogs []OCSFWebResourceActivity
+	for _, log := range logs {
+		ocsf, err := ConvertToOCSF(&log.Log, "ap-northeast-1", "123456789012")
+		require.NoError(t, err)
+		ocsfLogs = append(ocsfLogs, *ocsf)
+	}
+
+	data, err = GenerateParquet(ocsfLogs)
+	require.NoError(t, err)
+	records, err := validator.ReadParquet(context.Background(), data)
+	require.NoError(t, err)
+	require.Len(t, records, len(ocsfLogs))
+
+	v, err := NewValidator("")
+	require.NoError(t, err)
+	for i, record := range records {
+		assert.Empty(t, v.Validate(record), "row %d", i)
+	}
+}
+
+func TestNewValidator(t *testing.T) {
+	v, err := NewValidator("off")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	_, err = NewValidator("lenient")
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// SourceObject identifies a version of a raw log object
+type SourceObject struct {
+	Bucket string
+	Key    string
+	ETag   string
+}
+
+// ledgerID returns a string which is unique for the bucket, key and content version
+func (x SourceObject) ledgerID() string {
+	return fmt.Sprintf("%s/%s/%s", x.Bucket, x.Key, strings.Trim(x.ETag, `"`))
+}
+
+// ProcessedLedger records source objects that have already been converted, so that
+// SQS redeliveries and manual replays of the same object do not produce duplicate data
+type ProcessedLedger interface {
+	IsProcessed(ctx context.Context, obj SourceObject) (bool, error)
+	MarkProcessed(ctx context.Context, obj SourceObject) error
+}
+
+// memoryLedger keeps processed objects in memory. It is mainly for tests and local runs.
+type memoryLedger struct {
+	mu        sync.Mutex
+	processed map[string]time.Time
+}
+
+func newMemoryLedger() *memoryLedger {
+	return &memoryLedger{processed: map[string]time.Time{}}
+}
+
+func (x *memoryLedger) IsProcessed(ctx context.Context, obj SourceObject) (bool, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	_, ok := x.processed[obj.ledgerID()]
+	return ok, nil
+}
+
+func (x *memoryLedger) MarkProcessed(ctx context.Context, obj SourceObject) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.processed[obj.ledgerID()] = time.Now().UTC()
+	return nil
+}
+
+// s3Ledger stores an empty marker object per processed source object
+type s3Ledger struct {
+	client S3API
+	bucket string
+	prefix string
+}
+
+func newS3Ledger(client S3API, bucket, prefix string) *s3Ledger {
+	return &s3Ledger{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (x *s3Ledger) markerKey(obj SourceObject) string {
+	return x.prefix + obj.ledgerID()
+}
+
+func (x *s3Ledger) IsProcessed(ctx context.Context, obj SourceObject) (bool, error) {
+	resp, err := x.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(x.bucket),
+		Key:    aws.String(x.markerKey(obj)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get processed marker: %w", err)
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+func (x *s3Ledger) MarkProcessed(ctx context.Context, obj SourceObject) error {
+	_, err := x.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(x.bucket),
+		Key:         aws.String(x.markerKey(obj)),
+		Body:        bytes.NewReader([]byte(time.Now().UTC().Format(time.RFC3339))),
+		ContentType: aws.String("text/plain"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put processed marker: %w", err)
+	}
+	return nil
+}
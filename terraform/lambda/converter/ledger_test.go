@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLedger(t *testing.T) {
+	ctx := context.Background()
+	ledger := newMemoryLedger()
+	obj := SourceObject{Bucket: "raw", Key: "logs/a.jsonl", ETag: `"abc"`}
+
+	processed, err := ledger.IsProcessed(ctx, obj)
+	require.NoError(t, err)
+	assert.False(t, processed)
+
+	require.NoError(t, ledger.MarkProcessed(ctx, obj))
+
+	processed, err = ledger.IsProcessed(ctx, obj)
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	// A new version of the same key is not processed yet
+	processed, err = ledger.IsProcessed(ctx, SourceObject{Bucket: "raw", Key: "logs/a.jsonl", ETag: `"def"`})
+	require.NoError(t, err)
+	assert.False(t, processed)
+}
+
+func TestS3Ledger(t *testing.T) {
+	ctx := context.Background()
+	mockS3 := new(MockS3API)
+	ledger := newS3Ledger(mockS3, "state-bucket", "processed/")
+
+	notYet := SourceObject{Bucket: "raw", Key: "logs/new.jsonl", ETag: `"111"`}
+	done := SourceObject{Bucket: "raw", Key: "logs/done.jsonl", ETag: `"222"`}
+
+	mockS3.On("GetObject", mock.Anything, &s3.GetObjectInput{
+		Bucket: aws.String("state-bucket"),
+		Key:    aws.String("processed/raw/logs/new.jsonl/111"),
+	}).Return((*s3.GetObjectOutput)(nil), &types.NoSuchKey{})
+	mockS3.On("GetObject", mock.Anything, &s3.GetObjectInput{
+		Bucket: aws.String("state-bucket"),
+		Key:    aws.String("processed/raw/logs/done.jsonl/222"),
+	}).Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(""))}, nil)
+	mockS3.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return aws.ToString(input.Bucket) == "state-bucket" &&
+			aws.ToString(input.Key) == "processed/raw/logs/new.jsonl/111"
+	})).Return(&s3.PutObjectOutput{}, nil)
+
+	processed, err := ledger.IsProcessed(ctx, notYet)
+	require.NoError(t, err)
+	assert.False(t, processed)
+
+	processed, err = ledger.IsProcessed(ctx, done)
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	require.NoError(t, ledger.MarkProcessed(ctx, notYet))
+	mockS3.AssertExpectations(t)
+}
+
+func TestS3Ledger_GetObjectError(t *testing.T) {
+	mockS3 := new(MockS3API)
+	ledger := newS3Ledger(mockS3, "state-bucket", "processed/")
+
+	mockS3.On("GetObject", mock.Anything, mock.Anything).Return((*s3.GetObjectOutput)(nil), assert.AnError)
+
+	_, err := ledger.IsProcessed(context.Background(), SourceObject{Bucket: "raw", Key: "a", ETag: "1"})
+	assert.Error(t, err)
+}
+
+func TestHandleSQSEvent_Redelivery(t *testing.T) {
+	os.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	defer os.Unsetenv("AWS_ACCOUNT_ID")
+
+	testData := `{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:30.123456Z","uniqueQualifier":"358068855354","applicationName":"drive","customerId":"C03az79cb"},"actor":{"callerType":"USER","email":"user@muhai-academy.com","profileId":"114511147312345678901"},"ownerDomain":"muhai-academy.com","ipAddress":"203.0.113.255","events":[{"type":"access","name":"view","parameters":[{"name":"doc_id","value":"1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE2upms"}]}]}`
+
+	mockS3 := new(MockS3API)
+	mockS3.On("GetObject", mock.Anything, &s3.GetObjectInput{
+		Bucket: aws.String("test-raw-logs-bucket"),
+		Key:    aws.String("logs/test-file.jsonl"),
+	}).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader(testData)),
+	}, nil).Once()
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil).Once()
+
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+		ledger:             newMemoryLedger(),
+	}
+
+	s3EventJSON, _ := json.Marshal(events.S3Event{
+		Records: []events.S3EventRecord{
+			{
+				S3: events.S3Entity{
+					Bucket: events.S3Bucket{Name: "test-raw-logs-bucket"},
+					Object: events.S3Object{Key: "logs/test-file.jsonl", ETag: "0123456789abcdef"},
+				},
+			},
+		},
+	})
+	body, _ := json.Marshal(map[string]string{"Message": string(s3EventJSON)})
+	sqsEvent := events.SQSEvent{
+		Records: []events.SQSMessage{{MessageId: "test-message-id", Body: string(body)}},
+	}
+
+	// First delivery converts the object, the second one must be a no-op
+	for i := 0; i < 2; i++ {
+		resp, err := handler.HandleSQSEvent(context.Background(), sqsEvent)
+		require.NoError(t, err)
+		assert.Empty(t, resp.BatchItemFailures)
+	}
+
+	mockS3.AssertNumberOfCalls(t, "GetObject", 1)
+	mockS3.AssertNumberOfCalls(t, "PutObject", 1)
+}
@@ -2,17 +2,24 @@ package main
 
 import (
 	"bytes"
-	"compress/gzip"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"net/url"
 	"os"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-converter/session"
+	"seccamp2025-b1-converter/validator"
+
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -25,6 +32,16 @@ type Handler struct {
 	securityLakeBucket string
 	region             string
 	customLogSource    string
+	sourceVersion      string              // custom log source version in the output path
+	additionalSources  []core.SourceLayout // layouts also written during a migration
+	partitionByHour    bool                // adds eventHour= below eventDay= in the output path
+	ledger             ProcessedLedger
+	stateBucket        string
+	convertOptions     core.ConvertOptions
+	enrichers          core.EnricherChain
+	sessions           SessionStore
+	sessionConfig      session.Config
+	validator          *validator.Validator
 }
 
 func init() {
@@ -64,156 +81,252 @@ func NewHandler() (*Handler, error) {
 		slog.Info("Custom log source configured", "source", customLogSource)
 	}
 
+	// Custom log source version and OCSF metadata. ADDITIONAL_LOG_SOURCES lists "name/version"
+	// layouts written together with the configured source while migrating to a new version.
+	sourceVersion := cmp.Or(os.Getenv("CUSTOM_LOG_SOURCE_VERSION"), core.DefaultSourceVersion)
+	additionalSources, err := core.ParseSourceLayouts(os.Getenv("ADDITIONAL_LOG_SOURCES"))
+	if err != nil {
+		slog.Error("Invalid ADDITIONAL_LOG_SOURCES", "error", err)
+		return nil, fmt.Errorf("invalid ADDITIONAL_LOG_SOURCES: %w", err)
+	}
+	// PARTITION_BY_HOUR adds an eventHour= partition below eventDay=. It is off by default,
+	// as Security Lake only defines the region, accountId and eventDay partitions.
+	var partitionByHour bool
+	if v := os.Getenv("PARTITION_BY_HOUR"); v != "" {
+		if partitionByHour, err = strconv.ParseBool(v); err != nil {
+			slog.Error("Invalid PARTITION_BY_HOUR", "value", v, "error", err)
+			return nil, fmt.Errorf("invalid PARTITION_BY_HOUR %q", v)
+		}
+	}
+	slog.Info("Output layout configured", "source_version", sourceVersion, "additional_sources", additionalSources, "partition_by_hour", partitionByHour)
+
+	s3Client := s3.NewFromConfig(cfg)
+
+	// Processed-object ledger for idempotent conversion. Markers are kept in the state
+	// bucket if configured, otherwise only within the lifetime of this Lambda instance.
+	// The state bucket also stores quarantined records and conversion reports.
+	var ledger ProcessedLedger
+	stateBucket := os.Getenv("CONVERTER_STATE_BUCKET")
+	if stateBucket != "" {
+		ledger = newS3Ledger(s3Client, stateBucket, "processed/")
+		slog.Info("Processed ledger configured", "type", "s3", "bucket", stateBucket)
+	} else {
+		ledger = newMemoryLedger()
+		slog.Warn("CONVERTER_STATE_BUCKET not set, using in-memory processed ledger")
+	}
+
+	// Sessionizer state is carried over between files in the state bucket if configured
+	sessionConfig := session.DefaultConfig()
+	if v := os.Getenv("SESSION_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			slog.Error("Invalid SESSION_TIMEOUT", "value", v, "error", err)
+			return nil, fmt.Errorf("invalid SESSION_TIMEOUT %q", v)
+		}
+		sessionConfig.InactivityTimeout = timeout
+	}
+	var sessions SessionStore
+	if stateBucket != "" {
+		sessions = newS3SessionStore(s3Client, stateBucket, "sessions/state.json")
+	} else {
+		sessions = newMemorySessionStore()
+	}
+	slog.Info("Sessionizer configured", "inactivity_timeout", sessionConfig.InactivityTimeout)
+
+	// Event mapping catalog. Override files (local path or s3://bucket/key) are applied on top
+	// of the embedded catalog.
+	var catalogOverrides []string
+	if paths := os.Getenv("EVENT_CATALOG_PATH"); paths != "" {
+		catalogOverrides = strings.Split(paths, ",")
+	}
+	catalog, err := core.LoadEventCatalog(context.TODO(), s3Client, catalogOverrides...)
+	if err != nil {
+		slog.Error("Failed to load event catalog", "error", err, "overrides", catalogOverrides)
+		return nil, fmt.Errorf("failed to load event catalog: %w", err)
+	}
+	slog.Info("Event catalog loaded", "entries", catalog.Entries(), "overrides", catalogOverrides)
+
+	// Resource classification rules. Rule files are added to the embedded rules.
+	var classificationRules []string
+	if paths := os.Getenv("CLASSIFICATION_RULES_PATH"); paths != "" {
+		classificationRules = strings.Split(paths, ",")
+	}
+	classifier, err := core.LoadResourceClassifier(context.TODO(), s3Client, classificationRules...)
+	if err != nil {
+		slog.Error("Failed to load classification rules", "error", err, "rules", classificationRules)
+		return nil, fmt.Errorf("failed to load classification rules: %w", err)
+	}
+	slog.Info("Classification rules loaded", "rules", classificationRules)
+
+	// GeoIP enrichment by MaxMind DB (local path or s3://bucket/key). The static prefix
+	// table of test data is used if not configured, and as fallback of the database.
+	opts := core.ConvertOptions{
+		Catalog:       catalog,
+		Classifier:    classifier,
+		OCSFVersion:   cmp.Or(os.Getenv("OCSF_VERSION"), core.DefaultOCSFVersion),
+		ProductName:   cmp.Or(os.Getenv("PRODUCT_NAME"), core.DefaultProductName),
+		ProductVendor: cmp.Or(os.Getenv("PRODUCT_VENDOR"), core.DefaultProductVendor),
+	}
+	slog.Info("OCSF metadata configured", "version", opts.OCSFVersion, "product", opts.ProductName, "vendor", opts.ProductVendor)
+	if cityDB := os.Getenv("GEOIP_CITY_DB"); cityDB != "" {
+		asnDB := os.Getenv("GEOIP_ASN_DB")
+		resolver, err := core.NewGeoIPResolver(context.TODO(), s3Client, cityDB, asnDB)
+		if err != nil {
+			slog.Error("Failed to load GeoIP database", "error", err, "city_db", cityDB, "asn_db", asnDB)
+			return nil, fmt.Errorf("failed to load GeoIP database: %w", err)
+		}
+		opts.GeoIP = resolver
+		slog.Info("GeoIP database loaded", "city_db", cityDB, "asn_db", asnDB)
+	} else {
+		slog.Warn("GEOIP_CITY_DB not set, using static IP prefix table for location")
+	}
+
+	// Enrichers add directory and threat intelligence context. Each data is given by local
+	// path or s3://bucket/key, and the enricher is disabled if not set.
+	if os.Getenv("ENRICH_RESOURCE_SENSITIVITY") != "" {
+		return nil, fmt.Errorf("ENRICH_RESOURCE_SENSITIVITY is no longer supported, use path_prefix rules of CLASSIFICATION_RULES_PATH")
+	}
+	userDirectory := os.Getenv("ENRICH_USER_DIRECTORY")
+	ipIntel := os.Getenv("ENRICH_IP_INTEL")
+	enrichers, err := core.LoadEnrichers(context.TODO(), s3Client, userDirectory, ipIntel)
+	if err != nil {
+		slog.Error("Failed to load enrichers", "error", err)
+		return nil, fmt.Errorf("failed to load enrichers: %w", err)
+	}
+	slog.Info("Enrichers loaded", "count", len(enrichers), "user_directory", userDirectory, "ip_intel", ipIntel)
+
+	// OCSF validation of records before writing. Invalid records are quarantined.
+	ocsfValidator, err := core.NewValidator(os.Getenv("OCSF_VALIDATION"))
+	if err != nil {
+		slog.Error("Failed to create OCSF validator", "error", err)
+		return nil, fmt.Errorf("failed to create OCSF validator: %w", err)
+	}
+	slog.Info("OCSF validation configured", "mode", os.Getenv("OCSF_VALIDATION"), "enabled", ocsfValidator != nil)
+
 	handler := &Handler{
-		s3Client:           s3.NewFromConfig(cfg),
+		s3Client:           s3Client,
 		securityLakeBucket: securityLakeBucket,
 		region:             region,
 		customLogSource:    customLogSource,
+		sourceVersion:      sourceVersion,
+		additionalSources:  additionalSources,
+		partitionByHour:    partitionByHour,
+		ledger:             ledger,
+		stateBucket:        stateBucket,
+		convertOptions:     opts,
+		enrichers:          enrichers,
+		sessions:           sessions,
+		sessionConfig:      sessionConfig,
+		validator:          ocsfValidator,
 	}
 	slog.Info("Converter handler initialized successfully")
 	return handler, nil
 }
 
-func (h *Handler) HandleSQSEvent(ctx context.Context, event events.SQSEvent) error {
+// HandleSQSEvent processes each SQS record independently and reports only the failed
+// messages as BatchItemFailures, so that successful records in the batch are not retried.
+func (h *Handler) HandleSQSEvent(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
 	slog.Info("Received SQS event", "event", event)
+	var resp events.SQSEventResponse
 	for i, record := range event.Records {
 		slog.Info("Processing SQS record", "index", i+1, "total", len(event.Records), "message_id", record.MessageId)
-		if err := h.processRecord(ctx, record); err != nil {
+		if err := h.processRecordSafe(ctx, record); err != nil {
 			slog.Error("Error processing record", "message_id", record.MessageId, "error", err)
-			return err
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: record.MessageId,
+			})
+			continue
 		}
 		slog.Info("Successfully processed SQS record", "index", i+1, "total", len(event.Records))
 	}
-	slog.Info("Successfully processed all SQS records", "total", len(event.Records))
-	return nil
+	slog.Info("Finished processing SQS records", "total", len(event.Records), "failed", len(resp.BatchItemFailures))
+	return resp, nil
+}
+
+// processRecordSafe runs processRecord and converts a panic into an error of the record
+func (h *Handler) processRecordSafe(ctx context.Context, record events.SQSMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Panic during record processing", "message_id", record.MessageId, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic during record processing: %v", r)
+		}
+	}()
+	return h.processRecord(ctx, record)
 }
 
+// processRecord converts objects referenced by the SQS message body, which is an S3 event,
+// an SNS envelope or EventBridge event wrapping it, or a manual invocation payload
 func (h *Handler) processRecord(ctx context.Context, record events.SQSMessage) error {
-	// Debug: Log the raw SQS message body
 	slog.Info("Raw SQS message received", "body", record.Body)
 
-	// Try to detect the message format first
-	// Check if it's a direct SNS message or wrapped
-	var messageBody string
-	var tempMap map[string]interface{}
-	if err := json.Unmarshal([]byte(record.Body), &tempMap); err != nil {
-		slog.Error("Failed to parse SQS body as JSON", "error", err, "body", record.Body)
-		return fmt.Errorf("failed to parse SQS body as JSON: %w", err)
-	}
-
-	// Check if this is a wrapped SNS message (SQS receiving from SNS)
-	if message, exists := tempMap["Message"]; exists {
-		// This is a wrapped SNS message containing an S3 event
-		if msgStr, ok := message.(string); ok {
-			slog.Info("Found wrapped SNS message, parsing as S3 event", "message", msgStr)
-			// Parse directly as S3 event since SNS Message contains S3 event JSON
-			var s3Event events.S3Event
-			if err := json.Unmarshal([]byte(msgStr), &s3Event); err != nil {
-				slog.Error("Failed to parse S3 event from SNS Message", "error", err, "message", msgStr)
-				return fmt.Errorf("failed to parse S3 event from SNS Message: %w", err)
-			}
-
-			if len(s3Event.Records) == 0 {
-				slog.Warn("No S3 records found in SNS message")
-				return nil
-			}
-
-			slog.Info("Successfully parsed S3 event from SNS", "s3_records", len(s3Event.Records))
-			// Process S3 records directly
-			for i, s3Record := range s3Event.Records {
-				slog.Info("Processing S3 record", "index", i+1, "total", len(s3Event.Records), "event", s3Record.EventName)
-				if err := h.processS3Record(ctx, s3Record); err != nil {
-					slog.Error("Failed to process S3 record", "index", i+1, "error", err)
-					return err
-				}
-				slog.Info("Successfully processed S3 record", "index", i+1, "total", len(s3Event.Records))
-			}
-			return nil
-		} else {
-			slog.Error("Message field is not a string", "type", fmt.Sprintf("%T", message))
-			return fmt.Errorf("message field is not a string")
-		}
+	targets, err := parseTrigger([]byte(record.Body))
+	if err != nil {
+		slog.Error("Failed to parse SQS message body", "error", err, "body", record.Body)
+		return err
+	}
+	if len(targets) == 0 {
+		slog.Warn("No S3 objects found in SQS message")
+		return nil
 	}
 
-	// This might be a direct SNS event (fallback)
-	messageBody = record.Body
-	slog.Info("Using direct SQS body as SNS message")
-
-	// Parse SNS message - try different formats
-	slog.Info("Parsing SNS message", "message_body", messageBody)
+	_, err = h.processTargets(ctx, targets)
+	return err
+}
 
-	// First try to parse as SNSEvent (array of records)
-	var snsEvent events.SNSEvent
-	if err := json.Unmarshal([]byte(messageBody), &snsEvent); err == nil && len(snsEvent.Records) > 0 {
-		slog.Info("Parsed as SNSEvent with records", "count", len(snsEvent.Records))
-	} else {
-		// Try to parse as a single SNS record
-		var snsRecord events.SNSEventRecord
-		if err := json.Unmarshal([]byte(messageBody), &snsRecord); err == nil {
-			slog.Info("Parsed as single SNS record")
-			snsEvent.Records = []events.SNSEventRecord{snsRecord}
-		} else {
-			// Try to parse as direct S3 event (bypassing SNS structure)
-			var s3Event events.S3Event
-			if err := json.Unmarshal([]byte(messageBody), &s3Event); err == nil && len(s3Event.Records) > 0 {
-				slog.Info("Parsed as direct S3 event, bypassing SNS", "s3_records", len(s3Event.Records))
-				// Process S3 records directly
-				for i, s3Record := range s3Event.Records {
-					slog.Info("Processing S3 record directly", "index", i+1, "total", len(s3Event.Records), "event", s3Record.EventName)
-					if err := h.processS3Record(ctx, s3Record); err != nil {
-						slog.Error("Failed to process S3 record", "index", i+1, "error", err)
-						return err
-					}
-					slog.Info("Successfully processed S3 record", "index", i+1, "total", len(s3Event.Records))
-				}
-				return nil
-			} else {
-				slog.Error("Failed to parse message in any format", "sns_error", err, "message_body", messageBody)
-				return fmt.Errorf("failed to parse message in any supported format")
-			}
+// processObject converts an object unless the ledger has it, and marks it as processed.
+// Reprocess bypasses the ledger check. It returns false if the object was skipped.
+func (h *Handler) processObject(ctx context.Context, obj SourceObject, reprocess bool) (bool, error) {
+	if obj.ETag != "" && !reprocess {
+		processed, err := h.isProcessed(ctx, obj)
+		if err != nil {
+			return false, err
+		}
+		if processed {
+			slog.Info("S3 object already processed, skipping", "bucket", obj.Bucket, "key", obj.Key, "etag", obj.ETag)
+			return false, nil
 		}
 	}
-	slog.Info("Found SNS records to process", "count", len(snsEvent.Records))
 
-	// Process each SNS record
-	for _, snsRecord := range snsEvent.Records {
-		if err := h.processSNSRecord(ctx, snsRecord); err != nil {
-			return err
+	if err := h.convertObject(ctx, &obj, reprocess); err != nil {
+		if errors.Is(err, errAlreadyProcessed) {
+			return false, nil
 		}
+		return false, err
 	}
 
-	return nil
-}
-
-func (h *Handler) processSNSRecord(ctx context.Context, record events.SNSEventRecord) error {
-	// Parse S3 event from SNS message
-	slog.Info("Parsing S3 event from SNS message", "subject", record.SNS.Subject)
-	var s3Event events.S3Event
-	if err := json.Unmarshal([]byte(record.SNS.Message), &s3Event); err != nil {
-		slog.Error("Failed to parse S3 event from SNS message", "error", err, "message", record.SNS.Message)
-		return fmt.Errorf("failed to parse S3 event: %w", err)
-	}
-	slog.Info("Found S3 records in SNS message", "count", len(s3Event.Records))
-
-	// Process each S3 record
-	for i, s3Record := range s3Event.Records {
-		slog.Info("Processing S3 record", "index", i+1, "total", len(s3Event.Records), "event", s3Record.EventName)
-		if err := h.processS3Record(ctx, s3Record); err != nil {
-			slog.Error("Failed to process S3 record", "index", i+1, "error", err)
-			return err
+	if h.ledger != nil && obj.ETag != "" {
+		if err := h.ledger.MarkProcessed(ctx, obj); err != nil {
+			slog.Error("Failed to mark S3 object as processed", "error", err, "bucket", obj.Bucket, "key", obj.Key)
+			return false, err
 		}
-		slog.Info("Successfully processed S3 record", "index", i+1, "total", len(s3Event.Records))
+		slog.Info("Marked S3 object as processed", "bucket", obj.Bucket, "key", obj.Key, "etag", obj.ETag)
 	}
 
-	return nil
+	return true, nil
 }
 
-func (h *Handler) processS3Record(ctx context.Context, record events.S3EventRecord) error {
-	bucket := record.S3.Bucket.Name
-	key, err := url.QueryUnescape(record.S3.Object.Key)
+// errAlreadyProcessed is returned by convertObject when the object is found in the ledger
+// after its ETag is known from the GetObject response
+var errAlreadyProcessed = errors.New("object is already processed")
+
+// isProcessed checks the processed-object ledger. It always returns false if no ledger is configured.
+func (h *Handler) isProcessed(ctx context.Context, obj SourceObject) (bool, error) {
+	if h.ledger == nil {
+		return false, nil
+	}
+	processed, err := h.ledger.IsProcessed(ctx, obj)
 	if err != nil {
-		return fmt.Errorf("failed to decode S3 object key: %w", err)
+		slog.Error("Failed to check processed ledger", "error", err, "bucket", obj.Bucket, "key", obj.Key)
+		return false, err
 	}
+	return processed, nil
+}
+
+// convertObject downloads a raw log object, converts it to OCSF and uploads Parquet files.
+// obj.ETag is filled from the GetObject response if it was not known from the event, and
+// errAlreadyProcessed is returned if the ledger has it unless reprocess is set.
+func (h *Handler) convertObject(ctx context.Context, obj *SourceObject, reprocess bool) error {
+	bucket, key := obj.Bucket, obj.Key
 
 	slog.Info("Processing S3 object", "bucket", bucket, "key", key)
 	slog.Info("Configuration", "security_lake_bucket", h.securityLakeBucket, "region", h.region, "custom_log_source", h.customLogSource)
@@ -235,103 +348,120 @@ func (h *Handler) processS3Record(ctx context.Context, record events.S3EventReco
 	}
 	slog.Info("Successfully downloaded file", "content_length", contentLength)
 
-	// Parse JSON/JSONL file containing Google Workspace logs
-	slog.Info("Starting to parse JSON/JSONL file", "file_key", key)
-	
-	// Check if file is gzip compressed by file extension
-	var reader io.Reader = resp.Body
-	if strings.HasSuffix(key, ".gz") {
-		slog.Info("File is gzip compressed, decompressing", "file_key", key)
-		gzipReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			slog.Error("Failed to create gzip reader", "error", err, "file_key", key)
-			return fmt.Errorf("failed to create gzip reader for %s: %w", key, err)
-		}
-		defer gzipReader.Close()
-		reader = gzipReader
-	}
-	
-	var gwLogs []GoogleWorkspaceLog
-	decoder := json.NewDecoder(reader)
-	lineNum := 0
-
-	errorCount := 0
-	maxErrors := 100 // Limit consecutive errors to prevent infinite loops
-	
-	for {
-		lineNum++
-		var gwLog GoogleWorkspaceLog
-		err := decoder.Decode(&gwLog)
-		if err == io.EOF {
-			slog.Info("Reached end of file", "total_lines", lineNum-1, "parsed_logs", len(gwLogs))
-			break
-		}
+	if obj.ETag == "" && resp.ETag != nil {
+		obj.ETag = aws.ToString(resp.ETag)
+		processed, err := h.isProcessed(ctx, *obj)
 		if err != nil {
-			errorCount++
-			slog.Warn("Failed to parse JSON at line", "line", lineNum, "error", err, "error_count", errorCount)
-			
-			// If we hit too many consecutive errors, it's likely the file format is wrong
-			if errorCount > maxErrors {
-				slog.Error("Too many consecutive JSON parsing errors, stopping", "max_errors", maxErrors, "file_key", key)
-				return fmt.Errorf("too many consecutive JSON parsing errors (%d) in file %s", errorCount, key)
-			}
-			
-			// Try to skip to next valid JSON if this is JSONL with a corrupt line
-			continue
+			return err
 		}
-		
-		// Reset error count on successful parse
-		errorCount = 0
-		gwLogs = append(gwLogs, gwLog)
-		
-		// Log progress for very large files
-		if lineNum%10000 == 0 {
-			slog.Info("Processing progress", "lines_processed", lineNum, "logs_parsed", len(gwLogs))
+		if processed && !reprocess {
+			slog.Info("S3 object already processed, skipping", "bucket", bucket, "key", key, "etag", obj.ETag)
+			return errAlreadyProcessed
 		}
 	}
 
-	slog.Info("Parsed Google Workspace logs", "count", len(gwLogs), "file", key)
-	if len(gwLogs) == 0 {
-		slog.Warn("No valid logs found in file", "file", key)
-		return nil
+	// Read whole object to derive a content hash for deterministic output names
+	rawData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		slog.Error("Failed to read object body", "error", err, "file_key", key)
+		return fmt.Errorf("failed to read object body of %s: %w", key, err)
 	}
+	sourceHash := core.ContentHash(rawData)
 
-	// Get AWS account ID
+	// Decode, convert, enrich and validate the records. Records which fail any of the
+	// steps are quarantined instead of failing the whole file.
+	slog.Info("Starting to parse file", "file_key", key, "content_hash", sourceHash)
 	accountID := os.Getenv("AWS_ACCOUNT_ID")
 	slog.Info("AWS Account ID", "account_id", accountID)
-	if accountID == "" {
+	converter := core.Converter{
+		Region:    h.region,
+		AccountID: accountID,
+		Options:   h.convertOptions,
+		Enrichers: h.enrichers,
+		Validator: h.validator,
+	}
+	result, err := converter.Convert(ctx, key, rawData)
+	if errors.Is(err, core.ErrAccountIDRequired) {
 		slog.Error("AWS_ACCOUNT_ID environment variable is not set")
 		return fmt.Errorf("AWS_ACCOUNT_ID environment variable is required")
 	}
+	if err != nil {
+		slog.Error("Failed to convert file", "error", err, "file_key", key)
+		return err
+	}
+	ocsfLogs, quarantined := result.Logs, result.Quarantined
+	for i := range quarantined {
+		quarantined[i].SourceBucket = bucket
+	}
 
-	// Convert to OCSF format
-	slog.Info("Converting logs to OCSF format", "count", len(gwLogs))
-	var ocsfLogs []OCSFWebResourceActivity
-	convertedCount := 0
-	for i, gwLog := range gwLogs {
-		ocsfLog, err := ConvertToOCSF(&gwLog, h.region, accountID)
-		if err != nil {
-			slog.Error("Failed to convert log to OCSF format", "index", i+1, "error", err)
-			continue
-		}
-		ocsfLogs = append(ocsfLogs, *ocsfLog)
-		convertedCount++
+	report := &core.ConversionReport{
+		SourceBucket: bucket,
+		SourceKey:    key,
+		SourceETag:   obj.ETag,
+		ContentHash:  sourceHash,
+		Format:       result.Format,
+		Parsed:       result.Parsed,
+		Outputs:      []string{},
 	}
-	slog.Info("Successfully converted logs to OCSF format", "converted", convertedCount, "total", len(gwLogs))
 
-	if len(ocsfLogs) == 0 {
-		slog.Warn("No OCSF logs to process after conversion, skipping file upload")
+	if result.Parsed == 0 && len(quarantined) == 0 {
+		slog.Warn("No valid logs found in file", "file", key)
 		return nil
 	}
 
-	// Generate Parquet file
-	slog.Info("Generating Parquet file", "ocsf_log_count", len(ocsfLogs))
-	parquetData, err := h.generateOCSFParquetFile(ocsfLogs)
+	saveSessions, err := h.sessionize(ctx, ocsfLogs)
 	if err != nil {
-		return fmt.Errorf("failed to generate parquet file: %w", err)
+		return fmt.Errorf("failed to sessionize logs: %w", err)
+	}
+	report.Converted = len(ocsfLogs)
+	report.Quarantined = len(quarantined)
+
+	if len(ocsfLogs) == 0 {
+		slog.Warn("No OCSF logs to process after conversion, skipping file upload")
+	} else {
+		outputs, err := h.uploadPartitions(ctx, key, sourceHash, ocsfLogs)
+		if err != nil {
+			return err
+		}
+		report.Outputs = outputs
+	}
+
+	if h.stateBucket == "" {
+		slog.Warn("State bucket is not configured, quarantine records and report are only logged",
+			"file", key, "parsed", report.Parsed, "converted", report.Converted, "quarantined", report.Quarantined)
+		for _, record := range quarantined {
+			slog.Warn("Quarantined record", "line", record.LineNumber, "stage", record.Stage, "reason", record.Reason)
+		}
+	} else {
+		if len(quarantined) > 0 {
+			report.Quarantine = core.QuarantineKey(key, sourceHash)
+			if err := h.writeQuarantine(ctx, report.Quarantine, quarantined); err != nil {
+				return err
+			}
+		}
+
+		report.ProcessedAt = time.Now().UTC()
+		if err := h.writeReport(ctx, core.ReportKey(key, sourceHash), report); err != nil {
+			return err
+		}
+	}
+
+	// The session state is saved after all outputs, so a retry after a failure above
+	// converts the file from the same state. A reprocessed file was already counted in the
+	// state, which is left as is.
+	if reprocess {
+		slog.Info("Reprocessed file, session state is not saved", "file", key)
+		return nil
+	}
+	if err := saveSessions(ctx); err != nil {
+		return fmt.Errorf("failed to save session state: %w", err)
 	}
-	slog.Info("Generated Parquet file", "size_bytes", len(parquetData))
+	return nil
+}
 
+// uploadPartitions writes one Parquet object per event day (or hour) partition to the Security Lake bucket
+// and returns keys of the uploaded objects
+func (h *Handler) uploadPartitions(ctx context.Context, sourceKey, sourceHash string, ocsfLogs []core.OCSFWebResourceActivity) ([]string, error) {
 	// Extract bucket name from ARN if needed
 	securityLakeBucket := h.securityLakeBucket
 	slog.Info("Processing Security Lake bucket", "original", securityLakeBucket)
@@ -340,48 +470,55 @@ func (h *Handler) processS3Record(ctx context.Context, record events.S3EventReco
 		slog.Info("Extracted bucket name from ARN", "bucket", securityLakeBucket)
 	}
 
-	// Generate Security Lake compliant path for custom log source
-	// Custom log source path format: ext/{customSourceName}/{version}/region={region}/accountId={accountId}/eventDay={YYYYMMDD}/
-	now := time.Now().UTC()
-	
-	// Clean up the filename - remove extensions and path separators
-	baseFileName := key
-	// Remove .gz extension if present
-	baseFileName = strings.TrimSuffix(baseFileName, ".gz")
-	// Remove .jsonl extension if present
-	baseFileName = strings.TrimSuffix(baseFileName, ".jsonl")
-	// Replace path separators
-	baseFileName = strings.ReplaceAll(baseFileName, "/", "_")
-	
-	securityLakeKey := fmt.Sprintf("ext/%s/1.0/region=%s/accountId=%s/eventDay=%s/%s_%s.parquet",
-		h.customLogSource,
-		h.region,
-		accountID,
-		now.Format("20060102"),
-		now.Format("20060102150405"),
-		baseFileName)
-	slog.Info("Generated Security Lake key", "key", securityLakeKey)
-
-	// Upload to Security Lake S3 bucket
-	slog.Info("Uploading to Security Lake S3 bucket", "bucket", securityLakeBucket, "key", securityLakeKey)
-	putResp, err := h.s3Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(securityLakeBucket),
-		Key:         aws.String(securityLakeKey),
-		Body:        bytes.NewReader(parquetData),
-		ContentType: aws.String("application/octet-stream"),
-	})
-	if err != nil {
-		slog.Error("Failed to upload to Security Lake S3", "error", err, "bucket", securityLakeBucket, "key", securityLakeKey)
-		return fmt.Errorf("failed to upload parquet file to Security Lake: %w", err)
+	partitions := core.PartitionLogs(ocsfLogs, h.partitionByHour)
+	slog.Info("Split OCSF logs into partitions", "partitions", len(partitions), "ocsf_log_count", len(ocsfLogs))
+
+	var outputs []string
+	for _, p := range partitions {
+		// Generate Parquet file
+		slog.Info("Generating Parquet file", "event_day", p.Key.EventDay, "event_hour", p.Key.EventHour, "ocsf_log_count", len(p.Logs))
+		parquetData, err := core.GenerateParquet(p.Logs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate parquet file: %w", err)
+		}
+		slog.Info("Generated Parquet file", "size_bytes", len(parquetData))
+
+		// The same file is written to all layouts during migration of the source version
+		for _, layout := range h.outputLayouts() {
+			securityLakeKey := core.BuildSecurityLakeKey(layout, p.Key, sourceKey, sourceHash)
+			slog.Info("Generated Security Lake key", "key", securityLakeKey)
+
+			// Upload to Security Lake S3 bucket
+			slog.Info("Uploading to Security Lake S3 bucket", "bucket", securityLakeBucket, "key", securityLakeKey)
+			putResp, err := h.s3Client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket:      aws.String(securityLakeBucket),
+				Key:         aws.String(securityLakeKey),
+				Body:        bytes.NewReader(parquetData),
+				ContentType: aws.String("application/octet-stream"),
+			})
+			if err != nil {
+				slog.Error("Failed to upload to Security Lake S3", "error", err, "bucket", securityLakeBucket, "key", securityLakeKey)
+				return nil, fmt.Errorf("failed to upload parquet file to Security Lake: %w", err)
+			}
+
+			slog.Info("Successfully uploaded parquet file to Security Lake", "bucket", securityLakeBucket, "key", securityLakeKey, "etag", aws.ToString(putResp.ETag))
+			outputs = append(outputs, securityLakeKey)
+		}
 	}
 
-	slog.Info("Successfully uploaded parquet file to Security Lake", "bucket", securityLakeBucket, "key", securityLakeKey, "etag", aws.ToString(putResp.ETag))
-	return nil
+	return outputs, nil
 }
 
-func (h *Handler) generateOCSFParquetFile(logs []OCSFWebResourceActivity) ([]byte, error) {
-	// Use Apache Arrow implementation
-	return generateOCSFParquetFileArrow(logs)
+// outputLayouts returns the custom source layouts to write, the configured source first
+// and then additional layouts kept during a migration
+func (h *Handler) outputLayouts() []core.SourceLayout {
+	layouts := []core.SourceLayout{{Name: h.customLogSource, Version: cmp.Or(h.sourceVersion, core.DefaultSourceVersion)}}
+	for _, layout := range h.additionalSources {
+		if !slices.Contains(layouts, layout) {
+			layouts = append(layouts, layout)
+		}
+	}
+	return layouts
 }
 
 func main() {
@@ -394,7 +531,7 @@ func main() {
 	}()
 
 	slog.Info("Starting converter main function")
-	
+
 	handler, err := NewHandler()
 	if err != nil {
 		slog.Error("Failed to create handler", "error", err)
@@ -402,18 +539,14 @@ func main() {
 	}
 
 	slog.Info("Starting Lambda function")
-	
-	// Wrap handler with error catching
-	wrappedHandler := func(ctx context.Context, event events.SQSEvent) error {
-		defer func() {
-			if r := recover(); r != nil {
-				slog.Error("Panic during event processing", "panic", r)
-			}
-		}()
-		
+
+	// Wrap handler with error catching. Panics of each record are handled in
+	// HandleSQSEvent and reported as batch item failures. S3, EventBridge and manual
+	// invocations are dispatched by HandleEvent.
+	wrappedHandler := func(ctx context.Context, payload json.RawMessage) (any, error) {
 		slog.Info("Lambda invocation started")
-		return handler.HandleSQSEvent(ctx, event)
+		return handler.HandleEvent(ctx, payload)
 	}
-	
+
 	lambda.Start(wrappedHandler)
 }
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"testing"
 
+	"seccamp2025-b1-converter/core"
+
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -100,10 +102,11 @@ func TestHandleSQSEvent_ValidEvent(t *testing.T) {
 	}
 
 	// Execute
-	err := handler.HandleSQSEvent(context.Background(), sqsEvent)
+	resp, err := handler.HandleSQSEvent(context.Background(), sqsEvent)
 
 	// Verify
 	require.NoError(t, err)
+	assert.Empty(t, resp.BatchItemFailures)
 	mockS3.AssertExpectations(t)
 }
 
@@ -128,10 +131,12 @@ func TestHandleSQSEvent_InvalidJSON(t *testing.T) {
 	}
 
 	// Execute
-	err := handler.HandleSQSEvent(context.Background(), sqsEvent)
+	resp, err := handler.HandleSQSEvent(context.Background(), sqsEvent)
 
-	// Should return error for invalid JSON
-	assert.Error(t, err)
+	// Should report the message with invalid JSON as a failed item
+	require.NoError(t, err)
+	require.Len(t, resp.BatchItemFailures, 1)
+	assert.Equal(t, "test-message-id", resp.BatchItemFailures[0].ItemIdentifier)
 	mockS3.AssertExpectations(t)
 }
 
@@ -199,10 +204,12 @@ func TestHandleSQSEvent_S3GetObjectError(t *testing.T) {
 	}
 
 	// Execute
-	err := handler.HandleSQSEvent(context.Background(), sqsEvent)
+	resp, err := handler.HandleSQSEvent(context.Background(), sqsEvent)
 
-	// Should return error when S3 GetObject fails
-	assert.Error(t, err)
+	// Should report the message as a failed item when S3 GetObject fails
+	require.NoError(t, err)
+	require.Len(t, resp.BatchItemFailures, 1)
+	assert.Equal(t, "test-message-id", resp.BatchItemFailures[0].ItemIdentifier)
 	mockS3.AssertExpectations(t)
 }
 
@@ -274,13 +281,79 @@ func TestHandleSQSEvent_EmptyFile(t *testing.T) {
 	}
 
 	// Execute
-	err := handler.HandleSQSEvent(context.Background(), sqsEvent)
+	resp, err := handler.HandleSQSEvent(context.Background(), sqsEvent)
 
-	// Should not return error, but also shouldn't call PutObject
+	// Should not report failure, but also shouldn't call PutObject
 	require.NoError(t, err)
+	assert.Empty(t, resp.BatchItemFailures)
 	mockS3.AssertExpectations(t)
 }
 
+func TestHandleSQSEvent_PartialBatchFailure(t *testing.T) {
+	os.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	defer os.Unsetenv("AWS_ACCOUNT_ID")
+
+	mockS3 := new(MockS3API)
+
+	testData := `{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:30.123456Z","uniqueQualifier":"358068855354","applicationName":"drive","customerId":"C03az79cb"},"actor":{"callerType":"USER","email":"user@muhai-academy.com","profileId":"114511147312345678901"},"ownerDomain":"muhai-academy.com","ipAddress":"203.0.113.255","events":[{"type":"access","name":"view","parameters":[{"name":"doc_id","value":"1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE2upms"}]}]}`
+
+	for _, key := range []string{"logs/good-1.jsonl", "logs/good-2.jsonl"} {
+		mockS3.On("GetObject", mock.Anything, &s3.GetObjectInput{
+			Bucket: aws.String("test-raw-logs-bucket"),
+			Key:    aws.String(key),
+		}).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(strings.NewReader(testData)),
+		}, nil)
+	}
+	// Returning neither output nor error makes processing panic
+	mockS3.On("GetObject", mock.Anything, &s3.GetObjectInput{
+		Bucket: aws.String("test-raw-logs-bucket"),
+		Key:    aws.String("logs/panic.jsonl"),
+	}).Return((*s3.GetObjectOutput)(nil), nil)
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+	}
+
+	newMessage := func(id, key string) events.SQSMessage {
+		s3EventJSON, _ := json.Marshal(events.S3Event{
+			Records: []events.S3EventRecord{
+				{
+					S3: events.S3Entity{
+						Bucket: events.S3Bucket{Name: "test-raw-logs-bucket"},
+						Object: events.S3Object{Key: key},
+					},
+				},
+			},
+		})
+		body, _ := json.Marshal(map[string]string{"Message": string(s3EventJSON)})
+		return events.SQSMessage{MessageId: id, Body: string(body)}
+	}
+
+	sqsEvent := events.SQSEvent{
+		Records: []events.SQSMessage{
+			newMessage("msg-good-1", "logs/good-1.jsonl"),
+			{MessageId: "msg-invalid", Body: "invalid json"},
+			newMessage("msg-panic", "logs/panic.jsonl"),
+			newMessage("msg-good-2", "logs/good-2.jsonl"),
+		},
+	}
+
+	resp, err := handler.HandleSQSEvent(context.Background(), sqsEvent)
+	require.NoError(t, err)
+
+	var failed []string
+	for _, f := range resp.BatchItemFailures {
+		failed = append(failed, f.ItemIdentifier)
+	}
+	assert.Equal(t, []string{"msg-invalid", "msg-panic"}, failed)
+	mockS3.AssertNumberOfCalls(t, "PutObject", 2)
+}
+
 func TestNewHandler_MissingEnvironmentVariables(t *testing.T) {
 	// Clear environment variables
 	os.Unsetenv("SECURITY_LAKE_BUCKET")
@@ -289,3 +362,42 @@ func TestNewHandler_MissingEnvironmentVariables(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "SECURITY_LAKE_BUCKET environment variable is required")
 }
+
+func TestConvertObject_ReportsPage(t *testing.T) {
+	os.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	defer os.Unsetenv("AWS_ACCOUNT_ID")
+
+	const (
+		log1 = `{"kind":"admin#reports#activity","id":{"time":"2024-08-12T10:15:30Z","uniqueQualifier":"1","applicationName":"drive","customerId":"C03az79cb"},"actor":{"email":"user@muhai-academy.com"},"ipAddress":"203.0.113.1","events":[{"type":"access","name":"view","parameters":[{"name":"doc_id","value":"doc-1"}]}]}`
+		log2 = `{"kind":"admin#reports#activity","id":{"time":"2024-08-12T10:16:30Z","uniqueQualifier":"2","applicationName":"login","customerId":"C03az79cb"},"actor":{"email":"user@muhai-academy.com"},"ipAddress":"203.0.113.1","events":[{"type":"login","name":"login_success"}]}`
+	)
+	testData := `{"kind":"admin#reports#activities","items":[` + log1 + "," + log2 + `]}`
+	mockS3 := new(MockS3API)
+	mockS3.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader(testData)),
+	}, nil)
+	uploaded := map[string][]byte{}
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*s3.PutObjectInput)
+		body, _ := io.ReadAll(input.Body)
+		uploaded[aws.ToString(input.Bucket)+"/"+aws.ToString(input.Key)] = body
+	}).Return(&s3.PutObjectOutput{}, nil)
+
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+		stateBucket:        "state-bucket",
+	}
+	obj := SourceObject{Bucket: "raw", Key: "exports/page.json"}
+	require.NoError(t, handler.convertObject(context.Background(), &obj, false))
+
+	hash := core.ContentHash([]byte(testData))
+	var report core.ConversionReport
+	require.NoError(t, json.Unmarshal(uploaded["state-bucket/"+core.ReportKey(obj.Key, hash)], &report))
+	assert.Equal(t, core.InputFormatReportsPage, report.Format)
+	assert.Equal(t, 2, report.Converted)
+	require.Len(t, report.Outputs, 1)
+	assert.Contains(t, report.Outputs[0], "/exports_page_"+hash+".parquet")
+}
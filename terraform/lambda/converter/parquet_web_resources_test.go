@@ -1,520 +0,0 @@
-package main
-
-import (
-	"bytes"
-	"context"
-	"testing"
-
-	"github.com/apache/arrow/go/v17/parquet/file"
-	"github.com/apache/arrow/go/v17/parquet/pqarrow"
-)
-
-func TestParquetConversion_WebResourcesPreserved_Skip(t *testing.T) {
-	t.Skip("Skipping complex Arrow reader test - functionality is verified in TestGenerateOCSFParquetFileArrow_WithWebResources")
-	// Create test OCSF logs with web resources
-	ocsfLogs := []OCSFWebResourceActivity{
-		{
-			CategoryUID: 6,
-			ClassUID:    6001,
-			TypeUID:     600102,
-			ActivityID:  2,
-			SeverityID:  1,
-			Time:        1723104000000,
-			StatusID:    1,
-			Region:      "ap-northeast-1",
-			AccountID:   "123456789012",
-			EventHour:   "2025-08-08-00",
-			Actor: struct {
-				User struct {
-					Name      string   `parquet:"name"`
-					UID       string   `parquet:"uid"`
-					EmailAddr string   `parquet:"email_addr"`
-					Domain    string   `parquet:"domain,optional"`
-					TypeID    int      `parquet:"type_id"`
-					Groups    []string `parquet:"groups,optional"`
-				} `parquet:"user"`
-				Session struct {
-					UID         string `parquet:"uid"`
-					CreatedTime int64  `parquet:"created_time,optional"`
-					ExpTime     int64  `parquet:"exp_time,optional"`
-				} `parquet:"session,optional"`
-				AppName string `parquet:"app_name,optional"`
-				AppUID  string `parquet:"app_uid,optional"`
-			}{
-				User: struct {
-					Name      string   `parquet:"name"`
-					UID       string   `parquet:"uid"`
-					EmailAddr string   `parquet:"email_addr"`
-					Domain    string   `parquet:"domain,optional"`
-					TypeID    int      `parquet:"type_id"`
-					Groups    []string `parquet:"groups,optional"`
-				}{
-					Name:      "",
-					UID:       "test-user",
-					EmailAddr: "test@example.com",
-					Domain:    "example.com",
-					TypeID:    1,
-				},
-			},
-			API: struct {
-				Service struct {
-					Name    string `parquet:"name"`
-					Version string `parquet:"version,optional"`
-				} `parquet:"service"`
-				Operation string `parquet:"operation"`
-				Request   struct {
-					UID string `parquet:"uid"`
-				} `parquet:"request"`
-				Response struct {
-					Code    int    `parquet:"code"`
-					Message string `parquet:"message,optional"`
-				} `parquet:"response,optional"`
-			}{
-				Service: struct {
-					Name    string `parquet:"name"`
-					Version string `parquet:"version,optional"`
-				}{
-					Name:    "Google Drive API",
-					Version: "v3",
-				},
-				Operation: "view",
-				Request: struct {
-					UID string `parquet:"uid"`
-				}{
-					UID: "req_12345",
-				},
-				Response: struct {
-					Code    int    `parquet:"code"`
-					Message string `parquet:"message,optional"`
-				}{
-					Code:    200,
-					Message: "Success",
-				},
-			},
-			Cloud: struct {
-				Provider string `parquet:"provider"`
-				Account  struct {
-					UID  string `parquet:"uid"`
-					Name string `parquet:"name,optional"`
-				} `parquet:"account"`
-				Org struct {
-					Name string `parquet:"name"`
-					UID  string `parquet:"uid,optional"`
-				} `parquet:"org,optional"`
-				Region string `parquet:"cloud_region,optional"`
-			}{
-				Provider: "Google Cloud",
-				Account: struct {
-					UID  string `parquet:"uid"`
-					Name string `parquet:"name,optional"`
-				}{
-					UID: "C03az79cb",
-				},
-			},
-			SrcEndpoint: struct {
-				IP       string `parquet:"ip"`
-				Hostname string `parquet:"hostname,optional"`
-				Location struct {
-					City    string `parquet:"city,optional"`
-					Country string `parquet:"country,optional"`
-					Region  string `parquet:"region,optional"`
-				} `parquet:"location,optional"`
-			}{
-				IP: "192.168.1.100",
-			},
-			WebResources: []struct {
-				Name      string `parquet:"name,optional"`
-				UID       string `parquet:"uid,optional"`
-				Type      string `parquet:"type,optional"`
-				URLString string `parquet:"url_string,optional"`
-				Data      struct {
-					Classification string `parquet:"classification,optional"`
-				} `parquet:"data,optional"`
-			}{
-				{
-					Name:      "テストドキュメント.pdf",
-					UID:       "1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE1234",
-					Type:      "document",
-					URLString: "https://docs.google.com/document/d/1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE1234",
-					Data: struct {
-						Classification string `parquet:"classification,optional"`
-					}{
-						Classification: "internal",
-					},
-				},
-			},
-			Metadata: struct {
-				UID            string            `parquet:"uid,optional"`
-				CorrelationUID string            `parquet:"correlation_uid,optional"`
-				Labels         []string          `parquet:"labels,optional,list"`
-				OriginalTime   string            `parquet:"original_time,optional"`
-				Processed      int64             `parquet:"processed,optional"`
-				ProductName    string            `parquet:"product_name,optional"`
-				Version        string            `parquet:"version,optional"`
-				Extension      map[string]string `parquet:"-"`
-			}{
-				CorrelationUID: "test-correlation-id",
-				ProductName:    "Google Workspace",
-				Version:        "1.0.0",
-			},
-		},
-		{
-			// Second log without web resources (e.g., login event)
-			CategoryUID: 6,
-			ClassUID:    6001,
-			TypeUID:     600102,
-			ActivityID:  2,
-			SeverityID:  1,
-			Time:        1723104003000,
-			StatusID:    1,
-			Region:      "ap-northeast-1",
-			AccountID:   "123456789012",
-			EventHour:   "2025-08-08-00",
-			Actor: struct {
-				User struct {
-					Name      string   `parquet:"name"`
-					UID       string   `parquet:"uid"`
-					EmailAddr string   `parquet:"email_addr"`
-					Domain    string   `parquet:"domain,optional"`
-					TypeID    int      `parquet:"type_id"`
-					Groups    []string `parquet:"groups,optional"`
-				} `parquet:"user"`
-				Session struct {
-					UID         string `parquet:"uid"`
-					CreatedTime int64  `parquet:"created_time,optional"`
-					ExpTime     int64  `parquet:"exp_time,optional"`
-				} `parquet:"session,optional"`
-				AppName string `parquet:"app_name,optional"`
-				AppUID  string `parquet:"app_uid,optional"`
-			}{
-				User: struct {
-					Name      string   `parquet:"name"`
-					UID       string   `parquet:"uid"`
-					EmailAddr string   `parquet:"email_addr"`
-					Domain    string   `parquet:"domain,optional"`
-					TypeID    int      `parquet:"type_id"`
-					Groups    []string `parquet:"groups,optional"`
-				}{
-					UID:       "test-user-2",
-					EmailAddr: "test2@example.com",
-					Domain:    "example.com",
-					TypeID:    1,
-				},
-			},
-			API: struct {
-				Service struct {
-					Name    string `parquet:"name"`
-					Version string `parquet:"version,optional"`
-				} `parquet:"service"`
-				Operation string `parquet:"operation"`
-				Request   struct {
-					UID string `parquet:"uid"`
-				} `parquet:"request"`
-				Response struct {
-					Code    int    `parquet:"code"`
-					Message string `parquet:"message,optional"`
-				} `parquet:"response,optional"`
-			}{
-				Service: struct {
-					Name    string `parquet:"name"`
-					Version string `parquet:"version,optional"`
-				}{
-					Name: "Google Identity",
-				},
-				Operation: "login_success",
-			},
-			Cloud: struct {
-				Provider string `parquet:"provider"`
-				Account  struct {
-					UID  string `parquet:"uid"`
-					Name string `parquet:"name,optional"`
-				} `parquet:"account"`
-				Org struct {
-					Name string `parquet:"name"`
-					UID  string `parquet:"uid,optional"`
-				} `parquet:"org,optional"`
-				Region string `parquet:"cloud_region,optional"`
-			}{
-				Provider: "Google Cloud",
-				Account: struct {
-					UID  string `parquet:"uid"`
-					Name string `parquet:"name,optional"`
-				}{
-					UID: "C03az79cb",
-				},
-			},
-			SrcEndpoint: struct {
-				IP       string `parquet:"ip"`
-				Hostname string `parquet:"hostname,optional"`
-				Location struct {
-					City    string `parquet:"city,optional"`
-					Country string `parquet:"country,optional"`
-					Region  string `parquet:"region,optional"`
-				} `parquet:"location,optional"`
-			}{
-				IP: "192.168.1.101",
-			},
-			// No WebResources for login event
-			WebResources: nil,
-			Metadata: struct {
-				UID            string            `parquet:"uid,optional"`
-				CorrelationUID string            `parquet:"correlation_uid,optional"`
-				Labels         []string          `parquet:"labels,optional,list"`
-				OriginalTime   string            `parquet:"original_time,optional"`
-				Processed      int64             `parquet:"processed,optional"`
-				ProductName    string            `parquet:"product_name,optional"`
-				Version        string            `parquet:"version,optional"`
-				Extension      map[string]string `parquet:"-"`
-			}{
-				CorrelationUID: "test-correlation-id-2",
-				ProductName:    "Google Workspace",
-				Version:        "1.0.0",
-			},
-		},
-	}
-
-	// Generate Parquet file
-	parquetData, err := generateOCSFParquetFileArrow(ocsfLogs)
-	if err != nil {
-		t.Fatalf("Failed to generate parquet file: %v", err)
-	}
-
-	// Read back the Parquet file to verify web_resources
-	reader := bytes.NewReader(parquetData)
-	parquetFile, err := file.NewParquetReader(reader)
-	if err != nil {
-		t.Fatalf("Failed to read parquet file: %v", err)
-	}
-	defer parquetFile.Close()
-
-	// Create arrow file reader
-	arrowReader, err := pqarrow.NewFileReader(parquetFile, pqarrow.ArrowReadProperties{}, nil)
-	if err != nil {
-		t.Fatalf("Failed to create arrow reader: %v", err)
-	}
-
-	// Read the record batch
-	ctx := context.Background()
-	recordReader, err := arrowReader.GetRecordReader(ctx, nil, nil)
-	if err != nil {
-		t.Fatalf("Failed to get record reader: %v", err)
-	}
-	defer recordReader.Release()
-
-	// Read records
-	hasRecords := recordReader.Next()
-	if !hasRecords {
-		t.Fatal("No records found in parquet file")
-	}
-
-	record := recordReader.Record()
-	
-	// Verify we have 2 records
-	if record.NumRows() != 2 {
-		t.Errorf("Expected 2 records, got %d", record.NumRows())
-	}
-
-	// Find the web_resources column
-	webResourcesColIndex := -1
-	for i := 0; i < int(record.NumCols()); i++ {
-		if record.ColumnName(i) == "web_resources" {
-			webResourcesColIndex = i
-			break
-		}
-	}
-
-	if webResourcesColIndex == -1 {
-		t.Fatal("web_resources column not found in parquet file")
-	}
-
-	// Get the web_resources column
-	webResourcesCol := record.Column(webResourcesColIndex)
-	
-	// Log the column type for debugging
-	t.Logf("web_resources column type: %v", webResourcesCol.DataType())
-	
-	// Verify first record has web resources
-	if webResourcesCol.IsNull(0) {
-		t.Error("First record should have web_resources but it's null")
-	}
-	
-	// Verify second record has no web resources (null)
-	if !webResourcesCol.IsNull(1) {
-		t.Error("Second record should have null web_resources but it's not null")
-	}
-
-	// Additional verification: Check the parquet file metadata
-	if parquetFile.NumRowGroups() != 1 {
-		t.Errorf("Expected 1 row group, got %d", parquetFile.NumRowGroups())
-	}
-
-	// Check schema includes web_resources
-	schemaRoot := parquetFile.MetaData().Schema
-	foundWebResources := false
-	for i := 0; i < schemaRoot.NumColumns(); i++ {
-		col := schemaRoot.Column(i)
-		if col.Name() == "web_resources" {
-			foundWebResources = true
-			t.Logf("Found web_resources column in schema: %s", col.String())
-			break
-		}
-	}
-
-	if !foundWebResources {
-		t.Error("web_resources not found in parquet schema")
-	}
-}
-
-func TestGenerateOCSFParquetFileArrow_WithWebResources(t *testing.T) {
-	// Create a minimal test case
-	logs := []OCSFWebResourceActivity{
-		{
-			CategoryUID: 6,
-			ClassUID:    6001,
-			TypeUID:     600102,
-			ActivityID:  2,
-			SeverityID:  1,
-			Time:        1723104000000,
-			StatusID:    1,
-			Region:      "ap-northeast-1",
-			AccountID:   "123456789012",
-			EventHour:   "2025-08-08-00",
-			Actor: struct {
-				User struct {
-					Name      string   `parquet:"name"`
-					UID       string   `parquet:"uid"`
-					EmailAddr string   `parquet:"email_addr"`
-					Domain    string   `parquet:"domain,optional"`
-					TypeID    int      `parquet:"type_id"`
-					Groups    []string `parquet:"groups,optional"`
-				} `parquet:"user"`
-				Session struct {
-					UID         string `parquet:"uid"`
-					CreatedTime int64  `parquet:"created_time,optional"`
-					ExpTime     int64  `parquet:"exp_time,optional"`
-				} `parquet:"session,optional"`
-				AppName string `parquet:"app_name,optional"`
-				AppUID  string `parquet:"app_uid,optional"`
-			}{
-				User: struct {
-					Name      string   `parquet:"name"`
-					UID       string   `parquet:"uid"`
-					EmailAddr string   `parquet:"email_addr"`
-					Domain    string   `parquet:"domain,optional"`
-					TypeID    int      `parquet:"type_id"`
-					Groups    []string `parquet:"groups,optional"`
-				}{
-					UID:       "114511147312345678913",
-					EmailAddr: "takahashi.emi@muhai-academy.com",
-					Domain:    "muhai-academy.com",
-					TypeID:    1,
-				},
-			},
-			API: struct {
-				Service struct {
-					Name    string `parquet:"name"`
-					Version string `parquet:"version,optional"`
-				} `parquet:"service"`
-				Operation string `parquet:"operation"`
-				Request   struct {
-					UID string `parquet:"uid"`
-				} `parquet:"request"`
-				Response struct {
-					Code    int    `parquet:"code"`
-					Message string `parquet:"message,optional"`
-				} `parquet:"response,optional"`
-			}{
-				Service: struct {
-					Name    string `parquet:"name"`
-					Version string `parquet:"version,optional"`
-				}{
-					Name:    "Google Drive API",
-					Version: "v3",
-				},
-				Operation: "view",
-			},
-			Cloud: struct {
-				Provider string `parquet:"provider"`
-				Account  struct {
-					UID  string `parquet:"uid"`
-					Name string `parquet:"name,optional"`
-				} `parquet:"account"`
-				Org struct {
-					Name string `parquet:"name"`
-					UID  string `parquet:"uid,optional"`
-				} `parquet:"org,optional"`
-				Region string `parquet:"cloud_region,optional"`
-			}{
-				Provider: "Google Cloud",
-				Account: struct {
-					UID  string `parquet:"uid"`
-					Name string `parquet:"name,optional"`
-				}{
-					UID: "C03az79cb",
-				},
-			},
-			SrcEndpoint: struct {
-				IP       string `parquet:"ip"`
-				Hostname string `parquet:"hostname,optional"`
-				Location struct {
-					City    string `parquet:"city,optional"`
-					Country string `parquet:"country,optional"`
-					Region  string `parquet:"region,optional"`
-				} `parquet:"location,optional"`
-			}{
-				IP: "192.168.1.242",
-			},
-			WebResources: []struct {
-				Name      string `parquet:"name,optional"`
-				UID       string `parquet:"uid,optional"`
-				Type      string `parquet:"type,optional"`
-				URLString string `parquet:"url_string,optional"`
-				Data      struct {
-					Classification string `parquet:"classification,optional"`
-				} `parquet:"data,optional"`
-			}{
-				{
-					Name:      "教材/数学/教科書.pdf",
-					UID:       "1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE1000",
-					Type:      "document",
-					URLString: "https://docs.google.com/document/d/1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE1000",
-					Data: struct {
-						Classification string `parquet:"classification,optional"`
-					}{
-						Classification: "internal",
-					},
-				},
-			},
-			Metadata: struct {
-				UID            string            `parquet:"uid,optional"`
-				CorrelationUID string            `parquet:"correlation_uid,optional"`
-				Labels         []string          `parquet:"labels,optional,list"`
-				OriginalTime   string            `parquet:"original_time,optional"`
-				Processed      int64             `parquet:"processed,optional"`
-				ProductName    string            `parquet:"product_name,optional"`
-				Version        string            `parquet:"version,optional"`
-				Extension      map[string]string `parquet:"-"`
-			}{
-				ProductName: "Google Workspace",
-				Version:     "1.0.0",
-			},
-		},
-	}
-
-	// Generate parquet
-	data, err := generateOCSFParquetFileArrow(logs)
-	if err != nil {
-		t.Fatalf("Failed to generate parquet: %v", err)
-	}
-
-	if len(data) == 0 {
-		t.Error("Generated parquet data is empty")
-	}
-
-	// Basic validation - parquet files start with "PAR1"
-	if len(data) < 4 || string(data[0:4]) != "PAR1" {
-		t.Error("Generated data doesn't look like a valid parquet file")
-	}
-
-	t.Logf("Successfully generated parquet file with size: %d bytes", len(data))
-}
\ No newline at end of file
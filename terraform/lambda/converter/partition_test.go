@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadPartitions_MigrationLayouts(t *testing.T) {
+	mockS3 := new(MockS3API)
+	var keys []string
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		keys = append(keys, aws.ToString(args.Get(1).(*s3.PutObjectInput).Key))
+	}).Return(&s3.PutObjectOutput{}, nil)
+
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		customLogSource:    "google-workspace",
+		sourceVersion:      "2.0",
+		additionalSources: []core.SourceLayout{
+			{Name: "google-workspace", Version: "1.0"},
+			{Name: "google-workspace", Version: "2.0"}, // same as the primary layout
+		},
+	}
+
+	log := core.OCSFWebResourceActivity{Time: time.Date(2024, 8, 12, 10, 0, 0, 0, time.UTC).UnixMilli(), Region: "ap-northeast-1", AccountID: "123456789012"}
+	outputs, err := handler.uploadPartitions(context.Background(), "logs/data.jsonl", "abcd", []core.OCSFWebResourceActivity{log})
+	require.NoError(t, err)
+
+	want := []string{
+		"ext/google-workspace/2.0/region=ap-northeast-1/accountId=123456789012/eventDay=20240812/logs_data_abcd.parquet",
+		"ext/google-workspace/1.0/region=ap-northeast-1/accountId=123456789012/eventDay=20240812/logs_data_abcd.parquet",
+	}
+	assert.Equal(t, want, outputs)
+	assert.Equal(t, want, keys)
+}
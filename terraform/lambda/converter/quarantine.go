@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"seccamp2025-b1-converter/core"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// writeQuarantine uploads quarantined records as JSONL to the state bucket
+func (h *Handler) writeQuarantine(ctx context.Context, key string, records []core.QuarantineRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode quarantine record: %w", err)
+		}
+	}
+
+	if _, err := h.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(h.stateBucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/x-ndjson"),
+	}); err != nil {
+		return fmt.Errorf("failed to upload quarantine records: %w", err)
+	}
+
+	slog.Info("Uploaded quarantined records", "bucket", h.stateBucket, "key", key, "count", len(records))
+	return nil
+}
+
+// writeReport uploads the conversion report to the state bucket
+func (h *Handler) writeReport(ctx context.Context, key string, report *core.ConversionReport) error {
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode conversion report: %w", err)
+	}
+
+	if _, err := h.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(h.stateBucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(raw),
+		ContentType: aws.String("application/json"),
+	}); err != nil {
+		return fmt.Errorf("failed to upload conversion report: %w", err)
+	}
+
+	slog.Info("Uploaded conversion report", "bucket", h.stateBucket, "key", key)
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-converter/validator"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertObject_WritesQuarantineAndReport(t *testing.T) {
+	os.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	defer os.Unsetenv("AWS_ACCOUNT_ID")
+
+	testData := `{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:30Z","uniqueQualifier":"1","applicationName":"drive","customerId":"C03az79cb"},"actor":{"email":"user@muhai-academy.com"},"ipAddress":"203.0.113.1","events":[{"type":"access","name":"view"}]}
+invalid json line
+{"kind":"audit#activity","id":{"time":"not-a-time","uniqueQualifier":"2","applicationName":"drive","customerId":"C03az79cb"},"actor":{"email":"user@muhai-academy.com"},"ipAddress":"203.0.113.1","events":[{"type":"access","name":"view"}]}`
+
+	mockS3 := new(MockS3API)
+	mockS3.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader(testData)),
+		ETag: aws.String(`"etag-1"`),
+	}, nil)
+
+	uploaded := map[string][]byte{}
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*s3.PutObjectInput)
+		body, _ := io.ReadAll(input.Body)
+		uploaded[aws.ToString(input.Bucket)+"/"+aws.ToString(input.Key)] = body
+	}).Return(&s3.PutObjectOutput{}, nil)
+
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+		stateBucket:        "state-bucket",
+	}
+
+	obj := SourceObject{Bucket: "raw", Key: "logs/mixed.jsonl"}
+	require.NoError(t, handler.convertObject(context.Background(), &obj, false))
+	assert.Equal(t, `"etag-1"`, obj.ETag)
+
+	hash := core.ContentHash([]byte(testData))
+
+	rawQuarantine, ok := uploaded["state-bucket/"+core.QuarantineKey("logs/mixed.jsonl", hash)]
+	require.True(t, ok, "quarantine object should be uploaded")
+	lines := strings.Split(strings.TrimSpace(string(rawQuarantine)), "\n")
+	require.Len(t, lines, 2)
+
+	var parseFailure, convertFailure core.QuarantineRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &parseFailure))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &convertFailure))
+	assert.Equal(t, core.QuarantineStageParse, parseFailure.Stage)
+	assert.Equal(t, 2, parseFailure.LineNumber)
+	assert.Equal(t, "logs/mixed.jsonl", parseFailure.SourceKey)
+	assert.Equal(t, core.QuarantineStageConvert, convertFailure.Stage)
+	assert.Equal(t, 3, convertFailure.LineNumber)
+	assert.Contains(t, string(convertFailure.Raw), "not-a-time")
+
+	rawReport, ok := uploaded["state-bucket/"+core.ReportKey("logs/mixed.jsonl", hash)]
+	require.True(t, ok, "report should be uploaded")
+	var report core.ConversionReport
+	require.NoError(t, json.Unmarshal(rawReport, &report))
+	assert.Equal(t, 2, report.Parsed)
+	assert.Equal(t, 1, report.Converted)
+	assert.Equal(t, 2, report.Quarantined)
+	assert.Len(t, report.Outputs, 1)
+	assert.Equal(t, core.QuarantineKey("logs/mixed.jsonl", hash), report.Quarantine)
+}
+
+func TestHandleSQSEvent_InvalidLinesDoNotFailRecord(t *testing.T) {
+	os.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	defer os.Unsetenv("AWS_ACCOUNT_ID")
+
+	data, err := os.ReadFile("testdata/invalid.jsonl")
+	require.NoError(t, err)
+
+	mockS3 := new(MockS3API)
+	mockS3.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader(string(data))),
+	}, nil)
+
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+	}
+
+	s3EventJSON, _ := json.Marshal(events.S3Event{
+		Records: []events.S3EventRecord{
+			{
+				S3: events.S3Entity{
+					Bucket: events.S3Bucket{Name: "raw"},
+					Object: events.S3Object{Key: "logs/invalid.jsonl"},
+				},
+			},
+		},
+	})
+	body, _ := json.Marshal(map[string]string{"Message": string(s3EventJSON)})
+
+	resp, err := handler.HandleSQSEvent(context.Background(), events.SQSEvent{
+		Records: []events.SQSMessage{{MessageId: "msg", Body: string(body)}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, resp.BatchItemFailures)
+}
+
+func TestConvertObject_QuarantinesInvalidRecords(t *testing.T) {
+	os.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	defer os.Unsetenv("AWS_ACCOUNT_ID")
+
+	// The login event has no web resource, which violates the class in strict mode
+	testData := `{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:30Z","uniqueQualifier":"1","applicationName":"drive","customerId":"C03az79cb"},"actor":{"email":"user@muhai-academy.com"},"ipAddress":"203.0.113.1","events":[{"type":"access","name":"view","parameters":[{"name":"doc_id","value":"doc-1"},{"name":"doc_title","value":"教材/数学/教科書.pdf"}]}]}
+{"kind":"audit#activity","id":{"time":"2024-08-12T10:16:30Z","uniqueQualifier":"2","applicationName":"login","customerId":"C03az79cb"},"actor":{"email":"user@muhai-academy.com"},"ipAddress":"203.0.113.1","events":[{"type":"login","name":"login_success"}]}`
+
+	mockS3 := new(MockS3API)
+	mockS3.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader(testData)),
+	}, nil)
+	uploaded := map[string][]byte{}
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*s3.PutObjectInput)
+		body, _ := io.ReadAll(input.Body)
+		uploaded[aws.ToString(input.Bucket)+"/"+aws.ToString(input.Key)] = body
+	}).Return(&s3.PutObjectOutput{}, nil)
+
+	v, err := validator.New(validator.DefaultSchema(), core.OCSFClassName, validator.Deviation{Path: "observables.type_id"})
+	require.NoError(t, err)
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+		stateBucket:        "state-bucket",
+		validator:          v,
+	}
+
+	obj := SourceObject{Bucket: "raw", Key: "logs/login.jsonl"}
+	require.NoError(t, handler.convertObject(context.Background(), &obj, false))
+
+	hash := core.ContentHash([]byte(testData))
+	rawQuarantine, ok := uploaded["state-bucket/"+core.QuarantineKey("logs/login.jsonl", hash)]
+	require.True(t, ok, "quarantine object should be uploaded")
+	var record core.QuarantineRecord
+	require.NoError(t, json.Unmarshal(rawQuarantine, &record))
+	assert.Equal(t, core.QuarantineStageValidate, record.Stage)
+	assert.Equal(t, 2, record.LineNumber)
+	assert.Equal(t, []string{"required:web_resources"}, record.Rules)
+	assert.Contains(t, record.Reason, "web_resources is missing")
+
+	var report core.ConversionReport
+	require.NoError(t, json.Unmarshal(uploaded["state-bucket/"+core.ReportKey("logs/login.jsonl", hash)], &report))
+	assert.Equal(t, 1, report.Converted)
+	assert.Equal(t, 1, report.Quarantined)
+}
@@ -30,6 +30,11 @@ func (m *MockS3API) PutObject(ctx context.Context, params *s3.PutObjectInput, op
 	return args.Get(0).(*s3.PutObjectOutput), args.Error(1)
 }
 
+func (m *MockS3API) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*s3.ListObjectsV2Output), args.Error(1)
+}
+
 func TestS3Operations_GetObject_Success(t *testing.T) {
 	mockS3 := new(MockS3API)
 
@@ -0,0 +1,229 @@
+// Package session reconstructs user sessions from activity events. Events of the same actor
+// and source IP are grouped into a session until no activity is seen for the inactivity
+// timeout, and a successful login always starts a new session.
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultInactivityTimeout is the default idle time that ends a session
+	DefaultInactivityTimeout = 30 * time.Minute
+	// DefaultHistoryRetention is the default period to remember IP addresses and devices of actors
+	DefaultHistoryRetention = 90 * 24 * time.Hour
+)
+
+// Config configures sessionization
+type Config struct {
+	InactivityTimeout time.Duration
+	HistoryRetention  time.Duration
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		InactivityTimeout: DefaultInactivityTimeout,
+		HistoryRetention:  DefaultHistoryRetention,
+	}
+}
+
+// Event is an activity of an actor
+type Event struct {
+	Actor string
+	IP    string
+	// Device is an identifier of the client device, or empty if unknown
+	Device string
+	Time   time.Time
+	// Login is true for successful login events that seed a new session
+	Login bool
+}
+
+// Assignment is the session an event belongs to
+type Assignment struct {
+	UID         string
+	CreatedTime time.Time
+	LastSeen    time.Time
+	IsNewIP     bool
+	IsNewDevice bool
+}
+
+// Session is an active session kept in State
+type Session struct {
+	UID      string    `json:"uid"`
+	Created  time.Time `json:"created"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// State is carried over between sessionizer runs, e.g. between converted files
+type State struct {
+	// Sessions are keyed by actor and IP address
+	Sessions map[string]Session `json:"sessions"`
+	// KnownIPs and KnownDevices keep last seen time (unix milliseconds) per actor
+	KnownIPs     map[string]map[string]int64 `json:"known_ips"`
+	KnownDevices map[string]map[string]int64 `json:"known_devices"`
+}
+
+// NewState returns an empty state
+func NewState() *State {
+	return &State{
+		Sessions:     map[string]Session{},
+		KnownIPs:     map[string]map[string]int64{},
+		KnownDevices: map[string]map[string]int64{},
+	}
+}
+
+// Sessionizer assigns sessions to events
+type Sessionizer struct {
+	cfg   Config
+	state *State
+}
+
+// New returns a sessionizer continuing from the state. An empty state is used if state is nil.
+func New(cfg Config, state *State) *Sessionizer {
+	if cfg.InactivityTimeout <= 0 {
+		cfg.InactivityTimeout = DefaultInactivityTimeout
+	}
+	if cfg.HistoryRetention <= 0 {
+		cfg.HistoryRetention = DefaultHistoryRetention
+	}
+	if state == nil {
+		state = NewState()
+	}
+	if state.Sessions == nil {
+		state.Sessions = map[string]Session{}
+	}
+	if state.KnownIPs == nil {
+		state.KnownIPs = map[string]map[string]int64{}
+	}
+	if state.KnownDevices == nil {
+		state.KnownDevices = map[string]map[string]int64{}
+	}
+	return &Sessionizer{cfg: cfg, state: state}
+}
+
+// Config returns the configuration with defaults applied
+func (x *Sessionizer) Config() Config {
+	return x.cfg
+}
+
+// State returns the current state
+func (x *Sessionizer) State() *State {
+	return x.state
+}
+
+// sessionUID is derived from actor, IP address and created time so that the same events
+// always get the same UID even if they are processed again
+func sessionUID(actor, ip string, created time.Time) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", actor, ip, created.UnixMilli())))
+	return "sess-" + hex.EncodeToString(h[:])[:24]
+}
+
+// Assign returns the session of the event and updates the state. Events should be given in
+// time order; a late event within the timeout joins the session without moving it backward.
+func (x *Sessionizer) Assign(ev Event) Assignment {
+	key := ev.Actor + "|" + ev.IP
+	current, ok := x.state.Sessions[key]
+
+	continued := ok &&
+		!ev.Time.After(current.LastSeen.Add(x.cfg.InactivityTimeout)) &&
+		!ev.Time.Before(current.Created.Add(-x.cfg.InactivityTimeout))
+	if continued && ev.Login && ev.Time.After(current.Created) {
+		continued = false // a new login starts a new session
+	}
+
+	if continued {
+		if ev.Time.After(current.LastSeen) {
+			current.LastSeen = ev.Time
+		}
+	} else {
+		current = Session{
+			UID:      sessionUID(ev.Actor, ev.IP, ev.Time),
+			Created:  ev.Time,
+			LastSeen: ev.Time,
+		}
+	}
+	x.state.Sessions[key] = current
+
+	return Assignment{
+		UID:         current.UID,
+		CreatedTime: current.Created,
+		LastSeen:    current.LastSeen,
+		IsNewIP:     remember(x.state.KnownIPs, ev.Actor, ev.IP, ev.Time),
+		IsNewDevice: remember(x.state.KnownDevices, ev.Actor, ev.Device, ev.Time),
+	}
+}
+
+// remember records value of the actor and returns true if it has not been seen before
+func remember(history map[string]map[string]int64, actor, value string, t time.Time) bool {
+	if value == "" {
+		return false
+	}
+	values, ok := history[actor]
+	if !ok {
+		values = map[string]int64{}
+		history[actor] = values
+	}
+	last, seen := values[value]
+	if !seen || t.UnixMilli() > last {
+		values[value] = t.UnixMilli()
+	}
+	return !seen
+}
+
+// Prune removes sessions that can not be continued any more and history older than the
+// retention period at now
+func (x *Sessionizer) Prune(now time.Time) {
+	for key, s := range x.state.Sessions {
+		if now.Sub(s.LastSeen) > x.cfg.InactivityTimeout {
+			delete(x.state.Sessions, key)
+		}
+	}
+
+	threshold := now.Add(-x.cfg.HistoryRetention).UnixMilli()
+	for _, history := range []map[string]map[string]int64{x.state.KnownIPs, x.state.KnownDevices} {
+		for actor, values := range history {
+			for value, last := range values {
+				if last < threshold {
+					delete(values, value)
+				}
+			}
+			if len(values) == 0 {
+				delete(history, actor)
+			}
+		}
+	}
+}
+
+// Sessionize assigns sessions to events with an empty state. Events are processed in time
+// order and assignments are returned in the order of events.
+func Sessionize(cfg Config, events []Event) []Assignment {
+	return New(cfg, nil).AssignAll(events)
+}
+
+// AssignAll assigns sessions to events in time order and returns assignments in the order
+// of events
+func (x *Sessionizer) AssignAll(events []Event) []Assignment {
+	order := make([]int, len(events))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := events[order[i]], events[order[j]]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		// A login at the same time comes first to seed the session
+		return a.Login && !b.Login
+	})
+
+	assignments := make([]Assignment, len(events))
+	for _, i := range order {
+		assignments[i] = x.Assign(events[i])
+	}
+	return assignments
+}
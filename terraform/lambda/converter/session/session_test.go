@@ -0,0 +1,131 @@
+package session_test
+
+import (
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/session"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2024, 8, 12, 9, 0, 0, 0, time.UTC)
+
+func at(minutes int) time.Time {
+	return base.Add(time.Duration(minutes) * time.Minute)
+}
+
+func TestSessionize_InactivityTimeout(t *testing.T) {
+	events := []session.Event{
+		{Actor: "alice", IP: "192.0.2.1", Time: at(0), Login: true},
+		{Actor: "alice", IP: "192.0.2.1", Time: at(10)},
+		{Actor: "alice", IP: "192.0.2.1", Time: at(35)},
+		{Actor: "alice", IP: "192.0.2.1", Time: at(70)}, // 35 minutes idle
+	}
+
+	assignments := session.Sessionize(session.DefaultConfig(), events)
+	require.Len(t, assignments, 4)
+
+	assert.Equal(t, assignments[0].UID, assignments[1].UID)
+	assert.Equal(t, assignments[0].UID, assignments[2].UID)
+	assert.NotEqual(t, assignments[0].UID, assignments[3].UID)
+
+	assert.Equal(t, at(0), assignments[2].CreatedTime)
+	assert.Equal(t, at(70), assignments[3].CreatedTime)
+}
+
+func TestSessionize_LoginSeedsNewSession(t *testing.T) {
+	events := []session.Event{
+		{Actor: "alice", IP: "192.0.2.1", Time: at(0), Login: true},
+		{Actor: "alice", IP: "192.0.2.1", Time: at(5)},
+		{Actor: "alice", IP: "192.0.2.1", Time: at(10), Login: true},
+		{Actor: "alice", IP: "192.0.2.1", Time: at(12)},
+	}
+
+	assignments := session.Sessionize(session.DefaultConfig(), events)
+	assert.Equal(t, assignments[0].UID, assignments[1].UID)
+	assert.NotEqual(t, assignments[1].UID, assignments[2].UID)
+	assert.Equal(t, assignments[2].UID, assignments[3].UID)
+	assert.Equal(t, at(10), assignments[3].CreatedTime)
+}
+
+func TestSessionize_GroupsByActorAndIP(t *testing.T) {
+	events := []session.Event{
+		{Actor: "alice", IP: "192.0.2.1", Time: at(0)},
+		{Actor: "alice", IP: "198.51.100.20", Time: at(1)},
+		{Actor: "bob", IP: "192.0.2.1", Time: at(2)},
+		{Actor: "alice", IP: "192.0.2.1", Time: at(3)},
+	}
+
+	assignments := session.Sessionize(session.DefaultConfig(), events)
+	assert.Equal(t, assignments[0].UID, assignments[3].UID)
+	assert.NotEqual(t, assignments[0].UID, assignments[1].UID)
+	assert.NotEqual(t, assignments[0].UID, assignments[2].UID)
+
+	assert.True(t, assignments[0].IsNewIP)
+	assert.True(t, assignments[1].IsNewIP, "second IP of alice is new")
+	assert.True(t, assignments[2].IsNewIP, "first IP of bob is new")
+	assert.False(t, assignments[3].IsNewIP)
+}
+
+func TestSessionize_OrdersEventsByTime(t *testing.T) {
+	events := []session.Event{
+		{Actor: "alice", IP: "192.0.2.1", Time: at(10)},
+		{Actor: "alice", IP: "192.0.2.1", Time: at(0), Login: true},
+	}
+
+	assignments := session.Sessionize(session.DefaultConfig(), events)
+	assert.Equal(t, assignments[0].UID, assignments[1].UID)
+	assert.Equal(t, at(0), assignments[0].CreatedTime)
+	assert.False(t, assignments[0].IsNewIP)
+	assert.True(t, assignments[1].IsNewIP)
+}
+
+func TestSessionize_NewDevice(t *testing.T) {
+	events := []session.Event{
+		{Actor: "alice", IP: "192.0.2.1", Device: "laptop", Time: at(0)},
+		{Actor: "alice", IP: "192.0.2.1", Device: "laptop", Time: at(1)},
+		{Actor: "alice", IP: "192.0.2.1", Device: "phone", Time: at(2)},
+		{Actor: "alice", IP: "192.0.2.1", Time: at(3)},
+	}
+
+	assignments := session.Sessionize(session.DefaultConfig(), events)
+	assert.True(t, assignments[0].IsNewDevice)
+	assert.False(t, assignments[1].IsNewDevice)
+	assert.True(t, assignments[2].IsNewDevice)
+	assert.False(t, assignments[3].IsNewDevice, "unknown device is not new")
+}
+
+func TestSessionizer_CarryOverState(t *testing.T) {
+	cfg := session.DefaultConfig()
+	first := session.New(cfg, nil)
+	a := first.Assign(session.Event{Actor: "alice", IP: "192.0.2.1", Time: at(0), Login: true})
+
+	// The next file continues the session with the state of the previous run
+	second := session.New(cfg, first.State())
+	b := second.Assign(session.Event{Actor: "alice", IP: "192.0.2.1", Time: at(20)})
+	assert.Equal(t, a.UID, b.UID)
+	assert.Equal(t, at(0), b.CreatedTime)
+	assert.False(t, b.IsNewIP)
+
+	// Reprocessing the same events gives the same UID
+	again := session.Sessionize(cfg, []session.Event{{Actor: "alice", IP: "192.0.2.1", Time: at(0), Login: true}})
+	assert.Equal(t, a.UID, again[0].UID)
+}
+
+func TestSessionizer_Prune(t *testing.T) {
+	cfg := session.Config{InactivityTimeout: 30 * time.Minute, HistoryRetention: 24 * time.Hour}
+	s := session.New(cfg, nil)
+	s.Assign(session.Event{Actor: "alice", IP: "192.0.2.1", Device: "laptop", Time: at(0)})
+	s.Assign(session.Event{Actor: "bob", IP: "192.0.2.2", Time: at(50)})
+
+	s.Prune(at(60))
+	assert.Len(t, s.State().Sessions, 1, "idle session of alice is removed")
+	assert.Contains(t, s.State().KnownIPs, "alice", "history is kept within retention")
+
+	s.Prune(at(60 * 25))
+	assert.Empty(t, s.State().Sessions)
+	assert.NotContains(t, s.State().KnownIPs, "alice")
+	assert.Empty(t, s.State().KnownDevices)
+}
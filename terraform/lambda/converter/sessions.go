@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-converter/session"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// SessionStore keeps the sessionizer state between converted files. Load returns the
+// version of the state, and Save fails with errSessionStateChanged if the state was saved by
+// someone else since that version was loaded.
+type SessionStore interface {
+	Load(ctx context.Context) (*session.State, string, error)
+	Save(ctx context.Context, state *session.State, version string) error
+}
+
+// errSessionStateChanged is returned by Save if the stored state is not the loaded version.
+// The file is then converted again from the new state by the retry of the message.
+var errSessionStateChanged = errors.New("session state was changed by another conversion")
+
+// memorySessionStore keeps the state in memory. It is mainly for tests and local runs.
+type memorySessionStore struct {
+	mu      sync.Mutex
+	state   []byte
+	version int
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{}
+}
+
+func (x *memorySessionStore) Load(ctx context.Context) (*session.State, string, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	version := strconv.Itoa(x.version)
+	if x.state == nil {
+		return session.NewState(), version, nil
+	}
+	var state session.State
+	if err := json.Unmarshal(x.state, &state); err != nil {
+		return nil, "", fmt.Errorf("failed to decode session state: %w", err)
+	}
+	return &state, version, nil
+}
+
+func (x *memorySessionStore) Save(ctx context.Context, state *session.State, version string) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode session state: %w", err)
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if version != strconv.Itoa(x.version) {
+		return errSessionStateChanged
+	}
+	x.state = raw
+	x.version++
+	return nil
+}
+
+// s3SessionStore stores the state as a JSON object. The version is the ETag of the object,
+// and Save is a conditional put, so concurrent conversions do not overwrite the state of
+// each other.
+type s3SessionStore struct {
+	client S3API
+	bucket string
+	key    string
+}
+
+func newS3SessionStore(client S3API, bucket, key string) *s3SessionStore {
+	return &s3SessionStore{client: client, bucket: bucket, key: key}
+}
+
+func (x *s3SessionStore) Load(ctx context.Context) (*session.State, string, error) {
+	resp, err := x.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(x.bucket),
+		Key:    aws.String(x.key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return session.NewState(), "", nil
+		}
+		return nil, "", fmt.Errorf("failed to get session state: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read session state: %w", err)
+	}
+	var state session.State
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, "", fmt.Errorf("failed to decode session state: %w", err)
+	}
+	return &state, aws.ToString(resp.ETag), nil
+}
+
+// Save puts the state if the object still has the ETag of the version, or does not exist
+// yet if the version is empty
+func (x *s3SessionStore) Save(ctx context.Context, state *session.State, version string) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode session state: %w", err)
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(x.bucket),
+		Key:         aws.String(x.key),
+		Body:        bytes.NewReader(raw),
+		ContentType: aws.String("application/json"),
+	}
+	if version != "" {
+		input.IfMatch = aws.String(version)
+	} else {
+		input.IfNoneMatch = aws.String("*")
+	}
+	if _, err := x.client.PutObject(ctx, input); err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict") {
+			return errSessionStateChanged
+		}
+		return fmt.Errorf("failed to put session state: %w", err)
+	}
+	return nil
+}
+
+// sessionize assigns sessions to records, continuing from the state in the session store.
+// The updated state is not saved; the returned function saves it, and is called after the
+// outputs of the file are written, so that a retry of the file assigns the same sessions.
+func (h *Handler) sessionize(ctx context.Context, logs []core.OCSFWebResourceActivity) (func(context.Context) error, error) {
+	if h.sessions == nil || len(logs) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	state, version, err := h.sessions.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessionizer := session.New(h.sessionConfig, state)
+	core.ApplySessions(sessionizer, logs)
+
+	latest := time.Time{}
+	for _, log := range logs {
+		if t := time.UnixMilli(log.Time); t.After(latest) {
+			latest = t
+		}
+	}
+	sessionizer.Prune(latest)
+
+	return func(ctx context.Context) error {
+		return h.sessions.Save(ctx, sessionizer.State(), version)
+	}, nil
+}
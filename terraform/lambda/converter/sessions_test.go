@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-converter/session"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newSessionTestLog(email, ip, app, operation string, t time.Time) core.OCSFWebResourceActivity {
+	var log core.OCSFWebResourceActivity
+	log.Actor.User.EmailAddr = email
+	log.Actor.AppUID = app
+	log.API.Operation = operation
+	log.SrcEndpoint.IP = ip
+	log.Time = t.UnixMilli()
+	return log
+}
+
+func TestHandlerSessionize_CarriesOverBetweenFiles(t *testing.T) {
+	base := time.Date(2024, 8, 12, 9, 0, 0, 0, time.UTC)
+	handler := &Handler{
+		sessions:      newMemorySessionStore(),
+		sessionConfig: session.DefaultConfig(),
+	}
+
+	file1 := []core.OCSFWebResourceActivity{
+		newSessionTestLog("alice@muhaijuku.com", "192.0.2.1", "login", "login_success", base),
+		newSessionTestLog("alice@muhaijuku.com", "192.0.2.1", "drive", "view", base.Add(5*time.Minute)),
+	}
+	save, err := handler.sessionize(context.Background(), file1)
+	require.NoError(t, err)
+	require.NoError(t, save(context.Background()))
+
+	file2 := []core.OCSFWebResourceActivity{
+		newSessionTestLog("alice@muhaijuku.com", "192.0.2.1", "drive", "download", base.Add(20*time.Minute)),
+		newSessionTestLog("alice@muhaijuku.com", "198.51.100.20", "drive", "view", base.Add(21*time.Minute)),
+	}
+	save, err = handler.sessionize(context.Background(), file2)
+	require.NoError(t, err)
+	require.NoError(t, save(context.Background()))
+
+	sess := file1[0].Actor.Session
+	assert.NotEmpty(t, sess.UID)
+	assert.Equal(t, base.UnixMilli(), sess.CreatedTime)
+	assert.Equal(t, base.Add(30*time.Minute).UnixMilli(), sess.ExpTime)
+	assert.True(t, sess.IsNewIP)
+
+	assert.Equal(t, sess.UID, file1[1].Actor.Session.UID)
+	assert.Equal(t, sess.UID, file2[0].Actor.Session.UID, "session continues in the next file")
+	assert.Equal(t, base.UnixMilli(), file2[0].Actor.Session.CreatedTime)
+	assert.False(t, file2[0].Actor.Session.IsNewIP)
+
+	assert.NotEqual(t, sess.UID, file2[1].Actor.Session.UID)
+	assert.True(t, file2[1].Actor.Session.IsNewIP)
+}
+
+func TestConvertObject_SavesSessionsAfterOutputs(t *testing.T) {
+	os.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	defer os.Unsetenv("AWS_ACCOUNT_ID")
+
+	testData := `{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:30Z","uniqueQualifier":"1","applicationName":"drive","customerId":"C03az79cb"},"actor":{"email":"user@muhai-academy.com"},"ipAddress":"203.0.113.1","events":[{"type":"access","name":"view"}]}`
+	mockS3 := new(MockS3API)
+	for range 3 {
+		mockS3.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(strings.NewReader(testData)),
+		}, nil).Once()
+	}
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Return((*s3.PutObjectOutput)(nil), errors.New("slow down")).Once()
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	store := newMemorySessionStore()
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+		sessions:           store,
+		sessionConfig:      session.DefaultConfig(),
+	}
+	ctx := context.Background()
+	obj := SourceObject{Bucket: "raw", Key: "logs/sessions.jsonl"}
+
+	// A failed upload leaves the state for the retry
+	require.Error(t, handler.convertObject(ctx, &obj, false))
+	state, version, err := store.Load(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, state.Sessions)
+	assert.Equal(t, "0", version)
+
+	require.NoError(t, handler.convertObject(ctx, &obj, false))
+	state, version, err = store.Load(ctx)
+	require.NoError(t, err)
+	assert.Len(t, state.Sessions, 1)
+	assert.Equal(t, "1", version)
+
+	// Reprocessing does not save the state again
+	require.NoError(t, handler.convertObject(ctx, &obj, true))
+	_, version, err = store.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "1", version)
+}
+
+func TestMemorySessionStore_VersionConflict(t *testing.T) {
+	store := newMemorySessionStore()
+	ctx := context.Background()
+	state, version, err := store.Load(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, state, version))
+	assert.ErrorIs(t, store.Save(ctx, state, version), errSessionStateChanged)
+}
+
+func TestS3SessionStore(t *testing.T) {
+	var saved []byte
+	mockS3 := new(MockS3API)
+	mockS3.On("GetObject", mock.Anything, mock.Anything).Return((*s3.GetObjectOutput)(nil), &types.NoSuchKey{}).Once()
+	mockS3.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return aws.ToString(input.IfNoneMatch) == "*" && input.IfMatch == nil
+	})).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*s3.PutObjectInput)
+		assert.Equal(t, "sessions/state.json", *input.Key)
+		saved, _ = io.ReadAll(input.Body)
+	}).Return(&s3.PutObjectOutput{}, nil).Once()
+
+	store := newS3SessionStore(mockS3, "state-bucket", "sessions/state.json")
+	state, version, err := store.Load(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, state.Sessions)
+	assert.Empty(t, version)
+
+	s := session.New(session.DefaultConfig(), state)
+	a := s.Assign(session.Event{Actor: "alice", IP: "192.0.2.1", Time: time.Now()})
+	require.NoError(t, store.Save(context.Background(), s.State(), version))
+
+	mockS3.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(bytes.NewReader(saved)),
+		ETag: aws.String(`"etag-1"`),
+	}, nil)
+	state, version, err = store.Load(context.Background())
+	require.NoError(t, err)
+	require.Len(t, state.Sessions, 1)
+	assert.Equal(t, a.UID, state.Sessions["alice|192.0.2.1"].UID)
+	assert.Equal(t, `"etag-1"`, version)
+
+	// Another conversion saved the state since it was loaded
+	mockS3.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return aws.ToString(input.IfMatch) == `"etag-1"` && input.IfNoneMatch == nil
+	})).Return((*s3.PutObjectOutput)(nil), &smithy.GenericAPIError{Code: "PreconditionFailed"})
+	assert.ErrorIs(t, store.Save(context.Background(), state, version), errSessionStateChanged)
+}
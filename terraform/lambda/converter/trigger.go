@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// triggerTarget is a raw log object, or all objects under a prefix, to be converted.
+// Trigger events of all supported shapes are normalized into targets.
+type triggerTarget struct {
+	Bucket string
+	Key    string // single object, URL decoded
+	ETag   string
+	Prefix string // all objects under the prefix if Key is empty
+	// MaxObjects and ContinuationToken page through the objects under Prefix
+	MaxObjects        int
+	ContinuationToken string
+	// Reprocess converts the object even if the processed ledger has it
+	Reprocess bool
+}
+
+// ManualInvocation is the payload to invoke the converter directly, e.g.
+//
+//	{"bucket": "raw-logs", "key": "logs/2024/08/12/data.jsonl.gz"}
+//	{"bucket": "raw-logs", "prefix": "logs/2024/08/12/", "reprocess": true}
+//
+// A prefix invocation converts at most MaxObjects objects (defaultMaxObjects if zero). The
+// rest is converted by invoking again with NextContinuationToken of the result.
+type ManualInvocation struct {
+	Bucket            string `json:"bucket"`
+	Key               string `json:"key,omitempty"`
+	Prefix            string `json:"prefix,omitempty"`
+	MaxObjects        int    `json:"max_objects,omitempty"`
+	ContinuationToken string `json:"continuation_token,omitempty"`
+	Reprocess         bool   `json:"reprocess,omitempty"`
+}
+
+// TriggerResult summarizes an invocation other than an SQS batch. NextContinuationToken is
+// set if objects under the prefix remain.
+type TriggerResult struct {
+	Converted             int      `json:"converted"`
+	Skipped               int      `json:"skipped"`
+	Failed                []string `json:"failed,omitempty"`
+	NextContinuationToken string   `json:"next_continuation_token,omitempty"`
+}
+
+// defaultMaxObjects limits objects of a prefix invocation so that it finishes within the
+// Lambda timeout
+const defaultMaxObjects = 100
+
+// maxTriggerDepth limits nesting of envelopes (SQS body => SNS message => S3 event)
+const maxTriggerDepth = 4
+
+// parseTrigger normalizes a trigger payload into targets. Supported shapes are S3 event
+// notifications, SNS envelopes and SNS events wrapping them, SQS events, EventBridge
+// "Object Created" events and ManualInvocation.
+func parseTrigger(payload []byte) ([]triggerTarget, error) {
+	return parseTriggerDepth(payload, 0)
+}
+
+func parseTriggerDepth(payload []byte, depth int) ([]triggerTarget, error) {
+	if depth > maxTriggerDepth {
+		return nil, fmt.Errorf("trigger payload is nested too deeply")
+	}
+
+	var probe struct {
+		Records    []json.RawMessage `json:"Records"`
+		Message    *string           `json:"Message"`
+		Sns        *events.SNSEntity `json:"Sns"`
+		Event      string            `json:"Event"`
+		Source     string            `json:"source"`
+		DetailType string            `json:"detail-type"`
+		Detail     json.RawMessage   `json:"detail"`
+		ManualInvocation
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse trigger payload as JSON: %w", err)
+	}
+
+	switch {
+	case probe.Records != nil:
+		var targets []triggerTarget
+		for i, raw := range probe.Records {
+			recordTargets, err := parseTriggerRecord(raw, depth)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", i+1, err)
+			}
+			targets = append(targets, recordTargets...)
+		}
+		return targets, nil
+
+	case probe.Message != nil:
+		// SNS envelope delivered to SQS
+		return parseTriggerDepth([]byte(*probe.Message), depth+1)
+
+	case probe.Sns != nil:
+		// Single SNS event record
+		return parseTriggerDepth([]byte(probe.Sns.Message), depth+1)
+
+	case probe.Event == "s3:TestEvent":
+		slog.Info("Ignoring S3 test event")
+		return nil, nil
+
+	case probe.Source == "aws.s3":
+		return parseEventBridgeS3(probe.DetailType, probe.Detail)
+
+	case probe.Bucket != "":
+		return parseManualInvocation(probe.ManualInvocation)
+	}
+	return nil, fmt.Errorf("unsupported trigger payload")
+}
+
+// parseTriggerRecord parses an element of Records of S3, SNS and SQS events
+func parseTriggerRecord(raw json.RawMessage, depth int) ([]triggerTarget, error) {
+	var record struct {
+		EventSource string            `json:"eventSource"`
+		EventName   string            `json:"eventName"`
+		S3          *events.S3Entity  `json:"s3"`
+		Sns         *events.SNSEntity `json:"Sns"`
+		Body        *string           `json:"body"`
+	}
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse event record: %w", err)
+	}
+
+	switch {
+	case record.S3 != nil:
+		if record.EventName != "" && !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			slog.Info("Ignoring S3 event other than object creation", "event", record.EventName)
+			return nil, nil
+		}
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode S3 object key: %w", err)
+		}
+		return []triggerTarget{{Bucket: record.S3.Bucket.Name, Key: key, ETag: record.S3.Object.ETag}}, nil
+	case record.Sns != nil:
+		return parseTriggerDepth([]byte(record.Sns.Message), depth+1)
+	case record.Body != nil:
+		return parseTriggerDepth([]byte(*record.Body), depth+1)
+	}
+	return nil, fmt.Errorf("unsupported event record (source %q)", record.EventSource)
+}
+
+// parseEventBridgeS3 parses the detail of an EventBridge event from S3
+func parseEventBridgeS3(detailType string, raw json.RawMessage) ([]triggerTarget, error) {
+	if detailType != "Object Created" {
+		slog.Info("Ignoring EventBridge S3 event other than Object Created", "detail_type", detailType)
+		return nil, nil
+	}
+	var detail struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key  string `json:"key"`
+			ETag string `json:"etag"`
+		} `json:"object"`
+	}
+	if err := json.Unmarshal(raw, &detail); err != nil {
+		return nil, fmt.Errorf("failed to parse EventBridge event detail: %w", err)
+	}
+	if detail.Bucket.Name == "" || detail.Object.Key == "" {
+		return nil, fmt.Errorf("EventBridge event has no bucket or object key")
+	}
+	return []triggerTarget{{Bucket: detail.Bucket.Name, Key: detail.Object.Key, ETag: detail.Object.ETag}}, nil
+}
+
+// parseManualInvocation validates a manual invocation. Either key or prefix is required.
+func parseManualInvocation(inv ManualInvocation) ([]triggerTarget, error) {
+	switch {
+	case inv.Key != "" && inv.Prefix != "":
+		return nil, fmt.Errorf("manual invocation takes either key or prefix, not both")
+	case inv.Key == "" && inv.Prefix == "":
+		return nil, fmt.Errorf("manual invocation requires key or prefix")
+	case inv.Key != "" && (inv.MaxObjects != 0 || inv.ContinuationToken != ""):
+		return nil, fmt.Errorf("max_objects and continuation_token apply only to prefix")
+	case inv.MaxObjects < 0:
+		return nil, fmt.Errorf("max_objects must not be negative")
+	}
+	return []triggerTarget{{
+		Bucket:            inv.Bucket,
+		Key:               inv.Key,
+		Prefix:            inv.Prefix,
+		MaxObjects:        inv.MaxObjects,
+		ContinuationToken: inv.ContinuationToken,
+		Reprocess:         inv.Reprocess,
+	}}, nil
+}
+
+// isSQSEvent reports whether the payload is an SQS batch delivered by an event source mapping
+func isSQSEvent(payload []byte) bool {
+	var probe struct {
+		Records []struct {
+			EventSource string `json:"eventSource"`
+		} `json:"Records"`
+	}
+	return json.Unmarshal(payload, &probe) == nil &&
+		len(probe.Records) > 0 && probe.Records[0].EventSource == "aws:sqs"
+}
+
+// HandleEvent is the Lambda entry point for all triggers. SQS batches are handled by
+// HandleSQSEvent to report partial batch failures. Other events are converted in the
+// invocation, which fails if any object fails so that async invocations are retried.
+func (h *Handler) HandleEvent(ctx context.Context, payload json.RawMessage) (any, error) {
+	if isSQSEvent(payload) {
+		var event events.SQSEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse SQS event: %w", err)
+		}
+		return h.HandleSQSEvent(ctx, event)
+	}
+
+	slog.Info("Received event", "payload", string(payload))
+	targets, err := parseTrigger(payload)
+	if err != nil {
+		slog.Error("Failed to parse trigger event", "error", err)
+		return nil, err
+	}
+	result, err := h.processTargets(ctx, targets)
+	slog.Info("Finished processing event", "converted", result.Converted, "skipped", result.Skipped, "failed", len(result.Failed),
+		"next_continuation_token", result.NextContinuationToken)
+	return result, err
+}
+
+// processTargets converts all objects of the targets. Failure of an object does not stop
+// the others; failed objects are returned in the result and as a joined error.
+func (h *Handler) processTargets(ctx context.Context, targets []triggerTarget) (TriggerResult, error) {
+	var result TriggerResult
+	var errs []error
+
+	process := func(target triggerTarget) {
+		converted, err := h.processObject(ctx, SourceObject{Bucket: target.Bucket, Key: target.Key, ETag: target.ETag}, target.Reprocess)
+		switch {
+		case err != nil:
+			slog.Error("Failed to process object", "bucket", target.Bucket, "key", target.Key, "error", err)
+			result.Failed = append(result.Failed, target.Bucket+"/"+target.Key)
+			errs = append(errs, fmt.Errorf("%s/%s: %w", target.Bucket, target.Key, err))
+		case converted:
+			result.Converted++
+		default:
+			result.Skipped++
+		}
+	}
+
+	for _, target := range targets {
+		if target.Key != "" {
+			process(target)
+			continue
+		}
+
+		limit := target.MaxObjects
+		if limit == 0 {
+			limit = defaultMaxObjects
+		}
+		objects, next, err := h.listObjects(ctx, target.Bucket, target.Prefix, target.ContinuationToken, limit)
+		if err != nil {
+			result.Failed = append(result.Failed, target.Bucket+"/"+target.Prefix)
+			errs = append(errs, err)
+			continue
+		}
+		result.NextContinuationToken = next
+		slog.Info("Listed objects to reprocess", "bucket", target.Bucket, "prefix", target.Prefix, "count", len(objects), "more", next != "")
+		for _, obj := range objects {
+			process(triggerTarget{Bucket: obj.Bucket, Key: obj.Key, ETag: obj.ETag, Reprocess: target.Reprocess})
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// listObjects returns up to limit objects under the prefix from the continuation token
+// (the start if empty), except directory markers, and the token of the rest if any
+func (h *Handler) listObjects(ctx context.Context, bucket, prefix, token string, limit int) ([]SourceObject, string, error) {
+	var objects []SourceObject
+	// Keys are requested up to the limit, so the token of the last page points at the rest
+	for listed := 0; listed < limit; {
+		input := &s3.ListObjectsV2Input{
+			Bucket:  aws.String(bucket),
+			Prefix:  aws.String(prefix),
+			MaxKeys: aws.Int32(int32(min(limit-listed, 1000))),
+		}
+		if token != "" {
+			input.ContinuationToken = aws.String(token)
+		}
+		page, err := h.s3Client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list objects under s3://%s/%s: %w", bucket, prefix, err)
+		}
+		listed += len(page.Contents)
+		for _, item := range page.Contents {
+			key := aws.ToString(item.Key)
+			if strings.HasSuffix(key, "/") {
+				continue
+			}
+			objects = append(objects, SourceObject{Bucket: bucket, Key: key, ETag: aws.ToString(item.ETag)})
+		}
+		token = ""
+		if aws.ToBool(page.IsTruncated) {
+			token = aws.ToString(page.NextContinuationToken)
+		}
+		if token == "" {
+			break
+		}
+	}
+	return objects, token, nil
+}
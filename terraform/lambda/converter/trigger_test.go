@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const triggerTestLog = `{"kind":"audit#activity","id":{"time":"2024-08-12T10:15:30.123456Z","uniqueQualifier":"358068855354","applicationName":"drive","customerId":"C03az79cb"},"actor":{"callerType":"USER","email":"user@muhai-academy.com","profileId":"114511147312345678901"},"ownerDomain":"muhai-academy.com","ipAddress":"203.0.113.255","events":[{"type":"access","name":"view","parameters":[{"name":"doc_id","value":"1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE2upms"}]}]}`
+
+func TestParseTrigger(t *testing.T) {
+	s3Event := `{"Records":[{"eventSource":"aws:s3","eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"raw"},"object":{"key":"logs/2024/08/12/a+b%3D.jsonl","eTag":"e1"}}}]}`
+	snsEnvelope, _ := json.Marshal(map[string]string{"Type": "Notification", "Message": s3Event})
+	snsEvent, _ := json.Marshal(map[string]any{
+		"Records": []any{map[string]any{"EventSource": "aws:sns", "Sns": map[string]string{"Message": s3Event}}},
+	})
+	sqsEvent, _ := json.Marshal(map[string]any{
+		"Records": []any{map[string]string{"eventSource": "aws:sqs", "body": string(snsEnvelope)}},
+	})
+	want := []triggerTarget{{Bucket: "raw", Key: "logs/2024/08/12/a b=.jsonl", ETag: "e1"}}
+
+	testCases := []struct {
+		name    string
+		payload string
+		want    []triggerTarget
+		wantErr bool
+	}{
+		{name: "S3 event notification", payload: s3Event, want: want},
+		{name: "SNS envelope", payload: string(snsEnvelope), want: want},
+		{name: "SNS event", payload: string(snsEvent), want: want},
+		{name: "SQS event with SNS envelope", payload: string(sqsEvent), want: want},
+		{
+			name:    "EventBridge Object Created",
+			payload: `{"version":"0","source":"aws.s3","detail-type":"Object Created","detail":{"bucket":{"name":"raw"},"object":{"key":"logs/a b.jsonl","etag":"e2"}}}`,
+			want:    []triggerTarget{{Bucket: "raw", Key: "logs/a b.jsonl", ETag: "e2"}},
+		},
+		{
+			name:    "EventBridge other than Object Created",
+			payload: `{"source":"aws.s3","detail-type":"Object Deleted","detail":{"bucket":{"name":"raw"},"object":{"key":"logs/a.jsonl"}}}`,
+		},
+		{
+			name:    "S3 event other than object creation",
+			payload: `{"Records":[{"eventName":"ObjectRemoved:Delete","s3":{"bucket":{"name":"raw"},"object":{"key":"logs/a.jsonl"}}}]}`,
+		},
+		{name: "S3 test event", payload: `{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"raw"}`},
+		{
+			name:    "manual key",
+			payload: `{"bucket":"raw","key":"logs/a.jsonl"}`,
+			want:    []triggerTarget{{Bucket: "raw", Key: "logs/a.jsonl"}},
+		},
+		{
+			name:    "manual prefix",
+			payload: `{"bucket":"raw","prefix":"logs/2024/08/","reprocess":true}`,
+			want:    []triggerTarget{{Bucket: "raw", Prefix: "logs/2024/08/", Reprocess: true}},
+		},
+		{
+			name:    "manual prefix page",
+			payload: `{"bucket":"raw","prefix":"logs/2024/08/","max_objects":50,"continuation_token":"next"}`,
+			want:    []triggerTarget{{Bucket: "raw", Prefix: "logs/2024/08/", MaxObjects: 50, ContinuationToken: "next"}},
+		},
+		{name: "manual key and prefix", payload: `{"bucket":"raw","key":"a","prefix":"b"}`, wantErr: true},
+		{name: "manual key with max objects", payload: `{"bucket":"raw","key":"a","max_objects":1}`, wantErr: true},
+		{name: "manual negative max objects", payload: `{"bucket":"raw","prefix":"b","max_objects":-1}`, wantErr: true},
+		{name: "manual without key", payload: `{"bucket":"raw"}`, wantErr: true},
+		{name: "unknown shape", payload: `{"foo":"bar"}`, wantErr: true},
+		{name: "invalid json", payload: `invalid json`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			targets, err := parseTrigger([]byte(tc.payload))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, targets)
+		})
+	}
+}
+
+func TestHandleEvent_ReprocessPrefix(t *testing.T) {
+	os.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	defer os.Unsetenv("AWS_ACCOUNT_ID")
+
+	mockS3 := new(MockS3API)
+	// Two pages with a directory marker, the paginator follows the continuation token
+	mockS3.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return aws.ToString(input.Prefix) == "logs/2024/08/12/" && input.ContinuationToken == nil
+	})).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("logs/2024/08/12/"), ETag: aws.String("dir")},
+			{Key: aws.String("logs/2024/08/12/a.jsonl"), ETag: aws.String("etag-a")},
+		},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("next"),
+	}, nil).Once()
+	mockS3.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return aws.ToString(input.ContinuationToken) == "next"
+	})).Return(&s3.ListObjectsV2Output{
+		Contents:    []types.Object{{Key: aws.String("logs/2024/08/12/b.jsonl"), ETag: aws.String("etag-b")}},
+		IsTruncated: aws.Bool(false),
+	}, nil).Once()
+	for _, key := range []string{"logs/2024/08/12/a.jsonl", "logs/2024/08/12/b.jsonl"} {
+		mockS3.On("GetObject", mock.Anything, &s3.GetObjectInput{
+			Bucket: aws.String("raw"),
+			Key:    aws.String(key),
+		}).Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(triggerTestLog))}, nil)
+	}
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	ledger := newMemoryLedger()
+	require.NoError(t, ledger.MarkProcessed(context.Background(), SourceObject{Bucket: "raw", Key: "logs/2024/08/12/a.jsonl", ETag: "etag-a"}))
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+		ledger:             ledger,
+	}
+
+	// Without reprocess, the object in the ledger is skipped
+	result, err := handler.HandleEvent(context.Background(), json.RawMessage(`{"bucket":"raw","prefix":"logs/2024/08/12/"}`))
+	require.NoError(t, err)
+	assert.Equal(t, TriggerResult{Converted: 1, Skipped: 1}, result)
+	mockS3.AssertNumberOfCalls(t, "GetObject", 1)
+
+	// With reprocess, both objects are converted again
+	mockS3.On("ListObjectsV2", mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("logs/2024/08/12/a.jsonl"), ETag: aws.String("etag-a")},
+			{Key: aws.String("logs/2024/08/12/b.jsonl"), ETag: aws.String("etag-b")},
+		},
+	}, nil).Once()
+	result, err = handler.HandleEvent(context.Background(), json.RawMessage(`{"bucket":"raw","prefix":"logs/2024/08/12/","reprocess":true}`))
+	require.NoError(t, err)
+	assert.Equal(t, TriggerResult{Converted: 2}, result)
+	mockS3.AssertNumberOfCalls(t, "GetObject", 3)
+}
+
+func TestHandleEvent_ReprocessPrefixMaxObjects(t *testing.T) {
+	os.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	defer os.Unsetenv("AWS_ACCOUNT_ID")
+
+	mockS3 := new(MockS3API)
+	mockS3.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return input.ContinuationToken == nil && aws.ToInt32(input.MaxKeys) == 1
+	})).Return(&s3.ListObjectsV2Output{
+		Contents:              []types.Object{{Key: aws.String("logs/2024/08/12/a.jsonl"), ETag: aws.String("etag-a")}},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("next"),
+	}, nil).Once()
+	mockS3.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return aws.ToString(input.ContinuationToken) == "next" && aws.ToInt32(input.MaxKeys) == 1
+	})).Return(&s3.ListObjectsV2Output{
+		Contents:    []types.Object{{Key: aws.String("logs/2024/08/12/b.jsonl"), ETag: aws.String("etag-b")}},
+		IsTruncated: aws.Bool(false),
+	}, nil).Once()
+	mockS3.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(triggerTestLog))}, nil).Twice()
+	mockS3.On("PutObject", mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+	}
+
+	result, err := handler.HandleEvent(context.Background(), json.RawMessage(`{"bucket":"raw","prefix":"logs/2024/08/12/","max_objects":1}`))
+	require.NoError(t, err)
+	assert.Equal(t, TriggerResult{Converted: 1, NextContinuationToken: "next"}, result)
+
+	result, err = handler.HandleEvent(context.Background(), json.RawMessage(`{"bucket":"raw","prefix":"logs/2024/08/12/","max_objects":1,"continuation_token":"next"}`))
+	require.NoError(t, err)
+	assert.Equal(t, TriggerResult{Converted: 1}, result)
+	mockS3.AssertExpectations(t)
+}
+
+func TestHandleEvent_EventBridgeFailure(t *testing.T) {
+	mockS3 := new(MockS3API)
+	mockS3.On("GetObject", mock.Anything, mock.Anything).Return((*s3.GetObjectOutput)(nil), assert.AnError)
+
+	handler := &Handler{
+		s3Client:           mockS3,
+		securityLakeBucket: "test-security-lake-bucket",
+		region:             "ap-northeast-1",
+		customLogSource:    "google-workspace",
+	}
+
+	payload := `{"source":"aws.s3","detail-type":"Object Created","detail":{"bucket":{"name":"raw"},"object":{"key":"logs/a.jsonl","etag":"e1"}}}`
+	result, err := handler.HandleEvent(context.Background(), json.RawMessage(payload))
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, TriggerResult{Failed: []string{"raw/logs/a.jsonl"}}, result)
+}
+
+func TestHandleEvent_SQSBatch(t *testing.T) {
+	handler := &Handler{s3Client: new(MockS3API)}
+
+	payload, _ := json.Marshal(events.SQSEvent{
+		Records: []events.SQSMessage{{MessageId: "m1", EventSource: "aws:sqs", Body: "invalid json"}},
+	})
+	result, err := handler.HandleEvent(context.Background(), payload)
+	require.NoError(t, err)
+	require.IsType(t, events.SQSEventResponse{}, result)
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "m1"}}, result.(events.SQSEventResponse).BatchItemFailures)
+}
@@ -0,0 +1,64 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+)
+
+// ReadParquet decodes rows of a Parquet file into records for Validate. Numbers are
+// decoded as json.Number.
+func ReadParquet(ctx context.Context, data []byte) ([]map[string]any, error) {
+	reader, err := file.NewParquetReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open parquet file: %w", err)
+	}
+	defer reader.Close()
+
+	mem := memory.NewGoAllocator()
+	fileReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{BatchSize: 1024}, mem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create arrow reader: %w", err)
+	}
+	table, err := fileReader.ReadTable(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet table: %w", err)
+	}
+	defer table.Release()
+
+	tableReader := array.NewTableReader(table, 1024)
+	defer tableReader.Release()
+
+	var records []map[string]any
+	for tableReader.Next() {
+		batch := tableReader.Record()
+		for row := 0; row < int(batch.NumRows()); row++ {
+			values := make(map[string]any, batch.NumCols())
+			for col, field := range batch.Schema().Fields() {
+				values[field.Name] = batch.Column(col).GetOneForMarshal(row)
+			}
+			// Round trip through JSON to get the same representation as decoded JSON records
+			raw, err := json.Marshal(values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode row %d: %w", len(records), err)
+			}
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.UseNumber()
+			var record map[string]any
+			if err := decoder.Decode(&record); err != nil {
+				return nil, fmt.Errorf("failed to decode row %d: %w", len(records), err)
+			}
+			records = append(records, record)
+		}
+	}
+	if err := tableReader.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read parquet rows: %w", err)
+	}
+	return records, nil
+}
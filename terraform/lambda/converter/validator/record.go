@@ -0,0 +1,159 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ToRecord converts a struct with `parquet` tags into a record for Validate. Field names are
+// taken from the tags, and zero values of optional fields are left out as they are written
+// as null in Parquet.
+func ToRecord(v any) (map[string]any, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("record is nil")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("record must be a struct, got %s", rv.Kind())
+	}
+	return structRecord(rv), nil
+}
+
+func structRecord(rv reflect.Value) map[string]any {
+	record := map[string]any{}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("parquet")
+		if !field.IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		value := rv.Field(i)
+		if strings.Contains(","+options+",", ",optional,") && value.IsZero() {
+			continue
+		}
+		record[name] = recordValue(value)
+	}
+	return record
+}
+
+func recordValue(rv reflect.Value) any {
+	switch rv.Kind() {
+	case reflect.Struct:
+		return structRecord(rv)
+	case reflect.Slice, reflect.Array:
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = recordValue(rv.Index(i))
+		}
+		return items
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		return recordValue(rv.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	}
+	return rv.Interface()
+}
+
+// FromRecord sets fields of the struct pointed by v from a record, the inverse of ToRecord.
+// Field names are taken from `parquet` tags; missing and null values leave fields as zero.
+// Numbers can be json.Number as returned by ReadParquet.
+func FromRecord(record map[string]any, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("target must be a pointer to a struct, got %T", v)
+	}
+	return setStruct(rv.Elem(), record, "")
+}
+
+func setStruct(rv reflect.Value, record map[string]any, prefix string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("parquet")
+		if !field.IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		value, ok := record[name]
+		if !ok || value == nil {
+			continue
+		}
+		if err := setValue(rv.Field(i), value, prefix+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setValue(rv reflect.Value, value any, path string) error {
+	switch rv.Kind() {
+	case reflect.Struct:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, value)
+		}
+		return setStruct(rv, values, path+".")
+	case reflect.Slice:
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, value)
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(items), len(items))
+		for i, item := range items {
+			if item == nil {
+				continue
+			}
+			if err := setValue(slice.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := integer(value)
+		if !ok {
+			return fmt.Errorf("%s: expected integer, got %v", path, value)
+		}
+		rv.SetInt(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, ok := number(value)
+		if !ok {
+			return fmt.Errorf("%s: expected number, got %v", path, value)
+		}
+		rv.SetFloat(f)
+		return nil
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, value)
+		}
+		rv.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, value)
+		}
+		rv.SetString(s)
+		return nil
+	}
+	return fmt.Errorf("%s: unsupported field type %s", path, rv.Type())
+}
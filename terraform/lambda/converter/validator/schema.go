@@ -0,0 +1,112 @@
+// Package validator checks OCSF records against class definitions of a vendored OCSF
+// schema snapshot, before they are written to Security Lake.
+package validator
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Requirement levels of OCSF attributes
+const (
+	RequirementRequired    = "required"
+	RequirementRecommended = "recommended"
+	RequirementOptional    = "optional"
+)
+
+//go:embed schema/ocsf-1.1.0.json
+var defaultSchemaJSON []byte
+
+// Attribute is an attribute definition of a class or an object
+type Attribute struct {
+	Type        string            `json:"type"`
+	ObjectType  string            `json:"object_type,omitempty"`
+	IsArray     bool              `json:"is_array,omitempty"`
+	Requirement string            `json:"requirement"`
+	Enum        map[string]string `json:"enum,omitempty"` // value => caption
+}
+
+// Class is an event class definition
+type Class struct {
+	UID         int                  `json:"uid"`
+	Caption     string               `json:"caption"`
+	CategoryUID int                  `json:"category_uid"`
+	Attributes  map[string]Attribute `json:"attributes"`
+}
+
+// Object is an object definition
+type Object struct {
+	Attributes map[string]Attribute `json:"attributes"`
+}
+
+// Schema is a snapshot of OCSF class and object definitions. The format follows the
+// schema export of schema.ocsf.io, reduced to the fields used by the validator.
+type Schema struct {
+	Version string            `json:"version"`
+	Classes map[string]Class  `json:"classes"`
+	Objects map[string]Object `json:"objects"`
+}
+
+var (
+	defaultSchema     *Schema
+	defaultSchemaOnce sync.Once
+)
+
+// DefaultSchema returns the embedded OCSF schema snapshot
+func DefaultSchema() *Schema {
+	defaultSchemaOnce.Do(func() {
+		schema, err := LoadSchema(defaultSchemaJSON)
+		if err != nil {
+			panic(fmt.Sprintf("invalid embedded OCSF schema: %v", err))
+		}
+		defaultSchema = schema
+	})
+	return defaultSchema
+}
+
+// LoadSchema decodes a schema snapshot and checks that referenced objects are defined
+func LoadSchema(data []byte) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to decode OCSF schema: %w", err)
+	}
+	if len(schema.Classes) == 0 {
+		return nil, fmt.Errorf("OCSF schema has no classes")
+	}
+
+	check := func(owner string, attrs map[string]Attribute) error {
+		for name, attr := range attrs {
+			switch attr.Requirement {
+			case RequirementRequired, RequirementRecommended, RequirementOptional:
+			default:
+				return fmt.Errorf("%s.%s has unknown requirement %q", owner, name, attr.Requirement)
+			}
+			if attr.ObjectType == "" {
+				continue
+			}
+			if _, ok := schema.Objects[attr.ObjectType]; !ok {
+				return fmt.Errorf("%s.%s refers to undefined object %q", owner, name, attr.ObjectType)
+			}
+		}
+		return nil
+	}
+	for name, class := range schema.Classes {
+		if err := check(name, class.Attributes); err != nil {
+			return nil, err
+		}
+	}
+	for name, object := range schema.Objects {
+		if err := check(name, object.Attributes); err != nil {
+			return nil, err
+		}
+	}
+	return &schema, nil
+}
+
+// Class returns the class definition by name (e.g. "web_resources_activity")
+func (x *Schema) Class(name string) (Class, bool) {
+	class, ok := x.Classes[name]
+	return class, ok
+}
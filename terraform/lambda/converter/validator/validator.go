@@ -0,0 +1,239 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rules checked by the validator
+const (
+	// RuleRequired means a required attribute is missing or empty
+	RuleRequired = "required"
+	// RuleEnum means an enum attribute has a value which is not defined
+	RuleEnum = "enum"
+	// RuleTypeUID means type_uid is not class_uid * 100 + activity_id
+	RuleTypeUID = "type_uid"
+)
+
+// Deviation is an attribute path (without array indexes, e.g. "observables.type_id") where
+// violations are ignored. If Field is set, they are ignored only in records where the
+// attribute at Field is one of Values.
+type Deviation struct {
+	Path   string
+	Field  string
+	Values []string
+}
+
+// KnownDeviations are attribute paths where the converter output knowingly differs from
+// the OCSF class definition. They are ignored by the converter until the schema is fixed.
+var KnownDeviations = []Deviation{
+	// Events of applications without documents have no resource. Drive events must have one.
+	{Path: "web_resources", Field: "actor.app_uid", Values: []string{"login", "admin", "calendar", "gmail"}},
+	// Observables carry a free-form type instead of type_id
+	{Path: "observables.type_id"},
+}
+
+// applies reports whether the deviation applies to the record
+func (x Deviation) applies(record map[string]any) bool {
+	if x.Field == "" {
+		return true
+	}
+	var value any = record
+	for _, name := range strings.Split(x.Field, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		value = object[name]
+	}
+	s, ok := value.(string)
+	return ok && slices.Contains(x.Values, s)
+}
+
+// Violation is a rule violated by a record
+type Violation struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path"` // e.g. "observables[2].name"
+	Message string `json:"message"`
+}
+
+func (x Violation) String() string {
+	return fmt.Sprintf("%s: %s %s", x.Rule, x.Path, x.Message)
+}
+
+// Violations is the list of violations of a record
+type Violations []Violation
+
+func (x Violations) String() string {
+	msgs := make([]string, len(x))
+	for i, v := range x {
+		msgs[i] = v.String()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Rules returns unique "rule:path" identifiers of the violations, without array indexes
+func (x Violations) Rules() []string {
+	var rules []string
+	seen := map[string]bool{}
+	for _, v := range x {
+		id := v.Rule + ":" + arrayIndex.ReplaceAllString(v.Path, "")
+		if !seen[id] {
+			seen[id] = true
+			rules = append(rules, id)
+		}
+	}
+	return rules
+}
+
+// Validator checks records of a single OCSF class
+type Validator struct {
+	schema *Schema
+	class  Class
+	ignore []Deviation
+}
+
+// New returns a validator for the class. Violations of the ignored deviations are not
+// reported.
+func New(schema *Schema, className string, ignore ...Deviation) (*Validator, error) {
+	class, ok := schema.Class(className)
+	if !ok {
+		return nil, fmt.Errorf("OCSF class %q is not defined in schema %s", className, schema.Version)
+	}
+	return &Validator{schema: schema, class: class, ignore: ignore}, nil
+}
+
+// Validate returns violations of the record. The record is a decoded JSON object, or the
+// result of ToRecord.
+func (x *Validator) Validate(record map[string]any) Violations {
+	var violations Violations
+	x.validateAttributes(&violations, "", x.class.Attributes, record)
+	x.validateTypeUID(&violations, record)
+	violations = slices.DeleteFunc(violations, func(v Violation) bool {
+		path := arrayIndex.ReplaceAllString(v.Path, "")
+		return slices.ContainsFunc(x.ignore, func(d Deviation) bool { return d.Path == path && d.applies(record) })
+	})
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return violations
+}
+
+var arrayIndex = regexp.MustCompile(`\[\d+\]`)
+
+func (x *Validator) add(violations *Violations, v Violation) {
+	*violations = append(*violations, v)
+}
+
+func (x *Validator) validateAttributes(violations *Violations, prefix string, attrs map[string]Attribute, values map[string]any) {
+	for name, attr := range attrs {
+		path := prefix + name
+		value, ok := values[name]
+		if !ok || isEmpty(value) {
+			if attr.Requirement == RequirementRequired {
+				x.add(violations, Violation{Rule: RuleRequired, Path: path, Message: "is missing"})
+			}
+			continue
+		}
+
+		if attr.IsArray {
+			items, ok := value.([]any)
+			if !ok {
+				continue
+			}
+			for i, item := range items {
+				x.validateValue(violations, fmt.Sprintf("%s[%d]", path, i), attr, item)
+			}
+			continue
+		}
+		x.validateValue(violations, path, attr, value)
+	}
+}
+
+func (x *Validator) validateValue(violations *Violations, path string, attr Attribute, value any) {
+	if len(attr.Enum) > 0 {
+		n, ok := integer(value)
+		if !ok {
+			x.add(violations, Violation{Rule: RuleEnum, Path: path, Message: fmt.Sprintf("is not an integer: %v", value)})
+		} else if _, defined := attr.Enum[strconv.FormatInt(n, 10)]; !defined {
+			x.add(violations, Violation{Rule: RuleEnum, Path: path, Message: fmt.Sprintf("has undefined value %d", n)})
+		}
+	}
+
+	if attr.ObjectType == "" {
+		return
+	}
+	if object, ok := value.(map[string]any); ok {
+		x.validateAttributes(violations, path+".", x.schema.Objects[attr.ObjectType].Attributes, object)
+	}
+}
+
+func (x *Validator) validateTypeUID(violations *Violations, record map[string]any) {
+	classUID, ok1 := integer(record["class_uid"])
+	activityID, ok2 := integer(record["activity_id"])
+	typeUID, ok3 := integer(record["type_uid"])
+	if !ok1 || !ok2 || !ok3 {
+		return // missing values are reported as required
+	}
+	if want := classUID*100 + activityID; typeUID != want {
+		x.add(violations, Violation{
+			Rule:    RuleTypeUID,
+			Path:    "type_uid",
+			Message: fmt.Sprintf("is %d, want class_uid * 100 + activity_id = %d", typeUID, want),
+		})
+	}
+}
+
+// isEmpty reports whether the value does not satisfy a requirement. Zero numbers and false
+// are values, while empty strings, lists and objects are not.
+func isEmpty(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// integer returns the value as an integer if it is an integral number
+func integer(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// number returns the value as a float
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	n, ok := integer(value)
+	return float64(n), ok
+}
@@ -0,0 +1,229 @@
+package validator_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"seccamp2025-b1-converter/validator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validRecord() map[string]any {
+	return map[string]any{
+		"category_uid": 6,
+		"class_uid":    6001,
+		"activity_id":  2,
+		"type_uid":     600102,
+		"severity_id":  1,
+		"status_id":    1,
+		"time":         int64(1723457730000),
+		"metadata": map[string]any{
+			"version": "1.1.0",
+			"product": map[string]any{"name": "Google Workspace", "vendor_name": "Google"},
+		},
+		"api":   map[string]any{"operation": "view", "request": map[string]any{"uid": "1"}},
+		"cloud": map[string]any{"provider": "Google Workspace"},
+		"web_resources": []any{
+			map[string]any{"name": "教材/数学/教科書.pdf", "uid": "doc-1"},
+		},
+		"observables": []any{
+			map[string]any{"name": "src_endpoint.ip", "type_id": 2, "value": "192.0.2.1"},
+		},
+	}
+}
+
+func newValidator(t *testing.T, ignore ...validator.Deviation) *validator.Validator {
+	v, err := validator.New(validator.DefaultSchema(), "web_resources_activity", ignore...)
+	require.NoError(t, err)
+	return v
+}
+
+func TestValidate_Valid(t *testing.T) {
+	assert.Empty(t, newValidator(t).Validate(validRecord()))
+}
+
+func TestValidate_Violations(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(r map[string]any)
+		rule   string
+		path   string
+	}{
+		{
+			name:   "missing required attribute",
+			modify: func(r map[string]any) { delete(r, "time") },
+			rule:   validator.RuleRequired,
+			path:   "time",
+		},
+		{
+			name:   "empty required string in object",
+			modify: func(r map[string]any) { r["api"].(map[string]any)["operation"] = "" },
+			rule:   validator.RuleRequired,
+			path:   "api.operation",
+		},
+		{
+			name:   "required attribute in array item",
+			modify: func(r map[string]any) { r["observables"].([]any)[0].(map[string]any)["name"] = nil },
+			rule:   validator.RuleRequired,
+			path:   "observables[0].name",
+		},
+		{
+			name:   "undefined activity_id",
+			modify: func(r map[string]any) { r["activity_id"] = 42; r["type_uid"] = 600142 },
+			rule:   validator.RuleEnum,
+			path:   "activity_id",
+		},
+		{
+			name:   "undefined severity_id",
+			modify: func(r map[string]any) { r["severity_id"] = 7 },
+			rule:   validator.RuleEnum,
+			path:   "severity_id",
+		},
+		{
+			name:   "undefined status_id",
+			modify: func(r map[string]any) { r["status_id"] = 3 },
+			rule:   validator.RuleEnum,
+			path:   "status_id",
+		},
+		{
+			name:   "non integer enum",
+			modify: func(r map[string]any) { r["status_id"] = "Success" },
+			rule:   validator.RuleEnum,
+			path:   "status_id",
+		},
+		{
+			name:   "type_uid mismatch",
+			modify: func(r map[string]any) { r["type_uid"] = 600101 },
+			rule:   validator.RuleTypeUID,
+			path:   "type_uid",
+		},
+	}
+
+	v := newValidator(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := validRecord()
+			tc.modify(record)
+			violations := v.Validate(record)
+			require.Len(t, violations, 1, violations.String())
+			assert.Equal(t, tc.rule, violations[0].Rule)
+			assert.Equal(t, tc.path, violations[0].Path)
+		})
+	}
+}
+
+func TestValidate_Ignore(t *testing.T) {
+	record := validRecord()
+	delete(record, "web_resources")
+	delete(record["observables"].([]any)[0].(map[string]any), "type_id")
+
+	violations := newValidator(t).Validate(record)
+	assert.Equal(t, []string{"required:observables.type_id", "required:web_resources"}, violations.Rules())
+
+	assert.Empty(t, newValidator(t, validator.Deviation{Path: "web_resources"}, validator.Deviation{Path: "observables.type_id"}).Validate(record))
+}
+
+func TestValidate_KnownDeviations(t *testing.T) {
+	v := newValidator(t, validator.KnownDeviations...)
+
+	// Login events have no resource
+	record := validRecord()
+	delete(record, "web_resources")
+	record["actor"] = map[string]any{"app_uid": "login"}
+	assert.Empty(t, v.Validate(record))
+
+	// Drive events must have one
+	record["actor"] = map[string]any{"app_uid": "drive"}
+	assert.Equal(t, []string{"required:web_resources"}, v.Validate(record).Rules())
+	delete(record, "actor")
+	assert.Equal(t, []string{"required:web_resources"}, v.Validate(record).Rules())
+}
+
+func TestNew_UnknownClass(t *testing.T) {
+	_, err := validator.New(validator.DefaultSchema(), "network_activity")
+	assert.Error(t, err)
+}
+
+func TestLoadSchema_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"classes": {}}`,
+		`{"classes": {"x": {"attributes": {"a": {"requirement": "mandatory"}}}}}`,
+		`{"classes": {"x": {"attributes": {"a": {"requirement": "required", "object_type": "missing"}}}}}`,
+	} {
+		_, err := validator.LoadSchema([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestToRecord(t *testing.T) {
+	type item struct {
+		Name string `parquet:"name"`
+	}
+	type object struct {
+		Code    int    `parquet:"code"`
+		Message string `parquet:"message,optional"`
+	}
+	type record struct {
+		ID       int               `parquet:"id"`
+		Label    string            `parquet:"label,optional"`
+		Object   object            `parquet:"object,optional"`
+		Items    []item            `parquet:"items,optional,list"`
+		Internal map[string]string `parquet:"-"`
+	}
+
+	got, err := validator.ToRecord(&record{
+		Object: object{Code: 200},
+		Items:  []item{{Name: "a"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"id":     int64(0),
+		"object": map[string]any{"code": int64(200)},
+		"items":  []any{map[string]any{"name": "a"}},
+	}, got)
+
+	_, err = validator.ToRecord("not a struct")
+	assert.Error(t, err)
+}
+
+func TestFromRecord(t *testing.T) {
+	type item struct {
+		Name string `parquet:"name"`
+	}
+	type object struct {
+		Code  int     `parquet:"code"`
+		Ratio float64 `parquet:"ratio,optional"`
+		Flag  bool    `parquet:"flag,optional"`
+	}
+	type record struct {
+		ID     int64    `parquet:"id"`
+		Label  string   `parquet:"label,optional"`
+		Object object   `parquet:"object,optional"`
+		Items  []item   `parquet:"items,optional,list"`
+		Tags   []string `parquet:"tags,optional"`
+	}
+
+	var got record
+	err := validator.FromRecord(map[string]any{
+		"id":     json.Number("1723457730000"),
+		"label":  nil,
+		"object": map[string]any{"code": json.Number("200"), "ratio": json.Number("0.5"), "flag": true},
+		"items":  []any{map[string]any{"name": "a"}, nil},
+		"tags":   []any{"x"},
+		"extra":  "ignored",
+	}, &got)
+	require.NoError(t, err)
+	assert.Equal(t, record{
+		ID:     1723457730000,
+		Object: object{Code: 200, Ratio: 0.5, Flag: true},
+		Items:  []item{{Name: "a"}, {}},
+		Tags:   []string{"x"},
+	}, got)
+
+	err = validator.FromRecord(map[string]any{"object": map[string]any{"code": "200"}}, &got)
+	assert.ErrorContains(t, err, "object.code")
+	assert.Error(t, validator.FromRecord(map[string]any{}, got))
+}
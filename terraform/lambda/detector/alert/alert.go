@@ -0,0 +1,294 @@
+// Package alert defines the alert published by detectors to the alerts SNS topic. All
+// detectors publish the same versioned JSON (see Schema), so triage tooling can consume
+// alerts of any team.
+//
+// An alert is built from a detect.Alert by New, with the 5W1H description of the rule, sample
+// events, indicators of compromise taken from values of the alert, and Athena queries to
+// investigate it. Publisher publishes alerts with message attributes for subscription filter
+// policies, truncating them to the size limit of SNS messages.
+package alert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-detector/detect"
+)
+
+// SchemaVersion is the version of the alert schema. The minor version is raised by
+// compatible changes such as new optional fields, and the major version otherwise. The schema
+// allows unknown fields and any minor version, so alerts of later minor versions validate
+// against schemas of earlier ones.
+const SchemaVersion = "1.1"
+
+// Alert is an alert of the alerts SNS topic
+type Alert struct {
+	SchemaVersion string `json:"schema_version"`
+	// ID identifies the alert: the rule, values of its group and the start of the alert.
+	// Re-occurrences of an open alert have the ID of the open alert.
+	ID string `json:"id"`
+	// Fingerprint identifies alerts of the same rule and group over runs, see Deduplicator
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Occurrences is the number of alerts of the fingerprint since the open alert, including
+	// this one
+	Occurrences int    `json:"occurrences,omitempty"`
+	Rule        Rule   `json:"rule"`
+	Severity    string `json:"severity"`
+	SeverityID  int    `json:"severity_id"`
+	Title       string `json:"title"`
+
+	// Who, What, When, Where, Why and How describe the alert following the 5W1H guidance
+	Who   string `json:"who,omitempty"`
+	What  string `json:"what,omitempty"`
+	When  string `json:"when,omitempty"`
+	Where string `json:"where,omitempty"`
+	Why   string `json:"why,omitempty"`
+	How   string `json:"how,omitempty"`
+
+	// FirstSeen and LastSeen are times of the first and last events of the alert. They are
+	// omitted for SQL rules without the columns.
+	FirstSeen  *time.Time `json:"first_seen,omitempty"`
+	LastSeen   *time.Time `json:"last_seen,omitempty"`
+	DetectedAt time.Time  `json:"detected_at"`
+	EventCount int        `json:"event_count"`
+	Failures   int        `json:"failures"`
+
+	// Group are values of group-by fields of the rule
+	Group map[string]string `json:"group,omitempty"`
+	// Distinct are distinct values of fields collected by the rule
+	Distinct map[string][]string `json:"distinct,omitempty"`
+	// Values are other result columns of SQL rules
+	Values map[string]string `json:"values,omitempty"`
+
+	Samples []Event `json:"samples,omitempty"`
+	IoCs    []IoC   `json:"iocs,omitempty"`
+	Links   []Link  `json:"links,omitempty"`
+
+	// Truncated counts items dropped to fit the alert in a message
+	Truncated *Truncated `json:"truncated,omitempty"`
+}
+
+// Rule is the detection rule of an alert
+type Rule struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Technique   *Technique `json:"technique,omitempty"`
+}
+
+// Technique is the MITRE ATT&CK technique of a rule
+type Technique struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Tactic string `json:"tactic,omitempty"`
+}
+
+// Event is a sample event of an alert
+type Event struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	Service   string    `json:"service,omitempty"`
+	Operation string    `json:"operation,omitempty"`
+	Resource  string    `json:"resource,omitempty"`
+	StatusID  int       `json:"status_id"`
+}
+
+// Types of indicators of compromise
+const (
+	IoCIPAddress = "ip_address"
+	IoCEmail     = "email"
+	IoCUserID    = "user_id"
+	IoCDomain    = "domain"
+	IoCResource  = "resource"
+)
+
+// iocFields are fields whose values of alerts are indicators of compromise
+var iocFields = map[string]string{
+	"src_endpoint.ip":       IoCIPAddress,
+	"actor.user.email_addr": IoCEmail,
+	"actor.user.uid":        IoCUserID,
+	"actor.user.domain":     IoCDomain,
+	"web_resources.uid":     IoCResource,
+}
+
+// IoC is an indicator of compromise of an alert
+type IoC struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	// Field is the field of the value
+	Field string `json:"field"`
+}
+
+// Link is an Athena query to investigate an alert
+type Link struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// URL is the Athena query editor of the region, where the query is run
+	URL string `json:"url,omitempty"`
+}
+
+// Names of links
+const (
+	// LinkEvents selects events of the alert
+	LinkEvents = "events"
+	// LinkRule runs the query of the rule over the period of the alert
+	LinkRule = "rule"
+)
+
+// Truncated counts items dropped from an alert
+type Truncated struct {
+	Samples  int `json:"samples,omitempty"`
+	IoCs     int `json:"iocs,omitempty"`
+	Distinct int `json:"distinct,omitempty"`
+}
+
+// Config configures alerts built by New
+type Config struct {
+	// Table of queries of links, detect.DefaultTable if empty
+	Table string
+	// Region of the Athena console URL of links. Links have no URL if empty.
+	Region string
+}
+
+// New builds an alert of the schema from an alert of the rule. The rule may be nil if it is
+// not at hand, then the alert has no description and no rule query.
+func New(src *detect.Alert, rule *detect.Rule, cfg Config) *Alert {
+	x := &Alert{
+		SchemaVersion: SchemaVersion,
+		ID:            alertID(src),
+		Rule:          Rule{ID: src.RuleID, Title: src.Title},
+		Severity:      src.Severity.String(),
+		SeverityID:    int(src.Severity),
+		Title:         src.Title,
+		Who:           src.Fields.Who,
+		What:          src.Fields.What,
+		When:          src.Fields.When,
+		Where:         src.Fields.Where,
+		Why:           src.Fields.Why,
+		How:           src.Fields.How,
+		DetectedAt:    src.DetectedAt.UTC(),
+		EventCount:    src.Count,
+		Failures:      src.Failures,
+		Group:         src.Group,
+		Distinct:      src.Distinct,
+		Values:        src.Values,
+	}
+	if src.Technique != nil {
+		x.Rule.Technique = &Technique{ID: src.Technique.ID, Name: src.Technique.Name, Tactic: src.Technique.Tactic}
+	}
+	if rule != nil {
+		x.Rule.Description = strings.TrimSpace(rule.Description)
+	}
+	if !src.FirstSeen.IsZero() {
+		t := src.FirstSeen.UTC()
+		x.FirstSeen = &t
+	}
+	if !src.LastSeen.IsZero() {
+		t := src.LastSeen.UTC()
+		x.LastSeen = &t
+	}
+	for _, sample := range src.Samples {
+		x.Samples = append(x.Samples, Event{
+			Time:      sample.Time.UTC(),
+			User:      sample.User,
+			IP:        sample.IP,
+			Country:   sample.Country,
+			Service:   sample.Service,
+			Operation: sample.Operation,
+			Resource:  sample.Resource,
+			StatusID:  sample.StatusID,
+		})
+	}
+	x.IoCs = iocs(src)
+	x.Links = links(src, rule, cfg)
+	return x
+}
+
+// alertID hashes the rule, sorted values of the group and the start of the alert
+func alertID(src *detect.Alert) string {
+	names := make([]string, 0, len(src.Group))
+	for name := range src.Group {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	start := src.FirstSeen
+	if start.IsZero() {
+		start = src.DetectedAt
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", src.RuleID)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s\x00", name, src.Group[name])
+	}
+	fmt.Fprintf(h, "%d", start.UnixMilli())
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// iocs returns values of IoC fields in the group and distinct values of the alert, sorted by
+// type and value
+func iocs(src *detect.Alert) []IoC {
+	seen := map[IoC]bool{}
+	var result []IoC
+	add := func(field, value string) {
+		typ, ok := iocFields[field]
+		if !ok || value == "" {
+			return
+		}
+		ioc := IoC{Type: typ, Value: value, Field: field}
+		if !seen[ioc] {
+			seen[ioc] = true
+			result = append(result, ioc)
+		}
+	}
+	for field, value := range src.Group {
+		add(field, value)
+	}
+	for field, values := range src.Distinct {
+		for _, value := range values {
+			add(field, value)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+		if result[i].Value != result[j].Value {
+			return result[i].Value < result[j].Value
+		}
+		return result[i].Field < result[j].Field
+	})
+	return result
+}
+
+// links returns the query of events of the alert, and the query of the rule over the period
+// of the alert if it renders
+func links(src *detect.Alert, rule *detect.Rule, cfg Config) []Link {
+	table := cfg.Table
+	if table == "" {
+		table = detect.DefaultTable
+	}
+	var url string
+	if cfg.Region != "" {
+		url = fmt.Sprintf("https://%s.console.aws.amazon.com/athena/home?region=%s#/query-editor", cfg.Region, cfg.Region)
+	}
+
+	result := []Link{{Name: LinkEvents, Query: src.EventsQuery(table), URL: url}}
+	if rule == nil || src.FirstSeen.IsZero() || src.LastSeen.IsZero() {
+		return result
+	}
+	// Queries of native rules aggregate the whole period, so the period of the alert finds
+	// its group again
+	window := detect.QueryWindow{Table: table, Start: src.FirstSeen, End: src.LastSeen.Add(time.Millisecond)}
+	if query, err := rule.RenderQuery(window); err == nil {
+		result = append(result, Link{Name: LinkRule, Query: query, URL: url})
+	}
+	return result
+}
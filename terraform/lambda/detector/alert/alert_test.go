@@ -0,0 +1,111 @@
+package alert_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bruteForceRule(t *testing.T) detect.Rule {
+	for _, rule := range detect.DefaultRules() {
+		if rule.ID == "auth-brute-force" {
+			return rule
+		}
+	}
+	t.Fatal("auth-brute-force rule is not embedded")
+	return detect.Rule{}
+}
+
+// bruteForceAlert detects login failures of users from a single IP address
+func bruteForceAlert(t *testing.T, failures int) detect.Alert {
+	var records []core.OCSFWebResourceActivity
+	for i := 0; i < failures; i++ {
+		user := fmt.Sprintf("user%03d@muhaijuku.com", i%50)
+		records = append(records, ocsftest.Record(ocsftest.At(i), user, "133.200.32.94",
+			ocsftest.Failed, ocsftest.Service("Google Identity", "login_failure")))
+	}
+	alerts, err := detect.Evaluate([]detect.Rule{bruteForceRule(t)}, records)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	return alerts[0]
+}
+
+func TestNew(t *testing.T) {
+	rule := bruteForceRule(t)
+	src := bruteForceAlert(t, 12)
+	x := alert.New(&src, &rule, alert.Config{Table: "logs", Region: "ap-northeast-1"})
+
+	assert.Equal(t, alert.SchemaVersion, x.SchemaVersion)
+	assert.Len(t, x.ID, 32)
+	assert.Equal(t, "auth-brute-force", x.Rule.ID)
+	assert.Contains(t, x.Rule.Description, "credential stuffing")
+	assert.Equal(t, &alert.Technique{ID: "T1110", Name: "Brute Force", Tactic: "Credential Access"}, x.Rule.Technique)
+	assert.Equal(t, "high", x.Severity)
+	assert.Equal(t, 4, x.SeverityID)
+	assert.Equal(t, "133.200.32.94", x.Where)
+	assert.Equal(t, "12 login failures", x.What)
+	assert.Equal(t, ocsftest.Base, *x.FirstSeen)
+	assert.Equal(t, ocsftest.Base.Add(11*time.Second), *x.LastSeen)
+	assert.Equal(t, ocsftest.Base.Add(9*time.Second), x.DetectedAt)
+	assert.Equal(t, 12, x.EventCount)
+	assert.Equal(t, 12, x.Failures)
+	assert.Equal(t, map[string]string{"src_endpoint.ip": "133.200.32.94"}, x.Group)
+	require.Len(t, x.Samples, 10)
+	assert.Equal(t, alert.Event{
+		Time:      ocsftest.Base,
+		User:      "user000@muhaijuku.com",
+		IP:        "133.200.32.94",
+		Service:   "Google Identity",
+		Operation: "login_failure",
+		StatusID:  2,
+	}, x.Samples[0])
+
+	// IoCs are sorted by type and value
+	require.Len(t, x.IoCs, 13)
+	assert.Equal(t, alert.IoC{Type: alert.IoCEmail, Value: "user000@muhaijuku.com", Field: "actor.user.email_addr"}, x.IoCs[0])
+	assert.Equal(t, alert.IoC{Type: alert.IoCIPAddress, Value: "133.200.32.94", Field: "src_endpoint.ip"}, x.IoCs[12])
+
+	require.Len(t, x.Links, 2)
+	assert.Equal(t, alert.LinkEvents, x.Links[0].Name)
+	assert.Contains(t, x.Links[0].Query, "FROM logs\n")
+	assert.Contains(t, x.Links[0].Query, "AND src_endpoint.ip = '133.200.32.94'\n")
+	assert.Equal(t, "https://ap-northeast-1.console.aws.amazon.com/athena/home?region=ap-northeast-1#/query-editor", x.Links[0].URL)
+	assert.Equal(t, alert.LinkRule, x.Links[1].Name)
+	assert.Contains(t, x.Links[1].Query, "AND time >= 1723456800000 AND time < 1723456811001\n")
+	assert.Contains(t, x.Links[1].Query, "HAVING COUNT(*) >= 10")
+
+	// The ID is stable over runs finding the same alert
+	again := alert.New(&src, nil, alert.Config{})
+	assert.Equal(t, x.ID, again.ID)
+	assert.Empty(t, again.Rule.Description)
+	require.Len(t, again.Links, 1)
+	assert.Empty(t, again.Links[0].URL)
+}
+
+func TestNew_SQLRule(t *testing.T) {
+	// Alerts of SQL rules may have no period nor samples
+	src := detect.Alert{
+		RuleID:     "sql-rule",
+		Title:      "SQL rule",
+		Severity:   detect.SeverityLow,
+		Group:      map[string]string{"user": "tanaka@muhaijuku.com"},
+		DetectedAt: ocsftest.Base,
+		Count:      3,
+		Values:     map[string]string{"score": "0.9"},
+	}
+	x := alert.New(&src, nil, alert.Config{})
+	assert.Nil(t, x.FirstSeen)
+	assert.Nil(t, x.LastSeen)
+	assert.Nil(t, x.Rule.Technique)
+	assert.Empty(t, x.IoCs)
+	assert.Equal(t, map[string]string{"score": "0.9"}, x.Values)
+	assert.NotEqual(t, alert.New(&detect.Alert{RuleID: "sql-rule", DetectedAt: ocsftest.Base.Add(time.Minute)}, nil, alert.Config{}).ID, x.ID)
+}
@@ -0,0 +1,271 @@
+package alert
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultReopenAfter closes states without occurrences for the duration, so the next alert of
+// the fingerprint is notified again
+const DefaultReopenAfter = 24 * time.Hour
+
+// Decision is what to do with an alert after deduplication
+type Decision string
+
+const (
+	// DecisionNotify is a new alert, or an alert of a closed state
+	DecisionNotify Decision = "notify"
+	// DecisionEscalate is a re-occurrence with a higher severity than notified
+	DecisionEscalate Decision = "escalate"
+	// DecisionUpdate is a re-occurrence of an open state, which updates it without notifying
+	DecisionUpdate Decision = "update"
+	// DecisionSnoozed is a re-occurrence of a snoozed state
+	DecisionSnoozed Decision = "snoozed"
+	// DecisionSuppressed is an alert matching a suppression, which is not recorded
+	DecisionSuppressed Decision = "suppressed"
+)
+
+// Notify reports whether the alert should be delivered
+func (x Decision) Notify() bool {
+	return x == DecisionNotify || x == DecisionEscalate
+}
+
+// Value returns a value of the alert referred by fingerprint fields and suppressions:
+// "rule.id", "severity", a group-by field, a result column of a SQL rule, or a distinct field
+// (values joined by ", ")
+func (x *Alert) Value(name string) string {
+	switch name {
+	case "rule.id":
+		return x.Rule.ID
+	case "severity":
+		return x.Severity
+	}
+	if value, ok := x.Group[name]; ok {
+		return value
+	}
+	if value, ok := x.Values[name]; ok {
+		return value
+	}
+	return strings.Join(x.Distinct[name], ", ")
+}
+
+// ComputeFingerprint hashes the rule and values of the fields. Fields are the group-by fields
+// of the alert if empty, so an alert of the same rule and group has the same fingerprint over
+// runs.
+func (x *Alert) ComputeFingerprint(fields []string) string {
+	if len(fields) == 0 {
+		for name := range x.Group {
+			fields = append(fields, name)
+		}
+	}
+	fields = slices.Clone(fields)
+	slices.Sort(fields)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", x.Rule.ID)
+	for _, name := range slices.Compact(fields) {
+		fmt.Fprintf(h, "%s=%s\x00", name, x.Value(name))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// Suppression drops alerts matching it until it expires, such as alerts of a known scanner
+// during a penetration test
+type Suppression struct {
+	ID string `yaml:"id"`
+	// RuleID limits the suppression to alerts of the rule. All rules if empty.
+	RuleID string `yaml:"rule"`
+	// Match are values of the alert, see Alert.Value, which all must be equal
+	Match  map[string]string `yaml:"match"`
+	Until  time.Time         `yaml:"until"`
+	Reason string            `yaml:"reason"`
+}
+
+// Matches reports whether the suppression drops the alert
+func (x *Suppression) Matches(alert *Alert) bool {
+	if !alert.DetectedAt.Before(x.Until) {
+		return false
+	}
+	if x.RuleID != "" && x.RuleID != alert.Rule.ID {
+		return false
+	}
+	for name, value := range x.Match {
+		if alert.Value(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSuppressions parses a YAML list of suppressions
+func ParseSuppressions(data []byte) ([]Suppression, error) {
+	var suppressions []Suppression
+	if err := yaml.Unmarshal(data, &suppressions); err != nil {
+		return nil, fmt.Errorf("invalid suppressions: %w", err)
+	}
+	for i := range suppressions {
+		if err := suppressions[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return suppressions, nil
+}
+
+// Validate checks that the suppression has an ID, an expiry and a condition
+func (x *Suppression) Validate() error {
+	var errs []error
+	if x.ID == "" {
+		errs = append(errs, errors.New("id is required"))
+	}
+	if x.Until.IsZero() {
+		errs = append(errs, errors.New("until is required"))
+	}
+	if x.RuleID == "" && len(x.Match) == 0 {
+		errs = append(errs, errors.New("rule or match is required"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("suppression %q: %w", x.ID, err)
+	}
+	return nil
+}
+
+// DedupConfig configures Deduplicator
+type DedupConfig struct {
+	// GroupBy are fields of fingerprints of alerts by rule ID. Alerts of other rules are
+	// grouped by their group-by fields.
+	GroupBy map[string][]string
+	// ReopenAfter is DefaultReopenAfter if zero
+	ReopenAfter  time.Duration
+	Suppressions []Suppression
+}
+
+// Deduplicator decides whether alerts are new or re-occurrences of open alerts, recording
+// open alerts in a store. Times are detection times of alerts, so replays of past records
+// are deduplicated as they would have been.
+type Deduplicator struct {
+	store Store
+	cfg   DedupConfig
+}
+
+// NewDeduplicator returns a deduplicator recording states in the store
+func NewDeduplicator(store Store, cfg DedupConfig) (*Deduplicator, error) {
+	for i := range cfg.Suppressions {
+		if err := cfg.Suppressions[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ReopenAfter <= 0 {
+		cfg.ReopenAfter = DefaultReopenAfter
+	}
+	return &Deduplicator{store: store, cfg: cfg}, nil
+}
+
+// Process decides what to do with the alert and records it. It sets Fingerprint and
+// Occurrences of the alert, and the ID of the open alert to re-occurrences, so they update
+// the notified alert downstream.
+func (x *Deduplicator) Process(ctx context.Context, alert *Alert) (Decision, error) {
+	fields := x.cfg.GroupBy[alert.Rule.ID]
+	alert.Fingerprint = alert.ComputeFingerprint(fields)
+	for i := range x.cfg.Suppressions {
+		if x.cfg.Suppressions[i].Matches(alert) {
+			return DecisionSuppressed, nil
+		}
+	}
+
+	state, err := x.store.Get(ctx, alert.Fingerprint)
+	if err != nil {
+		return "", err
+	}
+	first, last := alert.DetectedAt, alert.DetectedAt
+	if alert.FirstSeen != nil {
+		first = *alert.FirstSeen
+	}
+	if alert.LastSeen != nil {
+		last = *alert.LastSeen
+	}
+
+	var decision Decision
+	switch {
+	case state == nil || alert.DetectedAt.Sub(state.LastSeen) > x.cfg.ReopenAfter:
+		state = &State{
+			Fingerprint: alert.Fingerprint,
+			AlertID:     alert.ID,
+			RuleID:      alert.Rule.ID,
+			Group:       map[string]string{},
+			Severity:    alert.Severity,
+			SeverityID:  alert.SeverityID,
+			FirstSeen:   first,
+			LastSeen:    last,
+			EventCount:  alert.EventCount,
+			NotifiedAt:  alert.DetectedAt,
+		}
+		for _, name := range fields {
+			state.Group[name] = alert.Value(name)
+		}
+		if len(fields) == 0 {
+			for name, value := range alert.Group {
+				state.Group[name] = value
+			}
+		}
+		decision = DecisionNotify
+
+	default:
+		state.FirstSeen = minTime(state.FirstSeen, first)
+		state.LastSeen = maxTime(state.LastSeen, last)
+		state.EventCount = max(state.EventCount, alert.EventCount)
+		alert.ID = state.AlertID
+		switch {
+		case alert.DetectedAt.Before(state.SnoozedUntil):
+			decision = DecisionSnoozed
+		case alert.SeverityID > state.SeverityID:
+			state.Severity, state.SeverityID = alert.Severity, alert.SeverityID
+			state.NotifiedAt = alert.DetectedAt
+			decision = DecisionEscalate
+		default:
+			decision = DecisionUpdate
+		}
+	}
+	state.Occurrences++
+	alert.Occurrences = state.Occurrences
+
+	if err := x.store.Put(ctx, state); err != nil {
+		return "", err
+	}
+	return decision, nil
+}
+
+// Snooze suppresses notifications of the open alert of the fingerprint until the time. It
+// returns an error if there is no open alert of the fingerprint.
+func (x *Deduplicator) Snooze(ctx context.Context, fingerprint string, until time.Time) error {
+	state, err := x.store.Get(ctx, fingerprint)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("no alert of fingerprint %s", fingerprint)
+	}
+	state.SnoozedUntil = until
+	return x.store.Put(ctx, state)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
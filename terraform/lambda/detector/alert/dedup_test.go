@@ -0,0 +1,174 @@
+package alert_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/internal/ocsftest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// occurrence is an alert of the brute force rule detected at the offset from ocsftest.Base
+func occurrence(offset time.Duration, ip, user string) *alert.Alert {
+	first := ocsftest.Base.Add(offset - 5*time.Minute)
+	last := ocsftest.Base.Add(offset)
+	return &alert.Alert{
+		SchemaVersion: alert.SchemaVersion,
+		ID:            "alert-" + offset.String(),
+		Rule:          alert.Rule{ID: "auth-brute-force"},
+		Severity:      "high",
+		SeverityID:    4,
+		FirstSeen:     &first,
+		LastSeen:      &last,
+		DetectedAt:    last,
+		EventCount:    10,
+		Group:         map[string]string{"src_endpoint.ip": ip},
+		Distinct:      map[string][]string{"actor.user.email_addr": {user}},
+	}
+}
+
+func TestDeduplicator_Process(t *testing.T) {
+	ctx := context.Background()
+	store := alert.NewMemoryStore()
+	dedup, err := alert.NewDeduplicator(store, alert.DedupConfig{ReopenAfter: time.Hour})
+	require.NoError(t, err)
+
+	// Pattern 4 keeps attacking from the same IP address, and runs over overlapping windows
+	// find it again
+	var decisions []alert.Decision
+	var ids []string
+	for i := 0; i < 4; i++ {
+		a := occurrence(time.Duration(i)*10*time.Minute, "133.200.32.94", "tanaka@muhaijuku.com")
+		decision, err := dedup.Process(ctx, a)
+		require.NoError(t, err)
+		decisions = append(decisions, decision)
+		ids = append(ids, a.ID)
+		assert.Equal(t, i+1, a.Occurrences)
+		assert.NotEmpty(t, a.Fingerprint)
+	}
+	assert.Equal(t, []alert.Decision{alert.DecisionNotify, alert.DecisionUpdate, alert.DecisionUpdate, alert.DecisionUpdate}, decisions)
+	// Re-occurrences update the notified alert
+	assert.Equal(t, []string{"alert-0s", "alert-0s", "alert-0s", "alert-0s"}, ids)
+
+	first := occurrence(0, "133.200.32.94", "")
+	state, err := store.Get(ctx, first.ComputeFingerprint(nil))
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, "alert-0s", state.AlertID)
+	assert.Equal(t, map[string]string{"src_endpoint.ip": "133.200.32.94"}, state.Group)
+	assert.Equal(t, ocsftest.Base.Add(-5*time.Minute), state.FirstSeen)
+	assert.Equal(t, ocsftest.Base.Add(30*time.Minute), state.LastSeen)
+	assert.Equal(t, 4, state.Occurrences)
+	assert.Equal(t, ocsftest.Base, state.NotifiedAt)
+
+	// Another group is another alert
+	decision, err := dedup.Process(ctx, occurrence(40*time.Minute, "198.51.100.7", "tanaka@muhaijuku.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionNotify, decision)
+
+	// A higher severity is notified again
+	escalated := occurrence(50*time.Minute, "133.200.32.94", "tanaka@muhaijuku.com")
+	escalated.Severity, escalated.SeverityID = "critical", 5
+	decision, err = dedup.Process(ctx, escalated)
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionEscalate, decision)
+	assert.True(t, decision.Notify())
+
+	// The alert is closed after an hour without occurrences, and the next one is new
+	reopened := occurrence(3*time.Hour, "133.200.32.94", "tanaka@muhaijuku.com")
+	decision, err = dedup.Process(ctx, reopened)
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionNotify, decision)
+	assert.Equal(t, "alert-3h0m0s", reopened.ID)
+	assert.Equal(t, 1, reopened.Occurrences)
+}
+
+func TestDeduplicator_GroupBy(t *testing.T) {
+	ctx := context.Background()
+	dedup, err := alert.NewDeduplicator(alert.NewMemoryStore(), alert.DedupConfig{
+		// Group alerts of the rule by the targeted user instead of the IP address
+		GroupBy: map[string][]string{"auth-brute-force": {"actor.user.email_addr"}},
+	})
+	require.NoError(t, err)
+
+	decision, err := dedup.Process(ctx, occurrence(0, "133.200.32.94", "tanaka@muhaijuku.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionNotify, decision)
+	decision, err = dedup.Process(ctx, occurrence(time.Minute, "198.51.100.7", "tanaka@muhaijuku.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionUpdate, decision)
+	decision, err = dedup.Process(ctx, occurrence(2*time.Minute, "133.200.32.94", "sato@muhaijuku.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionNotify, decision)
+}
+
+func TestDeduplicator_Suppression(t *testing.T) {
+	ctx := context.Background()
+	suppressions, err := alert.ParseSuppressions([]byte(`
+- id: pentest
+  rule: auth-brute-force
+  match:
+    src_endpoint.ip: 133.200.32.94
+  until: 2024-08-12T12:00:00Z
+  reason: Penetration test by the red team
+`))
+	require.NoError(t, err)
+	require.Len(t, suppressions, 1)
+	assert.Equal(t, "Penetration test by the red team", suppressions[0].Reason)
+
+	store := alert.NewMemoryStore()
+	dedup, err := alert.NewDeduplicator(store, alert.DedupConfig{Suppressions: suppressions})
+	require.NoError(t, err)
+
+	suppressed := occurrence(0, "133.200.32.94", "tanaka@muhaijuku.com")
+	decision, err := dedup.Process(ctx, suppressed)
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionSuppressed, decision)
+	assert.False(t, decision.Notify())
+	// Suppressed alerts are not recorded
+	state, err := store.Get(ctx, suppressed.Fingerprint)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	decision, err = dedup.Process(ctx, occurrence(0, "198.51.100.7", "tanaka@muhaijuku.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionNotify, decision)
+
+	// The suppression expires
+	decision, err = dedup.Process(ctx, occurrence(2*time.Hour, "133.200.32.94", "tanaka@muhaijuku.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionNotify, decision)
+
+	_, err = alert.ParseSuppressions([]byte(`[{id: forever, rule: auth-brute-force}]`))
+	assert.ErrorContains(t, err, "until is required")
+	_, err = alert.ParseSuppressions([]byte(`[{id: everything, until: 2024-08-12T12:00:00Z}]`))
+	assert.ErrorContains(t, err, "rule or match is required")
+}
+
+func TestDeduplicator_Snooze(t *testing.T) {
+	ctx := context.Background()
+	dedup, err := alert.NewDeduplicator(alert.NewMemoryStore(), alert.DedupConfig{})
+	require.NoError(t, err)
+
+	a := occurrence(0, "133.200.32.94", "tanaka@muhaijuku.com")
+	_, err = dedup.Process(ctx, a)
+	require.NoError(t, err)
+	require.NoError(t, dedup.Snooze(ctx, a.Fingerprint, ocsftest.Base.Add(time.Hour)))
+
+	decision, err := dedup.Process(ctx, occurrence(30*time.Minute, "133.200.32.94", "tanaka@muhaijuku.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionSnoozed, decision)
+
+	// Snoozing does not hide escalations after it expires
+	escalated := occurrence(90*time.Minute, "133.200.32.94", "tanaka@muhaijuku.com")
+	escalated.Severity, escalated.SeverityID = "critical", 5
+	decision, err = dedup.Process(ctx, escalated)
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionEscalate, decision)
+
+	assert.Error(t, dedup.Snooze(ctx, "unknown", ocsftest.Base.Add(time.Hour)))
+}
@@ -0,0 +1,189 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// SNSAPI defines the SNS operations used by Publisher
+type SNSAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// Ensure that sns.Client implements SNSAPI
+var _ SNSAPI = (*sns.Client)(nil)
+
+// MaxMessageSize is the limit of SNS messages, including message attributes
+const MaxMessageSize = 256 * 1024
+
+// maxSubjectLength is the limit of subjects of SNS messages (for email subscriptions)
+const maxSubjectLength = 100
+
+// Message attributes of published alerts, for subscription filter policies
+const (
+	AttributeSchemaVersion = "schema_version"
+	AttributeRuleID        = "rule_id"
+	AttributeSeverity      = "severity"
+	AttributeSeverityID    = "severity_id"
+	AttributeTactic        = "tactic"
+	AttributeTechnique     = "technique"
+)
+
+// ErrMessageTooLarge is returned when an alert does not fit in a message after truncation
+var ErrMessageTooLarge = errors.New("alert exceeds the message size limit")
+
+// Publisher publishes alerts to an SNS topic
+type Publisher struct {
+	api      SNSAPI
+	topicARN string
+	maxSize  int
+}
+
+// NewPublisher returns a publisher to the topic
+func NewPublisher(api SNSAPI, topicARN string) (*Publisher, error) {
+	if topicARN == "" {
+		return nil, errors.New("topic ARN is required")
+	}
+	return &Publisher{api: api, topicARN: topicARN, maxSize: MaxMessageSize}, nil
+}
+
+// Publish publishes the alert as JSON with message attributes of the alert, and returns the
+// message ID. Alerts over the size limit are truncated, see Encode.
+func (x *Publisher) Publish(ctx context.Context, alert *Alert) (string, error) {
+	attributes := Attributes(alert)
+	data, err := Encode(alert, x.maxSize-attributesSize(attributes))
+	if err != nil {
+		return "", fmt.Errorf("alert %s: %w", alert.ID, err)
+	}
+
+	input := &sns.PublishInput{
+		TopicArn:          aws.String(x.topicARN),
+		Message:           aws.String(string(data)),
+		MessageAttributes: attributes,
+	}
+	if subject := subject(alert.Title); subject != "" {
+		input.Subject = aws.String(subject)
+	}
+	output, err := x.api.Publish(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to publish alert %s: %w", alert.ID, err)
+	}
+	return aws.ToString(output.MessageId), nil
+}
+
+// Attributes returns message attributes of the alert
+func Attributes(alert *Alert) map[string]snstypes.MessageAttributeValue {
+	attributes := map[string]snstypes.MessageAttributeValue{
+		AttributeSchemaVersion: stringAttribute(alert.SchemaVersion),
+		AttributeRuleID:        stringAttribute(alert.Rule.ID),
+		AttributeSeverity:      stringAttribute(alert.Severity),
+		AttributeSeverityID: {
+			DataType:    aws.String("Number"),
+			StringValue: aws.String(strconv.Itoa(alert.SeverityID)),
+		},
+	}
+	if technique := alert.Rule.Technique; technique != nil {
+		attributes[AttributeTechnique] = stringAttribute(technique.ID)
+		if technique.Tactic != "" {
+			attributes[AttributeTactic] = stringAttribute(technique.Tactic)
+		}
+	}
+	return attributes
+}
+
+func stringAttribute(value string) snstypes.MessageAttributeValue {
+	return snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+// attributesSize is the size of attributes counted in the message size: names, data types
+// and values
+func attributesSize(attributes map[string]snstypes.MessageAttributeValue) int {
+	var size int
+	for name, value := range attributes {
+		size += len(name) + len(aws.ToString(value.DataType)) + len(aws.ToString(value.StringValue))
+	}
+	return size
+}
+
+// subject returns the title as the subject of a message, truncated to the limit. Subjects
+// must be printable ASCII, so titles with other characters have no subject.
+func subject(title string) string {
+	for _, c := range title {
+		if c < 0x20 || c > 0x7e {
+			return ""
+		}
+	}
+	if len(title) >= maxSubjectLength {
+		title = title[:maxSubjectLength-4] + "..."
+	}
+	return title
+}
+
+// Encode returns JSON of the alert within limit bytes. Over the limit, samples, IoCs and then
+// distinct values are dropped from the end, halving them until the alert fits, and the
+// numbers of dropped items are set to Truncated of the encoded alert. The alert itself is not
+// modified.
+func Encode(alert *Alert, limit int) ([]byte, error) {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode alert: %w", err)
+	}
+	if len(data) <= limit {
+		return data, nil
+	}
+
+	t := *alert
+	t.Truncated = &Truncated{}
+	if alert.Truncated != nil {
+		*t.Truncated = *alert.Truncated
+	}
+	if alert.Distinct != nil {
+		t.Distinct = make(map[string][]string, len(alert.Distinct))
+		for name, values := range alert.Distinct {
+			t.Distinct[name] = values
+		}
+	}
+	for len(data) > limit {
+		switch {
+		case len(t.Samples) > 0:
+			n := len(t.Samples) / 2
+			t.Truncated.Samples += len(t.Samples) - n
+			t.Samples = t.Samples[:n]
+		case len(t.IoCs) > 0:
+			n := len(t.IoCs) / 2
+			t.Truncated.IoCs += len(t.IoCs) - n
+			t.IoCs = t.IoCs[:n]
+		default:
+			name := longestDistinct(t.Distinct)
+			if name == "" {
+				return nil, fmt.Errorf("%w: %d bytes over %d", ErrMessageTooLarge, len(data), limit)
+			}
+			values := t.Distinct[name]
+			n := len(values) / 2
+			t.Truncated.Distinct += len(values) - n
+			t.Distinct[name] = values[:n]
+		}
+		if data, err = json.Marshal(&t); err != nil {
+			return nil, fmt.Errorf("failed to encode alert: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// longestDistinct returns the field with the most distinct values, or "" if all are empty
+func longestDistinct(distinct map[string][]string) string {
+	var longest string
+	for name, values := range distinct {
+		if len(values) > len(distinct[longest]) || (len(values) > 0 && len(values) == len(distinct[longest]) && name < longest) {
+			longest = name
+		}
+	}
+	return longest
+}
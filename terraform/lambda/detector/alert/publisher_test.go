@@ -0,0 +1,130 @@
+package alert_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"seccamp2025-b1-detector/alert"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSNS struct {
+	inputs []*sns.PublishInput
+	err    error
+}
+
+func (x *fakeSNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	if x.err != nil {
+		return nil, x.err
+	}
+	x.inputs = append(x.inputs, params)
+	return &sns.PublishOutput{MessageId: aws.String("message-1")}, nil
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	rule := bruteForceRule(t)
+	src := bruteForceAlert(t, 12)
+	x := alert.New(&src, &rule, alert.Config{})
+
+	api := &fakeSNS{}
+	publisher, err := alert.NewPublisher(api, "arn:aws:sns:ap-northeast-1:123456789012:alerts")
+	require.NoError(t, err)
+	id, err := publisher.Publish(context.Background(), x)
+	require.NoError(t, err)
+	assert.Equal(t, "message-1", id)
+
+	require.Len(t, api.inputs, 1)
+	input := api.inputs[0]
+	assert.Equal(t, "arn:aws:sns:ap-northeast-1:123456789012:alerts", aws.ToString(input.TopicArn))
+	assert.Equal(t, "Repeated login failures from the same IP address", aws.ToString(input.Subject))
+
+	var published alert.Alert
+	require.NoError(t, json.Unmarshal([]byte(aws.ToString(input.Message)), &published))
+	assert.Equal(t, x.ID, published.ID)
+	assert.Nil(t, published.Truncated)
+
+	attributes := map[string]string{}
+	for name, value := range input.MessageAttributes {
+		attributes[name] = aws.ToString(value.DataType) + ":" + aws.ToString(value.StringValue)
+	}
+	assert.Equal(t, map[string]string{
+		alert.AttributeSchemaVersion: "String:" + alert.SchemaVersion,
+		alert.AttributeRuleID:        "String:auth-brute-force",
+		alert.AttributeSeverity:      "String:high",
+		alert.AttributeSeverityID:    "Number:4",
+		alert.AttributeTechnique:     "String:T1110",
+		alert.AttributeTactic:        "String:Credential Access",
+	}, attributes)
+
+	// Titles which are not printable ASCII have no subject
+	x.Title = "深夜の管理者ダウンロード"
+	_, err = publisher.Publish(context.Background(), x)
+	require.NoError(t, err)
+	assert.Nil(t, api.inputs[1].Subject)
+
+	api.err = errors.New("throttled")
+	_, err = publisher.Publish(context.Background(), x)
+	assert.ErrorContains(t, err, "throttled")
+
+	_, err = alert.NewPublisher(api, "")
+	assert.Error(t, err)
+}
+
+func TestEncode(t *testing.T) {
+	src := bruteForceAlert(t, 12)
+	x := alert.New(&src, nil, alert.Config{})
+	full, err := alert.Encode(x, alert.MaxMessageSize)
+	require.NoError(t, err)
+
+	t.Run("samples are dropped first", func(t *testing.T) {
+		data, err := alert.Encode(x, len(full)-1)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(data), len(full)-1)
+
+		var truncated alert.Alert
+		require.NoError(t, json.Unmarshal(data, &truncated))
+		assert.Len(t, truncated.Samples, 5)
+		assert.Len(t, truncated.IoCs, 13)
+		assert.Equal(t, &alert.Truncated{Samples: 5}, truncated.Truncated)
+
+		// The alert is not modified
+		assert.Len(t, x.Samples, 10)
+		assert.Nil(t, x.Truncated)
+	})
+
+	t.Run("IoCs and distinct values are dropped next", func(t *testing.T) {
+		// Values of thousands of users exceed the limit of SNS messages
+		src := bruteForceAlert(t, 12)
+		var users []string
+		for i := 0; i < 3000; i++ {
+			users = append(users, fmt.Sprintf("user%04d-%s@muhaijuku.com", i, strings.Repeat("x", 80)))
+		}
+		src.Distinct = map[string][]string{"actor.user.email_addr": users}
+		large := alert.New(&src, nil, alert.Config{})
+		data, err := alert.Encode(large, alert.MaxMessageSize)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(data), alert.MaxMessageSize)
+
+		var truncated alert.Alert
+		require.NoError(t, json.Unmarshal(data, &truncated))
+		assert.Empty(t, truncated.Samples)
+		assert.Empty(t, truncated.IoCs)
+		assert.Equal(t, 10, truncated.Truncated.Samples)
+		assert.Equal(t, len(large.IoCs), truncated.Truncated.IoCs)
+		assert.Positive(t, truncated.Truncated.Distinct)
+		assert.Equal(t, 12, truncated.EventCount)
+	})
+
+	t.Run("alerts without items to drop are too large", func(t *testing.T) {
+		_, err := alert.Encode(&alert.Alert{Title: strings.Repeat("x", 100)}, 50)
+		assert.ErrorIs(t, err, alert.ErrMessageTooLarge)
+	})
+}
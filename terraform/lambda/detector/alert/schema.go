@@ -0,0 +1,11 @@
+package alert
+
+import _ "embed"
+
+//go:embed schema/alert.schema.json
+var schemaJSON []byte
+
+// Schema returns the JSON Schema of Alert of SchemaVersion
+func Schema() []byte {
+	return schemaJSON
+}
@@ -0,0 +1,89 @@
+package alert_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-detector/alert"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Pattern              string                 `json:"pattern"`
+	AdditionalProperties any                    `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Items                *jsonSchema            `json:"items"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+}
+
+// TestSchema checks that the schema declares the JSON fields of Alert, and requires those
+// without omitempty
+func TestSchema(t *testing.T) {
+	var schema jsonSchema
+	require.NoError(t, json.Unmarshal(alert.Schema(), &schema))
+	// Alerts of later minor versions, which may have new fields, validate against the schema
+	version := regexp.MustCompile(schema.Properties["schema_version"].Pattern)
+	major, _, _ := strings.Cut(alert.SchemaVersion, ".")
+	assert.Regexp(t, version, alert.SchemaVersion)
+	assert.Regexp(t, version, major+".99")
+	assert.NotRegexp(t, version, "99.0")
+
+	resolve := func(s *jsonSchema) *jsonSchema {
+		if name, ok := strings.CutPrefix(s.Ref, "#/$defs/"); ok {
+			require.Contains(t, schema.Defs, name)
+			return schema.Defs[name]
+		}
+		return s
+	}
+
+	var check func(path string, typ reflect.Type, object *jsonSchema)
+	check = func(path string, typ reflect.Type, object *jsonSchema) {
+		require.Equal(t, "object", object.Type, path)
+		assert.NotEqual(t, false, object.AdditionalProperties, "%s rejects unknown fields", path)
+		var names, required []string
+		for i := 0; i < typ.NumField(); i++ {
+			name, options, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			names = append(names, name)
+			if options != "omitempty" {
+				required = append(required, name)
+			}
+
+			prop, ok := object.Properties[name]
+			require.True(t, ok, "%s.%s is not in the schema", path, name)
+			prop = resolve(prop)
+			field := typ.Field(i).Type
+			if field.Kind() == reflect.Pointer {
+				field = field.Elem()
+			}
+			switch {
+			case field.Kind() == reflect.Struct && field != reflect.TypeOf(time.Time{}):
+				check(path+"."+name, field, prop)
+			case field.Kind() == reflect.Slice && field.Elem().Kind() == reflect.Struct:
+				require.Equal(t, "array", prop.Type, path+"."+name)
+				check(path+"."+name+"[]", field.Elem(), resolve(prop.Items))
+			}
+		}
+
+		var declared []string
+		for name := range object.Properties {
+			declared = append(declared, name)
+		}
+		slices.Sort(names)
+		slices.Sort(declared)
+		slices.Sort(required)
+		slices.Sort(object.Required)
+		assert.Equal(t, names, declared, path)
+		assert.Equal(t, required, object.Required, path)
+	}
+	check("alert", reflect.TypeOf(alert.Alert{}), &schema)
+}
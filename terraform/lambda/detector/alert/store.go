@@ -0,0 +1,142 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// State is an open alert, which re-occurrences of the same fingerprint update instead of
+// opening a new alert
+type State struct {
+	Fingerprint string `json:"fingerprint"`
+	// AlertID is the ID of the alert which opened the state
+	AlertID string `json:"alert_id"`
+	RuleID  string `json:"rule_id"`
+	// Group are values of the fields of the fingerprint
+	Group    map[string]string `json:"group,omitempty"`
+	Severity string            `json:"severity"`
+	// SeverityID is the highest severity of the occurrences
+	SeverityID int `json:"severity_id"`
+	// FirstSeen and LastSeen are times of the first and last events of the occurrences
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Occurrences is the number of alerts of the fingerprint while the state is open
+	Occurrences int `json:"occurrences"`
+	// EventCount is the highest event count of the occurrences. Alerts of overlapping runs
+	// count the same events, so counts are not summed.
+	EventCount int `json:"event_count"`
+	// NotifiedAt is the detection time of the last notified occurrence
+	NotifiedAt time.Time `json:"notified_at"`
+	// SnoozedUntil suppresses notifications of the state until the time
+	SnoozedUntil time.Time `json:"snoozed_until"`
+}
+
+// Store keeps states of open alerts by fingerprint
+type Store interface {
+	// Get returns the state of the fingerprint, or nil if there is none
+	Get(ctx context.Context, fingerprint string) (*State, error)
+	Put(ctx context.Context, state *State) error
+}
+
+// MemoryStore keeps states in memory, for tests and local runs
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: map[string]State{}}
+}
+
+func (x *MemoryStore) Get(ctx context.Context, fingerprint string) (*State, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	state, ok := x.states[fingerprint]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (x *MemoryStore) Put(ctx context.Context, state *State) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.states[state.Fingerprint] = *state
+	return nil
+}
+
+// S3API defines the S3 operations used by S3Store
+type S3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// Ensure that s3.Client implements S3API
+var _ S3API = (*s3.Client)(nil)
+
+// S3Store stores a JSON object per fingerprint under the prefix
+type S3Store struct {
+	client S3API
+	bucket string
+	prefix string
+}
+
+func NewS3Store(client S3API, bucket, prefix string) *S3Store {
+	return &S3Store{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (x *S3Store) key(fingerprint string) string {
+	return x.prefix + fingerprint + ".json"
+}
+
+func (x *S3Store) Get(ctx context.Context, fingerprint string) (*State, error) {
+	resp, err := x.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(x.bucket),
+		Key:    aws.String(x.key(fingerprint)),
+	})
+	if err != nil {
+		var noSuchKey *s3types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get alert state: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert state: %w", err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid alert state %s: %w", x.key(fingerprint), err)
+	}
+	return &state, nil
+}
+
+func (x *S3Store) Put(ctx context.Context, state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert state: %w", err)
+	}
+	_, err = x.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(x.bucket),
+		Key:         aws.String(x.key(state.Fingerprint)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put alert state: %w", err)
+	}
+	return nil
+}
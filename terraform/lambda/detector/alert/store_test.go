@@ -0,0 +1,99 @@
+package alert_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/internal/ocsftest"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 keeps objects of a bucket in memory
+type fakeS3 struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (x *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if x.err != nil {
+		return nil, x.err
+	}
+	data, ok := x.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (x *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if x.err != nil {
+		return nil, x.err
+	}
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	x.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestS3Store(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeS3{objects: map[string][]byte{}}
+	store := alert.NewS3Store(client, "state-bucket", "alerts/")
+
+	state, err := store.Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	put := &alert.State{
+		Fingerprint: "abc",
+		AlertID:     "alert-1",
+		RuleID:      "auth-brute-force",
+		Group:       map[string]string{"src_endpoint.ip": "133.200.32.94"},
+		Severity:    "high",
+		SeverityID:  4,
+		FirstSeen:   ocsftest.Base,
+		LastSeen:    ocsftest.Base.Add(time.Minute),
+		Occurrences: 2,
+		EventCount:  12,
+		NotifiedAt:  ocsftest.Base,
+	}
+	require.NoError(t, store.Put(ctx, put))
+	assert.Contains(t, client.objects, "state-bucket/alerts/abc.json")
+
+	state, err = store.Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, put, state)
+
+	// States survive across runs of the deduplicator with the store
+	dedup, err := alert.NewDeduplicator(store, alert.DedupConfig{})
+	require.NoError(t, err)
+	decision, err := dedup.Process(ctx, occurrence(0, "133.200.32.94", "tanaka@muhaijuku.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionNotify, decision)
+	dedup, err = alert.NewDeduplicator(store, alert.DedupConfig{})
+	require.NoError(t, err)
+	decision, err = dedup.Process(ctx, occurrence(10*time.Minute, "133.200.32.94", "tanaka@muhaijuku.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alert.DecisionUpdate, decision)
+
+	client.objects["state-bucket/alerts/broken.json"] = []byte("{")
+	_, err = store.Get(ctx, "broken")
+	assert.ErrorContains(t, err, "invalid alert state")
+
+	client.err = errors.New("access denied")
+	_, err = store.Get(ctx, "abc")
+	assert.ErrorContains(t, err, "access denied")
+	assert.ErrorContains(t, store.Put(ctx, put), "access denied")
+}
@@ -0,0 +1,270 @@
+// Package athena runs queries of detectors on Athena: it starts queries in the workgroup,
+// polls them with backoff until the context deadline, pages through results and decodes rows
+// into structs by column names. Queries must have a partition predicate (eventDay or time) and
+// are stopped when they scan more bytes than the ceiling.
+package athena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsathena "github.com/aws/aws-sdk-go-v2/service/athena"
+	athenatypes "github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// API defines the Athena operations used by Client
+type API interface {
+	StartQueryExecution(ctx context.Context, params *awsathena.StartQueryExecutionInput, optFns ...func(*awsathena.Options)) (*awsathena.StartQueryExecutionOutput, error)
+	GetQueryExecution(ctx context.Context, params *awsathena.GetQueryExecutionInput, optFns ...func(*awsathena.Options)) (*awsathena.GetQueryExecutionOutput, error)
+	GetQueryResults(ctx context.Context, params *awsathena.GetQueryResultsInput, optFns ...func(*awsathena.Options)) (*awsathena.GetQueryResultsOutput, error)
+	StopQueryExecution(ctx context.Context, params *awsathena.StopQueryExecutionInput, optFns ...func(*awsathena.Options)) (*awsathena.StopQueryExecutionOutput, error)
+}
+
+// Ensure that athena.Client implements API
+var _ API = (*awsathena.Client)(nil)
+
+const (
+	defaultPollInterval    = 500 * time.Millisecond
+	defaultMaxPollInterval = 5 * time.Second
+	defaultPageSize        = 1000
+	// stopTimeout bounds StopQueryExecution after the context of the query is done
+	stopTimeout = 10 * time.Second
+)
+
+// ErrBytesScannedExceeded is returned when a query scans more bytes than Config.MaxBytesScanned
+var ErrBytesScannedExceeded = errors.New("bytes scanned exceeded the ceiling")
+
+// Config configures Client
+type Config struct {
+	// WorkGroup runs queries, with the output location of the workgroup
+	WorkGroup string
+	// Database and Catalog are the default context of unqualified table names
+	Database string
+	Catalog  string
+	// MaxBytesScanned stops queries which scan more bytes. 0 disables the ceiling.
+	MaxBytesScanned int64
+	// PollInterval is the first interval of polling query state, doubled up to
+	// MaxPollInterval
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+	// PageSize is the number of rows of each GetQueryResults call, up to 1000
+	PageSize int32
+}
+
+// Client runs queries on Athena
+type Client struct {
+	api   API
+	cfg   Config
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New returns a client of the workgroup
+func New(api API, cfg Config) (*Client, error) {
+	if cfg.WorkGroup == "" {
+		return nil, errors.New("workgroup is required")
+	}
+	if cfg.MaxBytesScanned < 0 {
+		return nil, fmt.Errorf("invalid bytes scanned ceiling %d", cfg.MaxBytesScanned)
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.MaxPollInterval < cfg.PollInterval {
+		cfg.MaxPollInterval = max(defaultMaxPollInterval, cfg.PollInterval)
+	}
+	if cfg.PageSize <= 0 || cfg.PageSize > defaultPageSize {
+		cfg.PageSize = defaultPageSize
+	}
+	return &Client{api: api, cfg: cfg, sleep: sleep}, nil
+}
+
+// Result is the result of a query
+type Result struct {
+	QueryExecutionID string
+	Columns          []string
+	// Rows are values keyed by column names. NULL values are missing.
+	Rows         []map[string]string
+	BytesScanned int64
+}
+
+// Run runs the query and returns all rows of the result
+func (x *Client) Run(ctx context.Context, query string) (*Result, error) {
+	if err := CheckPartitionPredicate(query); err != nil {
+		return nil, err
+	}
+
+	input := &awsathena.StartQueryExecutionInput{
+		QueryString: aws.String(query),
+		WorkGroup:   aws.String(x.cfg.WorkGroup),
+	}
+	if x.cfg.Database != "" || x.cfg.Catalog != "" {
+		input.QueryExecutionContext = &athenatypes.QueryExecutionContext{}
+		if x.cfg.Database != "" {
+			input.QueryExecutionContext.Database = aws.String(x.cfg.Database)
+		}
+		if x.cfg.Catalog != "" {
+			input.QueryExecutionContext.Catalog = aws.String(x.cfg.Catalog)
+		}
+	}
+	started, err := x.api.StartQueryExecution(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start query: %w", err)
+	}
+	id := aws.ToString(started.QueryExecutionId)
+	slog.Info("Started Athena query", "query_execution_id", id, "workgroup", x.cfg.WorkGroup)
+
+	bytesScanned, err := x.wait(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result, err := x.results(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result.BytesScanned = bytesScanned
+	slog.Info("Completed Athena query", "query_execution_id", id, "rows", len(result.Rows), "bytes_scanned", bytesScanned)
+	return result, nil
+}
+
+// Query runs the query and returns rows, as detect.QueryRunner
+func (x *Client) Query(ctx context.Context, query string) ([]map[string]string, error) {
+	result, err := x.Run(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return result.Rows, nil
+}
+
+// wait polls the query until it succeeds, and returns bytes scanned by it. The query is
+// stopped when it fails to complete within the context or exceeds the bytes scanned ceiling.
+func (x *Client) wait(ctx context.Context, id string) (int64, error) {
+	interval := x.cfg.PollInterval
+	for {
+		out, err := x.api.GetQueryExecution(ctx, &awsathena.GetQueryExecutionInput{QueryExecutionId: aws.String(id)})
+		if err != nil {
+			if ctx.Err() != nil {
+				x.stop(ctx, id)
+			}
+			return 0, fmt.Errorf("failed to get query %s: %w", id, err)
+		}
+		execution := out.QueryExecution
+		if execution == nil || execution.Status == nil {
+			return 0, fmt.Errorf("query %s has no status", id)
+		}
+
+		var bytesScanned int64
+		if execution.Statistics != nil {
+			bytesScanned = aws.ToInt64(execution.Statistics.DataScannedInBytes)
+		}
+		if x.cfg.MaxBytesScanned > 0 && bytesScanned > x.cfg.MaxBytesScanned {
+			if execution.Status.State == athenatypes.QueryExecutionStateQueued || execution.Status.State == athenatypes.QueryExecutionStateRunning {
+				x.stop(ctx, id)
+			}
+			return bytesScanned, fmt.Errorf("query %s scanned %d bytes, more than %d: %w", id, bytesScanned, x.cfg.MaxBytesScanned, ErrBytesScannedExceeded)
+		}
+
+		switch execution.Status.State {
+		case athenatypes.QueryExecutionStateSucceeded:
+			return bytesScanned, nil
+		case athenatypes.QueryExecutionStateFailed, athenatypes.QueryExecutionStateCancelled:
+			reason := aws.ToString(execution.Status.StateChangeReason)
+			if execution.Status.AthenaError != nil && execution.Status.AthenaError.ErrorMessage != nil {
+				reason = aws.ToString(execution.Status.AthenaError.ErrorMessage)
+			}
+			return bytesScanned, fmt.Errorf("query %s %s: %s", id, execution.Status.State, reason)
+		}
+
+		if err := x.sleep(ctx, interval); err != nil {
+			x.stop(ctx, id)
+			return bytesScanned, fmt.Errorf("query %s did not complete: %w", id, err)
+		}
+		interval = min(interval*2, x.cfg.MaxPollInterval)
+	}
+}
+
+// stop stops the query, also after the context of the query is done
+func (x *Client) stop(ctx context.Context, id string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopTimeout)
+	defer cancel()
+	if _, err := x.api.StopQueryExecution(ctx, &awsathena.StopQueryExecutionInput{QueryExecutionId: aws.String(id)}); err != nil {
+		slog.Warn("Failed to stop Athena query", "query_execution_id", id, "error", err)
+		return
+	}
+	slog.Info("Stopped Athena query", "query_execution_id", id)
+}
+
+// results pages through the results of the query
+func (x *Client) results(ctx context.Context, id string) (*Result, error) {
+	result := &Result{QueryExecutionID: id}
+	var token *string
+	for page := 0; ; page++ {
+		out, err := x.api.GetQueryResults(ctx, &awsathena.GetQueryResultsInput{
+			QueryExecutionId: aws.String(id),
+			MaxResults:       aws.Int32(x.cfg.PageSize),
+			NextToken:        token,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get results of query %s: %w", id, err)
+		}
+		if out.ResultSet == nil {
+			return nil, fmt.Errorf("query %s has no result set", id)
+		}
+
+		rows := out.ResultSet.Rows
+		if page == 0 {
+			if out.ResultSet.ResultSetMetadata != nil {
+				for _, column := range out.ResultSet.ResultSetMetadata.ColumnInfo {
+					result.Columns = append(result.Columns, aws.ToString(column.Name))
+				}
+			}
+			// The first row of SELECT results is the header
+			if len(rows) > 0 && isHeader(rows[0], result.Columns) {
+				rows = rows[1:]
+			}
+		}
+		for _, row := range rows {
+			if len(row.Data) != len(result.Columns) {
+				return nil, fmt.Errorf("query %s returned %d values for %d columns", id, len(row.Data), len(result.Columns))
+			}
+			values := make(map[string]string, len(row.Data))
+			for i, datum := range row.Data {
+				if datum.VarCharValue != nil {
+					values[result.Columns[i]] = *datum.VarCharValue
+				}
+			}
+			result.Rows = append(result.Rows, values)
+		}
+
+		token = out.NextToken
+		if token == nil {
+			return result, nil
+		}
+	}
+}
+
+func isHeader(row athenatypes.Row, columns []string) bool {
+	if len(row.Data) != len(columns) {
+		return false
+	}
+	for i, datum := range row.Data {
+		if aws.ToString(datum.VarCharValue) != columns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,230 @@
+package athena_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-detector/athena"
+	"seccamp2025-b1-detector/detect"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsathena "github.com/aws/aws-sdk-go-v2/service/athena"
+	athenatypes "github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Client runs SQL rules of detectors
+var _ detect.QueryRunner = (*athena.Client)(nil)
+
+const testQuery = "SELECT user, count FROM logs WHERE eventday = '20240812'"
+
+// fakeAPI runs a single query through the given states, then returns pages of results
+type fakeAPI struct {
+	mu           sync.Mutex
+	states       []athenatypes.QueryExecutionState
+	bytesScanned []int64
+	reason       string
+	pages        []*athenatypes.ResultSet
+	startErr     error
+
+	started   []*awsathena.StartQueryExecutionInput
+	polls     int
+	tokens    []*string
+	stopped   []string
+	pageSizes []int32
+}
+
+func (x *fakeAPI) StartQueryExecution(ctx context.Context, params *awsathena.StartQueryExecutionInput, optFns ...func(*awsathena.Options)) (*awsathena.StartQueryExecutionOutput, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.started = append(x.started, params)
+	if x.startErr != nil {
+		return nil, x.startErr
+	}
+	return &awsathena.StartQueryExecutionOutput{QueryExecutionId: aws.String("q-1")}, nil
+}
+
+func (x *fakeAPI) GetQueryExecution(ctx context.Context, params *awsathena.GetQueryExecutionInput, optFns ...func(*awsathena.Options)) (*awsathena.GetQueryExecutionOutput, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	poll := x.polls
+	x.polls++
+	status := &athenatypes.QueryExecutionStatus{State: x.states[min(poll, len(x.states)-1)]}
+	if x.reason != "" {
+		status.StateChangeReason = aws.String(x.reason)
+	}
+	execution := &athenatypes.QueryExecution{QueryExecutionId: params.QueryExecutionId, Status: status}
+	if len(x.bytesScanned) > 0 {
+		execution.Statistics = &athenatypes.QueryExecutionStatistics{
+			DataScannedInBytes: aws.Int64(x.bytesScanned[min(poll, len(x.bytesScanned)-1)]),
+		}
+	}
+	return &awsathena.GetQueryExecutionOutput{QueryExecution: execution}, nil
+}
+
+func (x *fakeAPI) GetQueryResults(ctx context.Context, params *awsathena.GetQueryResultsInput, optFns ...func(*awsathena.Options)) (*awsathena.GetQueryResultsOutput, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.tokens = append(x.tokens, params.NextToken)
+	x.pageSizes = append(x.pageSizes, aws.ToInt32(params.MaxResults))
+	page := 0
+	if params.NextToken != nil {
+		fmt.Sscanf(*params.NextToken, "page-%d", &page)
+	}
+	out := &awsathena.GetQueryResultsOutput{ResultSet: x.pages[page]}
+	if page+1 < len(x.pages) {
+		out.NextToken = aws.String(fmt.Sprintf("page-%d", page+1))
+	}
+	return out, nil
+}
+
+func (x *fakeAPI) StopQueryExecution(ctx context.Context, params *awsathena.StopQueryExecutionInput, optFns ...func(*awsathena.Options)) (*awsathena.StopQueryExecutionOutput, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	x.stopped = append(x.stopped, aws.ToString(params.QueryExecutionId))
+	return &awsathena.StopQueryExecutionOutput{}, nil
+}
+
+func row(values ...*string) athenatypes.Row {
+	var data []athenatypes.Datum
+	for _, v := range values {
+		data = append(data, athenatypes.Datum{VarCharValue: v})
+	}
+	return athenatypes.Row{Data: data}
+}
+
+func resultSet(columns []string, rows ...athenatypes.Row) *athenatypes.ResultSet {
+	var info []athenatypes.ColumnInfo
+	for _, column := range columns {
+		info = append(info, athenatypes.ColumnInfo{Name: aws.String(column), Type: aws.String("varchar")})
+	}
+	return &athenatypes.ResultSet{ResultSetMetadata: &athenatypes.ResultSetMetadata{ColumnInfo: info}, Rows: rows}
+}
+
+func newClient(t *testing.T, api athena.API, cfg athena.Config) *athena.Client {
+	cfg.WorkGroup = "seccamp-workgroup"
+	cfg.PollInterval = time.Millisecond
+	client, err := athena.New(api, cfg)
+	require.NoError(t, err)
+	return client
+}
+
+func TestClient_Run(t *testing.T) {
+	columns := []string{"user", "count"}
+	api := &fakeAPI{
+		states: []athenatypes.QueryExecutionState{
+			athenatypes.QueryExecutionStateQueued,
+			athenatypes.QueryExecutionStateRunning,
+			athenatypes.QueryExecutionStateSucceeded,
+		},
+		bytesScanned: []int64{0, 512, 1024},
+		pages: []*athenatypes.ResultSet{
+			// The header is the first row of the first page
+			resultSet(columns, row(aws.String("user"), aws.String("count")), row(aws.String("a@example.com"), aws.String("3"))),
+			resultSet(columns, row(aws.String("b@example.com"), nil)),
+		},
+	}
+	client := newClient(t, api, athena.Config{Database: "security_lake", PageSize: 2})
+
+	result, err := client.Run(context.Background(), testQuery)
+	require.NoError(t, err)
+	assert.Equal(t, "q-1", result.QueryExecutionID)
+	assert.Equal(t, columns, result.Columns)
+	assert.Equal(t, []map[string]string{
+		{"user": "a@example.com", "count": "3"},
+		{"user": "b@example.com"},
+	}, result.Rows)
+	assert.Equal(t, int64(1024), result.BytesScanned)
+
+	require.Len(t, api.started, 1)
+	assert.Equal(t, testQuery, aws.ToString(api.started[0].QueryString))
+	assert.Equal(t, "seccamp-workgroup", aws.ToString(api.started[0].WorkGroup))
+	assert.Equal(t, "security_lake", aws.ToString(api.started[0].QueryExecutionContext.Database))
+	assert.Nil(t, api.started[0].QueryExecutionContext.Catalog)
+	assert.Equal(t, 3, api.polls)
+	assert.Equal(t, []*string{nil, aws.String("page-1")}, api.tokens)
+	assert.Equal(t, []int32{2, 2}, api.pageSizes)
+	assert.Empty(t, api.stopped)
+}
+
+func TestClient_Query(t *testing.T) {
+	api := &fakeAPI{
+		states: []athenatypes.QueryExecutionState{athenatypes.QueryExecutionStateSucceeded},
+		pages:  []*athenatypes.ResultSet{resultSet([]string{"n"}, row(aws.String("n")), row(aws.String("1")))},
+	}
+	rows, err := newClient(t, api, athena.Config{}).Query(context.Background(), testQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"n": "1"}}, rows)
+	assert.Equal(t, []int32{1000}, api.pageSizes)
+}
+
+func TestClient_Run_Failed(t *testing.T) {
+	api := &fakeAPI{
+		states: []athenatypes.QueryExecutionState{athenatypes.QueryExecutionStateRunning, athenatypes.QueryExecutionStateFailed},
+		reason: "SYNTAX_ERROR: line 1:8: Column 'usr' cannot be resolved",
+	}
+	_, err := newClient(t, api, athena.Config{}).Run(context.Background(), testQuery)
+	assert.EqualError(t, err, "query q-1 FAILED: SYNTAX_ERROR: line 1:8: Column 'usr' cannot be resolved")
+
+	api = &fakeAPI{startErr: errors.New("throttled")}
+	_, err = newClient(t, api, athena.Config{}).Run(context.Background(), testQuery)
+	assert.EqualError(t, err, "failed to start query: throttled")
+}
+
+func TestClient_Run_Deadline(t *testing.T) {
+	api := &fakeAPI{states: []athenatypes.QueryExecutionState{athenatypes.QueryExecutionStateRunning}}
+	client := newClient(t, api, athena.Config{MaxPollInterval: 5 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.Run(ctx, testQuery)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Greater(t, api.polls, 3)
+	// The query is stopped after the deadline
+	assert.Equal(t, []string{"q-1"}, api.stopped)
+}
+
+func TestClient_Run_BytesScannedCeiling(t *testing.T) {
+	api := &fakeAPI{
+		states:       []athenatypes.QueryExecutionState{athenatypes.QueryExecutionStateRunning},
+		bytesScanned: []int64{100, 2000, 4000},
+	}
+	client := newClient(t, api, athena.Config{MaxBytesScanned: 1000})
+
+	_, err := client.Run(context.Background(), testQuery)
+	require.ErrorIs(t, err, athena.ErrBytesScannedExceeded)
+	assert.ErrorContains(t, err, "query q-1 scanned 2000 bytes, more than 1000")
+	assert.Equal(t, 2, api.polls)
+	assert.Equal(t, []string{"q-1"}, api.stopped)
+
+	// Completed queries are rejected without stopping
+	api = &fakeAPI{
+		states:       []athenatypes.QueryExecutionState{athenatypes.QueryExecutionStateSucceeded},
+		bytesScanned: []int64{2000},
+	}
+	_, err = newClient(t, api, athena.Config{MaxBytesScanned: 1000}).Run(context.Background(), testQuery)
+	require.ErrorIs(t, err, athena.ErrBytesScannedExceeded)
+	assert.Empty(t, api.stopped)
+}
+
+func TestClient_Run_RejectsUnpartitionedQuery(t *testing.T) {
+	api := &fakeAPI{}
+	_, err := newClient(t, api, athena.Config{}).Run(context.Background(), "SELECT * FROM logs")
+	require.ErrorIs(t, err, athena.ErrNoPartitionPredicate)
+	assert.Empty(t, api.started)
+}
+
+func TestNew(t *testing.T) {
+	_, err := athena.New(&fakeAPI{}, athena.Config{})
+	assert.EqualError(t, err, "workgroup is required")
+	_, err = athena.New(&fakeAPI{}, athena.Config{WorkGroup: "w", MaxBytesScanned: -1})
+	assert.EqualError(t, err, "invalid bytes scanned ceiling -1")
+}
@@ -0,0 +1,134 @@
+package athena
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestampLayouts are formats of Athena timestamp values
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999 MST",
+	"2006-01-02 15:04:05.999999999 -07:00",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// QueryInto runs the query and decodes rows into T, see Decode
+func QueryInto[T any](ctx context.Context, client *Client, query string) ([]T, error) {
+	result, err := client.Run(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return Decode[T](result.Rows)
+}
+
+// Decode decodes rows into structs by column names. A field is set from the column of its
+// athena tag, or of its name in lower case. Fields of strings, integers, floats, booleans,
+// time.Time (unix milliseconds or Athena timestamps), encoding.TextUnmarshaler and pointers to
+// them are supported. NULL values leave fields zero, and pointers nil.
+func Decode[T any](rows []map[string]string) ([]T, error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot decode rows into %s, which is not a struct", t)
+	}
+	columns := map[string]int{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get("athena")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		columns[name] = i
+	}
+
+	decoded := make([]T, len(rows))
+	for i, row := range rows {
+		v := reflect.ValueOf(&decoded[i]).Elem()
+		for column, value := range row {
+			index, ok := columns[column]
+			if !ok {
+				continue
+			}
+			if err := setValue(v.Field(index), value); err != nil {
+				return nil, fmt.Errorf("row %d: column %s: %w", i+1, column, err)
+			}
+		}
+	}
+	return decoded, nil
+}
+
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok && v.Type() != reflect.TypeFor[time.Time]() {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected boolean, got %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected integer, got %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected unsigned integer, got %q", value)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected number, got %q", value)
+		}
+		v.SetFloat(n)
+	case reflect.Struct:
+		if v.Type() != reflect.TypeFor[time.Time]() {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		t, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// parseTime parses unix milliseconds, as the time column of OCSF, or an Athena timestamp
+func parseTime(value string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected time, got %q", value)
+}
@@ -0,0 +1,93 @@
+package athena_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-detector/athena"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	athenatypes "github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type loginFailure struct {
+	User      string     `athena:"user_email"`
+	IP        netip.Addr `athena:"source_ip"`
+	Failures  int
+	Ratio     float64 `athena:"failure_ratio"`
+	Blocked   *bool
+	FirstSeen time.Time  `athena:"first_seen"`
+	LastSeen  *time.Time `athena:"last_seen"`
+	Ignored   string     `athena:"-"`
+}
+
+func TestDecode(t *testing.T) {
+	records, err := athena.Decode[loginFailure]([]map[string]string{
+		{
+			"user_email":    "a@example.com",
+			"source_ip":     "192.0.2.1",
+			"failures":      "12",
+			"failure_ratio": "0.95",
+			"blocked":       "true",
+			"first_seen":    "1723453200000",
+			"last_seen":     "2024-08-12 09:05:00.000 UTC",
+			"ignored":       "x",
+			"other":         "y",
+		},
+		// NULL values are missing
+		{"user_email": "b@example.com", "source_ip": "198.51.100.1", "failures": "1", "first_seen": "2024-08-12T09:00:00Z"},
+	})
+	require.NoError(t, err)
+
+	lastSeen := time.Date(2024, 8, 12, 9, 5, 0, 0, time.UTC)
+	blocked := true
+	assert.Equal(t, []loginFailure{
+		{
+			User:      "a@example.com",
+			IP:        netip.MustParseAddr("192.0.2.1"),
+			Failures:  12,
+			Ratio:     0.95,
+			Blocked:   &blocked,
+			FirstSeen: time.Date(2024, 8, 12, 9, 0, 0, 0, time.UTC),
+			LastSeen:  &lastSeen,
+		},
+		{
+			User:      "b@example.com",
+			IP:        netip.MustParseAddr("198.51.100.1"),
+			Failures:  1,
+			FirstSeen: time.Date(2024, 8, 12, 9, 0, 0, 0, time.UTC),
+		},
+	}, records)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	_, err := athena.Decode[loginFailure]([]map[string]string{{"failures": "many"}})
+	assert.EqualError(t, err, `row 1: column failures: expected integer, got "many"`)
+
+	_, err = athena.Decode[loginFailure]([]map[string]string{{"user_email": "a"}, {"first_seen": "yesterday"}})
+	assert.EqualError(t, err, `row 2: column first_seen: expected time, got "yesterday"`)
+
+	_, err = athena.Decode[string](nil)
+	assert.EqualError(t, err, "cannot decode rows into string, which is not a struct")
+
+	type unsupported struct{ Values []string }
+	_, err = athena.Decode[unsupported]([]map[string]string{{"values": "[a, b]"}})
+	assert.EqualError(t, err, "row 1: column values: unsupported type []string")
+}
+
+func TestQueryInto(t *testing.T) {
+	api := &fakeAPI{
+		states: []athenatypes.QueryExecutionState{athenatypes.QueryExecutionStateSucceeded},
+		pages: []*athenatypes.ResultSet{resultSet([]string{"user_email", "failures"},
+			row(aws.String("user_email"), aws.String("failures")),
+			row(aws.String("a@example.com"), aws.String("12")),
+		)},
+	}
+	records, err := athena.QueryInto[loginFailure](context.Background(), newClient(t, api, athena.Config{}), testQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []loginFailure{{User: "a@example.com", Failures: 12}}, records)
+}
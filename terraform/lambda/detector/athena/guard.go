@@ -0,0 +1,39 @@
+package athena
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrNoPartitionPredicate is returned for queries without a predicate on eventDay or time,
+// which would scan all partitions of Security Lake tables
+var ErrNoPartitionPredicate = errors.New("query has no eventDay or time predicate")
+
+var (
+	// sqlLiteral matches string literals, quoted identifiers and comments, which are removed
+	// before looking for predicates
+	sqlLiteral = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"|--[^\n]*|/\*(?s:.*?)\*/`)
+	// partitionPredicate matches comparisons of eventDay or time columns, also qualified by a
+	// table alias
+	partitionPredicate = regexp.MustCompile(`(?i)(?:^|[^\w.])(?:\w+\.)?(?:eventday|time)\s*(?:=|<>|!=|<=?|>=?|between\b|in\s*\()`)
+	whereKeyword       = regexp.MustCompile(`(?i)\bwhere\b`)
+)
+
+// CheckPartitionPredicate checks that a WHERE clause of the query compares eventDay or time
+// columns. The check is lexical: it does not prove that every table scan is restricted.
+func CheckPartitionPredicate(query string) error {
+	stripped := sqlLiteral.ReplaceAllStringFunc(query, func(s string) string {
+		// Keep quoted identifiers such as "eventDay" and drop the others
+		if strings.HasPrefix(s, `"`) {
+			return strings.ReplaceAll(strings.Trim(s, `"`), `""`, `"`)
+		}
+		return " "
+	})
+	for _, loc := range whereKeyword.FindAllStringIndex(stripped, -1) {
+		if partitionPredicate.MatchString(stripped[loc[1]:]) {
+			return nil
+		}
+	}
+	return ErrNoPartitionPredicate
+}
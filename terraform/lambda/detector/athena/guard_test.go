@@ -0,0 +1,36 @@
+package athena_test
+
+import (
+	"testing"
+
+	"seccamp2025-b1-detector/athena"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPartitionPredicate(t *testing.T) {
+	for query, ok := range map[string]bool{
+		"SELECT * FROM logs WHERE eventday = '20240812'":                             true,
+		"SELECT * FROM logs WHERE eventDay BETWEEN '20240811' AND '20240812'":        true,
+		`SELECT * FROM logs l WHERE l."eventDay" >= '20240811'`:                      true,
+		"SELECT * FROM logs WHERE eventday IN ('20240811', '20240812')":              true,
+		"SELECT * FROM logs WHERE status_id = 2 AND time >= 1723420800000":           true,
+		"SELECT * FROM logs\nWHERE\n  time<1723420800000":                            true,
+		"WITH x AS (SELECT * FROM logs WHERE eventday = '20240812') SELECT * FROM x": true,
+		"SELECT * FROM logs": false,
+		"SELECT eventday, time FROM logs WHERE status_id = 2":                               false,
+		"SELECT * FROM logs WHERE api.operation = 'time >= 1'":                              false,
+		"SELECT * FROM logs WHERE status_id = 2 -- AND eventday = '20240812'":               false,
+		"SELECT * FROM logs WHERE status_id = 2 /* eventday = '20240812' */":                false,
+		"SELECT * FROM logs WHERE start_time >= 1723420800000":                              false,
+		"SELECT * FROM logs WHERE metadata.logged_time >= 1723420800000":                    false,
+		"SELECT eventday FROM logs GROUP BY eventday HAVING COUNT(*) > 1 ORDER BY eventday": false,
+	} {
+		err := athena.CheckPartitionPredicate(query)
+		if ok {
+			assert.NoError(t, err, query)
+		} else {
+			assert.ErrorIs(t, err, athena.ErrNoPartitionPredicate, query)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// Package baseline learns the behavior of users and their peer groups from OCSF records, and
+// scores activities against it.
+//
+// Builder counts activities of each user in buckets (an hour by default) over a trailing
+// window, and summarizes them into profiles: statistics of activities and downloads per
+// bucket for each hour of day, the share of activities in each hour of day, and typical source
+// IP addresses and services. Peer groups, such as the roles of loggen users, have profiles of
+// their members in the same way. Scorer flags buckets of a user at an unusual hour of day and
+// with unusual numbers of activities or downloads, against the profile of the user, or of the
+// peer group if the user has little history.
+package baseline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+)
+
+const (
+	// DefaultWindow is the default trailing window of a baseline
+	DefaultWindow = 14 * 24 * time.Hour
+	// DefaultBucket is the default period of counts of activities
+	DefaultBucket = time.Hour
+
+	// downloadActivityID is activity_id of downloads (Export)
+	downloadActivityID = 7
+)
+
+// Stat summarizes counts of buckets
+type Stat struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	Max    int     `json:"max"`
+}
+
+// ZScore returns the number of standard deviations of n above the mean. The standard
+// deviation is at least one, as counts are integers and quiet hours have none.
+func (x Stat) ZScore(n int) float64 {
+	return (float64(n) - x.Mean) / math.Max(x.StdDev, 1)
+}
+
+// accumulator computes a Stat over samples, including zero samples which are not added
+type accumulator struct {
+	sum, sumSq float64
+	max        int
+}
+
+func (x *accumulator) add(n int) {
+	x.sum += float64(n)
+	x.sumSq += float64(n) * float64(n)
+	x.max = max(x.max, n)
+}
+
+// stat returns the statistics of n samples
+func (x *accumulator) stat(n int) Stat {
+	if n == 0 {
+		return Stat{}
+	}
+	mean := x.sum / float64(n)
+	variance := math.Max(x.sumSq/float64(n)-mean*mean, 0)
+	return Stat{Mean: mean, StdDev: math.Sqrt(variance), Max: x.max}
+}
+
+// Profile is the behavior of a user or a peer group in the window
+type Profile struct {
+	// Members are users of a peer group with activities in the window
+	Members int `json:"members,omitempty"`
+	// Days are days with activities in the window
+	Days   int `json:"days"`
+	Events int `json:"events"`
+	// Activities and Downloads are statistics of counts of a user per bucket, for each hour of
+	// day (UTC). Buckets without activities count as zero.
+	Activities [24]Stat `json:"activities"`
+	Downloads  [24]Stat `json:"downloads"`
+	// Share is the ratio of activities in each hour of day
+	Share [24]float64 `json:"share"`
+	// IPs and Services are numbers of activities of source IP addresses and services
+	IPs      map[string]int `json:"ips"`
+	Services map[string]int `json:"services"`
+}
+
+// Percentile returns the ratio of activities in hours of day as quiet as the hour or quieter.
+// It is small for hours in which the user or the group rarely works.
+func (x *Profile) Percentile(hour int) float64 {
+	var p float64
+	for _, share := range x.Share {
+		if share <= x.Share[hour] {
+			p += share
+		}
+	}
+	return p
+}
+
+// Baseline is profiles of users and peer groups, built over [Start, End)
+type Baseline struct {
+	Start  time.Time           `json:"start"`
+	End    time.Time           `json:"end"`
+	Bucket time.Duration       `json:"bucket"`
+	Users  map[string]*Profile `json:"users"`
+	Groups map[string]*Profile `json:"groups,omitempty"`
+	// Peers map users to their peer groups
+	Peers map[string]string `json:"peers,omitempty"`
+}
+
+// Read reads a baseline written by Write
+func Read(r io.Reader) (*Baseline, error) {
+	var x Baseline
+	if err := json.NewDecoder(r).Decode(&x); err != nil {
+		return nil, fmt.Errorf("invalid baseline: %w", err)
+	}
+	if x.Bucket <= 0 {
+		return nil, fmt.Errorf("invalid baseline: bucket must be positive")
+	}
+	return &x, nil
+}
+
+// Write writes the baseline as JSON
+func (x *Baseline) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(x)
+}
+
+// ReadPeers reads users and their peer groups of JSON lines with email and group, as written
+// by loggen users
+func ReadPeers(r io.Reader) (map[string]string, error) {
+	peers := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var user struct {
+			Email string `json:"email"`
+			Group string `json:"group"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &user); err != nil {
+			return nil, fmt.Errorf("invalid user at line %d: %w", line, err)
+		}
+		if user.Email == "" || user.Group == "" {
+			return nil, fmt.Errorf("user at line %d requires email and group", line)
+		}
+		peers[user.Email] = user.Group
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+	return peers, nil
+}
+
+// Config configures a Builder. Zero values are replaced by defaults.
+type Config struct {
+	// Window is the trailing period of records before the end
+	Window time.Duration
+	// Bucket is the period of counts of activities. Scores compare counts of the same period.
+	Bucket time.Duration
+	// Peers map users to their peer groups. Users without a group have no peer profile.
+	Peers map[string]string
+}
+
+// Builder accumulates records of the window into a baseline
+type Builder struct {
+	cfg        Config
+	start, end time.Time
+	// covered are buckets with any record, in unix seconds. Users without activities in a
+	// covered bucket count zero for it, while uncovered buckets have no data.
+	covered map[int64]bool
+	users   map[string]*history
+}
+
+// history is activities of a user
+type history struct {
+	activities map[int64]int
+	downloads  map[int64]int
+	ips        map[string]int
+	services   map[string]int
+}
+
+// NewBuilder returns a builder of the window ending at end
+func NewBuilder(cfg Config, end time.Time) *Builder {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.Bucket <= 0 {
+		cfg.Bucket = DefaultBucket
+	}
+	end = end.UTC()
+	return &Builder{
+		cfg:     cfg,
+		start:   end.Add(-cfg.Window),
+		end:     end,
+		covered: map[int64]bool{},
+		users:   map[string]*history{},
+	}
+}
+
+// bucketOf returns the start of the bucket of the time in unix seconds
+func bucketOf(t time.Time, bucket time.Duration) int64 {
+	return t.Truncate(bucket).Unix()
+}
+
+// Add counts a record in the window. Records outside of the window are ignored.
+func (x *Builder) Add(record *core.OCSFWebResourceActivity) {
+	t := time.UnixMilli(record.Time).UTC()
+	if t.Before(x.start) || !t.Before(x.end) {
+		return
+	}
+	bucket := bucketOf(t, x.cfg.Bucket)
+	x.covered[bucket] = true
+
+	user := record.Actor.User.EmailAddr
+	if user == "" {
+		return
+	}
+	h, ok := x.users[user]
+	if !ok {
+		h = &history{activities: map[int64]int{}, downloads: map[int64]int{}, ips: map[string]int{}, services: map[string]int{}}
+		x.users[user] = h
+	}
+	h.activities[bucket]++
+	if record.ActivityID == downloadActivityID {
+		h.downloads[bucket]++
+	}
+	if ip := record.SrcEndpoint.IP; ip != "" {
+		h.ips[ip]++
+	}
+	if service := record.API.Service.Name; service != "" {
+		h.services[service]++
+	}
+}
+
+// Build returns profiles of users and their peer groups
+func (x *Builder) Build() *Baseline {
+	// covered buckets of each hour of day
+	var hours [24][]int64
+	for _, bucket := range slices.Sorted(maps.Keys(x.covered)) {
+		hour := time.Unix(bucket, 0).UTC().Hour()
+		hours[hour] = append(hours[hour], bucket)
+	}
+
+	b := &Baseline{
+		Start:  x.start,
+		End:    x.end,
+		Bucket: x.cfg.Bucket,
+		Users:  map[string]*Profile{},
+		Groups: map[string]*Profile{},
+		Peers:  map[string]string{},
+	}
+	members := map[string][]*history{}
+	for _, user := range slices.Sorted(maps.Keys(x.users)) {
+		h := x.users[user]
+		b.Users[user] = profile(&hours, h)
+		if group, ok := x.cfg.Peers[user]; ok {
+			b.Peers[user] = group
+			members[group] = append(members[group], h)
+		}
+	}
+	for group, histories := range members {
+		b.Groups[group] = profile(&hours, histories...)
+		b.Groups[group].Members = len(histories)
+	}
+	return b
+}
+
+// profile summarizes histories of users. Counts are per user and bucket, so a peer group
+// profile is the behavior of a typical member.
+func profile(hours *[24][]int64, histories ...*history) *Profile {
+	p := &Profile{IPs: map[string]int{}, Services: map[string]int{}}
+	days := map[int64]bool{}
+	var perHour [24]int
+	for hour, buckets := range hours {
+		var activities, downloads accumulator
+		for _, h := range histories {
+			for _, b := range buckets {
+				n := h.activities[b]
+				activities.add(n)
+				downloads.add(h.downloads[b])
+				if n > 0 {
+					perHour[hour] += n
+					days[b/int64(24*time.Hour/time.Second)] = true
+				}
+			}
+		}
+		samples := len(buckets) * len(histories)
+		p.Activities[hour] = activities.stat(samples)
+		p.Downloads[hour] = downloads.stat(samples)
+	}
+	for _, h := range histories {
+		for ip, n := range h.ips {
+			p.IPs[ip] += n
+		}
+		for service, n := range h.services {
+			p.Services[service] += n
+		}
+	}
+	for _, n := range perHour {
+		p.Events += n
+	}
+	if p.Events > 0 {
+		for hour, n := range perHour {
+			p.Share[hour] = float64(n) / float64(p.Events)
+		}
+	}
+	p.Days = len(days)
+	return p
+}
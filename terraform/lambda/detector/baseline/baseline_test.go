@@ -0,0 +1,215 @@
+package baseline_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/baseline"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+	"seccamp2025-b1-detector/travel"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// end is the end of the window of the baselines of the tests
+var end = time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC)
+
+// history returns records of the user over the days before end: n activities at 10:00 and
+// 14:00 each day, and a download at 10:00
+func history(user string, days, n int) []core.OCSFWebResourceActivity {
+	var records []core.OCSFWebResourceActivity
+	for day := 1; day <= days; day++ {
+		date := end.AddDate(0, 0, -day)
+		for _, hour := range []int{10, 14} {
+			for i := range n {
+				records = append(records, ocsftest.Record(date.Add(time.Duration(hour)*time.Hour+time.Duration(i)*time.Minute), user, "192.0.2.10", ocsftest.Activity(2)))
+			}
+		}
+		records = append(records, ocsftest.Record(date.Add(10*time.Hour+30*time.Minute), user, "192.0.2.10", ocsftest.Activity(7)))
+	}
+	return records
+}
+
+func build(cfg baseline.Config, records ...[]core.OCSFWebResourceActivity) *baseline.Baseline {
+	builder := baseline.NewBuilder(cfg, end)
+	for _, rs := range records {
+		for i := range rs {
+			builder.Add(&rs[i])
+		}
+	}
+	return builder.Build()
+}
+
+func TestBuilder(t *testing.T) {
+	const (
+		alice = "alice@muhaijuku.com"
+		bob   = "bob@muhaijuku.com"
+	)
+	// Bob works only on the last day
+	bobRecords := []core.OCSFWebResourceActivity{ocsftest.Record(end.Add(-14*time.Hour), bob, "192.0.2.10", ocsftest.Activity(2))}
+	outside := []core.OCSFWebResourceActivity{
+		ocsftest.Record(end.Add(-baseline.DefaultWindow-time.Hour), alice, "192.0.2.10", ocsftest.Activity(2)),
+		ocsftest.Record(end, alice, "192.0.2.10", ocsftest.Activity(2)),
+	}
+	b := build(baseline.Config{Peers: map[string]string{alice: "staff", bob: "staff"}},
+		history(alice, 10, 4), bobRecords, outside)
+
+	assert.Equal(t, end.Add(-baseline.DefaultWindow), b.Start)
+	assert.Equal(t, end, b.End)
+	assert.Equal(t, time.Hour, b.Bucket)
+
+	p := b.Users[alice]
+	require.NotNil(t, p)
+	assert.Equal(t, 10, p.Days)
+	assert.Equal(t, 90, p.Events)
+	assert.Equal(t, baseline.Stat{Mean: 5, Max: 5}, p.Activities[10])
+	assert.Equal(t, baseline.Stat{Mean: 1, Max: 1}, p.Downloads[10])
+	assert.Equal(t, baseline.Stat{Mean: 4, Max: 4}, p.Activities[14])
+	assert.Equal(t, baseline.Stat{}, p.Activities[3])
+	assert.InDelta(t, 50.0/90, p.Share[10], 1e-9)
+	assert.InDelta(t, 40.0/90, p.Share[14], 1e-9)
+	assert.InDelta(t, 40.0/90, p.Percentile(14), 1e-9)
+	assert.InDelta(t, 1, p.Percentile(10), 1e-9)
+	assert.Zero(t, p.Percentile(3))
+	assert.Equal(t, map[string]int{"192.0.2.10": 90}, p.IPs)
+	assert.Equal(t, map[string]int{"Google Drive API": 90}, p.Services)
+
+	// Bob counts zero in buckets covered by Alice
+	bobProfile := b.Users[bob]
+	assert.Equal(t, 1, bobProfile.Days)
+	assert.InDelta(t, 0.1, bobProfile.Activities[10].Mean, 1e-9)
+	assert.InDelta(t, 0.3, bobProfile.Activities[10].StdDev, 1e-9)
+
+	group := b.Groups["staff"]
+	require.NotNil(t, group)
+	assert.Equal(t, 2, group.Members)
+	assert.Equal(t, 91, group.Events)
+	assert.InDelta(t, 2.55, group.Activities[10].Mean, 1e-9)
+	assert.Equal(t, map[string]string{alice: "staff", bob: "staff"}, b.Peers)
+}
+
+func TestBaseline_ReadWrite(t *testing.T) {
+	b := build(baseline.Config{Bucket: 10 * time.Minute, Peers: map[string]string{"alice@muhaijuku.com": "staff"}},
+		history("alice@muhaijuku.com", 2, 3))
+
+	var buf bytes.Buffer
+	require.NoError(t, b.Write(&buf))
+	read, err := baseline.Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, b, read)
+
+	_, err = baseline.Read(strings.NewReader(`{"users": {}}`))
+	assert.ErrorContains(t, err, "bucket must be positive")
+}
+
+func TestReadPeers(t *testing.T) {
+	peers, err := baseline.ReadPeers(strings.NewReader(`{"email":"alice@muhaijuku.com","role":"cto","group":"staff"}
+
+{"email":"bob@muhaijuku.com","role":"instructor","group":"instructor"}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"alice@muhaijuku.com": "staff", "bob@muhaijuku.com": "instructor"}, peers)
+
+	_, err = baseline.ReadPeers(strings.NewReader(`{"email":"alice@muhaijuku.com"}`))
+	assert.ErrorContains(t, err, "user at line 1 requires email and group")
+}
+
+func TestScorer(t *testing.T) {
+	const (
+		alice = "alice@muhaijuku.com"
+		carol = "carol@muhaijuku.com"
+	)
+	peers := map[string]string{alice: "instructor", carol: "instructor"}
+	b := build(baseline.Config{Peers: peers}, history(alice, 10, 4))
+	// Carol is new, and compared with the instructors
+	b.Peers[carol] = "instructor"
+	scorer := baseline.NewScorer(b, baseline.ScoreConfig{})
+
+	p, name := scorer.Profile(alice)
+	assert.Same(t, b.Users[alice], p)
+	assert.Equal(t, "user", name)
+	p, name = scorer.Profile(carol)
+	assert.Same(t, b.Groups["instructor"], p)
+	assert.Equal(t, "group instructor", name)
+	p, _ = scorer.Profile("unknown@example.com")
+	assert.Nil(t, p)
+
+	day := end.Add(24 * time.Hour)
+
+	t.Run("usual activities", func(t *testing.T) {
+		var records []core.OCSFWebResourceActivity
+		for i := range 5 {
+			records = append(records, ocsftest.Record(day.Add(10*time.Hour+time.Duration(i)*time.Minute), alice, "192.0.2.10", ocsftest.Activity(2)))
+		}
+		assert.Empty(t, scorer.Score(records))
+	})
+
+	t.Run("unusual hour", func(t *testing.T) {
+		records := []core.OCSFWebResourceActivity{
+			ocsftest.Record(day.Add(3*time.Hour+5*time.Minute), carol, "192.0.2.10", ocsftest.Activity(2)),
+			ocsftest.Record(day.Add(3*time.Hour), carol, "192.0.2.10", ocsftest.Activity(2)),
+		}
+		records[0].SrcEndpoint.IP = "203.0.113.5"
+		alerts := scorer.Score(records)
+		require.Len(t, alerts, 1)
+		a := alerts[0]
+		assert.Equal(t, baseline.RuleTimeOfDay, a.RuleID)
+		assert.Equal(t, map[string]string{"actor.user.email_addr": carol}, a.Group)
+		assert.Equal(t, day.Add(3*time.Hour), a.FirstSeen)
+		assert.Equal(t, day.Add(3*time.Hour+5*time.Minute), a.LastSeen)
+		assert.Equal(t, a.LastSeen, a.DetectedAt)
+		assert.Equal(t, 2, a.Count)
+		assert.Equal(t, "group instructor", a.Values["profile"])
+		assert.Equal(t, "3", a.Values["hour"])
+		assert.Equal(t, "0.0000", a.Values["percentile"])
+		assert.Equal(t, "203.0.113.5", a.Values["new_ips"])
+		assert.Equal(t, []string{"192.0.2.10", "203.0.113.5"}, a.Distinct["src_endpoint.ip"])
+		assert.Equal(t, carol, a.Fields.Who)
+		assert.Equal(t, "2 activities at 03:00 UTC", a.Fields.What)
+	})
+
+	t.Run("unusual volume", func(t *testing.T) {
+		var records []core.OCSFWebResourceActivity
+		for i := range 20 {
+			records = append(records, ocsftest.Record(day.Add(14*time.Hour+time.Duration(i)*time.Minute), alice, "192.0.2.10", ocsftest.Activity(7)))
+		}
+		alerts := scorer.Score(records)
+		require.Len(t, alerts, 1)
+		a := alerts[0]
+		assert.Equal(t, baseline.RuleVolume, a.RuleID)
+		assert.Equal(t, detect.SeverityMedium, a.Severity)
+		assert.Equal(t, "user", a.Values["profile"])
+		assert.Equal(t, "16.00", a.Values["activities.zscore"])
+		assert.Equal(t, "20", a.Values["downloads"])
+		assert.Equal(t, "20.00", a.Values["downloads.zscore"])
+		assert.Equal(t, 20, a.Count)
+		assert.Len(t, a.Samples, 10)
+		assert.Equal(t, "20 activities and 20 downloads in 1h0m0s", a.Fields.What)
+	})
+
+	t.Run("too few activities", func(t *testing.T) {
+		var records []core.OCSFWebResourceActivity
+		for i := range 4 {
+			records = append(records, ocsftest.Record(day.Add(14*time.Hour+time.Duration(i)*time.Minute), alice, "192.0.2.10", ocsftest.Activity(7)))
+		}
+		// Downloads are unusual at 14:00, but 4 activities are fewer than the minimum
+		assert.Empty(t, scorer.Score(records))
+	})
+}
+
+func TestRules(t *testing.T) {
+	rules := baseline.Rules()
+	require.Len(t, rules, 2)
+	assert.Equal(t, baseline.RuleTimeOfDay, rules[0].ID)
+	assert.Equal(t, baseline.RuleVolume, rules[1].ID)
+	// No Athena query for the Go filter
+	_, err := rules[0].Query()
+	assert.Error(t, err)
+	// Alerts of a technique are grouped under one tactic across detectors
+	assert.Equal(t, travel.Rule(travel.Config{}).Technique, rules[0].Technique)
+}
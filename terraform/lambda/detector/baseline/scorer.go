@@ -0,0 +1,250 @@
+package baseline
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/detect"
+)
+
+// Rule IDs of alerts of Scorer
+const (
+	RuleTimeOfDay = "baseline-time-of-day"
+	RuleVolume    = "baseline-volume"
+)
+
+const (
+	// DefaultMinDays is the default days of history of a user to compare with the profile of
+	// the user instead of the peer group
+	DefaultMinDays = 7
+	// DefaultZScore is the default z-score of unusual numbers of activities and downloads
+	DefaultZScore = 3.0
+	// DefaultPercentile is the default percentile of unusual hours of day
+	DefaultPercentile = 0.02
+	// DefaultMinActivities is the default minimum activities of a bucket with unusual volume
+	DefaultMinActivities = 5
+)
+
+// ScoreConfig configures a Scorer. Zero values are replaced by defaults.
+type ScoreConfig struct {
+	MinDays       int
+	ZScore        float64
+	Percentile    float64
+	MinActivities int
+}
+
+// Rules describe alerts of Scorer for alert.New and scoring. Scorer raises the alerts, so the
+// rules have no query.
+func Rules() []detect.Rule {
+	all := func(*core.OCSFWebResourceActivity) bool { return true }
+	return []detect.Rule{
+		{
+			ID:          RuleTimeOfDay,
+			Title:       "Activity at an unusual hour of day for the user",
+			Description: "Activities of a user in an hour of day when the user, or the peer group of the user, rarely works. The account may be used by someone else.",
+			Severity:    detect.SeverityLow,
+			Technique:   detect.Technique{ID: "T1078", Name: "Valid Accounts", Tactic: "Initial Access"},
+			Expects:     []int{4},
+			Filter:      all,
+			GroupBy:     []string{"actor.user.email_addr"},
+		},
+		{
+			ID:          RuleVolume,
+			Title:       "Unusual volume of activities or downloads of the user",
+			Description: "More activities or downloads of a user in a period than usual for the user, or the peer group of the user, at the hour of day. It indicates data collection or automated use of the account.",
+			Severity:    detect.SeverityMedium,
+			Technique:   detect.Technique{ID: "T1530", Name: "Data from Cloud Storage", Tactic: "Collection"},
+			Expects:     []int{5},
+			Filter:      all,
+			GroupBy:     []string{"actor.user.email_addr"},
+		},
+	}
+}
+
+// Scorer compares activities of users with the baseline
+type Scorer struct {
+	baseline *Baseline
+	cfg      ScoreConfig
+	rules    map[string]*detect.Rule
+}
+
+// NewScorer returns a scorer of the baseline
+func NewScorer(b *Baseline, cfg ScoreConfig) *Scorer {
+	if cfg.MinDays <= 0 {
+		cfg.MinDays = DefaultMinDays
+	}
+	if cfg.ZScore <= 0 {
+		cfg.ZScore = DefaultZScore
+	}
+	if cfg.Percentile <= 0 {
+		cfg.Percentile = DefaultPercentile
+	}
+	if cfg.MinActivities <= 0 {
+		cfg.MinActivities = DefaultMinActivities
+	}
+	x := &Scorer{baseline: b, cfg: cfg, rules: map[string]*detect.Rule{}}
+	for _, rule := range Rules() {
+		x.rules[rule.ID] = &rule
+	}
+	return x
+}
+
+// Profile returns the profile to compare activities of the user with, and its name: the
+// profile of the user with history of MinDays, or else the profile of the peer group. It
+// returns nil if the user has neither.
+func (x *Scorer) Profile(user string) (*Profile, string) {
+	if p, ok := x.baseline.Users[user]; ok && p.Days >= x.cfg.MinDays {
+		return p, "user"
+	}
+	if group, ok := x.baseline.Peers[user]; ok {
+		if p, ok := x.baseline.Groups[group]; ok {
+			return p, "group " + group
+		}
+	}
+	return nil, ""
+}
+
+// bucket is activities of a user in a bucket
+type bucket struct {
+	user      string
+	start     time.Time
+	records   []*core.OCSFWebResourceActivity
+	downloads int
+	failures  int
+}
+
+// Score returns alerts of buckets of users at unusual hours of day, and with unusual numbers
+// of activities or downloads, ordered by detection time. Records may be in any order.
+func (x *Scorer) Score(records []core.OCSFWebResourceActivity) []detect.Alert {
+	buckets := map[string]*bucket{}
+	for i := range records {
+		record := &records[i]
+		user := record.Actor.User.EmailAddr
+		if user == "" {
+			continue
+		}
+		start := time.UnixMilli(record.Time).UTC().Truncate(x.baseline.Bucket)
+		key := fmt.Sprintf("%s\x00%d", user, start.Unix())
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{user: user, start: start}
+			buckets[key] = b
+		}
+		b.records = append(b.records, record)
+		if record.ActivityID == downloadActivityID {
+			b.downloads++
+		}
+		if detect.IsFailure(record) {
+			b.failures++
+		}
+	}
+
+	var alerts []detect.Alert
+	for _, key := range slices.Sorted(maps.Keys(buckets)) {
+		b := buckets[key]
+		p, name := x.Profile(b.user)
+		if p == nil {
+			continue
+		}
+		sort.SliceStable(b.records, func(i, j int) bool { return b.records[i].Time < b.records[j].Time })
+		hour := b.start.Hour()
+		values := x.unseen(p, b)
+		values["profile"] = name
+		values["hour"] = strconv.Itoa(hour)
+
+		if percentile := p.Percentile(hour); percentile <= x.cfg.Percentile {
+			v := maps.Clone(values)
+			v["percentile"] = strconv.FormatFloat(percentile, 'f', 4, 64)
+			v["share"] = strconv.FormatFloat(p.Share[hour], 'f', 4, 64)
+			a := x.alert(RuleTimeOfDay, b, v)
+			a.Fields.What = fmt.Sprintf("%d activities at %02d:00 UTC", len(b.records), hour)
+			a.Fields.Why = fmt.Sprintf("%.1f%% of activities of the %s are in hours as quiet as %02d:00 UTC", percentile*100, name, hour)
+			alerts = append(alerts, a)
+		}
+
+		activities := p.Activities[hour].ZScore(len(b.records))
+		downloads := p.Downloads[hour].ZScore(b.downloads)
+		if len(b.records) >= x.cfg.MinActivities && (activities >= x.cfg.ZScore || downloads >= x.cfg.ZScore) {
+			v := maps.Clone(values)
+			v["activities.zscore"] = strconv.FormatFloat(activities, 'f', 2, 64)
+			v["activities.mean"] = strconv.FormatFloat(p.Activities[hour].Mean, 'f', 2, 64)
+			v["downloads"] = strconv.Itoa(b.downloads)
+			v["downloads.zscore"] = strconv.FormatFloat(downloads, 'f', 2, 64)
+			v["downloads.mean"] = strconv.FormatFloat(p.Downloads[hour].Mean, 'f', 2, 64)
+			a := x.alert(RuleVolume, b, v)
+			a.Fields.What = fmt.Sprintf("%d activities and %d downloads in %s", len(b.records), b.downloads, x.baseline.Bucket)
+			a.Fields.Why = fmt.Sprintf("Usually %.1f activities (z-score %.1f) and %.1f downloads (z-score %.1f) of the %s at %02d:00 UTC",
+				p.Activities[hour].Mean, activities, p.Downloads[hour].Mean, downloads, name, hour)
+			alerts = append(alerts, a)
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].DetectedAt.Before(alerts[j].DetectedAt) })
+	return alerts
+}
+
+// unseen returns IP addresses and services of the bucket not in the profile
+func (x *Scorer) unseen(p *Profile, b *bucket) map[string]string {
+	ips, services := map[string]bool{}, map[string]bool{}
+	for _, record := range b.records {
+		if ip := record.SrcEndpoint.IP; ip != "" && p.IPs[ip] == 0 {
+			ips[ip] = true
+		}
+		if service := record.API.Service.Name; service != "" && p.Services[service] == 0 {
+			services[service] = true
+		}
+	}
+	values := map[string]string{}
+	if len(ips) > 0 {
+		values["new_ips"] = strings.Join(slices.Sorted(maps.Keys(ips)), ", ")
+	}
+	if len(services) > 0 {
+		values["new_services"] = strings.Join(slices.Sorted(maps.Keys(services)), ", ")
+	}
+	return values
+}
+
+// alert returns an alert of the rule for the bucket
+func (x *Scorer) alert(ruleID string, b *bucket, values map[string]string) detect.Alert {
+	rule := x.rules[ruleID]
+	technique := rule.Technique
+	first, last := b.records[0], b.records[len(b.records)-1]
+	a := detect.Alert{
+		RuleID:     rule.ID,
+		Title:      rule.Title,
+		Severity:   rule.Severity,
+		Technique:  &technique,
+		Group:      map[string]string{"actor.user.email_addr": b.user},
+		FirstSeen:  time.UnixMilli(first.Time).UTC(),
+		LastSeen:   time.UnixMilli(last.Time).UTC(),
+		DetectedAt: time.UnixMilli(last.Time).UTC(),
+		Count:      len(b.records),
+		Failures:   b.failures,
+		Values:     values,
+	}
+	ips, services := map[string]bool{}, map[string]bool{}
+	for _, record := range b.records {
+		if record.SrcEndpoint.IP != "" {
+			ips[record.SrcEndpoint.IP] = true
+		}
+		if record.API.Service.Name != "" {
+			services[record.API.Service.Name] = true
+		}
+		if len(a.Samples) < detect.MaxSamples {
+			a.Samples = append(a.Samples, detect.NewSample(record))
+		}
+	}
+	a.Distinct = map[string][]string{
+		"src_endpoint.ip":  slices.Sorted(maps.Keys(ips)),
+		"api.service.name": slices.Sorted(maps.Keys(services)),
+	}
+	a.Fields.Who = b.user
+	a.Fields.When = fmt.Sprintf("%s - %s", a.FirstSeen.Format(time.RFC3339), a.LastSeen.Format(time.RFC3339))
+	a.Fields.Where = strings.Join(a.Distinct["src_endpoint.ip"], ", ")
+	return a
+}
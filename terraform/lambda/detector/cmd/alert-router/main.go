@@ -0,0 +1,81 @@
+// Command alert-router is the Lambda function subscribed to the alerts SNS topic. It routes
+// alerts of SNS messages to Slack, webhooks and email with the configuration of the file of
+// ROUTER_CONFIG (router.yaml next to the binary by default). Deliveries are logged as JSON
+// lines to stdout.
+//
+// Secrets of url_env, secret_env and password_env of the configuration are SSM parameters
+// under ROUTER_SECRETS_PATH, read once at start, so they are kept out of the function
+// configuration. Environment variables are used for names without a parameter.
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/router"
+)
+
+// Handler routes alerts of SNS events
+type Handler struct {
+	router *router.Router
+}
+
+// HandleSNSEvent routes the alert of each record. Failed deliveries were already retried and
+// recorded in the delivery log, so they are not returned: retries of the invocation would
+// deliver again to destinations which succeeded. Only undecodable messages are errors.
+func (h *Handler) HandleSNSEvent(ctx context.Context, event events.SNSEvent) error {
+	var errs []error
+	for _, record := range event.Records {
+		var a alert.Alert
+		if err := json.Unmarshal([]byte(record.SNS.Message), &a); err != nil {
+			slog.Error("Failed to decode alert", "error", err, "message_id", record.SNS.MessageID)
+			errs = append(errs, fmt.Errorf("message %s: %w", record.SNS.MessageID, err))
+			continue
+		}
+		if _, err := h.router.Route(ctx, &a); err != nil {
+			slog.Error("Failed to deliver alert", "error", err, "alert_id", a.ID, "message_id", record.SNS.MessageID)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func main() {
+	path := cmp.Or(os.Getenv("ROUTER_CONFIG"), "router.yaml")
+	cfg, err := router.LoadConfig(path)
+	if err != nil {
+		slog.Error("Failed to load router config", "error", err, "path", path)
+		os.Exit(1)
+	}
+	secrets := map[string]string{}
+	if prefix := os.Getenv("ROUTER_SECRETS_PATH"); prefix != "" {
+		ctx := context.Background()
+		awsCfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			slog.Error("Failed to load AWS config", "error", err)
+			os.Exit(1)
+		}
+		if secrets, err = loadSecrets(ctx, ssm.NewFromConfig(awsCfg), prefix); err != nil {
+			slog.Error("Failed to load router secrets", "error", err)
+			os.Exit(1)
+		}
+	}
+	r, err := router.New(cfg, router.Options{Getenv: getenv(secrets)})
+	if err != nil {
+		slog.Error("Failed to create router", "error", err)
+		os.Exit(1)
+	}
+
+	h := &Handler{router: r}
+	lambda.Start(h.HandleSNSEvent)
+}
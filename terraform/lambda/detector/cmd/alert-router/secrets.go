@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// SSMAPI defines the SSM operations used by loadSecrets
+type SSMAPI interface {
+	GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+}
+
+// Ensure that ssm.Client implements SSMAPI
+var _ SSMAPI = (*ssm.Client)(nil)
+
+// loadSecrets reads parameters directly under the path, decrypting SecureString parameters,
+// and returns their values by the last element of their names, such as SLACK_WEBHOOK_URL of
+// /seccamp2025-b1/alert-router/SLACK_WEBHOOK_URL
+func loadSecrets(ctx context.Context, api SSMAPI, prefix string) (map[string]string, error) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	secrets := map[string]string{}
+	paginator := ssm.NewGetParametersByPathPaginator(api, &ssm.GetParametersByPathInput{
+		Path:           aws.String(prefix),
+		WithDecryption: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parameters of %s: %w", prefix, err)
+		}
+		for _, p := range page.Parameters {
+			secrets[path.Base(aws.ToString(p.Name))] = aws.ToString(p.Value)
+		}
+	}
+	return secrets, nil
+}
+
+// getenv returns secrets, falling back to environment variables for local runs
+func getenv(secrets map[string]string) func(string) string {
+	return func(name string) string {
+		if v, ok := secrets[name]; ok {
+			return v
+		}
+		return os.Getenv(name)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSSM returns a page of parameters per call
+type fakeSSM struct {
+	pages  [][]ssmtypes.Parameter
+	inputs []*ssm.GetParametersByPathInput
+	err    error
+}
+
+func (x *fakeSSM) GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	if x.err != nil {
+		return nil, x.err
+	}
+	x.inputs = append(x.inputs, params)
+	output := &ssm.GetParametersByPathOutput{Parameters: x.pages[len(x.inputs)-1]}
+	if len(x.inputs) < len(x.pages) {
+		output.NextToken = aws.String("next")
+	}
+	return output, nil
+}
+
+func parameter(name, value string) ssmtypes.Parameter {
+	return ssmtypes.Parameter{Name: aws.String(name), Value: aws.String(value)}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	api := &fakeSSM{pages: [][]ssmtypes.Parameter{
+		{parameter("/b1/alert-router/SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/x")},
+		{parameter("/b1/alert-router/SMTP_PASSWORD", "p@ss")},
+	}}
+	secrets, err := loadSecrets(context.Background(), api, "/b1/alert-router/")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"SLACK_WEBHOOK_URL": "https://hooks.slack.com/services/x",
+		"SMTP_PASSWORD":     "p@ss",
+	}, secrets)
+
+	require.Len(t, api.inputs, 2)
+	assert.Equal(t, "/b1/alert-router", aws.ToString(api.inputs[0].Path))
+	assert.True(t, aws.ToBool(api.inputs[0].WithDecryption))
+	assert.Equal(t, "next", aws.ToString(api.inputs[1].NextToken))
+}
+
+func TestLoadSecrets_Error(t *testing.T) {
+	_, err := loadSecrets(context.Background(), &fakeSSM{err: errors.New("access denied")}, "/b1/alert-router")
+	assert.ErrorContains(t, err, "failed to get parameters of /b1/alert-router: access denied")
+}
+
+func TestGetenv(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "from-env")
+	t.Setenv("SLACK_WEBHOOK_URL", "from-env")
+	lookup := getenv(map[string]string{"SLACK_WEBHOOK_URL": "from-ssm"})
+	assert.Equal(t, "from-ssm", lookup("SLACK_WEBHOOK_URL"))
+	assert.Equal(t, "from-env", lookup("WEBHOOK_SECRET"))
+	assert.Empty(t, lookup("UNKNOWN_SECRET"))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/baseline"
+	"seccamp2025-b1-detector/detect"
+)
+
+// runBaseline builds profiles of users and their peer groups over the trailing window of
+// records, and writes the baseline
+func runBaseline(args []string) error {
+	flags := flag.NewFlagSet("baseline", flag.ExitOnError)
+	outPath := flags.String("out", "baseline.json", "output baseline file")
+	window := flags.Duration("window", baseline.DefaultWindow, "trailing window of records")
+	bucket := flags.Duration("bucket", baseline.DefaultBucket, "period of counts of activities, the same as of logs to score")
+	end := flags.String("end", "", "end of the window in RFC 3339 (the bucket after the latest record if empty)")
+	usersPath := flags.String("users", "", "users and their peer groups written by loggen users")
+	verbose := flags.Bool("v", false, "print progress logs")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	setupLogger(*verbose)
+
+	var peers map[string]string
+	if *usersPath != "" {
+		f, err := os.Open(*usersPath)
+		if err != nil {
+			return err
+		}
+		peers, err = baseline.ReadPeers(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", *usersPath, err)
+		}
+	}
+
+	records, err := loadRecords(context.Background(), flags.Args())
+	if err != nil {
+		return err
+	}
+	var endTime time.Time
+	if *end != "" {
+		if endTime, err = time.Parse(time.RFC3339, *end); err != nil {
+			return fmt.Errorf("invalid -end: %w", err)
+		}
+	} else {
+		var latest int64
+		for i := range records {
+			latest = max(latest, records[i].Time)
+		}
+		endTime = time.UnixMilli(latest).UTC().Truncate(*bucket).Add(*bucket)
+	}
+
+	builder := baseline.NewBuilder(baseline.Config{Window: *window, Bucket: *bucket, Peers: peers}, endTime)
+	for i := range records {
+		builder.Add(&records[i])
+	}
+	b := builder.Build()
+
+	f, err := os.Create(*outPath)
+	if err != nil {
+		return err
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d records, %d users, %d groups from %s to %s\n",
+		len(records), len(b.Users), len(b.Groups), b.Start.Format(time.RFC3339), b.End.Format(time.RFC3339))
+	return nil
+}
+
+// baselineFlags are flags of scoring against a baseline, shared by detect and score
+type baselineFlags struct {
+	path          *string
+	minDays       *int
+	zscore        *float64
+	percentile    *float64
+	minActivities *int
+}
+
+func addBaselineFlags(flags *flag.FlagSet) *baselineFlags {
+	return &baselineFlags{
+		path:          flags.String("baseline", "", "also score activities against the baseline file written by baseline"),
+		minDays:       flags.Int("baseline-min-days", baseline.DefaultMinDays, "days of history of a user before -baseline compares with the user instead of the peer group"),
+		zscore:        flags.Float64("baseline-zscore", baseline.DefaultZScore, "z-score of unusual numbers of activities and downloads of -baseline"),
+		percentile:    flags.Float64("baseline-percentile", baseline.DefaultPercentile, "percentile of unusual hours of day of -baseline"),
+		minActivities: flags.Int("baseline-min-activities", baseline.DefaultMinActivities, "minimum activities of a bucket with unusual volume of -baseline"),
+	}
+}
+
+// run appends the rules and alerts of the baseline scorer if a baseline is given. Alerts stay
+// ordered by detection time.
+func (x *baselineFlags) run(rules []detect.Rule, records []core.OCSFWebResourceActivity, alerts []detect.Alert) ([]detect.Rule, []detect.Alert, error) {
+	if *x.path == "" {
+		return rules, alerts, nil
+	}
+	f, err := os.Open(*x.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := baseline.Read(f)
+	f.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", *x.path, err)
+	}
+	scorer := baseline.NewScorer(b, baseline.ScoreConfig{
+		MinDays:       *x.minDays,
+		ZScore:        *x.zscore,
+		Percentile:    *x.percentile,
+		MinActivities: *x.minActivities,
+	})
+	alerts = append(alerts, scorer.Score(records)...)
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].DetectedAt.Before(alerts[j].DetectedAt) })
+	return append(rules, baseline.Rules()...), alerts, nil
+}
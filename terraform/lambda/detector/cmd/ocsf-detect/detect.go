@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/localsql"
+)
+
+func runDetect(args []string) error {
+	flags := flag.NewFlagSet("detect", flag.ExitOnError)
+	ruleIDs := flags.String("rules", "", "comma separated IDs of rules to evaluate (all if empty)")
+	ruleFiles := flags.String("rule-files", "", "comma separated rule files or directories (embedded rules if empty)")
+	useSQL := flags.Bool("sql", false, "run the SQL of all rules on DuckDB as scheduled")
+	format := flags.String("format", "detect", "format of alerts: detect, or alert for the schema published to SNS")
+	dedup := flags.Bool("dedup", false, "print only alerts to notify after deduplication (with -format alert)")
+	suppressionsPath := flags.String("suppressions", "", "YAML file of suppressions of -dedup")
+	travelOpts := addTravelFlags(flags)
+	baselineOpts := addBaselineFlags(flags)
+	verbose := flags.Bool("v", false, "print progress logs")
+	flags.Parse(args)
+	if flags.NArg() == 0 || (*format != "detect" && *format != "alert") || (*dedup && *format != "alert") {
+		flags.Usage()
+		os.Exit(2)
+	}
+	setupLogger(*verbose)
+
+	rules, err := prepareRules(*ruleFiles, *ruleIDs, *useSQL)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	records, err := loadRecords(ctx, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	alerts, err := detectAlerts(ctx, rules, records, *useSQL)
+	if err != nil {
+		return err
+	}
+	if rules, alerts, err = travelOpts.run(rules, records, alerts); err != nil {
+		return err
+	}
+	if rules, alerts, err = baselineOpts.run(rules, records, alerts); err != nil {
+		return err
+	}
+	var deduplicator *alert.Deduplicator
+	if *dedup {
+		if deduplicator, err = newDeduplicator(*suppressionsPath); err != nil {
+			return err
+		}
+	}
+	byID := map[string]*detect.Rule{}
+	for i := range rules {
+		byID[rules[i].ID] = &rules[i]
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	var printed int
+	for _, a := range alerts {
+		var v any = a
+		if *format == "alert" {
+			published := alert.New(&a, byID[a.RuleID], alert.Config{})
+			if deduplicator != nil {
+				decision, err := deduplicator.Process(ctx, published)
+				if err != nil {
+					return err
+				}
+				slog.Info("Deduplicated alert", "rule", a.RuleID, "fingerprint", published.Fingerprint, "decision", decision, "occurrences", published.Occurrences)
+				if !decision.Notify() {
+					continue
+				}
+			}
+			v = published
+		}
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		printed++
+	}
+	if deduplicator != nil {
+		fmt.Fprintf(os.Stderr, "%d records, %d alerts, %d notified\n", len(records), len(alerts), printed)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%d records, %d alerts\n", len(records), len(alerts))
+	return nil
+}
+
+// newDeduplicator returns a deduplicator with an in-memory store and suppressions of the file
+func newDeduplicator(suppressionsPath string) (*alert.Deduplicator, error) {
+	var cfg alert.DedupConfig
+	if suppressionsPath != "" {
+		data, err := os.ReadFile(suppressionsPath)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Suppressions, err = alert.ParseSuppressions(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", suppressionsPath, err)
+		}
+	}
+	return alert.NewDeduplicator(alert.NewMemoryStore(), cfg)
+}
+
+// prepareRules loads and selects rules. Without useSQL, SQL rules are skipped as they run on
+// SQL backends only.
+func prepareRules(ruleFiles, ruleIDs string, useSQL bool) ([]detect.Rule, error) {
+	rules, err := loadDetectRules(splitList(ruleFiles))
+	if err != nil {
+		return nil, err
+	}
+	rules, err = selectRules(rules, splitList(ruleIDs))
+	if err != nil {
+		return nil, err
+	}
+	if useSQL {
+		return rules, nil
+	}
+	return slices.DeleteFunc(rules, func(rule detect.Rule) bool {
+		if rule.IsSQL() {
+			slog.Warn("Skipping SQL rule, use -sql to run it", "rule", rule.ID)
+			return true
+		}
+		return false
+	}), nil
+}
+
+// detectAlerts evaluates rules over records in memory, or replays their SQL with useSQL
+func detectAlerts(ctx context.Context, rules []detect.Rule, records []core.OCSFWebResourceActivity, useSQL bool) ([]detect.Alert, error) {
+	if useSQL {
+		return replaySQL(ctx, rules, records)
+	}
+	return detect.Evaluate(rules, records)
+}
+
+// replaySQL runs rules as scheduled over the period of records, with their SQL on DuckDB
+// over the Parquet of records
+func replaySQL(ctx context.Context, rules []detect.Rule, records []core.OCSFWebResourceActivity) ([]detect.Alert, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	db, err := localsql.New()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if err := db.LoadLogs(ctx, detect.DefaultTable, records); err != nil {
+		return nil, err
+	}
+	first, last := records[0].Time, records[0].Time
+	for _, record := range records {
+		first, last = min(first, record.Time), max(last, record.Time)
+	}
+
+	var alerts []detect.Alert
+	backend := &detect.SQLBackend{Runner: db}
+	for _, rule := range rules {
+		found, err := detect.Replay(ctx, backend, &rule, time.UnixMilli(first), time.UnixMilli(last+1))
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, found...)
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].DetectedAt.Before(alerts[j].DetectedAt) })
+	return alerts, nil
+}
+
+// runRules validates rule files and lists the rules
+func runRules(args []string) error {
+	flags := flag.NewFlagSet("rules", flag.ExitOnError)
+	showSQL := flags.Bool("sql", false, "print the Athena SQL of each rule")
+	flags.Parse(args)
+
+	rules, err := loadDetectRules(flags.Args())
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		kind := "native"
+		if rule.IsSQL() {
+			kind = "sql"
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", rule.ID, rule.Severity, rule.Technique.ID, rule.Schedule, kind, rule.Title)
+		if *showSQL {
+			query, err := rule.Query()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n\n", query)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d rules\n", len(rules))
+	return nil
+}
+
+// loadDetectRules loads rule files, or returns the embedded rules without paths
+func loadDetectRules(paths []string) ([]detect.Rule, error) {
+	if len(paths) == 0 {
+		return detect.DefaultRules(), nil
+	}
+	return detect.LoadRules(paths...)
+}
+
+func selectRules(rules []detect.Rule, ids []string) ([]detect.Rule, error) {
+	if len(ids) == 0 {
+		return rules, nil
+	}
+	byID := map[string]detect.Rule{}
+	for _, rule := range rules {
+		byID[rule.ID] = rule
+	}
+	var selected []detect.Rule
+	for _, id := range ids {
+		rule, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown rule %q", id)
+		}
+		selected = append(selected, rule)
+	}
+	return selected, nil
+}
+
+// loadRecords reads records of Parquet files, and converts other log files in memory with the
+// default configuration of the converter. Converted records are read back from Parquet, so
+// rules see the fields the converter writes.
+func loadRecords(ctx context.Context, args []string) ([]core.OCSFWebResourceActivity, error) {
+	var records []core.OCSFWebResourceActivity
+	var parquetArgs, logArgs []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case info.IsDir():
+			parquetArgs = append(parquetArgs, arg)
+			logArgs = append(logArgs, arg)
+		case strings.HasSuffix(arg, ".parquet"):
+			parquetArgs = append(parquetArgs, arg)
+		default:
+			logArgs = append(logArgs, arg)
+		}
+	}
+
+	files, err := parquetFiles(parquetArgs)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		logs, err := core.ReadParquet(ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		records = append(records, logs...)
+	}
+
+	inputs, err := inputFiles(logArgs)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return records, nil
+	}
+	converter := &core.Converter{Region: "ap-northeast-1", AccountID: "000000000000"}
+	for _, input := range inputs {
+		data, err := os.ReadFile(input.path)
+		if err != nil {
+			return nil, err
+		}
+		result, err := converter.Convert(ctx, input.key, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", input.path, err)
+		}
+		if len(result.Logs) == 0 {
+			continue
+		}
+		if data, err = core.GenerateParquet(result.Logs); err != nil {
+			return nil, fmt.Errorf("%s: %w", input.path, err)
+		}
+		logs, err := core.ReadParquet(ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", input.path, err)
+		}
+		records = append(records, logs...)
+	}
+	return records, nil
+}
@@ -0,0 +1,158 @@
+// Command ocsf-detect runs detection rules, alerting and routing locally over records of the
+// converter, without Athena and AWS credentials.
+//
+//	go run ./cmd/ocsf-detect detect [-sql] [-travel] [-baseline file] [-format alert] <parquet or log file or directory>...
+//	go run ./cmd/ocsf-detect rules [rule file or directory]...
+//	go run ./cmd/ocsf-detect alert-schema
+//	go run ./cmd/ocsf-detect baseline [-out baseline.json] [-users users.jsonl] <parquet or log file or directory>...
+//	go run ./cmd/ocsf-detect score -labels <labels file> [-sql] [-travel] [-baseline file] <log file or directory>...
+//	go run ./cmd/ocsf-detect route -config <router config> [-dry-run] [alert file]
+//
+// detect evaluates the detection rules over records of Parquet files, as written by
+// ocsf-convert convert of the converter, and raw log files converted in memory, and prints
+// alerts as JSON lines; with -sql, the SQL of all rules runs as scheduled on the local SQL
+// engine instead. rules validates rule files (the embedded rules without arguments) and lists
+// them. alert-schema prints the JSON Schema of alerts published to SNS, the format of detect
+// with -format alert. score runs detect over logs generated by tools/loggen and reports
+// precision, recall and time to detect of the rules against the label sidecar of the logs.
+// route delivers alerts printed by detect with -format alert (stdin without a file) to
+// destinations of the router configuration, as the alert-router Lambda function does.
+// baseline writes profiles of users and their peer groups over the trailing window of
+// records, against which detect and score flag activities at unusual hours and volumes with
+// -baseline.
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/alert"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s detect [flags] <parquet or log file or directory>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s rules [flags] [rule file or directory]...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s alert-schema\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s baseline [flags] <parquet or log file or directory>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s score [flags] <log file or directory>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s route [flags] [alert file]\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "detect":
+		err = runDetect(os.Args[2:])
+	case "rules":
+		err = runRules(os.Args[2:])
+	case "alert-schema":
+		_, err = os.Stdout.Write(alert.Schema())
+	case "baseline":
+		err = runBaseline(os.Args[2:])
+	case "score":
+		err = runScore(os.Args[2:])
+	case "route":
+		err = runRoute(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type inputFile struct {
+	path string
+	key  string
+}
+
+// inputFiles expands directories in args into files with a supported input extension
+func inputFiles(args []string) ([]inputFile, error) {
+	var files []inputFile
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, inputFile{path: arg, key: filepath.Base(arg)})
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if !slices.ContainsFunc(core.InputExtensions, func(ext string) bool { return strings.HasSuffix(path, ext) }) {
+				return nil
+			}
+			rel, err := filepath.Rel(arg, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, inputFile{path: path, key: filepath.ToSlash(rel)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// parquetFiles expands directories in args into *.parquet files
+func parquetFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".parquet") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// setupLogger sends logs to stderr, only warnings unless verbose
+func setupLogger(verbose bool) {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/router"
+)
+
+// runRoute delivers alerts of JSON lines, as printed by detect with -format alert, with the
+// router configuration. Deliveries are printed as JSON lines. With -dry-run, deliveries are
+// only printed without sending the alerts.
+func runRoute(args []string) error {
+	fs := flag.NewFlagSet("route", flag.ExitOnError)
+	configPath := fs.String("config", "", "router configuration file (required)")
+	dryRun := fs.Bool("dry-run", false, "print destinations of alerts without delivering them")
+	fs.Parse(args)
+
+	if *configPath == "" {
+		return errors.New("-config is required")
+	}
+	cfg, err := router.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	ctx := context.Background()
+	if *dryRun {
+		return forEachAlert(in, func(a *alert.Alert) error {
+			enc := json.NewEncoder(os.Stdout)
+			for i, route := range cfg.Routes {
+				if !route.Matches(a) {
+					continue
+				}
+				if err := enc.Encode(map[string]any{"alert_id": a.ID, "rule_id": a.Rule.ID, "route": routeName(route, i), "destinations": route.Destinations}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	r, err := router.New(cfg, router.Options{Log: router.NewJSONLog(os.Stdout)})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	err = forEachAlert(in, func(a *alert.Alert) error {
+		if _, err := r.Route(ctx, a); err != nil {
+			errs = append(errs, fmt.Errorf("alert %s: %w", a.ID, err))
+		}
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+func routeName(route router.Route, i int) string {
+	if route.Name != "" {
+		return route.Name
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+func forEachAlert(r io.Reader, fn func(a *alert.Alert) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), alert.MaxMessageSize*4)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var a alert.Alert
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(&a); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
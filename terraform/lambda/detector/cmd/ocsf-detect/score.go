@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"seccamp2025-b1-detector/score"
+)
+
+// runScore evaluates rules over logs generated by tools/loggen and scores alerts against the
+// label sidecar of the logs
+func runScore(args []string) error {
+	flags := flag.NewFlagSet("score", flag.ExitOnError)
+	labelsPath := flags.String("labels", "", "label sidecar of the logs written by loggen logs (required)")
+	ruleIDs := flags.String("rules", "", "comma separated IDs of rules to evaluate (all if empty)")
+	ruleFiles := flags.String("rule-files", "", "comma separated rule files or directories (embedded rules if empty)")
+	useSQL := flags.Bool("sql", false, "run the SQL of all rules on DuckDB as scheduled")
+	gap := flags.Duration("gap", score.DefaultIncidentGap, "gap between labeled events splitting incidents of a pattern and an actor")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	travelOpts := addTravelFlags(flags)
+	baselineOpts := addBaselineFlags(flags)
+	verbose := flags.Bool("v", false, "print progress logs")
+	flags.Parse(args)
+	if flags.NArg() == 0 || *labelsPath == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	setupLogger(*verbose)
+
+	f, err := os.Open(*labelsPath)
+	if err != nil {
+		return err
+	}
+	labels, err := score.ReadLabels(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *labelsPath, err)
+	}
+
+	rules, err := prepareRules(*ruleFiles, *ruleIDs, *useSQL)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	records, err := loadRecords(ctx, flags.Args())
+	if err != nil {
+		return err
+	}
+	alerts, err := detectAlerts(ctx, rules, records, *useSQL)
+	if err != nil {
+		return err
+	}
+	if rules, alerts, err = travelOpts.run(rules, records, alerts); err != nil {
+		return err
+	}
+	if rules, alerts, err = baselineOpts.run(rules, records, alerts); err != nil {
+		return err
+	}
+
+	scorer := &score.Scorer{IncidentGap: *gap}
+	report, err := scorer.Score(rules, labels, records, alerts)
+	if err != nil {
+		return err
+	}
+	if report.Labeled < report.Labels {
+		// Labels of other logs, or Parquet written before metadata.uid was added
+		slog.Warn("Labels without records, score the logs of the labels", "labels", report.Labels, "labeled", report.Labeled)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printReport(report)
+	return nil
+}
+
+func printReport(report *score.Report) {
+	fmt.Printf("%d records, %d labeled (%d labels), %d alerts\n\n", report.Records, report.Labeled, report.Labels, report.Alerts)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATTERN\tNAME\tEVENTS\tINCIDENTS\tDETECTED\tRECALL\tTTD MEDIAN\tTTD MAX\tRULES")
+	for _, p := range report.Patterns {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%.2f\t%s\t%s\t%s\n", p.Pattern, p.Name, p.Events, p.Incidents, p.Detected,
+			p.Recall, p.TimeToDetect.Median, p.TimeToDetect.Max, strings.Join(p.Rules, ","))
+	}
+	w.Flush()
+	fmt.Println()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tALERTS\tTRUE POSITIVES\tPRECISION\tPATTERNS\tCROSS PATTERN HITS")
+	for _, r := range report.Rules {
+		precision := "-"
+		if r.Alerts > 0 {
+			precision = fmt.Sprintf("%.2f", r.Precision)
+		}
+		var patterns []string
+		for _, p := range report.Patterns {
+			if d, ok := r.Patterns[p.Name]; ok {
+				patterns = append(patterns, fmt.Sprintf("%d:%d/%d", p.Pattern, d.Detected, d.Incidents))
+			}
+		}
+		crosses := []string{fmt.Sprint(r.CrossPatternHits)}
+		for _, p := range report.Patterns {
+			if n, ok := r.CrossPatterns[p.Name]; ok {
+				crosses = append(crosses, fmt.Sprintf("%d:%d", p.Pattern, n))
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", r.RuleID, r.Alerts, r.TruePositives, precision,
+			strings.Join(patterns, " "), strings.Join(crosses, " "))
+	}
+	w.Flush()
+}
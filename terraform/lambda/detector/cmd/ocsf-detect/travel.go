@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"sort"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/travel"
+)
+
+// travelFlags are flags of the impossible travel detector, shared by detect and score
+type travelFlags struct {
+	enabled  *bool
+	allow    *string
+	maxSpeed *float64
+}
+
+func addTravelFlags(flags *flag.FlagSet) *travelFlags {
+	return &travelFlags{
+		enabled:  flags.Bool("travel", false, "also run the impossible travel detector"),
+		allow:    flags.String("travel-allow", "", "comma separated CIDRs of VPN and corporate egress ignored by -travel"),
+		maxSpeed: flags.Float64("travel-max-speed", travel.DefaultMaxSpeed, "maximum plausible speed in km/h of -travel"),
+	}
+}
+
+// run appends the rule and alerts of the impossible travel detector if enabled. Alerts stay
+// ordered by detection time.
+func (x *travelFlags) run(rules []detect.Rule, records []core.OCSFWebResourceActivity, alerts []detect.Alert) ([]detect.Rule, []detect.Alert, error) {
+	if !*x.enabled {
+		return rules, alerts, nil
+	}
+	allowlist, err := travel.ParseAllowlist(*x.allow)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := travel.Config{MaxSpeed: *x.maxSpeed, Allowlist: allowlist}
+	alerts = append(alerts, travel.Evaluate(cfg, records)...)
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].DetectedAt.Before(alerts[j].DetectedAt) })
+	return append(rules, travel.Rule(cfg)), alerts, nil
+}
@@ -0,0 +1,129 @@
+package detect
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+)
+
+// MaxSamples limits records kept in an alert
+const MaxSamples = 10
+
+// Alert is raised when a group of records meets the threshold of a rule
+type Alert struct {
+	RuleID    string            `json:"rule_id"`
+	Title     string            `json:"title"`
+	Severity  Severity          `json:"severity"`
+	Technique *Technique        `json:"technique,omitempty"`
+	Group     map[string]string `json:"group"` // values of group-by fields
+	// FirstSeen and LastSeen are times of the first and last records in the alert
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// DetectedAt is the time of the record on which the threshold was met
+	DetectedAt time.Time `json:"detected_at"`
+	Count      int       `json:"count"`
+	Failures   int       `json:"failures"`
+	// Distinct are sorted distinct values of fields of distinct conditions and Rule.Collect
+	Distinct map[string][]string `json:"distinct,omitempty"`
+	// Values are other result columns of SQL rules
+	Values  map[string]string `json:"values,omitempty"`
+	Fields  AlertFields       `json:"fields"`
+	Samples []Sample          `json:"samples"`
+}
+
+// alertValueNames are values of all alerts, in addition to group-by fields and distinct
+// fields
+var alertValueNames = []string{
+	"rule.id", "rule.title", "severity",
+	MetricCount, MetricFailures, MetricFailureRatio,
+	"first_seen", "last_seen", "detected_at",
+}
+
+// Value returns a value of the alert referred by templates of AlertFields and suppression
+// keys: a name of alertValueNames, a group-by field, a distinct field (values joined by
+// ", "), "distinct(field)" (number of values) or a result column of a SQL rule.
+func (x *Alert) Value(name string) string {
+	switch name {
+	case "rule.id":
+		return x.RuleID
+	case "rule.title":
+		return x.Title
+	case "severity":
+		return x.Severity.String()
+	case MetricCount:
+		return strconv.Itoa(x.Count)
+	case MetricFailures:
+		return strconv.Itoa(x.Failures)
+	case MetricFailureRatio:
+		if x.Count == 0 {
+			return "0"
+		}
+		return strconv.FormatFloat(float64(x.Failures)/float64(x.Count), 'f', 2, 64)
+	case "first_seen":
+		return x.FirstSeen.Format(time.RFC3339)
+	case "last_seen":
+		return x.LastSeen.Format(time.RFC3339)
+	case "detected_at":
+		return x.DetectedAt.Format(time.RFC3339)
+	}
+	if value, ok := x.Group[name]; ok {
+		return value
+	}
+	if values, ok := x.Distinct[name]; ok {
+		return strings.Join(values, ", ")
+	}
+	if field, ok := strings.CutPrefix(name, "distinct("); ok {
+		if values, ok := x.Distinct[strings.TrimSuffix(field, ")")]; ok {
+			return strconv.Itoa(len(values))
+		}
+	}
+	return x.Values[name]
+}
+
+// describe sets attributes of the rule to the alert
+func (x *Alert) describe(rule *Rule) {
+	x.RuleID = rule.ID
+	x.Title = rule.Title
+	x.Severity = rule.Severity
+	if rule.Technique.ID != "" {
+		technique := rule.Technique
+		x.Technique = &technique
+	}
+	x.Fields = rule.Fields
+	for _, tmpl := range x.Fields.templates() {
+		*tmpl = os.Expand(*tmpl, x.Value)
+	}
+}
+
+// Sample summarizes a record in an alert
+type Sample struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	Service   string    `json:"service,omitempty"`
+	Operation string    `json:"operation,omitempty"`
+	Resource  string    `json:"resource,omitempty"`
+	StatusID  int       `json:"status_id"`
+}
+
+// NewSample summarizes the record for an alert
+func NewSample(x *core.OCSFWebResourceActivity) Sample {
+	return Sample{
+		Time:      eventTime(x),
+		User:      x.Actor.User.EmailAddr,
+		IP:        x.SrcEndpoint.IP,
+		Country:   x.SrcEndpoint.Location.Country,
+		Service:   x.API.Service.Name,
+		Operation: x.API.Operation,
+		Resource:  fields["web_resources.name"](x),
+		StatusID:  x.StatusID,
+	}
+}
+
+func eventTime(x *core.OCSFWebResourceActivity) time.Time {
+	return time.UnixMilli(x.Time).UTC()
+}
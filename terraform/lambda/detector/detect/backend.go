@@ -0,0 +1,146 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+)
+
+// ratePattern matches rate expressions of schedules
+var ratePattern = regexp.MustCompile(`^rate\(([1-9][0-9]*) (minute|hour|day)s?\)$`)
+
+// Backend runs a rule over records in the period [start, end)
+type Backend interface {
+	Run(ctx context.Context, rule *Rule, start, end time.Time) ([]Alert, error)
+}
+
+// MemoryBackend evaluates native rules over records in memory
+type MemoryBackend struct {
+	Records []core.OCSFWebResourceActivity
+}
+
+// Run evaluates the rule over records in the period. Zero start or end leaves the period open.
+func (x *MemoryBackend) Run(ctx context.Context, rule *Rule, start, end time.Time) ([]Alert, error) {
+	records := x.Records
+	if !start.IsZero() || !end.IsZero() {
+		records = nil
+		for _, record := range x.Records {
+			t := eventTime(&record)
+			if (start.IsZero() || !t.Before(start)) && (end.IsZero() || t.Before(end)) {
+				records = append(records, record)
+			}
+		}
+	}
+	return Evaluate([]Rule{*rule}, records)
+}
+
+// QueryRunner runs a SQL query and returns rows as values keyed by column names
+type QueryRunner interface {
+	Query(ctx context.Context, query string) ([]map[string]string, error)
+}
+
+// SQLBackend runs the SQL of rules with a QueryRunner, such as Athena
+type SQLBackend struct {
+	Runner QueryRunner
+	Table  string // DefaultTable if empty
+}
+
+// Run renders the query of the rule for the period, runs it and builds alerts from rows
+func (x *SQLBackend) Run(ctx context.Context, rule *Rule, start, end time.Time) ([]Alert, error) {
+	if start.IsZero() || end.IsZero() {
+		return nil, fmt.Errorf("SQL backend requires the period of the query")
+	}
+	table := x.Table
+	if table == "" {
+		table = DefaultTable
+	}
+	query, err := rule.RenderQuery(QueryWindow{Table: table, Start: start, End: end})
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %w", rule.ID, err)
+	}
+	rows, err := x.Runner.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %w", rule.ID, err)
+	}
+
+	alerts := make([]Alert, 0, len(rows))
+	for i, row := range rows {
+		alert, err := rule.alertFromRow(row, end)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: row %d: %w", rule.ID, i+1, err)
+		}
+		alerts = append(alerts, alert)
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].DetectedAt.Before(alerts[j].DetectedAt) })
+	last := map[string]time.Time{}
+	return slices.DeleteFunc(alerts, func(alert Alert) bool { return rule.suppressed(&alert, last) }), nil
+}
+
+// RunScheduled runs the rule over its lookback period ending at now, as a scheduled run
+func RunScheduled(ctx context.Context, backend Backend, rule *Rule, now time.Time) ([]Alert, error) {
+	return backend.Run(ctx, rule, now.Add(-rule.lookback()), now)
+}
+
+// Replay runs the rule at each scheduled time in the period [start, end), and the first
+// scheduled time at or after end, as deployed detectors would have run over past records.
+// Alerts of overlapping runs are suppressed across runs.
+func Replay(ctx context.Context, backend Backend, rule *Rule, start, end time.Time) ([]Alert, error) {
+	interval, err := rule.Interval()
+	if err != nil {
+		return nil, err
+	}
+	var alerts []Alert
+	for now := start.Add(interval); ; now = now.Add(interval) {
+		found, err := RunScheduled(ctx, backend, rule, now)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, found...)
+		if !now.Before(end) {
+			break
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].DetectedAt.Before(alerts[j].DetectedAt) })
+	last := map[string]time.Time{}
+	return slices.DeleteFunc(alerts, func(alert Alert) bool { return rule.suppressed(&alert, last) }), nil
+}
+
+// Interval returns the interval of a rate schedule, such as 5 minutes of "rate(5 minutes)"
+func (x *Rule) Interval() (time.Duration, error) {
+	m := ratePattern.FindStringSubmatch(x.Schedule)
+	if m == nil {
+		return 0, fmt.Errorf("rule %q: schedule %q is not a rate expression", x.ID, x.Schedule)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("rule %q: invalid schedule %q", x.ID, x.Schedule)
+	}
+	unit := map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour}[m[2]]
+	return time.Duration(n) * unit, nil
+}
+
+// suppressed reports whether the alert is suppressed by an earlier alert of the rule with
+// the same suppression key, and records the alert otherwise. Alerts must be checked in order
+// of detection time.
+func (x *Rule) suppressed(alert *Alert, last map[string]time.Time) bool {
+	if len(x.Suppression.Key) == 0 {
+		return false
+	}
+	values := []string{x.ID}
+	for _, name := range x.Suppression.Key {
+		values = append(values, alert.Value(name))
+	}
+	key := strings.Join(values, "\x00")
+	if t, ok := last[key]; ok && alert.DetectedAt.Sub(t) < x.Suppression.Duration {
+		return true
+	}
+	last[key] = alert.DetectedAt
+	return false
+}
@@ -0,0 +1,218 @@
+package detect_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner returns rows of the query and records the queries
+type fakeRunner struct {
+	rows    []map[string]string
+	err     error
+	queries []string
+}
+
+func (x *fakeRunner) Query(ctx context.Context, query string) ([]map[string]string, error) {
+	x.queries = append(x.queries, query)
+	return x.rows, x.err
+}
+
+func probingRecords() []core.OCSFWebResourceActivity {
+	var records []core.OCSFWebResourceActivity
+	for i, name := range []string{"Google Drive API", "Google Calendar API", "Gmail API", "Google Admin API"} {
+		records = append(records, ocsftest.Record(ocsftest.At(i*10), "probe@example.com", "198.51.100.1", ocsftest.Failed, ocsftest.Service(name, "permission_denied")))
+	}
+	// Normal activity of another user
+	for i := range 10 {
+		records = append(records, ocsftest.Record(ocsftest.At(i*10), "user@example.com", "192.0.2.1"))
+	}
+	return records
+}
+
+// The same rule runs in memory and on Athena
+func TestBackends_SameRule(t *testing.T) {
+	rule, err := detect.ParseRule([]byte(probingRuleYAML))
+	require.NoError(t, err)
+	start, end := ocsftest.At(-300), ocsftest.At(300)
+
+	memory := &detect.MemoryBackend{Records: probingRecords()}
+	alerts, err := memory.Run(context.Background(), rule, start, end)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	want := alerts[0]
+
+	// The row Athena returns for the records
+	runner := &fakeRunner{rows: []map[string]string{{
+		"actor.user.email_addr": "probe@example.com",
+		"count":                 "4",
+		"failures":              "4",
+		"first_seen":            fmt.Sprint(ocsftest.At(0).UnixMilli()),
+		"last_seen":             "2024-08-12 10:00:30.000",
+		"api.service.name":      "Gmail API\x1fGoogle Admin API\x1fGoogle Calendar API\x1fGoogle Drive API",
+		"src_endpoint.ip":       "198.51.100.1",
+	}}}
+	athena := &detect.SQLBackend{Runner: runner, Table: "logs"}
+	alerts, err = athena.Run(context.Background(), rule, start, end)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	got := alerts[0]
+
+	require.Len(t, runner.queries, 1)
+	assert.Contains(t, runner.queries[0], "FROM logs\n")
+	assert.Contains(t, runner.queries[0], "WHERE eventday BETWEEN '20240812' AND '20240812'")
+	assert.Contains(t, runner.queries[0], fmt.Sprintf("AND time >= %d AND time < %d", start.UnixMilli(), end.UnixMilli()))
+
+	for _, alert := range []detect.Alert{want, got} {
+		assert.Equal(t, "probing", alert.RuleID)
+		assert.Equal(t, "T1526", alert.Technique.ID)
+		assert.Equal(t, map[string]string{"actor.user.email_addr": "probe@example.com"}, alert.Group)
+		assert.Equal(t, 4, alert.Count)
+		assert.Equal(t, 4, alert.Failures)
+		assert.Equal(t, ocsftest.At(0), alert.FirstSeen)
+		assert.Equal(t, ocsftest.At(30), alert.LastSeen)
+		assert.Equal(t, detect.AlertFields{
+			Who:   "probe@example.com",
+			What:  "4 requests to 4 services",
+			Where: "198.51.100.1",
+		}, alert.Fields)
+	}
+	assert.Equal(t, want.Distinct, got.Distinct)
+}
+
+func TestSQLBackend_SQLRule(t *testing.T) {
+	rule, err := detect.ParseRule([]byte(`
+id: sql-rule
+title: SQL rule
+severity: low
+technique: {id: T1078}
+schedule: rate(1 hour)
+lookback: 1h
+query:
+  group_by: [user]
+  collect: [ips]
+  sql: SELECT user, ips, countries FROM {{.Table}} WHERE eventday = '{{.EndDay}}'
+alert:
+  who: ${user}
+  where: ${ips} (${countries})
+suppression:
+  key: [user]
+  duration: 1h
+`))
+	require.NoError(t, err)
+	runner := &fakeRunner{rows: []map[string]string{
+		{"user": "a@example.com", "ips": "192.0.2.1\x1f198.51.100.1", "countries": "JP, US"},
+		{"user": "a@example.com", "ips": "192.0.2.1", "countries": "JP"},
+		{"user": "b@example.com", "ips": "", "countries": ""},
+	}}
+
+	now := ocsftest.At(3600)
+	alerts, err := detect.RunScheduled(context.Background(), &detect.SQLBackend{Runner: runner}, rule, now)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT user, ips, countries FROM "+detect.DefaultTable+" WHERE eventday = '20240812'", runner.queries[0])
+
+	// The second alert of a@example.com is suppressed
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "a@example.com", alerts[0].Fields.Who)
+	assert.Equal(t, "192.0.2.1, 198.51.100.1 (JP, US)", alerts[0].Fields.Where)
+	assert.Equal(t, map[string]string{"countries": "JP, US"}, alerts[0].Values)
+	assert.Equal(t, now, alerts[0].DetectedAt)
+	assert.Equal(t, []string{}, alerts[1].Distinct["ips"])
+
+	runner.rows = []map[string]string{{"user": "a@example.com", "count": "many"}}
+	_, err = detect.RunScheduled(context.Background(), &detect.SQLBackend{Runner: runner}, rule, now)
+	assert.ErrorContains(t, err, `rule "sql-rule": row 1: invalid column count`)
+
+	runner.err = errors.New("query failed")
+	_, err = detect.RunScheduled(context.Background(), &detect.SQLBackend{Runner: runner}, rule, now)
+	assert.ErrorContains(t, err, "query failed")
+}
+
+func TestMemoryBackend_Period(t *testing.T) {
+	rule := failureRule()
+	var records []core.OCSFWebResourceActivity
+	for i := range 3 {
+		records = append(records, ocsftest.Record(ocsftest.At(i*10), "a@example.com", "192.0.2.1", ocsftest.Failed))
+	}
+	backend := &detect.MemoryBackend{Records: records}
+
+	alerts, err := backend.Run(context.Background(), &rule, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, alerts, 1)
+
+	// The last record is out of the period
+	alerts, err = backend.Run(context.Background(), &rule, ocsftest.At(0), ocsftest.At(20))
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	alerts, err = detect.RunScheduled(context.Background(), backend, &rule, ocsftest.At(30))
+	require.NoError(t, err)
+	assert.Len(t, alerts, 1)
+}
+
+func TestEvaluate_Suppression(t *testing.T) {
+	rule := failureRule()
+	rule.Suppression = detect.Suppression{Key: []string{"src_endpoint.ip"}, Duration: 10 * time.Minute}
+	var records []core.OCSFWebResourceActivity
+	// Bursts at 0, 5 and 15 minutes, each closed after a quiet window
+	for _, minute := range []int{0, 5, 15} {
+		for i := range 3 {
+			records = append(records, ocsftest.Record(ocsftest.At(minute*60+i), "a@example.com", "192.0.2.1", ocsftest.Failed))
+		}
+	}
+
+	alerts, err := detect.Evaluate([]detect.Rule{rule}, records)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, ocsftest.At(2), alerts[0].DetectedAt)
+	assert.Equal(t, ocsftest.At(15*60+2), alerts[1].DetectedAt)
+}
+
+func TestRule_Interval(t *testing.T) {
+	for schedule, want := range map[string]time.Duration{
+		"rate(1 minute)":  time.Minute,
+		"rate(5 minutes)": 5 * time.Minute,
+		"rate(2 hours)":   2 * time.Hour,
+		"rate(1 day)":     24 * time.Hour,
+	} {
+		rule := detect.Rule{ID: "r", Schedule: schedule}
+		interval, err := rule.Interval()
+		require.NoError(t, err, schedule)
+		assert.Equal(t, want, interval, schedule)
+	}
+
+	rule := detect.Rule{ID: "r", Schedule: "cron(0 * * * ? *)"}
+	_, err := rule.Interval()
+	assert.ErrorContains(t, err, "is not a rate expression")
+}
+
+func TestReplay(t *testing.T) {
+	rule := failureRule()
+	rule.Filter = nil
+	rule.Schedule = "rate(5 minutes)"
+	rule.Lookback = 10 * time.Minute
+	rule.Suppression = detect.Suppression{Key: []string{"src_endpoint.ip"}, Duration: time.Hour}
+	// Every run finds the same group in its lookback
+	runner := &fakeRunner{rows: []map[string]string{{
+		"src_endpoint.ip": "192.0.2.1",
+		"count":           "3",
+		"last_seen":       fmt.Sprint(ocsftest.At(60).UnixMilli()),
+	}}}
+
+	alerts, err := detect.Replay(context.Background(), &detect.SQLBackend{Runner: runner}, &rule, ocsftest.At(0), ocsftest.At(20*60))
+	require.NoError(t, err)
+	// Runs at 5, 10, 15 and 20 minutes, and the alerts of later runs are suppressed
+	assert.Len(t, runner.queries, 4)
+	assert.Contains(t, runner.queries[3], fmt.Sprintf("AND time >= %d AND time < %d", ocsftest.At(10*60).UnixMilli(), ocsftest.At(20*60).UnixMilli()))
+	require.Len(t, alerts, 1)
+	assert.Equal(t, ocsftest.At(60), alerts[0].DetectedAt)
+}
@@ -0,0 +1,292 @@
+package detect
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+)
+
+// Detector evaluates rules over a stream of records. Records must be observed in time order;
+// use Evaluate for records in arbitrary order.
+type Detector struct {
+	rules     []*ruleState
+	watermark time.Time // time of the latest observed record
+}
+
+type ruleState struct {
+	rule     Rule
+	groupBy  []Field
+	distinct []string // names of distinct fields
+	groups   map[string]*groupState
+	swept    time.Time // watermark at the last sweep of quiet groups
+}
+
+// groupState is the sliding window and the open alert of a group
+type groupState struct {
+	group    map[string]string
+	window   []windowRecord
+	failures int
+	// distinct counts records in the window per value, for each distinct field
+	distinct []map[string]int
+
+	alert       *Alert
+	alertFields []string
+	alertValues []map[string]struct{}
+}
+
+type windowRecord struct {
+	sample  Sample
+	time    time.Time
+	failure bool
+	values  []string // values of distinct fields
+}
+
+// New returns a detector of the rules
+func New(rules ...Rule) (*Detector, error) {
+	x := &Detector{}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		if rule.IsSQL() {
+			return nil, fmt.Errorf("rule %q is a SQL rule, which cannot be evaluated in memory", rule.ID)
+		}
+		state := &ruleState{rule: rule, distinct: rule.distinctFields(), groups: map[string]*groupState{}}
+		for _, name := range rule.GroupBy {
+			state.groupBy = append(state.groupBy, fields[name])
+		}
+		x.rules = append(x.rules, state)
+	}
+	return x, nil
+}
+
+// Observe evaluates a record and returns alerts closed by it. An alert is closed when its
+// group has no records for the window of the rule.
+func (x *Detector) Observe(record *core.OCSFWebResourceActivity) []Alert {
+	t := eventTime(record)
+	if t.After(x.watermark) {
+		x.watermark = t
+	}
+	var alerts []Alert
+	for _, state := range x.rules {
+		alerts = append(alerts, state.observe(record, t)...)
+		alerts = append(alerts, state.sweep(x.watermark)...)
+	}
+	return alerts
+}
+
+// Flush closes all open alerts, at the end of the stream
+func (x *Detector) Flush() []Alert {
+	var alerts []Alert
+	for _, state := range x.rules {
+		for _, key := range slices.Sorted(maps.Keys(state.groups)) {
+			if group := state.groups[key]; group.alert != nil {
+				alerts = append(alerts, group.closeAlert(&state.rule))
+			}
+		}
+		state.groups = map[string]*groupState{}
+	}
+	return alerts
+}
+
+// Evaluate runs the rules over records and returns alerts ordered by detection time, except
+// alerts dropped by suppression of the rules
+func Evaluate(rules []Rule, records []core.OCSFWebResourceActivity) ([]Alert, error) {
+	detector, err := New(rules...)
+	if err != nil {
+		return nil, err
+	}
+	ordered := make([]*core.OCSFWebResourceActivity, len(records))
+	for i := range records {
+		ordered[i] = &records[i]
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Time < ordered[j].Time })
+
+	var alerts []Alert
+	for _, record := range ordered {
+		alerts = append(alerts, detector.Observe(record)...)
+	}
+	alerts = append(alerts, detector.Flush()...)
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].DetectedAt.Before(alerts[j].DetectedAt) })
+
+	byID := map[string]*Rule{}
+	for i := range rules {
+		byID[rules[i].ID] = &rules[i]
+	}
+	last := map[string]time.Time{}
+	return slices.DeleteFunc(alerts, func(alert Alert) bool { return byID[alert.RuleID].suppressed(&alert, last) }), nil
+}
+
+func (x *ruleState) observe(record *core.OCSFWebResourceActivity, t time.Time) []Alert {
+	if x.rule.Where != nil && !x.rule.Where.Match(record) {
+		return nil
+	}
+	if x.rule.Filter != nil && !x.rule.Filter(record) {
+		return nil
+	}
+	values := make([]string, len(x.groupBy))
+	for i, field := range x.groupBy {
+		if values[i] = field(record); values[i] == "" {
+			return nil
+		}
+	}
+	key := strings.Join(values, "\x00")
+	group, ok := x.groups[key]
+	if !ok {
+		group = &groupState{group: map[string]string{}, distinct: make([]map[string]int, len(x.distinct))}
+		for i, name := range x.rule.GroupBy {
+			group.group[name] = values[i]
+		}
+		for i := range group.distinct {
+			group.distinct[i] = map[string]int{}
+		}
+		x.groups[key] = group
+	}
+
+	wr := windowRecord{sample: NewSample(record), time: t, failure: IsFailure(record), values: make([]string, len(x.distinct))}
+	for i, name := range x.distinct {
+		wr.values[i] = fields[name](record)
+	}
+
+	var alerts []Alert
+	if group.alert != nil {
+		if t.Sub(group.alert.LastSeen) < x.rule.Window {
+			group.addToAlert(wr)
+			return nil
+		}
+		alerts = append(alerts, group.closeAlert(&x.rule))
+	}
+
+	group.push(wr, x.rule.Window)
+	if x.matches(group) {
+		group.openAlert(x.distinct, t)
+	}
+	return alerts
+}
+
+// sweep closes alerts of groups without records for the window, and drops idle groups.
+// It runs at most once per window to keep Observe cheap.
+func (x *ruleState) sweep(watermark time.Time) []Alert {
+	if watermark.Sub(x.swept) < x.rule.Window {
+		return nil
+	}
+	x.swept = watermark
+
+	var alerts []Alert
+	for _, key := range slices.Sorted(maps.Keys(x.groups)) {
+		group := x.groups[key]
+		if group.alert != nil && watermark.Sub(group.alert.LastSeen) >= x.rule.Window {
+			alerts = append(alerts, group.closeAlert(&x.rule))
+		}
+		if group.alert == nil && (len(group.window) == 0 || watermark.Sub(group.window[len(group.window)-1].time) >= x.rule.Window) {
+			delete(x.groups, key)
+		}
+	}
+	return alerts
+}
+
+// matches reports whether all conditions hold for the window of the group
+func (x *ruleState) matches(group *groupState) bool {
+	count := len(group.window)
+	for _, cond := range x.rule.Threshold {
+		var value float64
+		switch cond.Metric {
+		case MetricCount:
+			value = float64(count)
+		case MetricFailures:
+			value = float64(group.failures)
+		case MetricFailureRatio:
+			value = float64(group.failures) / float64(count)
+		case MetricDistinct:
+			value = float64(len(group.distinct[slices.Index(x.distinct, cond.Field)]))
+		}
+		if value < cond.Min {
+			return false
+		}
+	}
+	return true
+}
+
+// push adds a record to the window and evicts records older than the window
+func (x *groupState) push(wr windowRecord, window time.Duration) {
+	x.window = append(x.window, wr)
+	x.count(wr, 1)
+	evict := 0
+	for evict < len(x.window) && wr.time.Sub(x.window[evict].time) >= window {
+		x.count(x.window[evict], -1)
+		evict++
+	}
+	x.window = x.window[evict:]
+}
+
+func (x *groupState) count(wr windowRecord, delta int) {
+	if wr.failure {
+		x.failures += delta
+	}
+	for i, value := range wr.values {
+		if value == "" {
+			continue
+		}
+		if x.distinct[i][value] += delta; x.distinct[i][value] == 0 {
+			delete(x.distinct[i], value)
+		}
+	}
+}
+
+// openAlert turns the window into an alert and starts a new window
+func (x *groupState) openAlert(distinct []string, detectedAt time.Time) {
+	x.alert = &Alert{
+		Group:      maps.Clone(x.group),
+		FirstSeen:  x.window[0].time,
+		LastSeen:   detectedAt,
+		DetectedAt: detectedAt,
+	}
+	if len(distinct) > 0 {
+		x.alert.Distinct = map[string][]string{}
+	}
+	x.alertFields = distinct
+	x.alertValues = make([]map[string]struct{}, len(distinct))
+	for i := range x.alertValues {
+		x.alertValues[i] = map[string]struct{}{}
+	}
+	for _, wr := range x.window {
+		x.addToAlert(wr)
+	}
+
+	x.window = nil
+	x.failures = 0
+	for i := range x.distinct {
+		x.distinct[i] = map[string]int{}
+	}
+}
+
+func (x *groupState) addToAlert(wr windowRecord) {
+	x.alert.Count++
+	if wr.failure {
+		x.alert.Failures++
+	}
+	x.alert.LastSeen = wr.time
+	for i, value := range wr.values {
+		if value != "" {
+			x.alertValues[i][value] = struct{}{}
+		}
+	}
+	if len(x.alert.Samples) < MaxSamples {
+		x.alert.Samples = append(x.alert.Samples, wr.sample)
+	}
+}
+
+func (x *groupState) closeAlert(rule *Rule) Alert {
+	alert := *x.alert
+	for i, name := range x.alertFields {
+		alert.Distinct[name] = slices.Sorted(maps.Keys(x.alertValues[i]))
+	}
+	alert.describe(rule)
+	x.alert = nil
+	return alert
+}
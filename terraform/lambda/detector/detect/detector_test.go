@@ -0,0 +1,155 @@
+package detect_test
+
+import (
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func failureRule() detect.Rule {
+	return detect.Rule{
+		ID:        "failures",
+		Title:     "Failures",
+		Severity:  detect.SeverityHigh,
+		Filter:    detect.IsFailure,
+		GroupBy:   []string{"src_endpoint.ip"},
+		Window:    time.Minute,
+		Threshold: []detect.Condition{{Metric: detect.MetricCount, Min: 3}},
+		Collect:   []string{"actor.user.email_addr"},
+	}
+}
+
+func TestEvaluate_Threshold(t *testing.T) {
+	records := []core.OCSFWebResourceActivity{
+		ocsftest.Record(ocsftest.At(0), "a@example.com", "192.0.2.1", ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(10), "b@example.com", "192.0.2.1", ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(15), "a@example.com", "192.0.2.1"), // not a failure
+		ocsftest.Record(ocsftest.At(20), "c@example.com", "192.0.2.1", ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(30), "a@example.com", "192.0.2.1", ocsftest.Failed), // added to the open alert
+		// Other IP address, only two failures
+		ocsftest.Record(ocsftest.At(5), "a@example.com", "192.0.2.2", ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(25), "a@example.com", "192.0.2.2", ocsftest.Failed),
+	}
+
+	alerts, err := detect.Evaluate([]detect.Rule{failureRule()}, records)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+
+	alert := alerts[0]
+	assert.Equal(t, "failures", alert.RuleID)
+	assert.Equal(t, detect.SeverityHigh, alert.Severity)
+	assert.Equal(t, map[string]string{"src_endpoint.ip": "192.0.2.1"}, alert.Group)
+	assert.Equal(t, ocsftest.At(0), alert.FirstSeen)
+	assert.Equal(t, ocsftest.At(20), alert.DetectedAt)
+	assert.Equal(t, ocsftest.At(30), alert.LastSeen)
+	assert.Equal(t, 4, alert.Count)
+	assert.Equal(t, 4, alert.Failures)
+	assert.Equal(t, []string{"a@example.com", "b@example.com", "c@example.com"}, alert.Distinct["actor.user.email_addr"])
+	require.Len(t, alert.Samples, 4)
+	assert.Equal(t, "b@example.com", alert.Samples[1].User)
+}
+
+func TestEvaluate_SlidingWindow(t *testing.T) {
+	// Failures are spread so that no minute contains three of them
+	records := []core.OCSFWebResourceActivity{
+		ocsftest.Record(ocsftest.At(0), "a@example.com", "192.0.2.1", ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(40), "a@example.com", "192.0.2.1", ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(70), "a@example.com", "192.0.2.1", ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(110), "a@example.com", "192.0.2.1", ocsftest.Failed),
+	}
+	alerts, err := detect.Evaluate([]detect.Rule{failureRule()}, records)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	// One more failure puts 70, 110 and 115 in the same minute
+	records = append(records, ocsftest.Record(ocsftest.At(115), "a@example.com", "192.0.2.1", ocsftest.Failed))
+	alerts, err = detect.Evaluate([]detect.Rule{failureRule()}, records)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, ocsftest.At(70), alerts[0].FirstSeen)
+	assert.Equal(t, 3, alerts[0].Count)
+}
+
+func TestEvaluate_AlertClosedAfterQuietWindow(t *testing.T) {
+	var records []core.OCSFWebResourceActivity
+	for _, seconds := range []int{0, 1, 2, 3, 4, 100, 101, 102} {
+		records = append(records, ocsftest.Record(ocsftest.At(seconds), "a@example.com", "192.0.2.1", ocsftest.Failed))
+	}
+
+	alerts, err := detect.Evaluate([]detect.Rule{failureRule()}, records)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, 5, alerts[0].Count)
+	assert.Equal(t, ocsftest.At(4), alerts[0].LastSeen)
+	assert.Equal(t, 3, alerts[1].Count)
+	assert.Equal(t, ocsftest.At(102), alerts[1].DetectedAt)
+}
+
+func TestDetector_Observe(t *testing.T) {
+	detector, err := detect.New(failureRule())
+	require.NoError(t, err)
+
+	for _, seconds := range []int{0, 1, 2} {
+		x := ocsftest.Record(ocsftest.At(seconds), "a@example.com", "192.0.2.1", ocsftest.Failed)
+		assert.Empty(t, detector.Observe(&x))
+	}
+
+	// The alert is closed once the stream passes the window, by a record of another group
+	x := ocsftest.Record(ocsftest.At(90), "a@example.com", "192.0.2.9")
+	alerts := detector.Observe(&x)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 3, alerts[0].Count)
+	assert.Empty(t, detector.Flush())
+}
+
+func TestEvaluate_DistinctAndRatio(t *testing.T) {
+	rule := detect.Rule{
+		ID:       "probing",
+		Severity: detect.SeverityMedium,
+		GroupBy:  []string{"actor.user.email_addr"},
+		Window:   5 * time.Minute,
+		Threshold: []detect.Condition{
+			{Metric: detect.MetricDistinct, Field: "api.service.name", Min: 3},
+			{Metric: detect.MetricFailureRatio, Min: 0.7},
+		},
+	}
+
+	// Three services, but only half of the attempts fail
+	normal := []core.OCSFWebResourceActivity{
+		ocsftest.Record(ocsftest.At(0), "a@example.com", "192.0.2.1", ocsftest.Service("Google Drive API", "view")),
+		ocsftest.Record(ocsftest.At(10), "a@example.com", "192.0.2.1", ocsftest.Service("Gmail API", "view"), ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(20), "a@example.com", "192.0.2.1", ocsftest.Service("Google Calendar API", "view")),
+		ocsftest.Record(ocsftest.At(30), "a@example.com", "192.0.2.1", ocsftest.Service("Google Admin API", "permission_denied"), ocsftest.Failed),
+	}
+	alerts, err := detect.Evaluate([]detect.Rule{rule}, normal)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	probing := []core.OCSFWebResourceActivity{
+		ocsftest.Record(ocsftest.At(0), "b@example.com", "192.0.2.2", ocsftest.Service("Google Drive API", "access_denied"), ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(10), "b@example.com", "192.0.2.2", ocsftest.Service("Gmail API", "permission_denied"), ocsftest.Failed),
+		ocsftest.Record(ocsftest.At(20), "b@example.com", "192.0.2.2", ocsftest.Service("Google Drive API", "view")),
+		ocsftest.Record(ocsftest.At(30), "b@example.com", "192.0.2.2", ocsftest.Service("Google Admin API", "permission_denied"), ocsftest.Failed),
+	}
+	alerts, err = detect.Evaluate([]detect.Rule{rule}, probing)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 3, alerts[0].Failures)
+	assert.Equal(t, []string{"Gmail API", "Google Admin API", "Google Drive API"}, alerts[0].Distinct["api.service.name"])
+}
+
+func TestEvaluate_SkipsRecordsWithoutGroupValue(t *testing.T) {
+	var records []core.OCSFWebResourceActivity
+	for i := range 5 {
+		records = append(records, ocsftest.Record(ocsftest.At(i), "a@example.com", "", ocsftest.Failed))
+	}
+	alerts, err := detect.Evaluate([]detect.Rule{failureRule()}, records)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
@@ -0,0 +1,406 @@
+package detect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"seccamp2025-b1-converter/core"
+)
+
+// Expr is a boolean expression over fields of records, evaluated natively by Match and
+// translated to an Athena SQL predicate by SQL, e.g.
+//
+//	api.service.name == "Google Identity" and (status_id == 2 or api.operation == "login_failure")
+//
+// Comparisons are ==, !=, <, <=, >, >= (ordering only for numeric fields), in [...],
+// not in [...] and contains "...". Operands are combined with and, or, not and parentheses.
+// Numeric fields are compared with numbers, and other fields with double quoted strings.
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+type exprNode interface {
+	match(x *core.OCSFWebResourceActivity) bool
+	sql() string
+}
+
+// ParseExpr parses and type checks an expression
+func ParseExpr(src string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && !p.done() {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Match reports whether the record satisfies the expression
+func (x *Expr) Match(record *core.OCSFWebResourceActivity) bool {
+	return x.root.match(record)
+}
+
+// SQL returns the expression as an Athena SQL predicate
+func (x *Expr) SQL() string {
+	return x.root.sql()
+}
+
+func (x *Expr) String() string {
+	return x.src
+}
+
+type andNode struct{ left, right exprNode }
+
+func (x andNode) match(r *core.OCSFWebResourceActivity) bool {
+	return x.left.match(r) && x.right.match(r)
+}
+
+func (x andNode) sql() string {
+	return "(" + x.left.sql() + " AND " + x.right.sql() + ")"
+}
+
+type orNode struct{ left, right exprNode }
+
+func (x orNode) match(r *core.OCSFWebResourceActivity) bool {
+	return x.left.match(r) || x.right.match(r)
+}
+
+func (x orNode) sql() string {
+	return "(" + x.left.sql() + " OR " + x.right.sql() + ")"
+}
+
+type notNode struct{ operand exprNode }
+
+func (x notNode) match(r *core.OCSFWebResourceActivity) bool {
+	return !x.operand.match(r)
+}
+
+func (x notNode) sql() string {
+	return "NOT (" + x.operand.sql() + ")"
+}
+
+// literal is a string or number operand
+type literal struct {
+	text    string
+	number  float64
+	numeric bool
+}
+
+func (x literal) sql() string {
+	if x.numeric {
+		return strconv.FormatFloat(x.number, 'f', -1, 64)
+	}
+	return "'" + strings.ReplaceAll(x.text, "'", "''") + "'"
+}
+
+// compareNode compares a field with literals
+type compareNode struct {
+	field   string
+	value   Field
+	numeric bool
+	op      string
+	values  []literal
+}
+
+func (x compareNode) match(r *core.OCSFWebResourceActivity) bool {
+	value := x.value(r)
+	switch x.op {
+	case "contains":
+		return strings.Contains(value, x.values[0].text)
+	case "in", "not in":
+		found := false
+		for _, v := range x.values {
+			if x.equal(value, v) {
+				found = true
+				break
+			}
+		}
+		return found == (x.op == "in")
+	case "==":
+		return x.equal(value, x.values[0])
+	case "!=":
+		return !x.equal(value, x.values[0])
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	want := x.values[0].number
+	switch x.op {
+	case "<":
+		return n < want
+	case "<=":
+		return n <= want
+	case ">":
+		return n > want
+	case ">=":
+		return n >= want
+	}
+	return false
+}
+
+func (x compareNode) equal(value string, v literal) bool {
+	if !x.numeric {
+		return value == v.text
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	return err == nil && n == v.number
+}
+
+func (x compareNode) sql() string {
+	column := sqlColumn(x.field)
+	switch x.op {
+	case "contains":
+		return fmt.Sprintf("strpos(%s, %s) > 0", column, x.values[0].sql())
+	case "in", "not in":
+		items := make([]string, len(x.values))
+		for i, v := range x.values {
+			items[i] = v.sql()
+		}
+		return fmt.Sprintf("%s %s (%s)", column, strings.ToUpper(x.op), strings.Join(items, ", "))
+	case "==":
+		return column + " = " + x.values[0].sql()
+	case "!=":
+		return column + " <> " + x.values[0].sql()
+	}
+	return column + " " + x.op + " " + x.values[0].sql()
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string // unquoted for strings
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			text, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s: %w", src[i:end+1], err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text})
+			i = end + 1
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(src) && (src[end] == '.' || (src[end] >= '0' && src[end] <= '9')) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[i:end]})
+			i = end
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i + 1
+			for end < len(src) && (src[end] == '_' || src[end] == '.' || unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[i:end]})
+			i = end
+		default:
+			symbol := string(c)
+			if i+1 < len(src) && src[i+1] == '=' && strings.ContainsRune("=!<>", rune(c)) {
+				symbol = src[i : i+2]
+			} else if !strings.ContainsRune("<>()[],", rune(c)) {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol})
+			i += len(symbol)
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *exprParser) peek() token {
+	if p.done() {
+		return token{kind: tokenSymbol, text: "end of expression"}
+	}
+	return p.tokens[p.pos]
+}
+
+// keyword consumes the next token if it is the keyword or symbol
+func (p *exprParser) keyword(text string) bool {
+	if t := p.peek(); !p.done() && (t.kind == tokenIdent || t.kind == tokenSymbol) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.keyword(text) {
+		return fmt.Errorf("expected %q, got %q", text, p.peek().text)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.keyword("or") {
+		var right exprNode
+		if right, err = p.parseAnd(); err == nil {
+			left = orNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	for err == nil && p.keyword("and") {
+		var right exprNode
+		if right, err = p.parseNot(); err == nil {
+			left = andNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.keyword("not") {
+		operand, err := p.parseNot()
+		return notNode{operand}, err
+	}
+	if p.keyword("(") {
+		node, err := p.parseOr()
+		if err == nil {
+			err = p.expect(")")
+		}
+		return node, err
+	}
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	t := p.peek()
+	if p.done() || t.kind != tokenIdent {
+		return nil, fmt.Errorf("expected field, got %q", t.text)
+	}
+	p.pos++
+	value, ok := LookupField(t.text)
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", t.text)
+	}
+	node := compareNode{field: t.text, value: value, numeric: numericFields[t.text]}
+
+	switch {
+	case p.keyword("contains"):
+		node.op = "contains"
+	case p.keyword("in"):
+		node.op = "in"
+	case p.keyword("not"):
+		if err := p.expect("in"); err != nil {
+			return nil, err
+		}
+		node.op = "not in"
+	default:
+		for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+			if p.keyword(op) {
+				node.op = op
+				break
+			}
+		}
+	}
+	if node.op == "" {
+		return nil, fmt.Errorf("expected operator after %s, got %q", node.field, p.peek().text)
+	}
+	switch {
+	case node.op == "contains" && node.numeric:
+		return nil, fmt.Errorf("contains is not supported for numeric field %s", node.field)
+	case strings.ContainsAny(node.op, "<>") && !node.numeric:
+		return nil, fmt.Errorf("%s is not supported for string field %s", node.op, node.field)
+	}
+
+	var err error
+	if node.op == "in" || node.op == "not in" {
+		node.values, err = p.parseList(node)
+	} else {
+		var v literal
+		v, err = p.parseLiteral(node)
+		node.values = []literal{v}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (p *exprParser) parseList(node compareNode) ([]literal, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	var values []literal
+	for {
+		v, err := p.parseLiteral(node)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		if p.keyword("]") {
+			return values, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseLiteral parses an operand of the type of the field
+func (p *exprParser) parseLiteral(node compareNode) (literal, error) {
+	t := p.peek()
+	switch {
+	case !p.done() && t.kind == tokenNumber && node.numeric:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return literal{}, fmt.Errorf("invalid number %q", t.text)
+		}
+		p.pos++
+		return literal{text: t.text, number: n, numeric: true}, nil
+	case !p.done() && t.kind == tokenString && !node.numeric:
+		p.pos++
+		return literal{text: t.text}, nil
+	case node.numeric:
+		return literal{}, fmt.Errorf("expected number for %s, got %q", node.field, t.text)
+	}
+	return literal{}, fmt.Errorf("expected string for %s, got %q", node.field, t.text)
+}
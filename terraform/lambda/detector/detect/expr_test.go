@@ -0,0 +1,62 @@
+package detect_test
+
+import (
+	"testing"
+
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpr_Match(t *testing.T) {
+	x := ocsftest.Record(ocsftest.At(0), "admin@muhai-academy.com", "192.0.2.1", ocsftest.Failed, ocsftest.Service("Google Identity", "login_failure"))
+
+	for src, want := range map[string]bool{
+		`status_id == 2`:                                        true,
+		`status_id != 2`:                                        false,
+		`status_id >= 2 and status_id < 3`:                      true,
+		`api.service.name == "Google Identity"`:                 true,
+		`api.service.name != "Google Identity"`:                 false,
+		`actor.user.email_addr contains "admin"`:                true,
+		`api.operation in ["login_success", "login_failure"]`:   true,
+		`api.operation not in ["login_failure"]`:                false,
+		`activity_id in [7, 8] or status_id == 2`:               true,
+		`not (status_id == 2)`:                                  false,
+		`not status_id == 1 and src_endpoint.ip == "192.0.2.1"`: true,
+		`api.service.name == "Google Drive API" or (status_id == 2 and api.operation == "login_failure")`: true,
+	} {
+		expr, err := detect.ParseExpr(src)
+		require.NoError(t, err, src)
+		assert.Equal(t, want, expr.Match(&x), src)
+		assert.Equal(t, src, expr.String())
+	}
+}
+
+func TestExpr_SQL(t *testing.T) {
+	expr, err := detect.ParseExpr(`api.service.name == "Google Identity" and (status_id == 2 or api.operation not in ["login_success", "it's"]) and web_resources.name contains "x"`)
+	require.NoError(t, err)
+	assert.Equal(t,
+		`((api.service.name = 'Google Identity' AND (status_id = 2 OR api.operation NOT IN ('login_success', 'it''s'))) AND strpos(web_resources[1].name, 'x') > 0)`,
+		expr.SQL())
+}
+
+func TestParseExpr_Errors(t *testing.T) {
+	for src, want := range map[string]string{
+		`status == 2`:                        `unknown field "status"`,
+		`status_id == "2"`:                   `expected number for status_id`,
+		`api.operation == login`:             `expected string for api.operation`,
+		`api.operation > "a"`:                `> is not supported for string field api.operation`,
+		`status_id contains "2"`:             `contains is not supported for numeric field status_id`,
+		`status_id == 2 and`:                 `expected field`,
+		`(status_id == 2`:                    `expected ")"`,
+		`status_id = 2`:                      `unexpected character '='`,
+		`api.operation == "a`:                `unterminated string`,
+		`api.operation in ["a" "b"]`:         `expected ","`,
+		`status_id == 2 api.operation == ""`: `unexpected "api.operation"`,
+	} {
+		_, err := detect.ParseExpr(src)
+		assert.ErrorContains(t, err, want, src)
+	}
+}
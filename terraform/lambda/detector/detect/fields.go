@@ -0,0 +1,87 @@
+package detect
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"seccamp2025-b1-converter/core"
+)
+
+// Field extracts an attribute of a record as a string. An empty string means the record has
+// no value for the field.
+type Field func(*core.OCSFWebResourceActivity) string
+
+// fields are the attributes available to rules, named by their OCSF path as in Athena
+// queries. web_resources fields refer to the first resource (web_resources[1] in SQL).
+var fields = map[string]Field{
+	"activity_id": func(x *core.OCSFWebResourceActivity) string { return strconv.Itoa(x.ActivityID) },
+	"status_id":   func(x *core.OCSFWebResourceActivity) string { return strconv.Itoa(x.StatusID) },
+	"severity_id": func(x *core.OCSFWebResourceActivity) string { return strconv.Itoa(x.SeverityID) },
+
+	"actor.user.email_addr": func(x *core.OCSFWebResourceActivity) string { return x.Actor.User.EmailAddr },
+	"actor.user.uid":        func(x *core.OCSFWebResourceActivity) string { return x.Actor.User.UID },
+	"actor.user.domain":     func(x *core.OCSFWebResourceActivity) string { return x.Actor.User.Domain },
+	"actor.session.uid":     func(x *core.OCSFWebResourceActivity) string { return x.Actor.Session.UID },
+	"actor.app_uid":         func(x *core.OCSFWebResourceActivity) string { return x.Actor.AppUID },
+
+	"api.service.name": func(x *core.OCSFWebResourceActivity) string { return x.API.Service.Name },
+	"api.operation":    func(x *core.OCSFWebResourceActivity) string { return x.API.Operation },
+
+	"cloud.account.uid": func(x *core.OCSFWebResourceActivity) string { return x.Cloud.Account.UID },
+
+	"src_endpoint.ip":                     func(x *core.OCSFWebResourceActivity) string { return x.SrcEndpoint.IP },
+	"src_endpoint.location.country":       func(x *core.OCSFWebResourceActivity) string { return x.SrcEndpoint.Location.Country },
+	"src_endpoint.location.city":          func(x *core.OCSFWebResourceActivity) string { return x.SrcEndpoint.Location.City },
+	"src_endpoint.autonomous_system.name": func(x *core.OCSFWebResourceActivity) string { return x.SrcEndpoint.AutonomousSystem.Name },
+
+	"web_resources.uid":                 firstResource(func(r core.OCSFWebResource) string { return r.UID }),
+	"web_resources.name":                firstResource(func(r core.OCSFWebResource) string { return r.Name }),
+	"web_resources.type":                firstResource(func(r core.OCSFWebResource) string { return r.Type }),
+	"web_resources.data.classification": firstResource(func(r core.OCSFWebResource) string { return r.Data.Classification }),
+}
+
+// numericFields are fields of integer columns, compared with numbers in expressions
+var numericFields = map[string]bool{
+	"activity_id": true,
+	"status_id":   true,
+	"severity_id": true,
+}
+
+// sqlColumn returns the column expression of a field in Athena SQL
+func sqlColumn(name string) string {
+	if rest, ok := strings.CutPrefix(name, "web_resources."); ok {
+		return "web_resources[1]." + rest
+	}
+	return name
+}
+
+func firstResource(value func(core.OCSFWebResource) string) Field {
+	return func(x *core.OCSFWebResourceActivity) string {
+		if len(x.WebResources) == 0 {
+			return ""
+		}
+		return value(x.WebResources[0])
+	}
+}
+
+// LookupField returns the field of the OCSF path
+func LookupField(name string) (Field, bool) {
+	field, ok := fields[name]
+	return field, ok
+}
+
+// FieldNames returns names of all fields in order
+func FieldNames() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// IsFailure reports whether the record is a failed activity (status_id = 2)
+func IsFailure(x *core.OCSFWebResourceActivity) bool {
+	return x.StatusID == 2
+}
@@ -0,0 +1,303 @@
+// Package detect evaluates detection rules over OCSF Web Resources Activity records. Rules are
+// declared in YAML files (see ParseRule) and run by backends: in memory against converted
+// records or Parquet files written by the converter, or on Athena against Security Lake.
+//
+// A native rule selects records with an expression, groups them by fields and counts records
+// of each group in a sliding time window. When all threshold conditions hold, an alert is
+// opened and following records of the group are added to it until the group is quiet for the
+// window. A SQL rule runs its query on Athena only.
+package detect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+)
+
+// Severity of an alert, in the same values as OCSF severity_id
+type Severity int
+
+const (
+	SeverityInformational Severity = 1
+	SeverityLow           Severity = 2
+	SeverityMedium        Severity = 3
+	SeverityHigh          Severity = 4
+	SeverityCritical      Severity = 5
+)
+
+var severityNames = map[Severity]string{
+	SeverityInformational: "informational",
+	SeverityLow:           "low",
+	SeverityMedium:        "medium",
+	SeverityHigh:          "high",
+	SeverityCritical:      "critical",
+}
+
+func (x Severity) String() string {
+	if name, ok := severityNames[x]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(x))
+}
+
+// MarshalText encodes the severity by its name
+func (x Severity) MarshalText() ([]byte, error) {
+	return []byte(x.String()), nil
+}
+
+// ParseSeverity returns the severity of a name such as "high"
+func ParseSeverity(name string) (Severity, bool) {
+	for severity, s := range severityNames {
+		if s == name {
+			return severity, true
+		}
+	}
+	return 0, false
+}
+
+const (
+	// MetricCount is the number of records in the window
+	MetricCount = "count"
+	// MetricFailures is the number of failed records (status_id = 2) in the window
+	MetricFailures = "failures"
+	// MetricFailureRatio is failures / count in the window
+	MetricFailureRatio = "failure_ratio"
+	// MetricDistinct is the number of distinct values of Condition.Field in the window
+	MetricDistinct = "distinct"
+)
+
+// Condition is a threshold on a metric of the records of a group in the window
+type Condition struct {
+	Metric string
+	Field  string // field of MetricDistinct
+	Min    float64
+}
+
+func (x Condition) String() string {
+	if x.Metric == MetricDistinct {
+		return fmt.Sprintf("distinct(%s) >= %g", x.Field, x.Min)
+	}
+	return fmt.Sprintf("%s >= %g", x.Metric, x.Min)
+}
+
+// Technique is the MITRE ATT&CK technique detected by a rule
+type Technique struct {
+	ID     string `yaml:"id" json:"id"` // e.g. T1110 or T1110.001
+	Name   string `yaml:"name" json:"name,omitempty"`
+	Tactic string `yaml:"tactic" json:"tactic,omitempty"`
+}
+
+// AlertFields describe an alert following the 5W1H guidance of the detector. Rules declare
+// them as templates referring to alert values by ${name}, see Alert.Value.
+type AlertFields struct {
+	Who   string `yaml:"who" json:"who,omitempty"`
+	What  string `yaml:"what" json:"what,omitempty"`
+	When  string `yaml:"when" json:"when,omitempty"`
+	Where string `yaml:"where" json:"where,omitempty"`
+	Why   string `yaml:"why" json:"why,omitempty"`
+	How   string `yaml:"how" json:"how,omitempty"`
+}
+
+func (x *AlertFields) templates() []*string {
+	return []*string{&x.Who, &x.What, &x.When, &x.Where, &x.Why, &x.How}
+}
+
+// Suppression drops alerts with the same values of Key within Duration after an alert
+type Suppression struct {
+	Key      []string
+	Duration time.Duration
+}
+
+// Rule is a detection rule. A native rule is a windowed aggregation over records, evaluated
+// in memory and translated to Athena SQL by Query. A SQL rule runs SQL only.
+type Rule struct {
+	ID          string
+	Title       string
+	Description string
+	Severity    Severity
+	Technique   Technique
+	// Expects are the anomaly patterns of tools/loggen which the rule is written for. Scores
+	// credit alerts of the rule only with incidents of them, or of any pattern if empty.
+	Expects []int
+	// Schedule is the EventBridge schedule expression of the detector, e.g. "rate(5 minutes)"
+	Schedule string
+	// Lookback is the period queried by each scheduled run, the window if zero
+	Lookback time.Duration
+
+	// Where selects records evaluated by the rule, translated to SQL
+	Where *Expr
+	// Filter selects records in addition to Where, for rules defined in Go. Rules with Filter
+	// cannot run on Athena.
+	Filter func(*core.OCSFWebResourceActivity) bool
+	// GroupBy are fields to group records by. Records without a value of any of the fields
+	// are skipped. All records are in a single group if empty. For SQL rules, they are
+	// result columns which identify the group.
+	GroupBy []string
+	Window  time.Duration
+	// Threshold are conditions which all must hold to open an alert
+	Threshold []Condition
+	// Collect are fields whose distinct values are reported in alerts, in addition to fields
+	// of distinct conditions. For SQL rules, they are result columns of values joined by
+	// chr(31).
+	Collect []string
+
+	// SQL is the query template of a SQL rule, see QueryWindow
+	SQL string
+
+	Fields      AlertFields
+	Suppression Suppression
+}
+
+var (
+	ruleIDPattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	techniquePattern = regexp.MustCompile(`^T[0-9]{4}(\.[0-9]{3})?$`)
+	schedulePattern  = regexp.MustCompile(`^(rate\([1-9][0-9]* (minute|minutes|hour|hours|day|days)\)|cron\(.+\))$`)
+)
+
+// IsSQL reports whether the rule runs SQL only
+func (x *Rule) IsSQL() bool {
+	return x.SQL != ""
+}
+
+// Validate checks that the rule refers to known fields, metrics and alert values
+func (x *Rule) Validate() error {
+	var errs []error
+	if !ruleIDPattern.MatchString(x.ID) {
+		errs = append(errs, fmt.Errorf("id must be lower case letters, digits and hyphens, got %q", x.ID))
+	}
+	if _, ok := severityNames[x.Severity]; !ok {
+		errs = append(errs, fmt.Errorf("unknown severity %d", int(x.Severity)))
+	}
+	if x.Technique.ID != "" && !techniquePattern.MatchString(x.Technique.ID) {
+		errs = append(errs, fmt.Errorf("invalid MITRE ATT&CK technique %q", x.Technique.ID))
+	}
+	if x.Schedule != "" && !schedulePattern.MatchString(x.Schedule) {
+		errs = append(errs, fmt.Errorf("invalid schedule %q", x.Schedule))
+	}
+	if x.Lookback < 0 {
+		errs = append(errs, errors.New("lookback must not be negative"))
+	}
+	for _, pattern := range x.Expects {
+		if pattern <= 0 {
+			errs = append(errs, fmt.Errorf("expected pattern must be positive, got %d", pattern))
+		}
+	}
+	if x.IsSQL() {
+		errs = append(errs, x.validateSQL()...)
+	} else {
+		errs = append(errs, x.validateNative()...)
+	}
+	errs = append(errs, x.validateAlert()...)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid rule %q: %w", x.ID, err)
+	}
+	return nil
+}
+
+func (x *Rule) validateNative() []error {
+	var errs []error
+	if x.Window <= 0 {
+		errs = append(errs, errors.New("window must be positive"))
+	}
+	if len(x.Threshold) == 0 {
+		errs = append(errs, errors.New("threshold requires at least one condition"))
+	}
+	for _, name := range append(append([]string{}, x.GroupBy...), x.Collect...) {
+		if _, ok := LookupField(name); !ok {
+			errs = append(errs, fmt.Errorf("unknown field %q", name))
+		}
+	}
+	for _, cond := range x.Threshold {
+		switch cond.Metric {
+		case MetricCount, MetricFailures, MetricFailureRatio:
+		case MetricDistinct:
+			if _, ok := LookupField(cond.Field); !ok {
+				errs = append(errs, fmt.Errorf("unknown field %q in condition %s", cond.Field, cond))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown metric %q", cond.Metric))
+		}
+	}
+	return errs
+}
+
+func (x *Rule) validateSQL() []error {
+	var errs []error
+	if x.Where != nil || x.Filter != nil || len(x.Threshold) > 0 || x.Window > 0 {
+		errs = append(errs, errors.New("SQL rule cannot have a native query"))
+	}
+	if x.Lookback <= 0 {
+		errs = append(errs, errors.New("SQL rule requires lookback"))
+	}
+	if _, err := x.RenderQuery(QueryWindow{Table: DefaultTable, End: time.Unix(0, 0)}); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// validateAlert checks that templates and the suppression key refer to alert values. Values
+// of SQL rules are result columns, which are only known when the query runs.
+func (x *Rule) validateAlert() []error {
+	var errs []error
+	known := func(name string) bool { return x.IsSQL() || slices.Contains(x.valueNames(), name) }
+	for _, tmpl := range x.Fields.templates() {
+		os.Expand(*tmpl, func(name string) string {
+			if !known(name) {
+				errs = append(errs, fmt.Errorf("unknown alert value ${%s} in %q", name, *tmpl))
+			}
+			return ""
+		})
+	}
+	for _, name := range x.Suppression.Key {
+		if !known(name) {
+			errs = append(errs, fmt.Errorf("unknown alert value %q in suppression key", name))
+		}
+	}
+	if len(x.Suppression.Key) > 0 && x.Suppression.Duration <= 0 {
+		errs = append(errs, errors.New("suppression requires duration"))
+	}
+	return errs
+}
+
+// valueNames returns names of alert values of a native rule
+func (x *Rule) valueNames() []string {
+	names := append([]string{}, alertValueNames...)
+	names = append(names, x.GroupBy...)
+	for _, name := range x.distinctFields() {
+		names = append(names, name, "distinct("+name+")")
+	}
+	return names
+}
+
+// lookback returns the period queried by a scheduled run
+func (x *Rule) lookback() time.Duration {
+	if x.Lookback > 0 {
+		return x.Lookback
+	}
+	return x.Window
+}
+
+// distinctFields returns fields whose distinct values are tracked in windows and alerts
+func (x *Rule) distinctFields() []string {
+	var names []string
+	add := func(name string) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, cond := range x.Threshold {
+		if cond.Metric == MetricDistinct {
+			add(cond.Field)
+		}
+	}
+	for _, name := range x.Collect {
+		add(name)
+	}
+	return names
+}
@@ -0,0 +1,122 @@
+package detect_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRule_Validate(t *testing.T) {
+	valid := failureRule()
+	assert.NoError(t, valid.Validate())
+
+	rule := detect.Rule{
+		Severity: detect.Severity(9),
+		GroupBy:  []string{"src_endpoint.ipv4"},
+		Threshold: []detect.Condition{
+			{Metric: detect.MetricDistinct, Field: "unknown"},
+			{Metric: "sum"},
+		},
+	}
+	err := rule.Validate()
+	require.Error(t, err)
+	for _, want := range []string{
+		`id must be lower case letters, digits and hyphens, got ""`,
+		"window must be positive",
+		"unknown severity 9",
+		`unknown field "src_endpoint.ipv4"`,
+		`unknown field "unknown" in condition distinct(unknown) >= 0`,
+		`unknown metric "sum"`,
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+
+	_, err = detect.New(rule)
+	assert.Error(t, err)
+}
+
+func TestRule_ValidateAlert(t *testing.T) {
+	rule := failureRule()
+	rule.Fields = detect.AlertFields{Who: "${actor.user.email_addr}", What: "${count} failures", Where: "${src_endpoint.ip}"}
+	rule.Suppression = detect.Suppression{Key: []string{"actor.user.email_addr"}, Duration: time.Hour}
+	assert.NoError(t, rule.Validate())
+
+	rule.Fields.How = "${api.operation}"
+	rule.Suppression = detect.Suppression{Key: []string{"api.service.name"}}
+	err := rule.Validate()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, `unknown alert value ${api.operation} in "${api.operation}"`)
+	assert.ErrorContains(t, err, `unknown alert value "api.service.name" in suppression key`)
+	assert.ErrorContains(t, err, "suppression requires duration")
+}
+
+func TestRule_ValidateSQL(t *testing.T) {
+	rule := detect.Rule{
+		ID:       "sql-rule",
+		Severity: detect.SeverityLow,
+		Lookback: time.Hour,
+		SQL:      "SELECT COUNT(*) AS count FROM {{.Table}} WHERE eventday >= '{{.StartDay}}'",
+		// Result columns are only known when the query runs
+		Fields: detect.AlertFields{What: "${anything}"},
+	}
+	assert.NoError(t, rule.Validate())
+	_, err := detect.New(rule)
+	assert.ErrorContains(t, err, "cannot be evaluated in memory")
+
+	rule.SQL = "SELECT * FROM {{.Tabel}}"
+	rule.Lookback = 0
+	rule.Window = time.Minute
+	err = rule.Validate()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "invalid SQL template")
+	assert.ErrorContains(t, err, "SQL rule requires lookback")
+	assert.ErrorContains(t, err, "SQL rule cannot have a native query")
+}
+
+func TestDefaultRules_Valid(t *testing.T) {
+	ids := map[string]bool{}
+	for _, rule := range detect.DefaultRules() {
+		assert.NoError(t, rule.Validate())
+		assert.False(t, ids[rule.ID], "duplicate rule %s", rule.ID)
+		assert.NotEmpty(t, rule.Technique.ID, rule.ID)
+		assert.NotEmpty(t, rule.Schedule, rule.ID)
+		ids[rule.ID] = true
+	}
+	for _, id := range []string{detect.RuleAuthBruteForce, detect.RuleMassDownload, detect.RuleServiceProbing, detect.RuleAfterHoursAdminDownload} {
+		assert.True(t, ids[id], id)
+	}
+}
+
+func TestAlert_JSON(t *testing.T) {
+	alert := detect.Alert{RuleID: "r", Severity: detect.SeverityHigh, DetectedAt: ocsftest.Base.Add(time.Minute)}
+	raw, err := json.Marshal(alert)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"severity":"high"`)
+	assert.Contains(t, string(raw), `"detected_at":"2024-08-12T10:01:00Z"`)
+}
+
+func TestLookupField(t *testing.T) {
+	x := ocsftest.Record(ocsftest.At(0), "a@example.com", "192.0.2.1", ocsftest.Failed)
+	field, ok := detect.LookupField("actor.user.email_addr")
+	require.True(t, ok)
+	assert.Equal(t, "a@example.com", field(&x))
+
+	field, ok = detect.LookupField("status_id")
+	require.True(t, ok)
+	assert.Equal(t, "2", field(&x))
+
+	// The first web resource, empty without resources
+	field, ok = detect.LookupField("web_resources.uid")
+	require.True(t, ok)
+	assert.Empty(t, field(&x))
+
+	_, ok = detect.LookupField("unknown")
+	assert.False(t, ok)
+	assert.Contains(t, detect.FieldNames(), "src_endpoint.location.country")
+}
@@ -0,0 +1,9 @@
+package detect
+
+// IDs of the built-in rules in rules/
+const (
+	RuleAuthBruteForce          = "auth-brute-force"
+	RuleMassDownload            = "mass-download"
+	RuleServiceProbing          = "service-probing"
+	RuleAfterHoursAdminDownload = "after-hours-admin-download"
+)
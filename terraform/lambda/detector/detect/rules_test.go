@@ -0,0 +1,207 @@
+package detect_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+	"seccamp2025-b1-detector/localsql"
+	"seccamp2025-b1-detector/score"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// workspaceLog returns a raw Google Workspace log line in the shape of tools/loggen output.
+// loggen does not write Example 1 for its users, who are neither administrators nor managers.
+func workspaceLog(t *testing.T, seconds int, app, user, ip, eventType, eventName string) string {
+	raw, err := json.Marshal(map[string]any{
+		"kind": "admin#reports#activity",
+		"id": map[string]any{
+			"time":            ocsftest.At(seconds).Format(time.RFC3339Nano),
+			"uniqueQualifier": fmt.Sprintf("%d", seconds),
+			"applicationName": app,
+			"customerId":      "C03az79cb",
+		},
+		"actor":     map[string]any{"email": user},
+		"ipAddress": ip,
+		"events": []any{map[string]any{
+			"type": eventType,
+			"name": eventName,
+			"parameters": []any{
+				map[string]any{"name": "doc_id", "value": fmt.Sprintf("doc-%d", seconds)},
+				map[string]any{"name": "doc_title", "value": fmt.Sprintf("file-%d.xlsx", seconds)},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	return string(raw)
+}
+
+// loggenSample returns the raw logs and the labels of testdata/loggen
+func loggenSample(t *testing.T) ([]string, []score.Label) {
+	raw, err := os.ReadFile("../testdata/loggen/day_2024-08-12_0200_0205.jsonl.gz")
+	require.NoError(t, err)
+	data, err := core.DecompressInput("day_2024-08-12_0200_0205.jsonl.gz", raw)
+	require.NoError(t, err)
+
+	f, err := os.Open("../testdata/loggen/day_2024-08-12_0200_0205.labels.jsonl.gz")
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	labels, err := score.ReadLabels(r)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n"), labels
+}
+
+// labeled returns the distinct values of a group-by field in the labels of a pattern
+func labeled(labels []score.Label, pattern int, field string) []string {
+	var values []string
+	for _, label := range labels {
+		if label.Pattern != pattern {
+			continue
+		}
+		value := label.IPAddress
+		if field == "actor.user.email_addr" {
+			value = label.Actor
+		}
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// convert runs the converter over raw log lines, as the records in converter output
+func convert(t *testing.T, lines []string) []core.OCSFWebResourceActivity {
+	converter := &core.Converter{Region: "ap-northeast-1", AccountID: "123456789012"}
+	result, err := converter.Convert(context.Background(), "logs/test.jsonl", []byte(strings.Join(lines, "\n")))
+	require.NoError(t, err)
+	require.Empty(t, result.Quarantined)
+	return result.Logs
+}
+
+// nativeRules returns the built-in rules evaluated in memory
+func nativeRules() []detect.Rule {
+	return slices.DeleteFunc(detect.DefaultRules(), func(rule detect.Rule) bool { return rule.IsSQL() })
+}
+
+func alertsByRule(alerts []detect.Alert) map[string][]detect.Alert {
+	byRule := map[string][]detect.Alert{}
+	for _, alert := range alerts {
+		byRule[alert.RuleID] = append(byRule[alert.RuleID], alert)
+	}
+	return byRule
+}
+
+func TestDefaultRules_LoggenSample(t *testing.T) {
+	lines, labels := loggenSample(t)
+	testCases := map[string]struct {
+		ruleID  string
+		pattern int
+		groupBy []string
+	}{
+		"high frequency auth attack": {
+			ruleID:  detect.RuleAuthBruteForce,
+			pattern: 6,
+			groupBy: []string{"src_endpoint.ip"},
+		},
+		"rapid data theft": {
+			ruleID:  detect.RuleMassDownload,
+			pattern: 7,
+			groupBy: []string{"actor.user.email_addr", "src_endpoint.ip"},
+		},
+		"multi service probing": {
+			ruleID:  detect.RuleServiceProbing,
+			pattern: 8,
+			groupBy: []string{"actor.user.email_addr"},
+		},
+	}
+
+	for name, alerts := range map[string][]detect.Alert{
+		"native": mustEvaluate(t, lines),
+		"sql":    mustReplaySQL(t, lines),
+	} {
+		byRule := alertsByRule(alerts)
+		for caseName, tc := range testCases {
+			t.Run(name+"/"+caseName, func(t *testing.T) {
+				require.NotEmpty(t, byRule[tc.ruleID], "alerts %v", byRule)
+				for _, field := range tc.groupBy {
+					var grouped []string
+					for _, alert := range byRule[tc.ruleID] {
+						grouped = append(grouped, alert.Group[field])
+					}
+					assert.ElementsMatch(t, labeled(labels, tc.pattern, field), grouped, field)
+				}
+			})
+		}
+		// Example 1 only changes logs of administrators and managers, who are not in the
+		// loggen users, and other patterns are not the targets of default rules
+		assert.Len(t, byRule, len(testCases), "%s alerts %v", name, byRule)
+	}
+}
+
+func TestDefaultRules_AfterHoursAdminDownload(t *testing.T) {
+	var lines []string
+	// PatternExample1NightAdminDownload: downloads by an administrator at 20:00 UTC from an
+	// internal IP address
+	for i := range 5 {
+		lines = append(lines, workspaceLog(t, 11*3600+i*60, "drive", "admin@muhai-academy.com", "10.0.1.5", "access", "download"))
+	}
+	// Downloads by a manager in business hours
+	for i := range 5 {
+		lines = append(lines, workspaceLog(t, 3*3600+i*60, "drive", "manager@muhai-academy.com", "10.0.1.6", "access", "download"))
+	}
+
+	byRule := alertsByRule(mustReplaySQL(t, lines))
+	require.Len(t, byRule[detect.RuleAfterHoursAdminDownload], 1, "alerts %v", byRule)
+	alert := byRule[detect.RuleAfterHoursAdminDownload][0]
+	assert.Equal(t, map[string]string{
+		"actor.user.email_addr": "admin@muhai-academy.com",
+		"src_endpoint.ip":       "10.0.1.5",
+	}, alert.Group)
+	assert.Equal(t, 5, alert.Count)
+	assert.Equal(t, ocsftest.At(11*3600), alert.FirstSeen)
+	assert.Equal(t, ocsftest.At(11*3600+240), alert.LastSeen)
+	assert.Len(t, alert.Distinct["web_resources.name"], 5)
+}
+
+// mustReplaySQL runs all rules as scheduled over the period of the lines, with their SQL on
+// DuckDB over converter Parquet
+func mustReplaySQL(t *testing.T, lines []string) []detect.Alert {
+	ctx := context.Background()
+	logs := convert(t, lines)
+	db, err := localsql.New()
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.LoadLogs(ctx, detect.DefaultTable, logs))
+
+	first, last := logs[0].Time, logs[0].Time
+	for _, log := range logs {
+		first, last = min(first, log.Time), max(last, log.Time)
+	}
+	start, end := time.UnixMilli(first), time.UnixMilli(last+1)
+	var alerts []detect.Alert
+	for _, rule := range detect.DefaultRules() {
+		found, err := detect.Replay(ctx, &detect.SQLBackend{Runner: db}, &rule, start, end)
+		require.NoError(t, err)
+		alerts = append(alerts, found...)
+	}
+	return alerts
+}
+
+func mustEvaluate(t *testing.T, lines []string) []detect.Alert {
+	alerts, err := detect.Evaluate(nativeRules(), convert(t, lines))
+	require.NoError(t, err)
+	return alerts
+}
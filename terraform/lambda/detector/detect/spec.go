@@ -0,0 +1,230 @@
+package detect
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed rules/*.yaml
+var defaultRulesFS embed.FS
+
+// ruleSpec is a rule file. Unknown keys are rejected.
+type ruleSpec struct {
+	ID          string      `yaml:"id"`
+	Title       string      `yaml:"title"`
+	Description string      `yaml:"description"`
+	Severity    string      `yaml:"severity"`
+	Technique   Technique   `yaml:"technique"`
+	Expects     []int       `yaml:"expects"`
+	Schedule    string      `yaml:"schedule"`
+	Lookback    string      `yaml:"lookback"`
+	Query       querySpec   `yaml:"query"`
+	Alert       AlertFields `yaml:"alert"`
+	Suppression struct {
+		Key      []string `yaml:"key"`
+		Duration string   `yaml:"duration"`
+	} `yaml:"suppression"`
+}
+
+// querySpec is either a native query (where, window and threshold) or sql
+type querySpec struct {
+	Where     string   `yaml:"where"`
+	GroupBy   []string `yaml:"group_by"`
+	Window    string   `yaml:"window"`
+	Threshold []string `yaml:"threshold"`
+	Collect   []string `yaml:"collect"`
+	SQL       string   `yaml:"sql"`
+}
+
+// conditionPattern matches threshold conditions such as "count >= 10" and
+// "distinct(api.service.name) >= 3"
+var conditionPattern = regexp.MustCompile(`^\s*([a-z_]+)(?:\(\s*([a-z_.]+)\s*\))?\s*>=\s*([0-9.]+)\s*$`)
+
+// ParseRule parses and validates a rule file
+func ParseRule(data []byte) (*Rule, error) {
+	var spec ruleSpec
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty rule file")
+		}
+		return nil, fmt.Errorf("failed to parse rule: %w", err)
+	}
+
+	rule, err := spec.rule()
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule %q: %w", spec.ID, err)
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (x *ruleSpec) rule() (*Rule, error) {
+	var errs []error
+	duration := func(key, value string) time.Duration {
+		if value == "" {
+			return 0
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q", key, value))
+		}
+		return d
+	}
+
+	rule := &Rule{
+		ID:          x.ID,
+		Title:       x.Title,
+		Description: strings.TrimSpace(x.Description),
+		Technique:   x.Technique,
+		Expects:     x.Expects,
+		Schedule:    x.Schedule,
+		Lookback:    duration("lookback", x.Lookback),
+		GroupBy:     x.Query.GroupBy,
+		Window:      duration("query.window", x.Query.Window),
+		Collect:     x.Query.Collect,
+		SQL:         strings.TrimSpace(x.Query.SQL),
+		Fields:      x.Alert,
+		Suppression: Suppression{
+			Key:      x.Suppression.Key,
+			Duration: duration("suppression.duration", x.Suppression.Duration),
+		},
+	}
+
+	// Rule files declare what Go rules may omit
+	if x.Title == "" {
+		errs = append(errs, errors.New("title is required"))
+	}
+	if x.Technique.ID == "" {
+		errs = append(errs, errors.New("technique.id is required"))
+	}
+	if x.Schedule == "" {
+		errs = append(errs, errors.New("schedule is required"))
+	}
+	if rule.SQL == "" && x.Query.Where == "" && x.Query.Window == "" && len(x.Query.Threshold) == 0 {
+		errs = append(errs, errors.New("query requires sql or a native query"))
+	}
+
+	severity, ok := ParseSeverity(x.Severity)
+	if !ok {
+		errs = append(errs, fmt.Errorf("unknown severity %q", x.Severity))
+	}
+	rule.Severity = severity
+
+	if x.Query.Where != "" {
+		where, err := ParseExpr(x.Query.Where)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		rule.Where = where
+	}
+	for _, s := range x.Query.Threshold {
+		cond, err := parseCondition(s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rule.Threshold = append(rule.Threshold, cond)
+	}
+	return rule, errors.Join(errs...)
+}
+
+// parseCondition parses a threshold condition in the form of Condition.String
+func parseCondition(s string) (Condition, error) {
+	m := conditionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Condition{}, fmt.Errorf("invalid threshold %q, expected \"metric >= number\"", s)
+	}
+	min, err := strconv.ParseFloat(m[3], 64)
+	if err != nil {
+		return Condition{}, fmt.Errorf("invalid threshold %q: %w", s, err)
+	}
+	if (m[1] == MetricDistinct) != (m[2] != "") {
+		return Condition{}, fmt.Errorf("invalid threshold %q, only distinct takes a field", s)
+	}
+	return Condition{Metric: m[1], Field: m[2], Min: min}, nil
+}
+
+// LoadRules loads rule files. Directories are read for *.yaml and *.yml files.
+func LoadRules(paths ...string) ([]Rule, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read rules: %w", err)
+			}
+			files = append(files, matches...)
+		}
+	}
+	sort.Strings(files)
+	return loadRules(files, os.ReadFile)
+}
+
+func loadRules(files []string, read func(string) ([]byte, error)) ([]Rule, error) {
+	var rules []Rule
+	seen := map[string]string{}
+	for _, file := range files {
+		data, err := read(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rule %s: %w", file, err)
+		}
+		rule, err := ParseRule(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if other, ok := seen[rule.ID]; ok {
+			return nil, fmt.Errorf("%s: rule %q is already defined in %s", file, rule.ID, other)
+		}
+		seen[rule.ID] = file
+		rules = append(rules, *rule)
+	}
+	return rules, nil
+}
+
+var (
+	defaultRulesOnce sync.Once
+	defaultRules     []Rule
+)
+
+// DefaultRules returns the rules embedded in the binary, which cover the detection patterns
+// of docs/06_lambda_implementation_and_detection_rules.md
+func DefaultRules() []Rule {
+	defaultRulesOnce.Do(func() {
+		files, err := fs.Glob(defaultRulesFS, "rules/*.yaml")
+		if err == nil {
+			defaultRules, err = loadRules(files, func(path string) ([]byte, error) {
+				return fs.ReadFile(defaultRulesFS, path)
+			})
+		}
+		if err != nil {
+			panic(fmt.Sprintf("embedded rules are invalid: %v", err))
+		}
+	})
+	return append([]Rule(nil), defaultRules...)
+}
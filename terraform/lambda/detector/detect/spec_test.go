@@ -0,0 +1,195 @@
+package detect_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-detector/detect"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const probingRuleYAML = `
+id: probing
+title: Probing
+severity: medium
+technique:
+  id: T1526
+  name: Cloud Service Discovery
+  tactic: Discovery
+expects: [8]
+schedule: rate(5 minutes)
+lookback: 10m
+query:
+  where: api.operation != "login_success"
+  group_by: [actor.user.email_addr]
+  window: 5m
+  threshold:
+    - distinct(api.service.name) >= 3
+    - failure_ratio >= 0.7
+  collect: [src_endpoint.ip]
+alert:
+  who: ${actor.user.email_addr}
+  what: ${count} requests to ${distinct(api.service.name)} services
+  where: ${src_endpoint.ip}
+suppression:
+  key: [actor.user.email_addr]
+  duration: 1h
+`
+
+func TestParseRule(t *testing.T) {
+	rule, err := detect.ParseRule([]byte(probingRuleYAML))
+	require.NoError(t, err)
+
+	assert.Equal(t, "probing", rule.ID)
+	assert.Equal(t, detect.SeverityMedium, rule.Severity)
+	assert.Equal(t, detect.Technique{ID: "T1526", Name: "Cloud Service Discovery", Tactic: "Discovery"}, rule.Technique)
+	assert.Equal(t, []int{8}, rule.Expects)
+	assert.Equal(t, "rate(5 minutes)", rule.Schedule)
+	assert.Equal(t, 10*time.Minute, rule.Lookback)
+	assert.Equal(t, 5*time.Minute, rule.Window)
+	assert.Equal(t, `api.operation != "login_success"`, rule.Where.String())
+	assert.Equal(t, []detect.Condition{
+		{Metric: detect.MetricDistinct, Field: "api.service.name", Min: 3},
+		{Metric: detect.MetricFailureRatio, Min: 0.7},
+	}, rule.Threshold)
+	assert.Equal(t, "${actor.user.email_addr}", rule.Fields.Who)
+	assert.Equal(t, detect.Suppression{Key: []string{"actor.user.email_addr"}, Duration: time.Hour}, rule.Suppression)
+	assert.False(t, rule.IsSQL())
+}
+
+func TestParseRule_Errors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		old, new string
+		want     []string
+	}{
+		"unknown key": {
+			old: "lookback: 10m", new: "lookbak: 10m",
+			want: []string{"field lookbak not found"},
+		},
+		"missing attributes": {
+			old: "title: Probing\nseverity: medium", new: "severity: severe",
+			want: []string{"title is required", `unknown severity "severe"`},
+		},
+		"technique": {
+			old: "id: T1526", new: "id: 1526",
+			want: []string{`invalid MITRE ATT&CK technique "1526"`},
+		},
+		"expects": {
+			old: "expects: [8]", new: "expects: [0]",
+			want: []string{"expected pattern must be positive, got 0"},
+		},
+		"schedule": {
+			old: "rate(5 minutes)", new: "every 5 minutes",
+			want: []string{`invalid schedule "every 5 minutes"`},
+		},
+		"durations": {
+			old: "window: 5m", new: "window: 5 minutes",
+			want: []string{`invalid query.window "5 minutes"`},
+		},
+		"threshold": {
+			old: "failure_ratio >= 0.7", new: "failure_ratio > 0.7",
+			want: []string{`invalid threshold "failure_ratio > 0.7"`},
+		},
+		"where": {
+			old: `api.operation != "login_success"`, new: `operation != "login_success"`,
+			want: []string{`unknown field "operation"`},
+		},
+		"alert values": {
+			old: "where: ${src_endpoint.ip}", new: "where: ${src_endpoint.location.country}",
+			want: []string{"unknown alert value ${src_endpoint.location.country}"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			src := strings.Replace(probingRuleYAML, tc.old, tc.new, 1)
+			require.NotEqual(t, probingRuleYAML, src)
+			_, err := detect.ParseRule([]byte(src))
+			require.Error(t, err)
+			for _, want := range tc.want {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+
+	_, err := detect.ParseRule(nil)
+	assert.ErrorContains(t, err, "empty rule file")
+}
+
+func TestParseRule_SQL(t *testing.T) {
+	rule, err := detect.ParseRule([]byte(`
+id: sql-rule
+title: SQL rule
+severity: low
+technique: {id: T1078}
+schedule: cron(0 * * * ? *)
+lookback: 1h
+query:
+  group_by: [user]
+  sql: |
+    SELECT actor.user.email_addr AS user, COUNT(*) AS count
+    FROM {{.Table}}
+    WHERE eventday BETWEEN '{{.StartDay}}' AND '{{.EndDay}}'
+    GROUP BY actor.user.email_addr
+alert:
+  who: ${user}
+`))
+	require.NoError(t, err)
+	assert.True(t, rule.IsSQL())
+	assert.Equal(t, []string{"user"}, rule.GroupBy)
+
+	// Native parts of queries are rejected with SQL
+	_, err = detect.ParseRule([]byte(`
+id: sql-rule
+title: SQL rule
+severity: low
+technique: {id: T1078}
+schedule: rate(1 hour)
+query:
+  window: 5m
+  sql: SELECT 1
+`))
+	assert.ErrorContains(t, err, "SQL rule cannot have a native query")
+	assert.ErrorContains(t, err, "SQL rule requires lookback")
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "probing.yaml"), []byte(probingRuleYAML), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a rule"), 0o644))
+
+	rules, err := detect.LoadRules(dir)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "probing", rules[0].ID)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "probing2.yml"), []byte(probingRuleYAML), 0o644))
+	_, err = detect.LoadRules(dir)
+	assert.ErrorContains(t, err, `rule "probing" is already defined in `+filepath.Join(dir, "probing.yaml"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("id: [broken"), 0o644))
+	_, err = detect.LoadRules(filepath.Join(dir, "broken.yaml"))
+	assert.ErrorContains(t, err, "broken.yaml: failed to parse rule")
+
+	_, err = detect.LoadRules(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+// The rule files of the repository are the embedded rules
+func TestLoadRules_Embedded(t *testing.T) {
+	rules, err := detect.LoadRules("rules")
+	require.NoError(t, err)
+	embedded := detect.DefaultRules()
+	require.Len(t, rules, len(embedded))
+	for i, rule := range rules {
+		assert.Equal(t, embedded[i].ID, rule.ID)
+		want, err := embedded[i].Query()
+		require.NoError(t, err)
+		got, err := rule.Query()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+}
@@ -0,0 +1,217 @@
+package detect
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultTable is the Security Lake table of the custom source in Athena
+const DefaultTable = "amazon_security_lake_glue_db_ap_northeast_1.amazon_security_lake_table_ap_northeast_1_ext_google_workspace_1_0"
+
+// sqlListSeparator separates values of collected columns, joined by array_join
+const sqlListSeparator = "\x1f"
+
+// QueryWindow is the data of SQL templates: the table and the period of a run. Templates
+// refer to {{.Table}}, {{.StartDay}}, {{.EndDay}} (eventDay partitions, YYYYMMDD),
+// {{.StartMillis}} and {{.EndMillis}} (time column, end exclusive).
+type QueryWindow struct {
+	Table      string
+	Start, End time.Time
+}
+
+func (x QueryWindow) StartDay() string   { return x.Start.UTC().Format("20060102") }
+func (x QueryWindow) EndDay() string     { return x.End.UTC().Format("20060102") }
+func (x QueryWindow) StartMillis() int64 { return x.Start.UnixMilli() }
+func (x QueryWindow) EndMillis() int64   { return x.End.UnixMilli() }
+
+// Query returns the SQL template of the rule: SQL of a SQL rule, or the aggregation of a
+// native rule over the period of a run. Native rules are evaluated over the whole period
+// instead of sliding windows, so a scheduled run with lookback of the window finds the same
+// groups as in memory, except bursts split between runs.
+func (x *Rule) Query() (string, error) {
+	if x.IsSQL() {
+		return x.SQL, nil
+	}
+	if x.Filter != nil {
+		return "", fmt.Errorf("rule %q has a Go filter, which cannot be translated to SQL", x.ID)
+	}
+
+	failures := "SUM(CASE WHEN status_id = 2 THEN 1 ELSE 0 END)"
+	var columns, groupBy, where, having []string
+	for _, name := range x.GroupBy {
+		column := sqlColumn(name)
+		columns = append(columns, fmt.Sprintf("%s AS %q", column, name))
+		groupBy = append(groupBy, column)
+		where = append(where, column+" IS NOT NULL")
+		if !numericFields[name] {
+			where = append(where, column+" <> ''")
+		}
+	}
+	columns = append(columns,
+		`COUNT(*) AS "count"`,
+		failures+` AS "failures"`,
+		`MIN(time) AS "first_seen"`,
+		`MAX(time) AS "last_seen"`,
+	)
+	for _, name := range x.distinctFields() {
+		columns = append(columns, fmt.Sprintf("array_join(array_agg(DISTINCT %s), chr(31)) AS %q", sqlColumn(name), name))
+	}
+	for _, cond := range x.Threshold {
+		min := strconv.FormatFloat(cond.Min, 'f', -1, 64)
+		switch cond.Metric {
+		case MetricCount:
+			having = append(having, "COUNT(*) >= "+min)
+		case MetricFailures:
+			having = append(having, failures+" >= "+min)
+		case MetricFailureRatio:
+			having = append(having, "CAST("+failures+" AS DOUBLE) / COUNT(*) >= "+min)
+		case MetricDistinct:
+			having = append(having, fmt.Sprintf("COUNT(DISTINCT %s) >= %s", sqlColumn(cond.Field), min))
+		}
+	}
+	if x.Where != nil {
+		where = append(where, x.Where.SQL())
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT\n    %s\n", strings.Join(columns, ",\n    "))
+	b.WriteString("FROM {{.Table}}\n")
+	b.WriteString("WHERE eventday BETWEEN '{{.StartDay}}' AND '{{.EndDay}}'\n")
+	b.WriteString("    AND time >= {{.StartMillis}} AND time < {{.EndMillis}}\n")
+	for _, predicate := range where {
+		fmt.Fprintf(&b, "    AND %s\n", predicate)
+	}
+	if len(groupBy) > 0 {
+		fmt.Fprintf(&b, "GROUP BY %s\n", strings.Join(groupBy, ", "))
+	}
+	fmt.Fprintf(&b, "HAVING %s", strings.Join(having, " AND "))
+	return b.String(), nil
+}
+
+// RenderQuery returns the query of a run over the window
+func (x *Rule) RenderQuery(window QueryWindow) (string, error) {
+	query, err := x.Query()
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(x.ID).Option("missingkey=error").Parse(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid SQL template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, window); err != nil {
+		return "", fmt.Errorf("invalid SQL template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// alertFromRow builds an alert from a result row. Columns of group-by fields form the group,
+// count, failures, first_seen and last_seen are read if present, and the other columns are
+// kept in Values.
+func (x *Rule) alertFromRow(row map[string]string, end time.Time) (Alert, error) {
+	alert := Alert{Group: map[string]string{}}
+	for _, name := range x.GroupBy {
+		alert.Group[name] = row[name]
+	}
+	for _, name := range x.distinctFields() {
+		if alert.Distinct == nil {
+			alert.Distinct = map[string][]string{}
+		}
+		if values := row[name]; values != "" {
+			alert.Distinct[name] = strings.Split(values, sqlListSeparator)
+		} else {
+			alert.Distinct[name] = []string{}
+		}
+	}
+
+	distinct := x.distinctFields()
+	var err error
+	for column, value := range row {
+		switch {
+		case column == MetricCount:
+			alert.Count, err = strconv.Atoi(value)
+		case column == MetricFailures:
+			alert.Failures, err = strconv.Atoi(value)
+		case column == "first_seen":
+			alert.FirstSeen, err = parseResultTime(value)
+		case column == "last_seen":
+			alert.LastSeen, err = parseResultTime(value)
+		case slices.Contains(x.GroupBy, column) || slices.Contains(distinct, column):
+		default:
+			if alert.Values == nil {
+				alert.Values = map[string]string{}
+			}
+			alert.Values[column] = value
+		}
+		if err != nil {
+			return Alert{}, fmt.Errorf("invalid column %s: %w", column, err)
+		}
+	}
+
+	alert.DetectedAt = alert.LastSeen
+	if alert.DetectedAt.IsZero() {
+		alert.DetectedAt = end
+	}
+	alert.describe(x)
+	return alert, nil
+}
+
+// parseResultTime parses a time column in unix milliseconds or an Athena timestamp
+func parseResultTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999", time.RFC3339Nano, "2006-01-02 15:04:05.999999999 MST"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", value)
+}
+
+// EventsQuery returns the SQL selecting records of the alert: records of its period with the
+// values of its group-by fields. Group columns of SQL rules which are not fields are ignored.
+func (x *Alert) EventsQuery(table string) string {
+	if table == "" {
+		table = DefaultTable
+	}
+	start, end := x.FirstSeen, x.LastSeen
+	if end.IsZero() {
+		end = x.DetectedAt
+	}
+	if start.IsZero() || start.After(end) {
+		start = end
+	}
+	window := QueryWindow{Table: table, Start: start, End: end.Add(time.Millisecond)}
+
+	names := make([]string, 0, len(x.Group))
+	for name := range x.Group {
+		if _, ok := fields[name]; ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT *\nFROM %s\n", window.Table)
+	fmt.Fprintf(&b, "WHERE eventday BETWEEN '%s' AND '%s'\n", window.StartDay(), window.EndDay())
+	fmt.Fprintf(&b, "    AND time >= %d AND time < %d\n", window.StartMillis(), window.EndMillis())
+	for _, name := range names {
+		value := literal{text: x.Group[name]}
+		if numericFields[name] {
+			if n, err := strconv.ParseFloat(value.text, 64); err == nil {
+				value = literal{number: n, numeric: true}
+			}
+		}
+		fmt.Fprintf(&b, "    AND %s = %s\n", sqlColumn(name), value.sql())
+	}
+	b.WriteString("ORDER BY time")
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package detect_test
+
+import (
+	"testing"
+	"time"
+
+	"seccamp2025-b1-detector/detect"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRule_Query(t *testing.T) {
+	rule, err := detect.ParseRule([]byte(probingRuleYAML))
+	require.NoError(t, err)
+
+	query, err := rule.Query()
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT
+    actor.user.email_addr AS "actor.user.email_addr",
+    COUNT(*) AS "count",
+    SUM(CASE WHEN status_id = 2 THEN 1 ELSE 0 END) AS "failures",
+    MIN(time) AS "first_seen",
+    MAX(time) AS "last_seen",
+    array_join(array_agg(DISTINCT api.service.name), chr(31)) AS "api.service.name",
+    array_join(array_agg(DISTINCT src_endpoint.ip), chr(31)) AS "src_endpoint.ip"
+FROM {{.Table}}
+WHERE eventday BETWEEN '{{.StartDay}}' AND '{{.EndDay}}'
+    AND time >= {{.StartMillis}} AND time < {{.EndMillis}}
+    AND actor.user.email_addr IS NOT NULL
+    AND actor.user.email_addr <> ''
+    AND api.operation <> 'login_success'
+GROUP BY actor.user.email_addr
+HAVING COUNT(DISTINCT api.service.name) >= 3 AND CAST(SUM(CASE WHEN status_id = 2 THEN 1 ELSE 0 END) AS DOUBLE) / COUNT(*) >= 0.7`, query)
+
+	// Go filters are not translated
+	goRule := failureRule()
+	_, err = goRule.Query()
+	assert.ErrorContains(t, err, "has a Go filter")
+}
+
+func TestQueryWindow(t *testing.T) {
+	window := detect.QueryWindow{
+		Start: time.Date(2024, 8, 12, 23, 55, 0, 0, time.UTC),
+		End:   time.Date(2024, 8, 13, 9, 5, 0, 0, time.FixedZone("JST", 9*60*60)),
+	}
+	assert.Equal(t, "20240812", window.StartDay())
+	// Partitions are in UTC
+	assert.Equal(t, "20240813", window.EndDay())
+	assert.Equal(t, int64(1723506900000), window.StartMillis())
+	assert.Equal(t, int64(1723507500000), window.EndMillis())
+}
+
+func TestAlert_EventsQuery(t *testing.T) {
+	alert := detect.Alert{
+		RuleID: "auth-brute-force",
+		Group: map[string]string{
+			"src_endpoint.ip": "133.200.32.94",
+			"status_id":       "2",
+			"user":            "o'brien", // result column of a SQL rule
+		},
+		FirstSeen:  time.Date(2024, 8, 12, 10, 0, 0, 0, time.UTC),
+		LastSeen:   time.Date(2024, 8, 12, 10, 4, 0, 0, time.UTC),
+		DetectedAt: time.Date(2024, 8, 12, 10, 2, 0, 0, time.UTC),
+	}
+	assert.Equal(t, `SELECT *
+FROM logs
+WHERE eventday BETWEEN '20240812' AND '20240812'
+    AND time >= 1723456800000 AND time < 1723457040001
+    AND src_endpoint.ip = '133.200.32.94'
+    AND status_id = 2
+ORDER BY time`, alert.EventsQuery("logs"))
+
+	// Without first and last seen, the query selects records at the detection
+	alert = detect.Alert{DetectedAt: time.Date(2024, 8, 12, 10, 2, 0, 0, time.UTC)}
+	assert.Contains(t, alert.EventsQuery(""), "FROM "+detect.DefaultTable+"\n")
+	assert.Contains(t, alert.EventsQuery(""), "AND time >= 1723456920000 AND time < 1723456920001\n")
+}
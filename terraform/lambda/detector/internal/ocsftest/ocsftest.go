@@ -0,0 +1,65 @@
+// Package ocsftest builds OCSF records for tests of the detection packages.
+package ocsftest
+
+import (
+	"time"
+
+	"seccamp2025-b1-converter/core"
+)
+
+// Base is the time the records of tests start at
+var Base = time.Date(2024, 8, 12, 10, 0, 0, 0, time.UTC)
+
+// At returns the time the given seconds after Base
+func At(seconds int) time.Time {
+	return Base.Add(time.Duration(seconds) * time.Second)
+}
+
+// Option changes a record of Record
+type Option func(*core.OCSFWebResourceActivity)
+
+// Record returns a successful Google Drive view of the user from the IP address
+func Record(t time.Time, user, ip string, opts ...Option) core.OCSFWebResourceActivity {
+	x := core.OCSFWebResourceActivity{
+		ClassUID:   6001,
+		ActivityID: 2,
+		StatusID:   1,
+		Time:       t.UnixMilli(),
+		Actor:      core.OCSFActor{User: core.OCSFUser{EmailAddr: user}},
+		API: core.OCSFAPI{
+			Service:   core.OCSFService{Name: "Google Drive API"},
+			Operation: "view",
+		},
+		SrcEndpoint: core.OCSFEndpoint{IP: ip},
+	}
+	for _, opt := range opts {
+		opt(&x)
+	}
+	return x
+}
+
+// Failed marks the record as a failure
+func Failed(x *core.OCSFWebResourceActivity) { x.StatusID = 2 }
+
+// Service sets the service and the operation of the record
+func Service(name, operation string) Option {
+	return func(x *core.OCSFWebResourceActivity) {
+		x.API.Service.Name = name
+		x.API.Operation = operation
+	}
+}
+
+// Activity sets the activity ID of the record
+func Activity(id int) Option {
+	return func(x *core.OCSFWebResourceActivity) { x.ActivityID = id }
+}
+
+// Location sets the location of the source endpoint of the record
+func Location(loc core.OCSFLocation) Option {
+	return func(x *core.OCSFWebResourceActivity) { x.SrcEndpoint.Location = loc }
+}
+
+// UID sets the unique ID of the record
+func UID(uid string) Option {
+	return func(x *core.OCSFWebResourceActivity) { x.Metadata.UID = uid }
+}
@@ -0,0 +1,324 @@
+// Package localsql runs SQL of detection rules offline over Parquet files of the converter, on
+// an embedded DuckDB. Tables are views over Parquet files in the Security Lake layout, with
+// partition columns (region, accountid, eventday) taken from the paths as strings, and are
+// registered under the names of Athena tables, so rule queries run without rewriting.
+//
+// DuckDB requires cgo. The package is used by tests and ocsf-detect, and not by the Lambda
+// functions, which are built without cgo. Sessions are set up for the Athena (Trino) dialect of
+// rules: division of integers truncates, from_unixtime returns a timestamp in UTC, array_join
+// skips NULL elements and cardinality counts elements of arrays. Results are formatted as
+// Athena returns them.
+package localsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+const timestampLayout = "2006-01-02 15:04:05.000"
+
+// dialect sets up a session for the Athena SQL of rules
+var dialect = []string{
+	// Extensions are not downloaded, so queries run offline
+	"SET autoinstall_known_extensions = false",
+	"SET autoload_known_extensions = false",
+	"SET integer_division = true",
+	"CREATE MACRO from_unixtime(seconds) AS make_timestamp(CAST(CAST(seconds AS DOUBLE) * 1000000 AS BIGINT))",
+	"CREATE MACRO cardinality(items) AS len(items)",
+	`CREATE MACRO array_join(items, sep) AS
+		CASE WHEN len(list_filter(items, item -> item IS NOT NULL)) = 0 THEN ''
+		ELSE list_reduce(list_transform(list_filter(items, item -> item IS NOT NULL), item -> CAST(item AS VARCHAR)), (a, b) -> a || sep || b)
+		END`,
+}
+
+// DB is a DuckDB database in memory with views of Parquet files
+type DB struct {
+	mu     sync.Mutex
+	db     *sql.DB
+	dir    string              // Parquet files written by LoadLogs
+	files  map[string][]string // Parquet files of views
+	loaded int                 // directories written by LoadLogs
+}
+
+// New returns an empty DB. It must be closed to remove Parquet files written by LoadLogs.
+func New() (*DB, error) {
+	connector, err := duckdb.NewConnector("", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DuckDB: %w", err)
+	}
+	db := sql.OpenDB(connector)
+	// Settings and the search path are of a connection
+	db.SetMaxOpenConns(1)
+	for _, stmt := range dialect {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to set up DuckDB: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp("", "localsql")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{db: db, dir: dir, files: map[string][]string{}}, nil
+}
+
+// Close closes the database and removes Parquet files written by LoadLogs
+func (x *DB) Close() error {
+	err := x.db.Close()
+	if rmErr := os.RemoveAll(x.dir); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// LoadLogs writes converted records to Parquet per partition, as the converter uploads them,
+// and adds the files to the table
+func (x *DB) LoadLogs(ctx context.Context, name string, logs []core.OCSFWebResourceActivity) error {
+	x.mu.Lock()
+	x.loaded++
+	dir := filepath.Join(x.dir, strconv.Itoa(x.loaded))
+	x.mu.Unlock()
+
+	layout := core.SourceLayout{Name: "local", Version: core.DefaultSourceVersion}
+	for _, partition := range core.PartitionLogs(logs, false) {
+		data, err := core.GenerateParquet(partition.Logs)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, filepath.FromSlash(core.BuildSecurityLakeKey(layout, partition.Key, "logs", core.ContentHash(data))))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	_, err := x.LoadDir(ctx, name, dir)
+	return err
+}
+
+// LoadDir adds Parquet files under the directory to the table, such as converter output in
+// the layout of ext/{source}/{version}/region=.../accountId=.../eventDay=.../. Path segments of
+// key=value are partition columns, with lower case names as in Athena. It returns the number
+// of records of the files.
+func (x *DB) LoadDir(ctx context.Context, name, dir string) (int, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".parquet") {
+			files = append(files, filepath.ToSlash(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	if len(files) == 0 {
+		return 0, nil
+	}
+	sort.Strings(files)
+
+	var n int
+	if err := x.db.QueryRowContext(ctx, "SELECT count(*) FROM "+readParquet(files)).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to read Parquet files of %s: %w", dir, err)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	name = strings.ToLower(name)
+	x.files[name] = append(x.files[name], files...)
+	if err := x.createView(ctx, name); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// createView creates or replaces the view of the table over its files. The schema of a
+// qualified name is created and added to the search path, so unqualified names are resolved
+// as Athena does in the database of the query.
+func (x *DB) createView(ctx context.Context, name string) error {
+	files := x.files[name]
+	var columns []string
+	for key := range partitionsOf(files[0]) {
+		columns = append(columns, key)
+	}
+	sort.Strings(columns)
+	selected := "*"
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		renamed := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = quote(column)
+			renamed[i] = fmt.Sprintf("%s AS %s", quote(column), quote(strings.ToLower(column)))
+		}
+		selected = fmt.Sprintf("* EXCLUDE (%s), %s", strings.Join(quoted, ", "), strings.Join(renamed, ", "))
+	}
+
+	view := quote(name)
+	if schema, table, ok := strings.Cut(name, "."); ok {
+		if _, err := x.db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+quote(schema)); err != nil {
+			return err
+		}
+		view = quote(schema) + "." + quote(table)
+	}
+	stmt := fmt.Sprintf("CREATE OR REPLACE VIEW %s AS SELECT %s FROM %s", view, selected, readParquet(files))
+	if _, err := x.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", name, err)
+	}
+
+	schemas := []string{"main"}
+	for table := range x.files {
+		if schema, _, ok := strings.Cut(table, "."); ok {
+			schemas = append(schemas, schema)
+		}
+	}
+	_, err := x.db.ExecContext(ctx, "SET search_path = "+literal(strings.Join(schemas, ",")))
+	return err
+}
+
+// readParquet returns the table function reading the files with partition columns as strings
+func readParquet(files []string) string {
+	literals := make([]string, len(files))
+	for i, file := range files {
+		literals[i] = literal(file)
+	}
+	return fmt.Sprintf("read_parquet([%s], hive_partitioning = true, hive_types_autocast = false, union_by_name = true)", strings.Join(literals, ", "))
+}
+
+// partitionsOf returns partition columns of key=value directories of the path
+func partitionsOf(path string) map[string]string {
+	partitions := map[string]string{}
+	for _, segment := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if k, v, ok := strings.Cut(segment, "="); ok && k != "" {
+			partitions[k] = v
+		}
+	}
+	return partitions
+}
+
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func literal(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// Result is the result of a query
+type Result struct {
+	Columns []string
+	// Rows are values keyed by column names, formatted as Athena returns them. NULL values
+	// are missing.
+	Rows []map[string]string
+}
+
+// Query runs the query and returns rows, as detect.QueryRunner
+func (x *DB) Query(ctx context.Context, query string) ([]map[string]string, error) {
+	result, err := x.Run(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return result.Rows, nil
+}
+
+// Run runs a query
+func (x *DB) Run(ctx context.Context, query string) (*Result, error) {
+	rows, err := x.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Columns: columns, Rows: []map[string]string{}}
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := map[string]string{}
+		for i, column := range columns {
+			if values[i] != nil {
+				row[column] = format(values[i])
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
+
+// format returns the value as Athena returns it in results
+func format(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	case *big.Int:
+		return v.String()
+	case float32:
+		return format(float64(v))
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".NI") {
+			s += ".0"
+		}
+		return s
+	case duckdb.Decimal:
+		return new(big.Float).Quo(new(big.Float).SetInt(v.Value), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(v.Scale)), nil))).Text('f', int(v.Scale))
+	case time.Time:
+		return v.UTC().Format(timestampLayout)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			if item == nil {
+				items[i] = "null"
+			} else {
+				items[i] = format(item)
+			}
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		items := make([]string, len(names))
+		for i, name := range names {
+			if v[name] == nil {
+				items[i] = name + "=null"
+			} else {
+				items[i] = name + "=" + format(v[name])
+			}
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,200 @@
+package localsql_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/internal/ocsftest"
+	"seccamp2025-b1-detector/localsql"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const securityLakeTable = "amazon_security_lake_glue_db_ap_northeast_1.amazon_security_lake_table_ap_northeast_1_ext_google_workspace_1_0"
+
+func convertedLogs(t *testing.T) []core.OCSFWebResourceActivity {
+	lines := []string{
+		`{"kind":"admin#reports#activity","id":{"time":"2024-08-12T23:59:00Z","uniqueQualifier":"1","applicationName":"drive","customerId":"C03az79cb"},"actor":{"email":"a@muhai-academy.com"},"ipAddress":"192.0.2.1","events":[{"type":"access","name":"download","parameters":[{"name":"doc_title","value":"a.xlsx"}]}]}`,
+		`{"kind":"admin#reports#activity","id":{"time":"2024-08-13T00:01:00Z","uniqueQualifier":"2","applicationName":"login","customerId":"C03az79cb"},"actor":{"email":"b@muhai-academy.com"},"ipAddress":"192.0.2.2","events":[{"type":"login","name":"login_failure"}]}`,
+	}
+	converter := &core.Converter{Region: "ap-northeast-1", AccountID: "123456789012"}
+	result, err := converter.Convert(context.Background(), "logs/test.jsonl", []byte(strings.Join(lines, "\n")))
+	require.NoError(t, err)
+	require.Len(t, result.Logs, 2)
+	return result.Logs
+}
+
+func newDB(t *testing.T) *localsql.DB {
+	db, err := localsql.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+	return db
+}
+
+func TestDB_LoadDir(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	layout := core.SourceLayout{Name: "google-workspace", Version: core.DefaultSourceVersion}
+	for _, partition := range core.PartitionLogs(convertedLogs(t), false) {
+		data, err := core.GenerateParquet(partition.Logs)
+		require.NoError(t, err)
+		path := filepath.Join(dir, core.BuildSecurityLakeKey(layout, partition.Key, "logs/test.jsonl", "0123456789abcdef"))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+	}
+
+	db := newDB(t)
+	n, err := db.LoadDir(ctx, securityLakeTable, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	rows, err := db.Query(ctx, `SELECT eventday, region, accountid, actor.user.email_addr AS email, web_resources[1].name AS file
+		FROM amazon_security_lake_glue_db_ap_northeast_1.amazon_security_lake_table_ap_northeast_1_ext_google_workspace_1_0
+		WHERE eventday = '20240812'`)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{
+		"eventday":  "20240812",
+		"region":    "ap-northeast-1",
+		"accountid": "123456789012",
+		"email":     "a@muhai-academy.com",
+		"file":      "a.xlsx",
+	}}, rows)
+}
+
+func TestDB_LoadLogs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	require.NoError(t, db.LoadLogs(ctx, securityLakeTable, convertedLogs(t)))
+
+	// Unqualified names are resolved as in the database of the query
+	rows, err := db.Query(ctx, `SELECT eventday, api.operation AS operation, status_id FROM amazon_security_lake_table_ap_northeast_1_ext_google_workspace_1_0 ORDER BY time`)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"eventday": "20240812", "operation": "download", "status_id": "1"},
+		{"eventday": "20240813", "operation": "login_failure", "status_id": "2"},
+	}, rows)
+}
+
+// testDB returns a table of records in the shape of converter output, from 10:00 UTC
+func testDB(t *testing.T) *localsql.DB {
+	files := func(names ...string) ocsftest.Option {
+		return func(x *core.OCSFWebResourceActivity) {
+			for _, name := range names {
+				x.WebResources = append(x.WebResources, core.OCSFWebResource{Name: name})
+			}
+		}
+	}
+	db := newDB(t)
+	require.NoError(t, db.LoadLogs(context.Background(), "db.activity", []core.OCSFWebResourceActivity{
+		ocsftest.Record(ocsftest.At(0), "a@example.com", "192.0.2.1", files("a.xlsx")),
+		ocsftest.Record(ocsftest.At(60), "a@example.com", "192.0.2.1", ocsftest.Failed, files("b.xlsx")),
+		ocsftest.Record(ocsftest.At(120), "a@example.com", "192.0.2.2", ocsftest.Failed, ocsftest.Service("Gmail API", "view")),
+		ocsftest.Record(ocsftest.At(180), "b@example.com", "192.0.2.3", files("a.xlsx", "c.xlsx")),
+	}))
+	return db
+}
+
+// TestDB_Query checks the Athena dialect of the SQL of rules
+func TestDB_Query(t *testing.T) {
+	testCases := map[string]struct {
+		query string
+		want  []map[string]string
+	}{
+		"filter and struct fields": {
+			query: "SELECT actor.user.email_addr AS email, src_endpoint.ip AS ip FROM db.activity WHERE status_id = 2 ORDER BY time",
+			want: []map[string]string{
+				{"email": "a@example.com", "ip": "192.0.2.1"},
+				{"email": "a@example.com", "ip": "192.0.2.2"},
+			},
+		},
+		"group by with having": {
+			query: `SELECT actor.user.email_addr AS "actor.user.email_addr", COUNT(*) AS "count",
+				SUM(CASE WHEN status_id = 2 THEN 1 ELSE 0 END) AS failures, MIN(time) AS first_seen
+				FROM db.activity GROUP BY actor.user.email_addr HAVING COUNT(*) >= 2`,
+			want: []map[string]string{
+				{"actor.user.email_addr": "a@example.com", "count": "3", "failures": "2", "first_seen": "1723456800000"},
+			},
+		},
+		"distinct aggregations": {
+			query: `SELECT COUNT(DISTINCT api.service.name) AS services,
+				array_join(array_agg(DISTINCT web_resources[1].name ORDER BY web_resources[1].name), chr(44)) AS files,
+				CAST(SUM(CASE WHEN status_id = 2 THEN 1 ELSE 0 END) AS DOUBLE) / COUNT(*) AS ratio
+				FROM db.activity a WHERE a.actor.user.email_addr = 'a@example.com' AND cardinality(web_resources) > 0`,
+			want: []map[string]string{{"services": "1", "files": "a.xlsx,b.xlsx", "ratio": "0.5"}},
+		},
+		"array_join skips nulls": {
+			query: "SELECT array_join(array_agg(web_resources[1].name), chr(44)) AS files, array_join(array_agg(web_resources[2].name), chr(44)) AS seconds FROM db.activity WHERE time >= 1723456920000",
+			want:  []map[string]string{{"files": "a.xlsx", "seconds": "c.xlsx"}},
+		},
+		"aggregations without rows": {
+			query: "SELECT COUNT(*) AS n, MAX(time) AS latest FROM db.activity WHERE status_id = 3",
+			want:  []map[string]string{{"n": "0"}},
+		},
+		"integer division and time functions": {
+			query: "SELECT time / 1000 AS seconds, hour(from_unixtime(time / 1000)) AS h, from_unixtime(time / 1000) AS ts FROM db.activity ORDER BY 1 LIMIT 1",
+			want:  []map[string]string{{"seconds": "1723456800", "h": "10", "ts": "2024-08-12 10:00:00.000"}},
+		},
+		"partition predicate": {
+			query: "SELECT COUNT(*) AS n FROM db.activity WHERE eventday BETWEEN '20240812' AND '20240812' AND time >= 1723456860000 AND time < 1723456980000",
+			want:  []map[string]string{{"n": "2"}},
+		},
+		"in like and strpos": {
+			query: "SELECT DISTINCT src_endpoint.ip AS ip FROM db.activity WHERE api.service.name IN ('Google Drive API', 'Google Calendar API') AND actor.user.email_addr LIKE 'a@%' AND strpos(src_endpoint.ip, '192.') = 1",
+			want:  []map[string]string{{"ip": "192.0.2.1"}},
+		},
+		"order by alias descending": {
+			query: "SELECT src_endpoint.ip AS ip, COUNT(*) AS n FROM db.activity GROUP BY 1 ORDER BY n DESC, ip",
+			want: []map[string]string{
+				{"ip": "192.0.2.1", "n": "2"},
+				{"ip": "192.0.2.2", "n": "1"},
+				{"ip": "192.0.2.3", "n": "1"},
+			},
+		},
+	}
+
+	db := testDB(t)
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rows, err := db.Query(context.Background(), tc.query)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, rows)
+		})
+	}
+}
+
+func TestDB_QueryErrors(t *testing.T) {
+	testCases := map[string]struct {
+		query string
+		want  string
+	}{
+		"unknown table":      {"SELECT * FROM db.other", "other does not exist"},
+		"unknown column":     {"SELECT user_name FROM db.activity", "user_name"},
+		"type mismatch":      {"SELECT time FROM db.activity WHERE eventday + 1 > 0", "No function matches"},
+		"column not grouped": {"SELECT src_endpoint.ip, COUNT(*) FROM db.activity GROUP BY status_id", "GROUP BY"},
+		"syntax":             {"SELECT FROM db.activity WHERE", "syntax error"},
+	}
+
+	db := testDB(t)
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := db.Query(context.Background(), tc.query)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+}
+
+func TestDB_Run(t *testing.T) {
+	result, err := testDB(t).Run(context.Background(), "SELECT status_id, COUNT(*) AS n FROM db.activity GROUP BY status_id ORDER BY status_id")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"status_id", "n"}, result.Columns)
+	assert.Equal(t, []map[string]string{
+		{"status_id": "1", "n": "2"},
+		{"status_id": "2", "n": "2"},
+	}, result.Rows)
+}
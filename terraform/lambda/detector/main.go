@@ -0,0 +1,202 @@
+// Command detector is the scheduled detection Lambda function. Each invocation of the
+// EventBridge schedule runs the SQL of the built-in rules which are due on Athena, builds
+// alerts of the schema, deduplicates them with states of open alerts in S3, and publishes
+// alerts to notify to the alerts SNS topic.
+//
+// A rule is due when a multiple of its interval (UTC) has passed since the previous
+// invocation, and runs as scheduled at that time. The schedule of the function must not be
+// longer than the shortest interval of rules. Times are DETECTION_DELAY before the invocation,
+// as records arrive in Security Lake minutes later through the importer and the converter.
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	awsathena "github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/athena"
+	"seccamp2025-b1-detector/detect"
+)
+
+const (
+	defaultSchedule       = 5 * time.Minute
+	defaultDetectionDelay = 10 * time.Minute
+	// alertStatePrefix is the prefix of states of open alerts in the state bucket
+	alertStatePrefix = "alerts/"
+)
+
+// AlertPublisher publishes alerts, such as alert.Publisher
+type AlertPublisher interface {
+	Publish(ctx context.Context, alert *alert.Alert) (string, error)
+}
+
+// Handler runs due rules at each invocation
+type Handler struct {
+	backend      detect.Backend
+	rules        []detect.Rule
+	intervals    []time.Duration // intervals of rules
+	schedule     time.Duration   // interval of invocations
+	delay        time.Duration
+	deduplicator *alert.Deduplicator
+	publisher    AlertPublisher
+	alertConfig  alert.Config
+}
+
+// NewHandler returns a handler of the rules. The rules must run on SQL with a rate schedule
+// of a multiple of the schedule of invocations.
+func NewHandler(backend detect.Backend, rules []detect.Rule, schedule, delay time.Duration, deduplicator *alert.Deduplicator, publisher AlertPublisher, alertConfig alert.Config) (*Handler, error) {
+	if schedule <= 0 {
+		return nil, fmt.Errorf("invalid schedule %s", schedule)
+	}
+	intervals := make([]time.Duration, len(rules))
+	for i := range rules {
+		interval, err := rules[i].Interval()
+		if err != nil {
+			return nil, err
+		}
+		if interval < schedule || interval%schedule != 0 {
+			return nil, fmt.Errorf("rule %q: interval %s is not a multiple of the schedule %s", rules[i].ID, interval, schedule)
+		}
+		intervals[i] = interval
+	}
+	return &Handler{
+		backend:      backend,
+		rules:        rules,
+		intervals:    intervals,
+		schedule:     schedule,
+		delay:        delay,
+		deduplicator: deduplicator,
+		publisher:    publisher,
+		alertConfig:  alertConfig,
+	}, nil
+}
+
+// HandleEvent runs rules due at the time of the scheduled event. Failed rules do not stop
+// other rules, and their errors are returned together, so the invocation is retried. Alerts
+// are recorded as open before they are published (the SNS client retries throttling), so
+// retries do not notify them again, and an alert whose publishing failed is notified when it
+// escalates or reopens.
+func (h *Handler) HandleEvent(ctx context.Context, event events.EventBridgeEvent) error {
+	now := cmp.Or(event.Time, time.Now()).UTC().Add(-h.delay)
+	var errs []error
+	for i := range h.rules {
+		rule := &h.rules[i]
+		due := now.Truncate(h.intervals[i])
+		if !due.After(now.Add(-h.schedule)) {
+			continue
+		}
+		if err := h.run(ctx, rule, due); err != nil {
+			slog.Error("Failed to run rule", "rule", rule.ID, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run runs the rule as scheduled at the time, and publishes alerts to notify
+func (h *Handler) run(ctx context.Context, rule *detect.Rule, now time.Time) error {
+	found, err := detect.RunScheduled(ctx, h.backend, rule, now)
+	if err != nil {
+		return err
+	}
+	var published int
+	for i := range found {
+		a := alert.New(&found[i], rule, h.alertConfig)
+		decision, err := h.deduplicator.Process(ctx, a)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", rule.ID, err)
+		}
+		slog.Info("Deduplicated alert", "rule", rule.ID, "alert_id", a.ID, "fingerprint", a.Fingerprint, "decision", decision, "occurrences", a.Occurrences)
+		if !decision.Notify() {
+			continue
+		}
+		messageID, err := h.publisher.Publish(ctx, a)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", rule.ID, err)
+		}
+		slog.Info("Published alert", "rule", rule.ID, "alert_id", a.ID, "message_id", messageID)
+		published++
+	}
+	slog.Info("Ran rule", "rule", rule.ID, "scheduled_at", now, "alerts", len(found), "published", published)
+	return nil
+}
+
+// durationEnv returns the duration of the environment variable, or the default if unset
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return d, nil
+}
+
+func newHandlerFromEnv(ctx context.Context) (*Handler, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	var maxBytesScanned int64
+	if v := os.Getenv("ATHENA_MAX_BYTES_SCANNED"); v != "" {
+		if maxBytesScanned, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid ATHENA_MAX_BYTES_SCANNED %q", v)
+		}
+	}
+	client, err := athena.New(awsathena.NewFromConfig(cfg), athena.Config{
+		WorkGroup:       os.Getenv("ATHENA_WORKGROUP"),
+		MaxBytesScanned: maxBytesScanned,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ATHENA_WORKGROUP: %w", err)
+	}
+
+	stateBucket := os.Getenv("DETECTOR_STATE_BUCKET")
+	if stateBucket == "" {
+		return nil, errors.New("DETECTOR_STATE_BUCKET environment variable is required")
+	}
+	deduplicator, err := alert.NewDeduplicator(alert.NewS3Store(s3.NewFromConfig(cfg), stateBucket, alertStatePrefix), alert.DedupConfig{})
+	if err != nil {
+		return nil, err
+	}
+	publisher, err := alert.NewPublisher(sns.NewFromConfig(cfg), os.Getenv("ALERTS_TOPIC_ARN"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ALERTS_TOPIC_ARN: %w", err)
+	}
+
+	schedule, err := durationEnv("DETECTOR_SCHEDULE", defaultSchedule)
+	if err != nil {
+		return nil, err
+	}
+	delay, err := durationEnv("DETECTION_DELAY", defaultDetectionDelay)
+	if err != nil {
+		return nil, err
+	}
+	return NewHandler(&detect.SQLBackend{Runner: client}, detect.DefaultRules(), schedule, delay, deduplicator, publisher, alert.Config{Region: cfg.Region})
+}
+
+func main() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	h, err := newHandlerFromEnv(context.Background())
+	if err != nil {
+		slog.Error("Failed to create detector handler", "error", err)
+		os.Exit(1)
+	}
+	lambda.Start(h.HandleEvent)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+	"seccamp2025-b1-detector/localsql"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRunner counts queries of the runner
+type countingRunner struct {
+	detect.QueryRunner
+	queries int
+}
+
+func (x *countingRunner) Query(ctx context.Context, query string) ([]map[string]string, error) {
+	x.queries++
+	return x.QueryRunner.Query(ctx, query)
+}
+
+type fakePublisher struct {
+	alerts []*alert.Alert
+	err    error
+}
+
+func (x *fakePublisher) Publish(ctx context.Context, a *alert.Alert) (string, error) {
+	if x.err != nil {
+		return "", x.err
+	}
+	x.alerts = append(x.alerts, a)
+	return "message-1", nil
+}
+
+// newTestHandler returns a handler of the built-in rules on DuckDB over 12 login failures
+// from one IP address from 10:00 UTC
+func newTestHandler(t *testing.T, publisher AlertPublisher) (*Handler, *countingRunner) {
+	var records []core.OCSFWebResourceActivity
+	for i := range 12 {
+		records = append(records, ocsftest.Record(ocsftest.At(i*10), "a@example.com", "198.51.100.1", ocsftest.Failed, ocsftest.Service("Google Identity", "login_failure")))
+	}
+	db, err := localsql.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.LoadLogs(context.Background(), detect.DefaultTable, records))
+
+	runner := &countingRunner{QueryRunner: db}
+	deduplicator, err := alert.NewDeduplicator(alert.NewMemoryStore(), alert.DedupConfig{})
+	require.NoError(t, err)
+	h, err := NewHandler(&detect.SQLBackend{Runner: runner}, detect.DefaultRules(), 5*time.Minute, 0, deduplicator, publisher, alert.Config{})
+	require.NoError(t, err)
+	return h, runner
+}
+
+func TestHandler_HandleEvent(t *testing.T) {
+	ctx := context.Background()
+	publisher := &fakePublisher{}
+	h, runner := newTestHandler(t, publisher)
+
+	// Rules of 5 minutes run as scheduled at 10:05, and the hourly rule is not due
+	require.NoError(t, h.HandleEvent(ctx, events.EventBridgeEvent{Time: ocsftest.At(330)}))
+	assert.Equal(t, 3, runner.queries)
+	require.Len(t, publisher.alerts, 1)
+	published := publisher.alerts[0]
+	assert.Equal(t, detect.RuleAuthBruteForce, published.Rule.ID)
+	assert.Equal(t, ocsftest.At(110), published.DetectedAt)
+	assert.Equal(t, 1, published.Occurrences)
+
+	// The same attack at 10:10 is a re-occurrence of the open alert and is not published
+	require.NoError(t, h.HandleEvent(ctx, events.EventBridgeEvent{Time: ocsftest.At(620)}))
+	assert.Equal(t, 6, runner.queries)
+	assert.Len(t, publisher.alerts, 1)
+
+	// All rules are due at 11:00
+	require.NoError(t, h.HandleEvent(ctx, events.EventBridgeEvent{Time: ocsftest.At(3610)}))
+	assert.Equal(t, 10, runner.queries)
+}
+
+func TestHandler_HandleEventDelay(t *testing.T) {
+	publisher := &fakePublisher{}
+	h, runner := newTestHandler(t, publisher)
+	h.delay = 10 * time.Minute
+
+	// Invoked at 10:15, rules run as scheduled at 10:05
+	require.NoError(t, h.HandleEvent(context.Background(), events.EventBridgeEvent{Time: ocsftest.At(930)}))
+	assert.Equal(t, 3, runner.queries)
+	require.Len(t, publisher.alerts, 1)
+	assert.Equal(t, ocsftest.At(110), publisher.alerts[0].DetectedAt)
+}
+
+func TestHandler_HandleEventPublishError(t *testing.T) {
+	publisher := &fakePublisher{err: errors.New("throttled")}
+	h, runner := newTestHandler(t, publisher)
+
+	// Other rules run after the failure
+	err := h.HandleEvent(context.Background(), events.EventBridgeEvent{Time: ocsftest.At(330)})
+	assert.ErrorContains(t, err, "throttled")
+	assert.Equal(t, 3, runner.queries)
+}
+
+func TestNewHandler_InvalidSchedule(t *testing.T) {
+	deduplicator, err := alert.NewDeduplicator(alert.NewMemoryStore(), alert.DedupConfig{})
+	require.NoError(t, err)
+	_, err = NewHandler(&detect.SQLBackend{}, detect.DefaultRules(), 10*time.Minute, 0, deduplicator, &fakePublisher{}, alert.Config{})
+	assert.ErrorContains(t, err, "is not a multiple of the schedule 10m0s")
+}
@@ -0,0 +1,188 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"seccamp2025-b1-detector/detect"
+)
+
+// Types of destinations
+const (
+	TypeSlack   = "slack"
+	TypeWebhook = "webhook"
+	TypeEmail   = "email"
+)
+
+// Config declares destinations and routes of alerts
+//
+//	destinations:
+//	  - name: soc-slack
+//	    type: slack
+//	    url_env: SLACK_WEBHOOK_URL
+//	  - name: triage
+//	    type: webhook
+//	    url: https://triage.example.com/alerts
+//	    secret_env: TRIAGE_WEBHOOK_SECRET
+//	  - name: oncall
+//	    type: email
+//	    smtp:
+//	      addr: smtp.example.com:587
+//	      from: alerts@example.com
+//	      to: [oncall@example.com]
+//	      username: alerts
+//	      password_env: SMTP_PASSWORD
+//	routes:
+//	  - name: high
+//	    min_severity: high
+//	    destinations: [soc-slack, oncall]
+//	  - name: all
+//	    destinations: [triage]
+type Config struct {
+	Destinations []Destination `yaml:"destinations"`
+	Routes       []Route       `yaml:"routes"`
+	Retry        Retry         `yaml:"retry"`
+}
+
+// Destination is where alerts are delivered. Secrets are read from environment variables of
+// the *_env fields, so the configuration can be committed.
+type Destination struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// URL of the Slack incoming webhook or the generic webhook, or URLEnv to read it from the
+	// environment
+	URL    string `yaml:"url"`
+	URLEnv string `yaml:"url_env"`
+	// SecretEnv is the environment variable of the key signing webhook requests. Requests are
+	// not signed if empty.
+	SecretEnv string `yaml:"secret_env"`
+	SMTP      SMTP   `yaml:"smtp"`
+	// Template overrides the default text/template of the message: the text of Slack
+	// messages, the body of webhook requests, or the body of emails
+	Template string `yaml:"template"`
+	// Subject overrides the default template of email subjects
+	Subject string `yaml:"subject"`
+}
+
+// SMTP configures email destinations
+type SMTP struct {
+	// Addr is host:port of the SMTP server
+	Addr        string   `yaml:"addr"`
+	From        string   `yaml:"from"`
+	To          []string `yaml:"to"`
+	Username    string   `yaml:"username"`
+	PasswordEnv string   `yaml:"password_env"`
+}
+
+// Route delivers alerts matching it to destinations. Alerts are delivered by all matching
+// routes, once per destination.
+type Route struct {
+	Name string `yaml:"name"`
+	// Rules are IDs of rules of alerts. All rules if empty.
+	Rules []string `yaml:"rules"`
+	// MinSeverity is the lowest severity of alerts, such as "high". All alerts if empty.
+	MinSeverity  string   `yaml:"min_severity"`
+	Destinations []string `yaml:"destinations"`
+}
+
+// Retry configures retries of failed deliveries
+type Retry struct {
+	// Attempts is the maximum number of attempts of a delivery, DefaultAttempts if zero
+	Attempts int `yaml:"attempts"`
+	// Backoff is the interval before the second attempt, doubled for each retry,
+	// DefaultBackoff if zero
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+const (
+	DefaultAttempts = 3
+	DefaultBackoff  = time.Second
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ParseConfig parses and validates a YAML configuration
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid router config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// LoadConfig reads a YAML configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks destinations and their references from routes. Secrets in the environment
+// are checked when transports are built.
+func (x *Config) Validate() error {
+	var errs []error
+	names := map[string]bool{}
+	for _, d := range x.Destinations {
+		if !namePattern.MatchString(d.Name) {
+			errs = append(errs, fmt.Errorf("invalid destination name %q", d.Name))
+		}
+		if names[d.Name] {
+			errs = append(errs, fmt.Errorf("duplicated destination %q", d.Name))
+		}
+		names[d.Name] = true
+
+		switch d.Type {
+		case TypeSlack, TypeWebhook:
+			if (d.URL == "") == (d.URLEnv == "") {
+				errs = append(errs, fmt.Errorf("destination %q: either url or url_env is required", d.Name))
+			}
+		case TypeEmail:
+			if d.SMTP.Addr == "" || d.SMTP.From == "" || len(d.SMTP.To) == 0 {
+				errs = append(errs, fmt.Errorf("destination %q: smtp addr, from and to are required", d.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("destination %q: unknown type %q", d.Name, d.Type))
+		}
+		if _, err := parseTemplates(&d); err != nil {
+			errs = append(errs, fmt.Errorf("destination %q: %w", d.Name, err))
+		}
+	}
+
+	for i, r := range x.Routes {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if r.MinSeverity != "" {
+			if _, ok := detect.ParseSeverity(r.MinSeverity); !ok {
+				errs = append(errs, fmt.Errorf("route %s: unknown severity %q", name, r.MinSeverity))
+			}
+		}
+		if len(r.Destinations) == 0 {
+			errs = append(errs, fmt.Errorf("route %s: destinations are required", name))
+		}
+		for _, d := range r.Destinations {
+			if !names[d] {
+				errs = append(errs, fmt.Errorf("route %s: unknown destination %q", name, d))
+			}
+		}
+	}
+	if x.Retry.Attempts < 0 || x.Retry.Backoff < 0 {
+		errs = append(errs, errors.New("retry attempts and backoff must not be negative"))
+	}
+	return errors.Join(errs...)
+}
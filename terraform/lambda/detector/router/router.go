@@ -0,0 +1,294 @@
+// Package router delivers alerts of the alerts SNS topic to Slack, generic webhooks and email.
+//
+// Routes of the configuration (see Config) select alerts by rule and minimum severity, and
+// deliver them to destinations. Messages are rendered with text/template from the alert.
+// Failed deliveries are retried with exponential backoff unless the destination rejects them
+// (PermanentError), and every delivery is recorded in a delivery log.
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/smtp"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/detect"
+)
+
+// defaultHTTPTimeout bounds requests to Slack and webhooks
+const defaultHTTPTimeout = 10 * time.Second
+
+// Statuses of deliveries
+const (
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Delivery is a record of the delivery log
+type Delivery struct {
+	Time        time.Time `json:"time"`
+	AlertID     string    `json:"alert_id"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	RuleID      string    `json:"rule_id"`
+	Severity    string    `json:"severity"`
+	Route       string    `json:"route"`
+	Destination string    `json:"destination"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// DeliveryLog records deliveries
+type DeliveryLog interface {
+	Record(ctx context.Context, delivery Delivery) error
+}
+
+// JSONLog writes deliveries as JSON lines, such as to stdout of the Lambda function
+type JSONLog struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewJSONLog(w io.Writer) *JSONLog {
+	return &JSONLog{encoder: json.NewEncoder(w)}
+}
+
+func (x *JSONLog) Record(ctx context.Context, delivery Delivery) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.encoder.Encode(delivery)
+}
+
+// MemoryLog keeps deliveries in memory, for tests and local runs
+type MemoryLog struct {
+	mu         sync.Mutex
+	deliveries []Delivery
+}
+
+func (x *MemoryLog) Record(ctx context.Context, delivery Delivery) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.deliveries = append(x.deliveries, delivery)
+	return nil
+}
+
+// Deliveries returns recorded deliveries in order
+func (x *MemoryLog) Deliveries() []Delivery {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return slices.Clone(x.deliveries)
+}
+
+// Options are dependencies of Router, replaced in tests
+type Options struct {
+	// HTTPClient sends Slack and webhook requests
+	HTTPClient *http.Client
+	// Getenv reads secrets of destinations, os.Getenv if nil
+	Getenv func(string) string
+	// SendMail sends emails, as smtp.SendMail bound to the context if nil
+	SendMail func(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	// Log records deliveries, JSON lines to stdout if nil
+	Log DeliveryLog
+	// Now returns the current time
+	Now func() time.Time
+	// Sleep waits between attempts
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Router delivers alerts to destinations of matching routes
+type Router struct {
+	routes     []Route
+	transports map[string]Transport
+	retry      Retry
+	log        DeliveryLog
+	now        func() time.Time
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// New builds transports of the destinations of the configuration
+func New(cfg *Config, opts Options) (*Router, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	if opts.Getenv == nil {
+		opts.Getenv = os.Getenv
+	}
+	if opts.SendMail == nil {
+		opts.SendMail = sendMail
+	}
+	if opts.Log == nil {
+		opts.Log = NewJSONLog(os.Stdout)
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Sleep == nil {
+		opts.Sleep = sleep
+	}
+
+	x := &Router{
+		routes:     cfg.Routes,
+		transports: map[string]Transport{},
+		retry:      cfg.Retry,
+		log:        opts.Log,
+		now:        opts.Now,
+		sleep:      opts.Sleep,
+	}
+	if x.retry.Attempts == 0 {
+		x.retry.Attempts = DefaultAttempts
+	}
+	if x.retry.Backoff == 0 {
+		x.retry.Backoff = DefaultBackoff
+	}
+
+	for _, d := range cfg.Destinations {
+		tmpl, err := parseTemplates(&d)
+		if err != nil {
+			return nil, fmt.Errorf("destination %q: %w", d.Name, err)
+		}
+		url := d.URL
+		if d.URLEnv != "" {
+			if url = opts.Getenv(d.URLEnv); url == "" {
+				return nil, fmt.Errorf("destination %q: %s is not set", d.Name, d.URLEnv)
+			}
+		}
+
+		switch d.Type {
+		case TypeSlack:
+			x.transports[d.Name] = &Slack{URL: url, Client: opts.HTTPClient, templates: tmpl}
+		case TypeWebhook:
+			webhook := &Webhook{URL: url, Client: opts.HTTPClient, templates: tmpl, custom: d.Template != "", now: opts.Now}
+			if d.SecretEnv != "" {
+				secret := opts.Getenv(d.SecretEnv)
+				if secret == "" {
+					return nil, fmt.Errorf("destination %q: %s is not set", d.Name, d.SecretEnv)
+				}
+				webhook.Secret = []byte(secret)
+			}
+			x.transports[d.Name] = webhook
+		case TypeEmail:
+			email := &Email{SMTP: d.SMTP, templates: tmpl, sendMail: opts.SendMail}
+			if d.SMTP.PasswordEnv != "" {
+				email.Password = opts.Getenv(d.SMTP.PasswordEnv)
+			}
+			x.transports[d.Name] = email
+		}
+	}
+	return x, nil
+}
+
+// Matches reports whether the route delivers the alert
+func (x *Route) Matches(a *alert.Alert) bool {
+	if len(x.Rules) > 0 && !slices.Contains(x.Rules, a.Rule.ID) {
+		return false
+	}
+	if x.MinSeverity != "" {
+		min, _ := detect.ParseSeverity(x.MinSeverity)
+		if a.SeverityID < int(min) {
+			return false
+		}
+	}
+	return true
+}
+
+// Route delivers the alert to destinations of all matching routes, once per destination,
+// and returns the deliveries. It returns an error joining failed deliveries, after trying
+// all destinations.
+func (x *Router) Route(ctx context.Context, a *alert.Alert) ([]Delivery, error) {
+	var deliveries []Delivery
+	var errs []error
+	delivered := map[string]bool{}
+	for i, route := range x.routes {
+		if !route.Matches(a) {
+			continue
+		}
+		name := route.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		for _, dest := range route.Destinations {
+			if delivered[dest] {
+				continue
+			}
+			delivered[dest] = true
+
+			delivery := x.deliver(ctx, a, name, dest)
+			deliveries = append(deliveries, delivery)
+			if err := x.log.Record(ctx, delivery); err != nil {
+				slog.Error("Failed to record delivery", "error", err, "alert_id", a.ID, "destination", dest)
+			}
+			if delivery.Status == StatusFailed {
+				errs = append(errs, fmt.Errorf("destination %q: %s", dest, delivery.Error))
+			}
+		}
+	}
+	if len(deliveries) == 0 {
+		slog.Info("No route matched alert", "alert_id", a.ID, "rule", a.Rule.ID, "severity", a.Severity)
+	}
+	return deliveries, errors.Join(errs...)
+}
+
+// deliver sends the alert to the destination with retries
+func (x *Router) deliver(ctx context.Context, a *alert.Alert, route, dest string) Delivery {
+	delivery := Delivery{
+		AlertID:     a.ID,
+		Fingerprint: a.Fingerprint,
+		RuleID:      a.Rule.ID,
+		Severity:    a.Severity,
+		Route:       route,
+		Destination: dest,
+	}
+	transport := x.transports[dest]
+
+	backoff := x.retry.Backoff
+	var err error
+	for delivery.Attempts < x.retry.Attempts {
+		if delivery.Attempts > 0 {
+			if err := x.sleep(ctx, backoff); err != nil {
+				break
+			}
+			backoff *= 2
+		}
+		delivery.Attempts++
+		if err = transport.Send(ctx, a); err == nil {
+			break
+		}
+		slog.Warn("Failed to deliver alert", "error", err, "alert_id", a.ID, "destination", dest, "attempt", delivery.Attempts)
+		var permanent *PermanentError
+		if errors.As(err, &permanent) {
+			break
+		}
+	}
+
+	delivery.Time = x.now().UTC()
+	if err != nil {
+		delivery.Status = StatusFailed
+		delivery.Error = err.Error()
+	} else {
+		delivery.Status = StatusDelivered
+	}
+	return delivery
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
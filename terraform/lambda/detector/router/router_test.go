@@ -0,0 +1,402 @@
+package router_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-detector/alert"
+	"seccamp2025-b1-detector/internal/ocsftest"
+	"seccamp2025-b1-detector/router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAlert(ruleID, severity string, severityID int) *alert.Alert {
+	first, last := ocsftest.Base, ocsftest.Base.Add(4*time.Minute)
+	return &alert.Alert{
+		SchemaVersion: alert.SchemaVersion,
+		ID:            "alert-" + ruleID,
+		Fingerprint:   "fp-" + ruleID,
+		Occurrences:   1,
+		Rule: alert.Rule{
+			ID:        ruleID,
+			Title:     "Repeated login failures from the same IP address",
+			Technique: &alert.Technique{ID: "T1110", Name: "Brute Force", Tactic: "Credential Access"},
+		},
+		Severity:   severity,
+		SeverityID: severityID,
+		Title:      "Repeated login failures from the same IP address",
+		Who:        "tanaka@muhaijuku.com",
+		What:       "12 login failures",
+		Where:      "133.200.32.94",
+		FirstSeen:  &first,
+		LastSeen:   &last,
+		DetectedAt: last,
+		EventCount: 12,
+		IoCs:       []alert.IoC{{Type: alert.IoCIPAddress, Value: "133.200.32.94", Field: "src_endpoint.ip"}},
+		Links:      []alert.Link{{Name: alert.LinkEvents, Query: "SELECT * FROM logs"}},
+	}
+}
+
+// server records requests and replies with statuses in order, then 200
+type server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+func newServer(t *testing.T, statuses ...int) *server {
+	s := &server{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// smtpServer is a minimal SMTP server accepting all messages
+type smtpServer struct {
+	net.Listener
+	mu       sync.Mutex
+	messages []string
+	rcpts    [][]string
+	reject   bool
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpServer{Listener: l}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (x *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+	var rcpts []string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case cmd == "EHLO" || cmd == "HELO":
+			tp.PrintfLine("250 localhost")
+		case cmd == "MAIL":
+			if x.reject {
+				tp.PrintfLine("550 sender rejected")
+				continue
+			}
+			tp.PrintfLine("250 OK")
+		case cmd == "RCPT":
+			rcpts = append(rcpts, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			tp.PrintfLine("250 OK")
+		case cmd == "DATA":
+			tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			x.mu.Lock()
+			x.messages = append(x.messages, string(data))
+			x.rcpts = append(x.rcpts, rcpts)
+			x.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case cmd == "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func noSleep(ctx context.Context, d time.Duration) error { return nil }
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := router.ParseConfig([]byte(`
+destinations:
+  - name: soc-slack
+    type: slack
+    url_env: SLACK_WEBHOOK_URL
+  - name: oncall
+    type: email
+    smtp:
+      addr: smtp.example.com:587
+      from: alerts@example.com
+      to: [oncall@example.com]
+routes:
+  - name: high
+    min_severity: high
+    destinations: [soc-slack, oncall]
+retry:
+  attempts: 5
+  backoff: 2s
+`))
+	require.NoError(t, err)
+	assert.Len(t, cfg.Destinations, 2)
+	assert.Equal(t, router.Retry{Attempts: 5, Backoff: 2 * time.Second}, cfg.Retry)
+
+	_, err = router.ParseConfig([]byte(`
+destinations:
+  - name: Slack
+    type: teams
+  - name: hook
+    type: webhook
+    template: "{{.Title"
+routes:
+  - min_severity: urgent
+    destinations: [pager]
+`))
+	assert.ErrorContains(t, err, `invalid destination name "Slack"`)
+	assert.ErrorContains(t, err, `unknown type "teams"`)
+	assert.ErrorContains(t, err, `destination "hook": either url or url_env is required`)
+	assert.ErrorContains(t, err, "invalid template")
+	assert.ErrorContains(t, err, `route #1: unknown severity "urgent"`)
+	assert.ErrorContains(t, err, `route #1: unknown destination "pager"`)
+}
+
+func TestRouter_Route(t *testing.T) {
+	slack := newServer(t)
+	webhook := newServer(t)
+	smtpSrv := newSMTPServer(t)
+
+	cfg := &router.Config{
+		Destinations: []router.Destination{
+			{Name: "soc-slack", Type: router.TypeSlack, URLEnv: "SLACK_WEBHOOK_URL"},
+			{Name: "triage", Type: router.TypeWebhook, URL: webhook.URL, SecretEnv: "TRIAGE_SECRET"},
+			{Name: "oncall", Type: router.TypeEmail, SMTP: router.SMTP{
+				Addr: smtpSrv.Addr().String(),
+				From: "alerts@muhaijuku.com",
+				To:   []string{"oncall@muhaijuku.com"},
+			}},
+		},
+		Routes: []router.Route{
+			{Name: "critical", MinSeverity: "critical", Destinations: []string{"oncall", "soc-slack"}},
+			{Name: "auth", Rules: []string{"auth-brute-force"}, MinSeverity: "medium", Destinations: []string{"soc-slack"}},
+			{Name: "all", Destinations: []string{"triage"}},
+		},
+	}
+	env := map[string]string{"SLACK_WEBHOOK_URL": slack.URL, "TRIAGE_SECRET": "s3cret"}
+	log := &router.MemoryLog{}
+	r, err := router.New(cfg, router.Options{
+		Getenv: func(name string) string { return env[name] },
+		Log:    log,
+		Now:    func() time.Time { return ocsftest.Base.Add(5 * time.Minute) },
+		Sleep:  noSleep,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("rule and severity routes", func(t *testing.T) {
+		deliveries, err := r.Route(ctx, testAlert("auth-brute-force", "high", 4))
+		require.NoError(t, err)
+		require.Len(t, deliveries, 2)
+		assert.Equal(t, "auth", deliveries[0].Route)
+		assert.Equal(t, "soc-slack", deliveries[0].Destination)
+		assert.Equal(t, router.StatusDelivered, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, "all", deliveries[1].Route)
+		assert.Equal(t, "triage", deliveries[1].Destination)
+
+		// Block Kit message
+		require.Len(t, slack.bodies, 1)
+		var msg struct {
+			Text   string `json:"text"`
+			Blocks []struct {
+				Type string `json:"type"`
+				Text struct {
+					Text string `json:"text"`
+				} `json:"text"`
+			} `json:"blocks"`
+		}
+		require.NoError(t, json.Unmarshal(slack.bodies[0], &msg))
+		assert.Contains(t, msg.Text, "[HIGH] Repeated login failures from the same IP address\nWhat: 12 login failures\n")
+		assert.Contains(t, msg.Text, "When: 2024-08-12T10:00:00Z - 2024-08-12T10:04:00Z\n")
+		assert.Contains(t, msg.Text, "Rule: auth-brute-force (T1110 Brute Force)\n")
+		require.Len(t, msg.Blocks, 4)
+		assert.Equal(t, "header", msg.Blocks[0].Type)
+		assert.Equal(t, ":red_circle: Repeated login failures from the same IP address", msg.Blocks[0].Text.Text)
+
+		// Signed alert JSON
+		require.Len(t, webhook.requests, 1)
+		req := webhook.requests[0]
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		timestamp := req.Header.Get(router.HeaderTimestamp)
+		assert.Equal(t, "1723457100", timestamp)
+		assert.Equal(t, "sha256="+router.Sign([]byte("s3cret"), timestamp, webhook.bodies[0]), req.Header.Get(router.HeaderSignature))
+		var published alert.Alert
+		require.NoError(t, json.Unmarshal(webhook.bodies[0], &published))
+		assert.Equal(t, "alert-auth-brute-force", published.ID)
+	})
+
+	t.Run("low severity of the rule", func(t *testing.T) {
+		deliveries, err := r.Route(ctx, testAlert("auth-brute-force", "low", 2))
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, "triage", deliveries[0].Destination)
+	})
+
+	t.Run("critical alerts are emailed once per destination", func(t *testing.T) {
+		a := testAlert("auth-brute-force", "critical", 5)
+		a.Title = "深夜の大量ダウンロード"
+		deliveries, err := r.Route(ctx, a)
+		require.NoError(t, err)
+		var dests []string
+		for _, d := range deliveries {
+			dests = append(dests, d.Destination)
+		}
+		assert.Equal(t, []string{"oncall", "soc-slack", "triage"}, dests)
+
+		require.Len(t, smtpSrv.messages, 1)
+		assert.Equal(t, []string{"oncall@muhaijuku.com"}, smtpSrv.rcpts[0])
+		msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(smtpSrv.messages[0]))).ReadMIMEHeader()
+		require.NoError(t, err)
+		subject, err := new(mime.WordDecoder).DecodeHeader(msg.Get("Subject"))
+		require.NoError(t, err)
+		assert.Equal(t, "[CRITICAL] 深夜の大量ダウンロード", subject)
+		assert.Equal(t, "alert-auth-brute-force", msg.Get("X-Alert-ID"))
+		// ReadDotBytes of the server converts CRLF to LF
+		assert.Contains(t, smtpSrv.messages[0], "IoC: ip_address 133.200.32.94\n")
+		assert.Contains(t, smtpSrv.messages[0], "Athena query (events):\nSELECT * FROM logs\n")
+	})
+
+	assert.Len(t, log.Deliveries(), 6)
+}
+
+func TestRouter_Retry(t *testing.T) {
+	hook := newServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	rejecting := newServer(t, http.StatusBadRequest)
+	smtpSrv := newSMTPServer(t)
+	smtpSrv.reject = true
+
+	cfg := &router.Config{
+		Destinations: []router.Destination{
+			{Name: "flaky", Type: router.TypeWebhook, URL: hook.URL},
+			{Name: "rejecting", Type: router.TypeWebhook, URL: rejecting.URL},
+			{Name: "mail", Type: router.TypeEmail, SMTP: router.SMTP{Addr: smtpSrv.Addr().String(), From: "a@example.com", To: []string{"b@example.com"}}},
+		},
+		Routes: []router.Route{{Destinations: []string{"flaky", "rejecting", "mail"}}},
+		Retry:  router.Retry{Attempts: 4, Backoff: time.Second},
+	}
+	var waits []time.Duration
+	log := &router.MemoryLog{}
+	r, err := router.New(cfg, router.Options{
+		Log: log,
+		Sleep: func(ctx context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	deliveries, err := r.Route(context.Background(), testAlert("auth-brute-force", "high", 4))
+	require.Len(t, deliveries, 3)
+	// 503 and 429 are retried with backoff
+	assert.Equal(t, router.StatusDelivered, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+	// Rejected requests and messages are not retried
+	assert.Equal(t, router.StatusFailed, deliveries[1].Status)
+	assert.Equal(t, 1, deliveries[1].Attempts)
+	assert.Contains(t, deliveries[1].Error, "unexpected status 400")
+	assert.Equal(t, router.StatusFailed, deliveries[2].Status)
+	assert.Equal(t, 1, deliveries[2].Attempts)
+	assert.Contains(t, deliveries[2].Error, "550")
+
+	assert.ErrorContains(t, err, `destination "rejecting"`)
+	assert.ErrorContains(t, err, `destination "mail"`)
+	assert.Equal(t, deliveries, log.Deliveries())
+}
+
+func TestRouter_EmailContext(t *testing.T) {
+	// A server accepting connections without a greeting
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	cfg := &router.Config{
+		Destinations: []router.Destination{
+			{Name: "mail", Type: router.TypeEmail, SMTP: router.SMTP{Addr: l.Addr().String(), From: "a@example.com", To: []string{"b@example.com"}}},
+		},
+		Routes: []router.Route{{Destinations: []string{"mail"}}},
+	}
+	r, err := router.New(cfg, router.Options{Log: &router.MemoryLog{}, Sleep: noSleep})
+	require.NoError(t, err)
+
+	for name, ctx := range map[string]func() (context.Context, context.CancelFunc){
+		"deadline": func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 100*time.Millisecond)
+		},
+		"cancel": func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			return ctx, cancel
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := ctx()
+			defer cancel()
+			start := time.Now()
+			deliveries, err := r.Route(ctx, testAlert("auth-brute-force", "high", 4))
+			require.Error(t, err)
+			assert.Less(t, time.Since(start), 5*time.Second)
+			require.Len(t, deliveries, 1)
+			assert.Equal(t, router.StatusFailed, deliveries[0].Status)
+			assert.Contains(t, deliveries[0].Error, ctx.Err().Error())
+		})
+	}
+}
+
+func TestNew_MissingSecret(t *testing.T) {
+	cfg := &router.Config{
+		Destinations: []router.Destination{{Name: "slack", Type: router.TypeSlack, URLEnv: "SLACK_WEBHOOK_URL"}},
+		Routes:       []router.Route{{Destinations: []string{"slack"}}},
+	}
+	_, err := router.New(cfg, router.Options{Getenv: func(string) string { return "" }})
+	assert.ErrorContains(t, err, "SLACK_WEBHOOK_URL is not set")
+}
@@ -0,0 +1,92 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"seccamp2025-b1-detector/alert"
+)
+
+// Templates are executed with the alert.Alert as data, and these functions
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+	"time": func(t any) string {
+		switch t := t.(type) {
+		case time.Time:
+			return t.UTC().Format(time.RFC3339)
+		case *time.Time:
+			if t != nil {
+				return t.UTC().Format(time.RFC3339)
+			}
+		}
+		return "-"
+	},
+}
+
+const (
+	defaultTextTemplate = `[{{upper .Severity}}] {{.Title}}
+{{- if .What}}
+What: {{.What}}{{end}}
+{{- if .Who}}
+Who: {{.Who}}{{end}}
+{{- if .Where}}
+Where: {{.Where}}{{end}}
+When: {{time .FirstSeen}} - {{time .LastSeen}}
+{{- if .Why}}
+Why: {{.Why}}{{end}}
+Rule: {{.Rule.ID}}{{with .Rule.Technique}} ({{.ID}} {{.Name}}){{end}}
+Events: {{.EventCount}}{{if gt .Occurrences 1}}, occurrence {{.Occurrences}}{{end}}
+Alert: {{.ID}}`
+
+	defaultEmailBody = defaultTextTemplate + `
+{{- range .IoCs}}
+IoC: {{.Type}} {{.Value}}{{end}}
+{{- range .Links}}
+
+Athena query ({{.Name}}):
+{{.Query}}{{end}}
+`
+
+	defaultSubjectTemplate = `[{{upper .Severity}}] {{.Title}}`
+)
+
+// templates are parsed templates of a destination
+type templates struct {
+	text    *template.Template
+	subject *template.Template
+}
+
+func parseTemplates(d *Destination) (*templates, error) {
+	text := d.Template
+	if text == "" {
+		text = defaultTextTemplate
+		if d.Type == TypeEmail {
+			text = defaultEmailBody
+		}
+	}
+	subject := d.Subject
+	if subject == "" {
+		subject = defaultSubjectTemplate
+	}
+
+	var x templates
+	var err error
+	if x.text, err = template.New(d.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(text); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	if x.subject, err = template.New(d.Name + "-subject").Funcs(templateFuncs).Option("missingkey=error").Parse(subject); err != nil {
+		return nil, fmt.Errorf("invalid subject template: %w", err)
+	}
+	return &x, nil
+}
+
+func render(tmpl *template.Template, a *alert.Alert) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, a); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,324 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-detector/alert"
+)
+
+// Transport delivers an alert to a destination
+type Transport interface {
+	Send(ctx context.Context, a *alert.Alert) error
+}
+
+// PermanentError is a failed delivery which retries would not fix, such as a rejected request
+type PermanentError struct {
+	Err error
+}
+
+func (x *PermanentError) Error() string { return x.Err.Error() }
+func (x *PermanentError) Unwrap() error { return x.Err }
+
+// post sends the body to the URL. Responses other than 2xx are errors, permanent except 429
+// and 5xx.
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("invalid request: %w", err)}
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return &PermanentError{Err: err}
+}
+
+// Slack posts alerts to a Slack incoming webhook as Block Kit messages
+type Slack struct {
+	URL       string
+	Client    *http.Client
+	templates *templates
+}
+
+// slackEmoji are emoji of severities in the header of messages
+var slackEmoji = map[string]string{
+	"informational": ":information_source:",
+	"low":           ":large_blue_circle:",
+	"medium":        ":large_yellow_circle:",
+	"high":          ":red_circle:",
+	"critical":      ":rotating_light:",
+}
+
+// Limits of characters of text of header and section blocks
+const (
+	slackHeaderLimit = 150
+	slackTextLimit   = 3000
+)
+
+// truncate cuts s to n characters with an ellipsis
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
+
+func (x *Slack) Send(ctx context.Context, a *alert.Alert) error {
+	text, err := render(x.templates.text, a)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	body, err := json.Marshal(slackMessage(a, text))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	return post(ctx, x.Client, x.URL, "application/json", body, nil)
+}
+
+// slackMessage returns a message of a header, the rendered text, fields of the alert and the
+// context of the rule. The text is the notification fallback.
+func slackMessage(a *alert.Alert, text string) map[string]any {
+	header := truncate(strings.TrimSpace(slackEmoji[a.Severity]+" "+a.Title), slackHeaderLimit)
+	section := truncate(text, slackTextLimit)
+
+	field := func(name, value string) map[string]any {
+		return map[string]any{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", name, value)}
+	}
+	fields := []any{
+		field("Severity", a.Severity),
+		field("Events", strconv.Itoa(a.EventCount)),
+	}
+	if a.Who != "" {
+		fields = append(fields, field("Who", a.Who))
+	}
+	if a.Where != "" {
+		fields = append(fields, field("Where", a.Where))
+	}
+
+	contexts := []any{map[string]any{"type": "mrkdwn", "text": fmt.Sprintf("Rule `%s` | Alert `%s`", a.Rule.ID, a.ID)}}
+	if t := a.Rule.Technique; t != nil {
+		contexts = append(contexts, map[string]any{"type": "mrkdwn", "text": fmt.Sprintf("%s %s (%s)", t.ID, t.Name, t.Tactic)})
+	}
+
+	return map[string]any{
+		"text": text,
+		"blocks": []any{
+			map[string]any{"type": "header", "text": map[string]any{"type": "plain_text", "text": header}},
+			map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": section}},
+			map[string]any{"type": "section", "fields": fields},
+			map[string]any{"type": "context", "elements": contexts},
+		},
+	}
+}
+
+// Headers of signed webhook requests
+const (
+	HeaderTimestamp = "X-Alert-Timestamp"
+	HeaderSignature = "X-Alert-Signature"
+)
+
+// Webhook posts alerts to a URL. Without a template, the body is the alert JSON. With a
+// secret, requests are signed with HMAC-SHA256 of "{timestamp}.{body}".
+type Webhook struct {
+	URL       string
+	Secret    []byte
+	Client    *http.Client
+	templates *templates
+	custom    bool
+	now       func() time.Time
+}
+
+func (x *Webhook) Send(ctx context.Context, a *alert.Alert) error {
+	contentType := "application/json"
+	var body []byte
+	if x.custom {
+		text, err := render(x.templates.text, a)
+		if err != nil {
+			return &PermanentError{Err: err}
+		}
+		body = []byte(text)
+		if !json.Valid(body) {
+			contentType = "text/plain; charset=utf-8"
+		}
+	} else {
+		data, err := alert.Encode(a, alert.MaxMessageSize)
+		if err != nil {
+			return &PermanentError{Err: err}
+		}
+		body = data
+	}
+
+	header := http.Header{}
+	if len(x.Secret) > 0 {
+		timestamp := strconv.FormatInt(x.now().Unix(), 10)
+		header.Set(HeaderTimestamp, timestamp)
+		header.Set(HeaderSignature, "sha256="+Sign(x.Secret, timestamp, body))
+	}
+	return post(ctx, x.Client, x.URL, contentType, body, header)
+}
+
+// Sign returns the hex HMAC-SHA256 of "{timestamp}.{body}" with the secret. Receivers compare
+// it with the signature header, and reject old timestamps to prevent replays.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Email sends alerts by SMTP as plain text
+type Email struct {
+	SMTP      SMTP
+	Password  string
+	templates *templates
+	sendMail  func(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (x *Email) Send(ctx context.Context, a *alert.Alert) error {
+	subject, err := render(x.templates.subject, a)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	body, err := render(x.templates.text, a)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+
+	var auth smtp.Auth
+	if x.SMTP.Username != "" {
+		host, _, _ := strings.Cut(x.SMTP.Addr, ":")
+		auth = smtp.PlainAuth("", x.SMTP.Username, x.Password, host)
+	}
+	if err := x.sendMail(ctx, x.SMTP.Addr, auth, x.SMTP.From, x.SMTP.To, emailMessage(x.SMTP, subject, body, a)); err != nil {
+		// 5xx replies reject the message, and 4xx replies are transient failures
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+			return &PermanentError{Err: fmt.Errorf("failed to send email: %w", err)}
+		}
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// sendMail is smtp.SendMail bound to the context. The connection is dialed with the context,
+// has its deadline, and is closed when it is done, so a stalled server cannot block the
+// caller beyond the context.
+func sendMail(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	if err := dialMail(ctx, addr, a, from, to, msg); err != nil {
+		// Errors of the closed connection are caused by the context
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+func dialMail(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	return deliverMail(conn, addr, a, from, to, msg)
+}
+
+// deliverMail sends the message over the connection as smtp.SendMail does
+func deliverMail(conn net.Conn, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	host, _, _ := net.SplitHostPort(addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if a != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailMessage returns a MIME message. The subject is encoded as UTF-8 for titles of any
+// language.
+func emailMessage(cfg SMTP, subject, body string, a *alert.Alert) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", strings.ReplaceAll(subject, "\n", " ")))
+	fmt.Fprintf(&b, "X-Alert-ID: %s\r\n", a.ID)
+	fmt.Fprintf(&b, "X-Alert-Rule: %s\r\n", a.Rule.ID)
+	fmt.Fprintf(&b, "X-Alert-Severity: %s\r\n", a.Severity)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
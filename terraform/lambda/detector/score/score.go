@@ -0,0 +1,413 @@
+// Package score measures detection rules against ground truth of generated logs.
+//
+// tools/loggen writes a label sidecar with the anomaly pattern of each anomalous log, keyed by
+// id.uniqueQualifier, which the converter keeps as metadata.uid. Records are joined to labels
+// by metadata.uid, and alerts to records by values of group-by fields and the period of the
+// alert. Records are read from the Parquet of the converter, as rules run on them in Athena.
+//
+// Labeled records of a pattern and an actor form an incident, split where events are apart by
+// more than the incident gap. An alert detects an incident if it covers a record of the
+// incident, the incident starts at or before the detection, and the rule expects the pattern
+// (detect.Rule.Expects). For each rule, precision is the ratio of alerts detecting any
+// incident, and alerts covering only incidents of other patterns are counted separately. For
+// each pattern, recall is the ratio of incidents that any alert detects, and time to detect is
+// from the first event of an incident to the earliest detecting alert.
+package score
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/detect"
+)
+
+// DefaultIncidentGap splits labeled events of a pattern and an actor into incidents
+const DefaultIncidentGap = time.Hour
+
+// Label is the ground truth of a log, as written by tools/loggen
+type Label struct {
+	UniqueQualifier string    `json:"unique_qualifier"`
+	Time            time.Time `json:"time"`
+	Pattern         int       `json:"pattern"`
+	PatternName     string    `json:"pattern_name"`
+	Actor           string    `json:"actor,omitempty"`
+	IPAddress       string    `json:"ip_address,omitempty"`
+}
+
+// ReadLabels reads labels of JSON lines
+func ReadLabels(r io.Reader) ([]Label, error) {
+	var labels []Label
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var label Label
+		if err := json.Unmarshal(scanner.Bytes(), &label); err != nil {
+			return nil, fmt.Errorf("invalid label at line %d: %w", line, err)
+		}
+		if label.UniqueQualifier == "" {
+			return nil, fmt.Errorf("label at line %d has no unique_qualifier", line)
+		}
+		labels = append(labels, label)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read labels: %w", err)
+	}
+	return labels, nil
+}
+
+// Report is the score of rules over a labeled dataset
+type Report struct {
+	Records int `json:"records"`
+	// Labeled is the number of records with a label. It is less than Labels if the records
+	// are not the logs of the labels.
+	Labeled  int            `json:"labeled"`
+	Labels   int            `json:"labels"`
+	Alerts   int            `json:"alerts"`
+	Rules    []RuleScore    `json:"rules"`
+	Patterns []PatternScore `json:"patterns"`
+}
+
+// RuleScore is the precision of a rule and its detections per pattern
+type RuleScore struct {
+	RuleID string `json:"rule_id"`
+	Alerts int    `json:"alerts"`
+	// TruePositives are alerts detecting any incident of the expected patterns
+	TruePositives int `json:"true_positives"`
+	// CrossPatternHits are alerts which are not true positives but cover incidents of
+	// patterns which the rule does not expect
+	CrossPatternHits int `json:"cross_pattern_hits"`
+	// Precision is 0 without alerts
+	Precision float64 `json:"precision"`
+	// Patterns are detections of incidents of patterns by the rule, keyed by pattern name
+	Patterns map[string]Detection `json:"patterns,omitempty"`
+	// CrossPatterns are numbers of incidents of patterns which the rule does not expect
+	// covered by its alerts, keyed by pattern name
+	CrossPatterns map[string]int `json:"cross_patterns,omitempty"`
+}
+
+// PatternScore is the recall of all rules for a pattern
+type PatternScore struct {
+	Pattern int    `json:"pattern"`
+	Name    string `json:"name"`
+	// Events are labeled records of the pattern
+	Events int `json:"events"`
+	Detection
+	// Rules are IDs of rules detecting incidents of the pattern
+	Rules []string `json:"rules,omitempty"`
+}
+
+// Detection is how many incidents are detected and how fast
+type Detection struct {
+	Incidents    int          `json:"incidents"`
+	Detected     int          `json:"detected"`
+	Recall       float64      `json:"recall"`
+	TimeToDetect TimeToDetect `json:"time_to_detect"`
+}
+
+// TimeToDetect summarizes times from the first events of detected incidents to the alerts
+type TimeToDetect struct {
+	Mean   time.Duration `json:"mean"`
+	Median time.Duration `json:"median"`
+	Max    time.Duration `json:"max"`
+}
+
+// MarshalJSON writes durations as strings such as "1m30s"
+func (x TimeToDetect) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"mean":   x.Mean.String(),
+		"median": x.Median.String(),
+		"max":    x.Max.String(),
+	})
+}
+
+// Scorer scores alerts of rules over labeled records
+type Scorer struct {
+	// IncidentGap is DefaultIncidentGap if zero
+	IncidentGap time.Duration
+}
+
+// incident is labeled records of a pattern and an actor close in time
+type incident struct {
+	pattern int
+	name    string
+	start   time.Time
+	// detectedAt is the earliest DetectedAt of covering alerts per rule
+	detectedAt map[string]time.Time
+}
+
+// labeled is a record joined to its label
+type labeled struct {
+	record   *core.OCSFWebResourceActivity
+	time     time.Time
+	label    *Label
+	incident *incident
+}
+
+// Score scores alerts of the rules. Alerts of rules missing in rules are scored too.
+func (x *Scorer) Score(rules []detect.Rule, labels []Label, records []core.OCSFWebResourceActivity, alerts []detect.Alert) (*Report, error) {
+	gap := x.IncidentGap
+	if gap == 0 {
+		gap = DefaultIncidentGap
+	}
+
+	byUID := make(map[string]*Label, len(labels))
+	for i := range labels {
+		byUID[labels[i].UniqueQualifier] = &labels[i]
+	}
+	items := make([]labeled, len(records))
+	for i := range records {
+		items[i] = labeled{
+			record: &records[i],
+			time:   time.UnixMilli(records[i].Time).UTC(),
+			label:  byUID[records[i].Metadata.UID],
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].time.Before(items[j].time) })
+
+	report := &Report{Records: len(records), Labels: len(labels), Alerts: len(alerts)}
+	incidents := groupIncidents(items, gap)
+	for _, item := range items {
+		if item.label != nil {
+			report.Labeled++
+		}
+	}
+
+	ruleScores := map[string]*RuleScore{}
+	var ruleIDs []string
+	ruleScore := func(id string) *RuleScore {
+		if s, ok := ruleScores[id]; ok {
+			return s
+		}
+		s := &RuleScore{RuleID: id}
+		ruleScores[id] = s
+		ruleIDs = append(ruleIDs, id)
+		return s
+	}
+	expects := map[string][]int{}
+	for _, rule := range rules {
+		ruleScore(rule.ID)
+		expects[rule.ID] = rule.Expects
+	}
+
+	crossed := map[string]map[*incident]bool{}
+	for i := range alerts {
+		alert := &alerts[i]
+		covered, err := cover(items, alert)
+		if err != nil {
+			return nil, err
+		}
+		s := ruleScore(alert.RuleID)
+		s.Alerts++
+		positive, cross := false, false
+		for _, item := range covered {
+			inc := item.incident
+			// Records after the detection are in the period of the alert, but incidents
+			// starting after it are not detected by the alert
+			if inc == nil || inc.start.After(alert.DetectedAt) {
+				continue
+			}
+			if patterns := expects[alert.RuleID]; len(patterns) > 0 && !slices.Contains(patterns, inc.pattern) {
+				cross = true
+				if crossed[alert.RuleID] == nil {
+					crossed[alert.RuleID] = map[*incident]bool{}
+				}
+				crossed[alert.RuleID][inc] = true
+				continue
+			}
+			positive = true
+			if t, ok := inc.detectedAt[alert.RuleID]; !ok || alert.DetectedAt.Before(t) {
+				inc.detectedAt[alert.RuleID] = alert.DetectedAt
+			}
+		}
+		switch {
+		case positive:
+			s.TruePositives++
+		case cross:
+			s.CrossPatternHits++
+		}
+	}
+	for id, incidents := range crossed {
+		s := ruleScores[id]
+		s.CrossPatterns = map[string]int{}
+		for inc := range incidents {
+			s.CrossPatterns[inc.name]++
+		}
+	}
+
+	for _, id := range ruleIDs {
+		s := ruleScores[id]
+		if s.Alerts > 0 {
+			s.Precision = float64(s.TruePositives) / float64(s.Alerts)
+		}
+		report.Rules = append(report.Rules, *s)
+	}
+	report.Patterns = scorePatterns(incidents, items, report.Rules)
+	return report, nil
+}
+
+// groupIncidents assigns labeled records sorted by time to incidents
+func groupIncidents(items []labeled, gap time.Duration) []*incident {
+	type key struct {
+		pattern int
+		actor   string
+	}
+	var incidents []*incident
+	open := map[key]*incident{}
+	last := map[key]time.Time{}
+	for i := range items {
+		item := &items[i]
+		if item.label == nil {
+			continue
+		}
+		actor := item.label.Actor
+		if actor == "" {
+			actor = item.record.Actor.User.EmailAddr
+		}
+		k := key{pattern: item.label.Pattern, actor: actor}
+		current, ok := open[k]
+		if !ok || item.time.Sub(last[k]) > gap {
+			current = &incident{
+				pattern:    item.label.Pattern,
+				name:       item.label.PatternName,
+				start:      item.time,
+				detectedAt: map[string]time.Time{},
+			}
+			if current.name == "" {
+				current.name = fmt.Sprintf("Pattern %d", current.pattern)
+			}
+			open[k] = current
+			incidents = append(incidents, current)
+		}
+		last[k] = item.time
+		item.incident = current
+	}
+	return incidents
+}
+
+// cover returns records in the period of the alert with the values of its group-by fields
+func cover(items []labeled, alert *detect.Alert) ([]*labeled, error) {
+	type condition struct {
+		field detect.Field
+		value string
+	}
+	var conditions []condition
+	for name, value := range alert.Group {
+		field, ok := detect.LookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown group-by field %q of alert of rule %s", name, alert.RuleID)
+		}
+		conditions = append(conditions, condition{field: field, value: value})
+	}
+
+	// Alerts of SQL rules without first_seen and last_seen columns cover records until the
+	// detection
+	end := alert.LastSeen
+	if end.IsZero() {
+		end = alert.DetectedAt
+	}
+	i := 0
+	if !alert.FirstSeen.IsZero() {
+		i = sort.Search(len(items), func(i int) bool { return !items[i].time.Before(alert.FirstSeen) })
+	}
+
+	var covered []*labeled
+	for ; i < len(items) && !items[i].time.After(end); i++ {
+		matched := true
+		for _, c := range conditions {
+			if c.field(items[i].record) != c.value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			covered = append(covered, &items[i])
+		}
+	}
+	return covered, nil
+}
+
+// scorePatterns summarizes incidents per pattern, in order of patterns
+func scorePatterns(incidents []*incident, items []labeled, rules []RuleScore) []PatternScore {
+	byPattern := map[int]*PatternScore{}
+	var patterns []int
+	for _, inc := range incidents {
+		if _, ok := byPattern[inc.pattern]; !ok {
+			byPattern[inc.pattern] = &PatternScore{Pattern: inc.pattern, Name: inc.name}
+			patterns = append(patterns, inc.pattern)
+		}
+	}
+	for _, item := range items {
+		if item.incident != nil {
+			byPattern[item.incident.pattern].Events++
+		}
+	}
+	sort.Ints(patterns)
+
+	var scores []PatternScore
+	for _, pattern := range patterns {
+		s := byPattern[pattern]
+		var all []time.Duration
+		perRule := map[string][]time.Duration{}
+		var incidentsOfPattern int
+		for _, inc := range incidents {
+			if inc.pattern != pattern {
+				continue
+			}
+			incidentsOfPattern++
+			var earliest time.Time
+			for id, t := range inc.detectedAt {
+				perRule[id] = append(perRule[id], t.Sub(inc.start))
+				if earliest.IsZero() || t.Before(earliest) {
+					earliest = t
+				}
+			}
+			if !earliest.IsZero() {
+				all = append(all, earliest.Sub(inc.start))
+			}
+		}
+		s.Detection = detection(incidentsOfPattern, all)
+		for i := range rules {
+			delays, ok := perRule[rules[i].RuleID]
+			if !ok {
+				continue
+			}
+			if rules[i].Patterns == nil {
+				rules[i].Patterns = map[string]Detection{}
+			}
+			rules[i].Patterns[s.Name] = detection(incidentsOfPattern, delays)
+			s.Rules = append(s.Rules, rules[i].RuleID)
+		}
+		scores = append(scores, *s)
+	}
+	return scores
+}
+
+func detection(incidents int, delays []time.Duration) Detection {
+	d := Detection{Incidents: incidents, Detected: len(delays)}
+	if incidents > 0 {
+		d.Recall = float64(len(delays)) / float64(incidents)
+	}
+	if len(delays) == 0 {
+		return d
+	}
+	sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+	var sum time.Duration
+	for _, delay := range delays {
+		sum += delay
+	}
+	d.TimeToDetect = TimeToDetect{
+		Mean:   sum / time.Duration(len(delays)),
+		Median: delays[len(delays)/2],
+		Max:    delays[len(delays)-1],
+	}
+	return d
+}
@@ -0,0 +1,311 @@
+package score_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+	"seccamp2025-b1-detector/score"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func label(seconds int, uid string, pattern int, name, user string) score.Label {
+	return score.Label{UniqueQualifier: uid, Time: ocsftest.At(seconds), Pattern: pattern, PatternName: name, Actor: user}
+}
+
+const (
+	authAttack = "Example4 High Frequency Auth Attack"
+	dataTheft  = "Example5 Rapid Data Theft"
+)
+
+func TestReadLabels(t *testing.T) {
+	labels, err := score.ReadLabels(strings.NewReader(`{"unique_qualifier":"1","time":"2024-08-12T10:00:00Z","pattern":6,"pattern_name":"Example4 High Frequency Auth Attack","actor":"a@muhai-academy.com"}
+
+{"unique_qualifier":"2","time":"2024-08-12T10:00:01Z","pattern":7,"pattern_name":"Example5 Rapid Data Theft"}
+`))
+	require.NoError(t, err)
+	require.Len(t, labels, 2)
+	assert.Equal(t, label(0, "1", 6, authAttack, "a@muhai-academy.com"), labels[0])
+	assert.Equal(t, 7, labels[1].Pattern)
+
+	_, err = score.ReadLabels(strings.NewReader("{\"unique_qualifier\":\"1\"}\n{broken\n"))
+	assert.ErrorContains(t, err, "invalid label at line 2")
+
+	_, err = score.ReadLabels(strings.NewReader(`{"pattern":6}`))
+	assert.ErrorContains(t, err, "has no unique_qualifier")
+}
+
+func TestScorer_Score(t *testing.T) {
+	records := []core.OCSFWebResourceActivity{
+		// Normal records of a user, falsely alerted
+		ocsftest.Record(ocsftest.At(60), "noisy@muhai-academy.com", "192.0.2.1", ocsftest.UID("n1")),
+		ocsftest.Record(ocsftest.At(120), "noisy@muhai-academy.com", "192.0.2.1", ocsftest.UID("n2")),
+		// An incident of auth attacks, detected 2 minutes after the first event
+		ocsftest.Record(ocsftest.At(0), "victim@muhai-academy.com", "203.0.113.9", ocsftest.UID("a1")),
+		ocsftest.Record(ocsftest.At(60), "victim@muhai-academy.com", "203.0.113.9", ocsftest.UID("a2")),
+		ocsftest.Record(ocsftest.At(240), "victim@muhai-academy.com", "203.0.113.9", ocsftest.UID("a3")),
+		// Another incident of the same actor after the incident gap, not detected
+		ocsftest.Record(ocsftest.At(3*3600), "victim@muhai-academy.com", "203.0.113.9", ocsftest.UID("a4")),
+		// An incident of data theft, detected 1 minute after the first event
+		ocsftest.Record(ocsftest.At(600), "thief@muhai-academy.com", "198.51.100.7", ocsftest.UID("t1")),
+		ocsftest.Record(ocsftest.At(660), "thief@muhai-academy.com", "198.51.100.7", ocsftest.UID("t2")),
+	}
+	labels := []score.Label{
+		label(0, "a1", 6, authAttack, "victim@muhai-academy.com"),
+		label(60, "a2", 6, authAttack, "victim@muhai-academy.com"),
+		label(240, "a3", 6, authAttack, "victim@muhai-academy.com"),
+		label(3*3600, "a4", 6, authAttack, "victim@muhai-academy.com"),
+		label(600, "t1", 7, dataTheft, "thief@muhai-academy.com"),
+		label(660, "t2", 7, dataTheft, "thief@muhai-academy.com"),
+		// A label of a log missing in records
+		label(700, "missing", 7, dataTheft, "thief@muhai-academy.com"),
+	}
+	alerts := []detect.Alert{
+		{
+			RuleID:     detect.RuleAuthBruteForce,
+			Group:      map[string]string{"src_endpoint.ip": "203.0.113.9"},
+			FirstSeen:  ocsftest.At(0),
+			LastSeen:   ocsftest.At(240),
+			DetectedAt: ocsftest.At(120),
+		},
+		{
+			RuleID:     detect.RuleAuthBruteForce,
+			Group:      map[string]string{"src_endpoint.ip": "192.0.2.1"},
+			FirstSeen:  ocsftest.At(60),
+			LastSeen:   ocsftest.At(120),
+			DetectedAt: ocsftest.At(120),
+		},
+		{
+			RuleID:     "mass-download",
+			Group:      map[string]string{"actor.user.email_addr": "thief@muhai-academy.com", "src_endpoint.ip": "198.51.100.7"},
+			FirstSeen:  ocsftest.At(600),
+			LastSeen:   ocsftest.At(660),
+			DetectedAt: ocsftest.At(660),
+		},
+		// An alert of a SQL rule without first_seen and last_seen covers records until the
+		// detection
+		{
+			RuleID:     "sql-rule",
+			Group:      map[string]string{"actor.user.email_addr": "thief@muhai-academy.com"},
+			DetectedAt: ocsftest.At(700),
+		},
+	}
+	rules := []detect.Rule{{ID: detect.RuleAuthBruteForce}, {ID: "mass-download"}, {ID: "service-probing"}}
+
+	scorer := &score.Scorer{}
+	report, err := scorer.Score(rules, labels, records, alerts)
+	require.NoError(t, err)
+
+	assert.Equal(t, 8, report.Records)
+	assert.Equal(t, 6, report.Labeled)
+	assert.Equal(t, 7, report.Labels)
+	assert.Equal(t, 4, report.Alerts)
+
+	assert.Equal(t, []score.RuleScore{
+		{
+			RuleID:        detect.RuleAuthBruteForce,
+			Alerts:        2,
+			TruePositives: 1,
+			Precision:     0.5,
+			Patterns: map[string]score.Detection{
+				authAttack: {Incidents: 2, Detected: 1, Recall: 0.5, TimeToDetect: score.TimeToDetect{Mean: 2 * time.Minute, Median: 2 * time.Minute, Max: 2 * time.Minute}},
+			},
+		},
+		{
+			RuleID:        "mass-download",
+			Alerts:        1,
+			TruePositives: 1,
+			Precision:     1,
+			Patterns: map[string]score.Detection{
+				dataTheft: {Incidents: 1, Detected: 1, Recall: 1, TimeToDetect: score.TimeToDetect{Mean: time.Minute, Median: time.Minute, Max: time.Minute}},
+			},
+		},
+		{RuleID: "service-probing"},
+		{
+			RuleID:        "sql-rule",
+			Alerts:        1,
+			TruePositives: 1,
+			Precision:     1,
+			Patterns: map[string]score.Detection{
+				dataTheft: {Incidents: 1, Detected: 1, Recall: 1, TimeToDetect: score.TimeToDetect{Mean: 100 * time.Second, Median: 100 * time.Second, Max: 100 * time.Second}},
+			},
+		},
+	}, report.Rules)
+
+	assert.Equal(t, []score.PatternScore{
+		{
+			Pattern:   6,
+			Name:      authAttack,
+			Events:    4,
+			Detection: score.Detection{Incidents: 2, Detected: 1, Recall: 0.5, TimeToDetect: score.TimeToDetect{Mean: 2 * time.Minute, Median: 2 * time.Minute, Max: 2 * time.Minute}},
+			Rules:     []string{detect.RuleAuthBruteForce},
+		},
+		{
+			Pattern: 7,
+			Name:    dataTheft,
+			Events:  2,
+			// The earliest alert of any rule counts
+			Detection: score.Detection{Incidents: 1, Detected: 1, Recall: 1, TimeToDetect: score.TimeToDetect{Mean: time.Minute, Median: time.Minute, Max: time.Minute}},
+			Rules:     []string{"mass-download", "sql-rule"},
+		},
+	}, report.Patterns)
+
+	raw, err := json.Marshal(report.Patterns[0].TimeToDetect)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"mean":"2m0s","median":"2m0s","max":"2m0s"}`, string(raw))
+}
+
+func TestScorer_ScoreIncidentGap(t *testing.T) {
+	records := []core.OCSFWebResourceActivity{
+		ocsftest.Record(ocsftest.At(0), "victim@muhai-academy.com", "203.0.113.9", ocsftest.UID("a1")),
+		ocsftest.Record(ocsftest.At(600), "victim@muhai-academy.com", "203.0.113.9", ocsftest.UID("a2")),
+	}
+	labels := []score.Label{
+		label(0, "a1", 6, authAttack, ""),
+		label(600, "a2", 6, authAttack, ""),
+	}
+
+	// Actors of records are used without actors of labels
+	report, err := (&score.Scorer{}).Score(nil, labels, records, nil)
+	require.NoError(t, err)
+	require.Len(t, report.Patterns, 1)
+	assert.Equal(t, 1, report.Patterns[0].Incidents)
+	assert.Zero(t, report.Patterns[0].Recall)
+
+	report, err = (&score.Scorer{IncidentGap: 5 * time.Minute}).Score(nil, labels, records, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Patterns[0].Incidents)
+}
+
+func TestScorer_ScoreExpectedPatterns(t *testing.T) {
+	records := []core.OCSFWebResourceActivity{
+		ocsftest.Record(ocsftest.At(0), "victim@muhai-academy.com", "203.0.113.9", ocsftest.UID("a1")),
+		ocsftest.Record(ocsftest.At(60), "victim@muhai-academy.com", "203.0.113.9", ocsftest.UID("a2")),
+		// An incident of another actor from the same IP, starting after the detection
+		ocsftest.Record(ocsftest.At(180), "other@muhai-academy.com", "203.0.113.9", ocsftest.UID("a3")),
+		ocsftest.Record(ocsftest.At(0), "thief@muhai-academy.com", "198.51.100.7", ocsftest.UID("t1")),
+	}
+	labels := []score.Label{
+		label(0, "a1", 6, authAttack, "victim@muhai-academy.com"),
+		label(60, "a2", 6, authAttack, "victim@muhai-academy.com"),
+		label(180, "a3", 6, authAttack, "other@muhai-academy.com"),
+		label(0, "t1", 7, dataTheft, "thief@muhai-academy.com"),
+	}
+	alerts := []detect.Alert{
+		{
+			RuleID:     detect.RuleAuthBruteForce,
+			Group:      map[string]string{"src_endpoint.ip": "203.0.113.9"},
+			FirstSeen:  ocsftest.At(0),
+			LastSeen:   ocsftest.At(180),
+			DetectedAt: ocsftest.At(60),
+		},
+		// An alert of a rule for data theft covering only auth attacks
+		{
+			RuleID:     "mass-download",
+			Group:      map[string]string{"src_endpoint.ip": "203.0.113.9"},
+			FirstSeen:  ocsftest.At(0),
+			LastSeen:   ocsftest.At(60),
+			DetectedAt: ocsftest.At(60),
+		},
+	}
+	rules := []detect.Rule{
+		{ID: detect.RuleAuthBruteForce, Expects: []int{6}},
+		{ID: "mass-download", Expects: []int{7}},
+	}
+
+	report, err := (&score.Scorer{}).Score(rules, labels, records, alerts)
+	require.NoError(t, err)
+	assert.Equal(t, []score.RuleScore{
+		{
+			RuleID:        detect.RuleAuthBruteForce,
+			Alerts:        1,
+			TruePositives: 1,
+			Precision:     1,
+			Patterns: map[string]score.Detection{
+				authAttack: {Incidents: 2, Detected: 1, Recall: 0.5, TimeToDetect: score.TimeToDetect{Mean: time.Minute, Median: time.Minute, Max: time.Minute}},
+			},
+		},
+		{
+			RuleID:           "mass-download",
+			Alerts:           1,
+			CrossPatternHits: 1,
+			CrossPatterns:    map[string]int{authAttack: 1},
+		},
+	}, report.Rules)
+	require.Len(t, report.Patterns, 2)
+	assert.Equal(t, []string{detect.RuleAuthBruteForce}, report.Patterns[0].Rules)
+	assert.Zero(t, report.Patterns[1].Detected)
+}
+
+func TestScorer_ScoreUnknownField(t *testing.T) {
+	alerts := []detect.Alert{{RuleID: "custom", Group: map[string]string{"user": "a"}}}
+	_, err := (&score.Scorer{}).Score(nil, nil, nil, alerts)
+	assert.ErrorContains(t, err, `unknown group-by field "user" of alert of rule custom`)
+}
+
+func TestScorer_ScoreDefaultRules(t *testing.T) {
+	var lines []string
+	var labels []score.Label
+	workspaceLog := func(seconds int, user, ip, eventName string) {
+		uid := fmt.Sprintf("%d", seconds)
+		raw, err := json.Marshal(map[string]any{
+			"kind":      "admin#reports#activity",
+			"id":        map[string]any{"time": ocsftest.At(seconds).Format(time.RFC3339Nano), "uniqueQualifier": uid, "applicationName": "login", "customerId": "C03az79cb"},
+			"actor":     map[string]any{"email": user},
+			"ipAddress": ip,
+			"events":    []any{map[string]any{"type": "login", "name": eventName}},
+		})
+		require.NoError(t, err)
+		lines = append(lines, string(raw))
+	}
+	for i := range 10 {
+		workspaceLog(i*60, "staff@muhai-academy.com", "192.0.2.10", "login_success")
+	}
+	// PatternExample4HighFreqAuthAttack against 4 accounts from one IP
+	for i := range 12 {
+		user := fmt.Sprintf("user%d@muhai-academy.com", i%4)
+		workspaceLog(600+i*10, user, "133.200.32.94", "login_failure")
+		labels = append(labels, label(600+i*10, fmt.Sprintf("%d", 600+i*10), 6, authAttack, user))
+	}
+
+	converter := &core.Converter{Region: "ap-northeast-1", AccountID: "123456789012"}
+	result, err := converter.Convert(context.Background(), "logs/test.jsonl", []byte(strings.Join(lines, "\n")))
+	require.NoError(t, err)
+	data, err := core.GenerateParquet(result.Logs)
+	require.NoError(t, err)
+	records, err := core.ReadParquet(context.Background(), data)
+	require.NoError(t, err)
+	rules := slices.DeleteFunc(detect.DefaultRules(), func(rule detect.Rule) bool { return rule.IsSQL() })
+	alerts, err := detect.Evaluate(rules, records)
+	require.NoError(t, err)
+
+	report, err := (&score.Scorer{}).Score(rules, labels, records, alerts)
+	require.NoError(t, err)
+	assert.Equal(t, 12, report.Labeled)
+	require.Len(t, report.Patterns, 1)
+	pattern := report.Patterns[0]
+	// Each account is an incident, detected at the 10th failure at 690s
+	assert.Equal(t, 4, pattern.Incidents)
+	assert.Equal(t, 4, pattern.Detected)
+	assert.Equal(t, 1.0, pattern.Recall)
+	assert.Equal(t, score.TimeToDetect{Mean: 75 * time.Second, Median: 80 * time.Second, Max: 90 * time.Second}, pattern.TimeToDetect)
+	assert.Equal(t, []string{detect.RuleAuthBruteForce}, pattern.Rules)
+
+	for _, rule := range report.Rules {
+		if rule.RuleID == detect.RuleAuthBruteForce {
+			assert.Equal(t, 1, rule.Alerts)
+			assert.Equal(t, 1.0, rule.Precision)
+		} else {
+			assert.Zero(t, rule.Alerts, rule.RuleID)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+// Package travel detects impossible travel: consecutive activities of a user from locations
+// too far apart to travel between in the time between them.
+//
+// The detector keeps the last known location of each user, from the latitude and longitude
+// of src_endpoint.location resolved by the converter. For each located activity, it computes
+// the great-circle distance from the last location and the implied speed. A hop faster than
+// the maximum speed over more than the minimum distance opens an alert of the user, and
+// following hops are added to it until the user has no impossible hop for the window.
+// Activities from allowlisted ranges, such as VPN and corporate egress, are not where users
+// are, so they neither raise alerts nor move the last location.
+package travel
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/detect"
+)
+
+const (
+	// RuleID is the rule ID of alerts of the detector
+	RuleID = "impossible-travel"
+	// DefaultMaxSpeed is the default maximum plausible speed in km/h, about an airliner
+	DefaultMaxSpeed = 900.0
+	// DefaultMinDistance is the default minimum distance in km of a hop, below which
+	// inaccuracy of geolocation of IP addresses dominates
+	DefaultMinDistance = 500.0
+	// DefaultWindow is the default quiet time closing an alert of a user
+	DefaultWindow = time.Hour
+	// DefaultRetention is the default period to remember the last location of a user
+	DefaultRetention = 30 * 24 * time.Hour
+
+	// earthRadius is the mean radius of the earth in km
+	earthRadius = 6371.0088
+)
+
+// Config configures the detector. Zero values are replaced by defaults.
+type Config struct {
+	// MaxSpeed is the maximum plausible speed in km/h
+	MaxSpeed float64
+	// MinDistance is the minimum distance in km of an impossible hop
+	MinDistance float64
+	// Window is the quiet time closing an alert of a user
+	Window time.Duration
+	// Retention is the period to remember the last location of a user, see Prune
+	Retention time.Duration
+	// Allowlist are ranges of VPN and corporate egress addresses
+	Allowlist []netip.Prefix
+}
+
+// ParseAllowlist parses comma separated CIDRs or addresses
+func ParseAllowlist(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowlist entry %q: %w", item, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry %q: %w", item, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Location is a located activity of a user
+type Location struct {
+	Time    time.Time `json:"time"`
+	IP      string    `json:"ip"`
+	City    string    `json:"city,omitempty"`
+	Country string    `json:"country,omitempty"`
+	Lat     float64   `json:"lat"`
+	Long    float64   `json:"long"`
+}
+
+// String returns the place of the location, such as "Tokyo, JP"
+func (x Location) String() string {
+	var parts []string
+	for _, s := range []string{x.City, x.Country} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%.4f, %.4f", x.Lat, x.Long)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// State is carried over between detector runs, e.g. between scheduled invocations
+type State struct {
+	// Users are last known locations keyed by email address
+	Users map[string]Location `json:"users"`
+}
+
+// NewState returns an empty state
+func NewState() *State {
+	return &State{Users: map[string]Location{}}
+}
+
+// Hop is a move of a user between consecutive located activities
+type Hop struct {
+	From Location `json:"from"`
+	To   Location `json:"to"`
+	// Distance is the great-circle distance in km
+	Distance float64 `json:"distance_km"`
+	// Speed is the implied speed in km/h, +Inf for simultaneous activities
+	Speed float64 `json:"speed_kmh"`
+}
+
+// Elapsed returns the time between the activities of the hop
+func (x Hop) Elapsed() time.Duration {
+	return x.To.Time.Sub(x.From.Time)
+}
+
+// Distance returns the great-circle distance in km between coordinates in degrees, by the
+// haversine formula
+func Distance(lat1, long1, lat2, long2 float64) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(lat2 - lat1)
+	dLong := rad(long2 - long1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// newHop returns the hop between locations in time order
+func newHop(from, to Location) Hop {
+	hop := Hop{From: from, To: to, Distance: Distance(from.Lat, from.Long, to.Lat, to.Long)}
+	if hours := hop.Elapsed().Hours(); hours > 0 {
+		hop.Speed = hop.Distance / hours
+	} else if hop.Distance > 0 {
+		hop.Speed = math.Inf(1)
+	}
+	return hop
+}
+
+// Rule describes alerts of the detector for alert.New and scoring. The filter selects
+// records with a location, and Detector raises the alerts.
+func Rule(cfg Config) detect.Rule {
+	cfg = cfg.withDefaults()
+	return detect.Rule{
+		ID:    RuleID,
+		Title: "Impossible travel between consecutive activities of a user",
+		Description: fmt.Sprintf("Activities of the same user from locations more than %g km apart, at a speed faster than %g km/h. "+
+			"The account may be used by someone else at the same time, such as with stolen credentials or session tokens.",
+			cfg.MinDistance, cfg.MaxSpeed),
+		Severity:  detect.SeverityHigh,
+		Technique: detect.Technique{ID: "T1078", Name: "Valid Accounts", Tactic: "Initial Access"},
+		Expects:   []int{9},
+		Filter:    func(x *core.OCSFWebResourceActivity) bool { _, ok := locate(x); return ok },
+		GroupBy:   []string{"actor.user.email_addr"},
+		Window:    cfg.Window,
+	}
+}
+
+func (x Config) withDefaults() Config {
+	if x.MaxSpeed <= 0 {
+		x.MaxSpeed = DefaultMaxSpeed
+	}
+	if x.MinDistance <= 0 {
+		x.MinDistance = DefaultMinDistance
+	}
+	if x.Window <= 0 {
+		x.Window = DefaultWindow
+	}
+	if x.Retention <= 0 {
+		x.Retention = DefaultRetention
+	}
+	return x
+}
+
+// Detector detects impossible travel over a stream of records in time order
+type Detector struct {
+	cfg   Config
+	state *State
+	rule  detect.Rule
+	// open are alerts of users with impossible hops in the window
+	open map[string]*openAlert
+}
+
+type openAlert struct {
+	hops    []Hop
+	samples []detect.Sample
+	ips     map[string]struct{}
+	places  map[string]struct{}
+}
+
+// New returns a detector continuing from the state. An empty state is used if state is nil.
+func New(cfg Config, state *State) *Detector {
+	cfg = cfg.withDefaults()
+	if state == nil {
+		state = NewState()
+	}
+	if state.Users == nil {
+		state.Users = map[string]Location{}
+	}
+	return &Detector{cfg: cfg, state: state, rule: Rule(cfg), open: map[string]*openAlert{}}
+}
+
+// State returns the current state
+func (x *Detector) State() *State {
+	return x.state
+}
+
+// locate returns the location of a successful activity with coordinates
+func locate(record *core.OCSFWebResourceActivity) (Location, bool) {
+	loc := record.SrcEndpoint.Location
+	if record.Actor.User.EmailAddr == "" || record.SrcEndpoint.IP == "" || detect.IsFailure(record) {
+		return Location{}, false
+	}
+	if loc.Lat == 0 && loc.Long == 0 {
+		return Location{}, false
+	}
+	return Location{
+		Time:    time.UnixMilli(record.Time).UTC(),
+		IP:      record.SrcEndpoint.IP,
+		City:    loc.City,
+		Country: loc.Country,
+		Lat:     loc.Lat,
+		Long:    loc.Long,
+	}, true
+}
+
+// allowed reports whether the address is in the allowlist
+func (x *Detector) allowed(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range x.cfg.Allowlist {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Observe evaluates a record and returns alerts closed by it. Records before the last
+// location of the user are ignored.
+func (x *Detector) Observe(record *core.OCSFWebResourceActivity) []detect.Alert {
+	to, ok := locate(record)
+	if !ok || x.allowed(to.IP) {
+		return nil
+	}
+	user := record.Actor.User.EmailAddr
+
+	var alerts []detect.Alert
+	for _, u := range slices.Sorted(maps.Keys(x.open)) {
+		if a := x.open[u]; to.Time.Sub(a.last()) >= x.cfg.Window {
+			alerts = append(alerts, x.close(u))
+		}
+	}
+
+	from, known := x.state.Users[user]
+	if known && to.Time.Before(from.Time) {
+		return alerts
+	}
+	x.state.Users[user] = to
+	if !known {
+		return alerts
+	}
+	hop := newHop(from, to)
+	if hop.Distance < x.cfg.MinDistance || hop.Speed <= x.cfg.MaxSpeed {
+		return alerts
+	}
+
+	a, ok := x.open[user]
+	if !ok {
+		a = &openAlert{ips: map[string]struct{}{}, places: map[string]struct{}{}}
+		x.open[user] = a
+	}
+	a.add(hop, record)
+	return alerts
+}
+
+// Flush closes all open alerts, at the end of the stream
+func (x *Detector) Flush() []detect.Alert {
+	var alerts []detect.Alert
+	for _, user := range slices.Sorted(maps.Keys(x.open)) {
+		alerts = append(alerts, x.close(user))
+	}
+	return alerts
+}
+
+// Prune forgets last locations older than the retention period at now
+func (x *Detector) Prune(now time.Time) {
+	for user, loc := range x.state.Users {
+		if now.Sub(loc.Time) > x.cfg.Retention {
+			delete(x.state.Users, user)
+		}
+	}
+}
+
+func (x *openAlert) last() time.Time {
+	return x.hops[len(x.hops)-1].To.Time
+}
+
+func (x *openAlert) add(hop Hop, record *core.OCSFWebResourceActivity) {
+	x.hops = append(x.hops, hop)
+	for _, loc := range []Location{hop.From, hop.To} {
+		x.ips[loc.IP] = struct{}{}
+		if loc.Country != "" {
+			x.places[loc.Country] = struct{}{}
+		}
+	}
+	if len(x.samples) < detect.MaxSamples {
+		x.samples = append(x.samples, detect.NewSample(record))
+	}
+}
+
+// close returns the alert of the user. Values describe the first hop, which is where the
+// account was first seen in two places, and the fastest hop.
+func (x *Detector) close(user string) detect.Alert {
+	a := x.open[user]
+	delete(x.open, user)
+
+	first := a.hops[0]
+	fastest := first
+	for _, hop := range a.hops[1:] {
+		if hop.Speed > fastest.Speed {
+			fastest = hop
+		}
+	}
+
+	rule := &x.rule
+	technique := rule.Technique
+	alert := detect.Alert{
+		RuleID:     rule.ID,
+		Title:      rule.Title,
+		Severity:   rule.Severity,
+		Technique:  &technique,
+		Group:      map[string]string{"actor.user.email_addr": user},
+		FirstSeen:  first.From.Time,
+		LastSeen:   a.last(),
+		DetectedAt: first.To.Time,
+		Count:      len(a.hops),
+		Distinct: map[string][]string{
+			"src_endpoint.ip":               slices.Sorted(maps.Keys(a.ips)),
+			"src_endpoint.location.country": slices.Sorted(maps.Keys(a.places)),
+		},
+		Values:  hopValues(first, fastest),
+		Samples: a.samples,
+	}
+	alert.Fields = detect.AlertFields{
+		Who:   user,
+		What:  fmt.Sprintf("%d impossible travels, first %s from %s to %s", len(a.hops), formatDistance(first), first.From, first.To),
+		When:  fmt.Sprintf("%s - %s", first.From.Time.Format(time.RFC3339), first.To.Time.Format(time.RFC3339)),
+		Where: fmt.Sprintf("%s (%s) -> %s (%s)", first.From.IP, first.From, first.To.IP, first.To),
+		Why:   fmt.Sprintf("The fastest travel is %s, faster than %g km/h", formatSpeed(fastest.Speed), x.cfg.MaxSpeed),
+	}
+	return alert
+}
+
+// hopValues returns values of the first and the fastest hops
+func hopValues(first, fastest Hop) map[string]string {
+	values := map[string]string{}
+	for prefix, loc := range map[string]Location{"from": first.From, "to": first.To} {
+		values[prefix+".time"] = loc.Time.Format(time.RFC3339)
+		values[prefix+".ip"] = loc.IP
+		values[prefix+".city"] = loc.City
+		values[prefix+".country"] = loc.Country
+		values[prefix+".lat"] = strconv.FormatFloat(loc.Lat, 'f', 4, 64)
+		values[prefix+".long"] = strconv.FormatFloat(loc.Long, 'f', 4, 64)
+	}
+	values["distance_km"] = strconv.FormatFloat(first.Distance, 'f', 0, 64)
+	values["speed_kmh"] = strconv.FormatFloat(first.Speed, 'f', 0, 64)
+	values["max_speed_kmh"] = strconv.FormatFloat(fastest.Speed, 'f', 0, 64)
+	return values
+}
+
+func formatDistance(hop Hop) string {
+	if hop.Elapsed() == 0 {
+		return fmt.Sprintf("%.0f km simultaneously", hop.Distance)
+	}
+	return fmt.Sprintf("%.0f km in %s", hop.Distance, hop.Elapsed())
+}
+
+func formatSpeed(speed float64) string {
+	if math.IsInf(speed, 1) {
+		return "simultaneous"
+	}
+	return fmt.Sprintf("%.0f km/h", speed)
+}
+
+// Evaluate runs the detector over records in arbitrary order and returns alerts ordered by
+// detection time
+func Evaluate(cfg Config, records []core.OCSFWebResourceActivity) []detect.Alert {
+	ordered := make([]*core.OCSFWebResourceActivity, len(records))
+	for i := range records {
+		ordered[i] = &records[i]
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Time < ordered[j].Time })
+
+	detector := New(cfg, nil)
+	var alerts []detect.Alert
+	for _, record := range ordered {
+		alerts = append(alerts, detector.Observe(record)...)
+	}
+	alerts = append(alerts, detector.Flush()...)
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].DetectedAt.Before(alerts[j].DetectedAt) })
+	return alerts
+}
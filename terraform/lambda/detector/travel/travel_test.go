@@ -0,0 +1,205 @@
+package travel_test
+
+import (
+	"compress/gzip"
+	"context"
+	"net/netip"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"seccamp2025-b1-converter/core"
+	"seccamp2025-b1-detector/detect"
+	"seccamp2025-b1-detector/internal/ocsftest"
+	"seccamp2025-b1-detector/score"
+	"seccamp2025-b1-detector/travel"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	tokyo        = core.OCSFLocation{City: "Tokyo", Country: "JP", Lat: 35.6895, Long: 139.6917}
+	osaka        = core.OCSFLocation{City: "Osaka", Country: "JP", Lat: 34.6937, Long: 135.5023}
+	sanFrancisco = core.OCSFLocation{City: "San Francisco", Country: "US", Lat: 37.7749, Long: -122.4194}
+)
+
+func TestDistance(t *testing.T) {
+	assert.InDelta(t, 8271, travel.Distance(tokyo.Lat, tokyo.Long, sanFrancisco.Lat, sanFrancisco.Long), 5)
+	assert.InDelta(t, 397, travel.Distance(tokyo.Lat, tokyo.Long, osaka.Lat, osaka.Long), 5)
+	assert.Zero(t, travel.Distance(tokyo.Lat, tokyo.Long, tokyo.Lat, tokyo.Long))
+	// Across the antimeridian
+	assert.InDelta(t, 222, travel.Distance(0, 179, 0, -179), 1)
+}
+
+func TestEvaluate_AlternatingCountries(t *testing.T) {
+	const user = "yamada.takeshi@muhaijuku.com"
+	records := []core.OCSFWebResourceActivity{
+		ocsftest.Record(ocsftest.Base, user, "192.0.2.10", ocsftest.Location(tokyo)),
+		ocsftest.Record(ocsftest.Base.Add(2*time.Minute), user, "198.51.100.20", ocsftest.Location(sanFrancisco)),
+		ocsftest.Record(ocsftest.Base.Add(3*time.Minute), user, "192.0.2.10", ocsftest.Location(tokyo)),
+		ocsftest.Record(ocsftest.Base.Add(3*time.Minute), user, "192.0.2.10", ocsftest.Location(tokyo)),
+		ocsftest.Record(ocsftest.Base.Add(5*time.Minute), user, "198.51.100.20", ocsftest.Location(sanFrancisco)),
+		// Another user in Tokyo, and in Osaka 1 hour later
+		ocsftest.Record(ocsftest.Base, "sato@muhaijuku.com", "210.160.34.10", ocsftest.Location(tokyo)),
+		ocsftest.Record(ocsftest.Base.Add(time.Hour), "sato@muhaijuku.com", "126.204.1.1", ocsftest.Location(osaka)),
+	}
+
+	alerts := travel.Evaluate(travel.Config{}, records)
+	require.Len(t, alerts, 1)
+	a := alerts[0]
+	assert.Equal(t, travel.RuleID, a.RuleID)
+	assert.Equal(t, detect.SeverityHigh, a.Severity)
+	assert.Equal(t, map[string]string{"actor.user.email_addr": user}, a.Group)
+	assert.Equal(t, ocsftest.Base, a.FirstSeen)
+	assert.Equal(t, ocsftest.Base.Add(2*time.Minute), a.DetectedAt)
+	assert.Equal(t, ocsftest.Base.Add(5*time.Minute), a.LastSeen)
+	assert.Equal(t, 3, a.Count)
+	assert.Equal(t, []string{"192.0.2.10", "198.51.100.20"}, a.Distinct["src_endpoint.ip"])
+	assert.Equal(t, []string{"JP", "US"}, a.Distinct["src_endpoint.location.country"])
+	assert.Len(t, a.Samples, 3)
+
+	assert.Equal(t, "192.0.2.10", a.Values["from.ip"])
+	assert.Equal(t, "Tokyo", a.Values["from.city"])
+	assert.Equal(t, "2024-08-12T10:00:00Z", a.Values["from.time"])
+	assert.Equal(t, "198.51.100.20", a.Values["to.ip"])
+	assert.Equal(t, "US", a.Values["to.country"])
+	assert.Equal(t, "2024-08-12T10:02:00Z", a.Values["to.time"])
+	assert.Equal(t, "8271", a.Values["distance_km"])
+	assert.Equal(t, "248122", a.Values["speed_kmh"])
+
+	assert.Equal(t, user, a.Fields.Who)
+	assert.Equal(t, "3 impossible travels, first 8271 km in 2m0s from Tokyo, JP to San Francisco, US", a.Fields.What)
+	assert.Equal(t, "192.0.2.10 (Tokyo, JP) -> 198.51.100.20 (San Francisco, US)", a.Fields.Where)
+	assert.Equal(t, "The fastest travel is 496244 km/h, faster than 900 km/h", a.Fields.Why)
+}
+
+// TestEvaluate_LoggenSample checks the detector against the ground truth of the loggen sample
+func TestEvaluate_LoggenSample(t *testing.T) {
+	raw, err := os.ReadFile("../testdata/loggen/day_2024-08-12_0200_0205.jsonl.gz")
+	require.NoError(t, err)
+	converter := &core.Converter{Region: "ap-northeast-1", AccountID: "123456789012"}
+	result, err := converter.Convert(context.Background(), "day_2024-08-12_0200_0205.jsonl.gz", raw)
+	require.NoError(t, err)
+	f, err := os.Open("../testdata/loggen/day_2024-08-12_0200_0205.labels.jsonl.gz")
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	labels, err := score.ReadLabels(r)
+	require.NoError(t, err)
+
+	alerts := travel.Evaluate(travel.Config{}, result.Logs)
+	report, err := (&score.Scorer{}).Score([]detect.Rule{travel.Rule(travel.Config{})}, labels, result.Logs, alerts)
+	require.NoError(t, err)
+
+	// Example 7 alternates 192.0.2.10 (Tokyo) and 198.51.100.20 (San Francisco)
+	i := slices.IndexFunc(report.Patterns, func(p score.PatternScore) bool { return p.Pattern == 9 })
+	require.GreaterOrEqual(t, i, 0)
+	assert.Equal(t, 1, report.Patterns[i].Incidents)
+	assert.Equal(t, 1, report.Patterns[i].Detected)
+	assert.Equal(t, []string{travel.RuleID}, report.Patterns[i].Rules)
+
+	// The other alert is the account of Example 5, used from 198.51.100.99 (San Francisco)
+	// while its owner works in Tokyo
+	require.Len(t, report.Rules, 1)
+	assert.Equal(t, 2, report.Rules[0].Alerts)
+	assert.Equal(t, 1, report.Rules[0].TruePositives)
+	assert.Equal(t, 1, report.Rules[0].CrossPatternHits)
+	assert.Contains(t, report.Rules[0].CrossPatterns, "Example5 Rapid Data Theft")
+}
+
+func TestDetector(t *testing.T) {
+	const user = "tanaka@muhaijuku.com"
+
+	t.Run("plausible travel", func(t *testing.T) {
+		// Tokyo to San Francisco by a 10 hour flight
+		alerts := travel.Evaluate(travel.Config{}, []core.OCSFWebResourceActivity{
+			ocsftest.Record(ocsftest.Base, user, "192.0.2.10", ocsftest.Location(tokyo)),
+			ocsftest.Record(ocsftest.Base.Add(10*time.Hour), user, "198.51.100.20", ocsftest.Location(sanFrancisco)),
+		})
+		assert.Empty(t, alerts)
+	})
+
+	t.Run("allowlisted egress", func(t *testing.T) {
+		allowlist, err := travel.ParseAllowlist("198.51.100.0/24, 203.0.113.5")
+		require.NoError(t, err)
+		records := []core.OCSFWebResourceActivity{
+			ocsftest.Record(ocsftest.Base, user, "192.0.2.10", ocsftest.Location(tokyo)),
+			ocsftest.Record(ocsftest.Base.Add(time.Minute), user, "198.51.100.20", ocsftest.Location(sanFrancisco)),
+			// The last location stays in Tokyo
+			ocsftest.Record(ocsftest.Base.Add(2*time.Minute), user, "192.0.2.11", ocsftest.Location(tokyo)),
+		}
+		assert.Empty(t, travel.Evaluate(travel.Config{Allowlist: allowlist}, records))
+		assert.Len(t, travel.Evaluate(travel.Config{}, records), 1)
+	})
+
+	t.Run("failed and unlocated activities", func(t *testing.T) {
+		failed := ocsftest.Record(ocsftest.Base.Add(time.Minute), user, "198.51.100.20", ocsftest.Location(sanFrancisco))
+		failed.StatusID = 2
+		alerts := travel.Evaluate(travel.Config{}, []core.OCSFWebResourceActivity{
+			ocsftest.Record(ocsftest.Base, user, "192.0.2.10", ocsftest.Location(tokyo)),
+			failed,
+			ocsftest.Record(ocsftest.Base.Add(2*time.Minute), user, "198.51.100.21", ocsftest.Location(core.OCSFLocation{Country: "US"})),
+		})
+		assert.Empty(t, alerts)
+	})
+
+	t.Run("alerts close after the window", func(t *testing.T) {
+		d := travel.New(travel.Config{Window: 30 * time.Minute}, nil)
+		observe := func(r core.OCSFWebResourceActivity) []detect.Alert { return d.Observe(&r) }
+
+		assert.Empty(t, observe(ocsftest.Record(ocsftest.Base, user, "192.0.2.10", ocsftest.Location(tokyo))))
+		assert.Empty(t, observe(ocsftest.Record(ocsftest.Base.Add(time.Minute), user, "198.51.100.20", ocsftest.Location(sanFrancisco))))
+		assert.Empty(t, observe(ocsftest.Record(ocsftest.Base.Add(20*time.Minute), user, "198.51.100.20", ocsftest.Location(sanFrancisco))))
+		closed := observe(ocsftest.Record(ocsftest.Base.Add(31*time.Minute), "sato@muhaijuku.com", "192.0.2.10", ocsftest.Location(tokyo)))
+		require.Len(t, closed, 1)
+		assert.Equal(t, ocsftest.Base.Add(time.Minute), closed[0].LastSeen)
+
+		// A new alert after the window
+		assert.Empty(t, observe(ocsftest.Record(ocsftest.Base.Add(40*time.Minute), user, "192.0.2.10", ocsftest.Location(tokyo))))
+		flushed := d.Flush()
+		require.Len(t, flushed, 1)
+		assert.Equal(t, ocsftest.Base.Add(20*time.Minute), flushed[0].FirstSeen)
+	})
+
+	t.Run("state carries over", func(t *testing.T) {
+		first := travel.New(travel.Config{}, nil)
+		r := ocsftest.Record(ocsftest.Base, user, "192.0.2.10", ocsftest.Location(tokyo))
+		first.Observe(&r)
+		assert.Empty(t, first.Flush())
+		assert.Equal(t, "192.0.2.10", first.State().Users[user].IP)
+
+		second := travel.New(travel.Config{}, first.State())
+		r = ocsftest.Record(ocsftest.Base.Add(time.Hour), user, "198.51.100.20", ocsftest.Location(sanFrancisco))
+		second.Observe(&r)
+		assert.Len(t, second.Flush(), 1)
+
+		second.Prune(ocsftest.Base.Add(travel.DefaultRetention + 2*time.Hour))
+		assert.Empty(t, second.State().Users)
+	})
+}
+
+func TestParseAllowlist(t *testing.T) {
+	prefixes, err := travel.ParseAllowlist("10.0.0.0/8,192.0.2.1,2001:db8::/32,10.1.2.3/16")
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("10.1.0.0/16"),
+	}, prefixes)
+
+	_, err = travel.ParseAllowlist("10.0.0.0/33")
+	assert.ErrorContains(t, err, `invalid allowlist entry "10.0.0.0/33"`)
+}
+
+func TestRule(t *testing.T) {
+	rule := travel.Rule(travel.Config{MaxSpeed: 1000})
+	assert.Equal(t, travel.RuleID, rule.ID)
+	assert.Contains(t, rule.Description, "faster than 1000 km/h")
+	// No Athena query for the Go filter
+	_, err := rule.Query()
+	assert.Error(t, err)
+}
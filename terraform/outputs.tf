@@ -46,6 +46,11 @@ output "converter_lambda_function_name" {
   value       = aws_lambda_function.converter.function_name
 }
 
+output "detector_lambda_function_name" {
+  description = "Detector Lambda function name"
+  value       = aws_lambda_function.detector.function_name
+}
+
 output "alerts_sns_topic_arn" {
   description = "SNS topic ARN for alerts"
   value       = aws_sns_topic.alerts.arn
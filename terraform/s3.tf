@@ -74,4 +74,35 @@ resource "aws_s3_bucket_public_access_block" "auditlog_seeds" {
   block_public_policy     = true
   ignore_public_acls      = true
   restrict_public_buckets = true
-}
\ No newline at end of file
+}
+###########################################
+# S3 Bucket for Converter State
+###########################################
+
+resource "aws_s3_bucket" "converter_state" {
+  bucket = "${var.basename}-converter-state"
+
+  tags = merge(local.common_tags, {
+    Name = "${var.basename}-converter-state"
+    Type = "converter-state"
+  })
+}
+
+resource "aws_s3_bucket_public_access_block" "converter_state" {
+  bucket = aws_s3_bucket.converter_state.id
+
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
+
+resource "aws_s3_bucket_server_side_encryption_configuration" "converter_state" {
+  bucket = aws_s3_bucket.converter_state.id
+
+  rule {
+    apply_server_side_encryption_by_default {
+      sse_algorithm = "AES256"
+    }
+  }
+}
@@ -51,7 +51,7 @@ resource "aws_securitylake_data_lake" "main" {
 # Custom source for Google Workspace logs
 resource "aws_securitylake_custom_log_source" "google_workspace" {
   source_name    = "google-workspace"
-  source_version = "1.0"
+  source_version = var.custom_log_source_version
 
   event_classes = ["API_ACTIVITY", "FILE_ACTIVITY", "AUTHENTICATION", "AUTHORIZATION"]
 
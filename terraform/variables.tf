@@ -11,3 +11,75 @@ variable "aws_region" {
 }
 
 # Team variable removed - using shared resources only
+
+variable "geoip_city_database_key" {
+  description = "Object key of MaxMind City database (.mmdb) in the converter state bucket. GeoIP enrichment uses the static prefix table if empty."
+  type        = string
+  default     = ""
+}
+
+variable "geoip_asn_database_key" {
+  description = "Object key of MaxMind ASN or ISP database (.mmdb) in the converter state bucket"
+  type        = string
+  default     = ""
+}
+
+variable "enrichment_data_keys" {
+  description = "Object keys of enrichment data (JSON or CSV) in the converter state bucket. Supported keys: user_directory, ip_intel"
+  type        = map(string)
+  default     = {}
+}
+
+variable "event_catalog_keys" {
+  description = "Object keys of event catalog override files (JSON) in the converter state bucket, applied in order"
+  type        = list(string)
+  default     = []
+}
+
+variable "classification_rules_keys" {
+  description = "Object keys of classification rule files (JSON) in the converter state bucket, added to the embedded rules in order"
+  type        = list(string)
+  default     = []
+}
+
+variable "ocsf_version" {
+  description = "OCSF schema version written to metadata.version by the converter"
+  type        = string
+  default     = "1.1.0"
+}
+
+variable "custom_log_source_version" {
+  description = "Version of the Security Lake custom log source. Objects are written under ext/<source>/<version>/"
+  type        = string
+  default     = "1.0"
+}
+
+variable "converter_additional_log_sources" {
+  description = "Additional custom source layouts (\"name/version\") written together with the current source while migrating to a new version"
+  type        = list(string)
+  default     = []
+}
+
+variable "detector_max_bytes_scanned" {
+  description = "Bytes scanned ceiling of each Athena query of the detector Lambda. 0 disables the ceiling."
+  type        = number
+  default     = 10737418240
+}
+
+variable "detection_delay" {
+  description = "Delay of scheduled runs of rules behind invocations of the detector Lambda, for records arriving late through the importer and the converter (Go duration)"
+  type        = string
+  default     = "10m"
+}
+
+variable "alert_router_config_file" {
+  description = "router.yaml of the alert-router Lambda, relative to the terraform directory, such as lambda/detector/cmd/alert-router/router.yaml. The alert-router Lambda is not deployed if empty."
+  type        = string
+  default     = ""
+}
+
+variable "alert_router_secrets_path" {
+  description = "SSM parameter path of secrets of the alert-router Lambda. SecureString parameters under it, such as <path>/SLACK_WEBHOOK_URL, are read by url_env, secret_env and password_env of router.yaml."
+  type        = string
+  default     = "/seccamp2025-b1/alert-router"
+}
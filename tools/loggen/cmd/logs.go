@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/seccamp-2025-b1/tools/loggen/internal/dataset"
+	"github.com/urfave/cli/v3"
+)
+
+func LogsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "logs",
+		Usage: "Generate logs from seeds with a ground truth label sidecar",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "seeds",
+				Usage:    "Path to seeds file",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "time-range",
+				Usage: "Time range (e.g., '10:00-11:00'). Whole day if empty",
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "Output directory",
+				Value: "./output",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			return logsAction(c)
+		},
+	}
+}
+
+func logsAction(c *cli.Command) error {
+	seedsPath := c.String("seeds")
+	timeRange := c.String("time-range")
+	output := c.String("output")
+
+	// シードファイル読み込み（自動判定）
+	dayTemplate, err := loadDayTemplate(seedsPath)
+	if err != nil {
+		return fmt.Errorf("failed to load seeds file: %w", err)
+	}
+
+	// 時間範囲をパース（未指定なら1日分）
+	name := fmt.Sprintf("day_%s", dayTemplate.Date)
+	var startTime, endTime time.Time
+	if timeRange == "" {
+		startTime, err = time.Parse("2006-01-02", dayTemplate.Date)
+		if err != nil {
+			return fmt.Errorf("invalid date of seeds: %w", err)
+		}
+		endTime = startTime.Add(24 * time.Hour)
+	} else {
+		startTime, endTime, err = parseTimeRange(timeRange, dayTemplate.Date)
+		if err != nil {
+			return fmt.Errorf("failed to parse time range: %w", err)
+		}
+		name += "_" + strings.ReplaceAll(strings.ReplaceAll(timeRange, ":", ""), "-", "_")
+	}
+
+	ds, err := dataset.Expand(dayTemplate, startTime, endTime)
+	if err != nil {
+		return err
+	}
+
+	logsDir := filepath.Join(output, "logs")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// ログ本体とラベルのサイドカーを同じ名前で出力
+	logsPath := filepath.Join(logsDir, name+".jsonl")
+	if err := writeFile(logsPath, ds.WriteLogs); err != nil {
+		return err
+	}
+	labelsPath := filepath.Join(logsDir, name+".labels.jsonl")
+	if err := writeFile(labelsPath, ds.WriteLabels); err != nil {
+		return err
+	}
+
+	fmt.Printf("Logs written to: %s (%d logs)\n", logsPath, len(ds.Logs))
+	fmt.Printf("Labels written to: %s (%d anomalous logs)\n", labelsPath, len(ds.Labels))
+	return nil
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
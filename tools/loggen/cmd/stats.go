@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/m-mizutani/seccamp-2025-b1/internal/logcore"
+	"github.com/m-mizutani/seccamp-2025-b1/tools/loggen/internal/dataset"
 	"github.com/urfave/cli/v3"
 )
 
@@ -126,20 +127,7 @@ func calculateStats(seeds []logcore.LogSeed) SeedStats {
 }
 
 func getPatternName(pattern uint8) string {
-	switch pattern {
-	case logcore.PatternExample1NightAdminDownload:
-		return "Example1 Night Admin Download"
-	case logcore.PatternExample2ExternalLinkAccess:
-		return "Example2 External Link Access"
-	case logcore.PatternExample3VpnLateralMovement:
-		return "Example3 VPN Lateral Movement"
-	case logcore.PatternTimeAnomaly:
-		return "Time Anomaly"
-	case logcore.PatternVolumeAnomaly:
-		return "Volume Anomaly"
-	default:
-		return fmt.Sprintf("Pattern %d", pattern)
-	}
+	return dataset.PatternName(pattern)
 }
 
 func generateBar(ratio float64, maxWidth int) string {
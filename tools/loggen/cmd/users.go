@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/m-mizutani/seccamp-2025-b1/internal/logcore"
+	"github.com/m-mizutani/seccamp-2025-b1/tools/loggen/internal/dataset"
+	"github.com/urfave/cli/v3"
+)
+
+func UsersCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "users",
+		Usage: "Print users of the logs with their roles and peer groups as JSON lines",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "Output file. Stdout if empty",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			return usersAction(c)
+		},
+	}
+}
+
+func usersAction(c *cli.Command) error {
+	users := dataset.Users(logcore.DefaultConfig())
+	output := c.String("output")
+	if output == "" {
+		return dataset.WriteUsers(os.Stdout, users)
+	}
+	if err := writeFile(output, func(w io.Writer) error { return dataset.WriteUsers(w, users) }); err != nil {
+		return err
+	}
+	fmt.Printf("Users written to: %s (%d users)\n", output, len(users))
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package dataset expands seeds into logs together with their ground truth labels.
+//
+// Seeds know which anomaly pattern each entry belongs to, but the generated logs do not. A
+// label sidecar keeps the pattern of each anomalous entry keyed by id.uniqueQualifier, which
+// the converter keeps as metadata.uid, so detection results over the logs can be scored.
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/m-mizutani/seccamp-2025-b1/internal/logcore"
+)
+
+// Label is the ground truth of a generated log entry
+type Label struct {
+	UniqueQualifier string    `json:"unique_qualifier"`
+	Time            time.Time `json:"time"`
+	Pattern         uint8     `json:"pattern"`
+	PatternName     string    `json:"pattern_name"`
+	Actor           string    `json:"actor,omitempty"`
+	IPAddress       string    `json:"ip_address,omitempty"`
+}
+
+// Dataset is logs generated from seeds and labels of anomalous entries. Entries without a
+// label are normal.
+type Dataset struct {
+	Logs   []*logcore.GoogleWorkspaceLogEntry
+	Labels []Label
+}
+
+// Expand generates logs of seeds in [start, end) of the day template. Entries are generated
+// with their index in the range as the auditlog Lambda does, so the logs are the same as those
+// served for the range.
+func Expand(template *logcore.DayTemplate, start, end time.Time) (*Dataset, error) {
+	baseDate, err := time.Parse("2006-01-02", template.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date of day template: %w", err)
+	}
+
+	generator := logcore.NewGenerator(logcore.DefaultConfig())
+	seeds := logcore.ExtractSeedsInRange(template, start, end)
+	ds := &Dataset{Logs: make([]*logcore.GoogleWorkspaceLogEntry, 0, len(seeds))}
+	for i, seed := range seeds {
+		entry := generator.GenerateLogEntry(seed, baseDate, i)
+		ds.Logs = append(ds.Logs, entry)
+		if seed.Pattern == logcore.PatternNormal {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, entry.ID.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time of log %s: %w", entry.ID.UniqueQualifier, err)
+		}
+		ds.Labels = append(ds.Labels, Label{
+			UniqueQualifier: entry.ID.UniqueQualifier,
+			Time:            t.UTC(),
+			Pattern:         seed.Pattern,
+			PatternName:     PatternName(seed.Pattern),
+			Actor:           entry.Actor.Email,
+			IPAddress:       entry.IPAddress,
+		})
+	}
+	return ds, nil
+}
+
+// WriteLogs writes logs as JSON lines, the format of the auditlog API
+func (x *Dataset) WriteLogs(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range x.Logs {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to encode log: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteLabels writes labels as JSON lines
+func (x *Dataset) WriteLabels(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, label := range x.Labels {
+		if err := encoder.Encode(label); err != nil {
+			return fmt.Errorf("failed to encode label: %w", err)
+		}
+	}
+	return nil
+}
+
+// PatternName returns the name of an anomaly pattern
+func PatternName(pattern uint8) string {
+	switch pattern {
+	case logcore.PatternNormal:
+		return "Normal"
+	case logcore.PatternExample1NightAdminDownload:
+		return "Example1 Night Admin Download"
+	case logcore.PatternExample2ExternalLinkAccess:
+		return "Example2 External Link Access"
+	case logcore.PatternExample3VpnLateralMovement:
+		return "Example3 VPN Lateral Movement"
+	case logcore.PatternTimeAnomaly:
+		return "Time Anomaly"
+	case logcore.PatternVolumeAnomaly:
+		return "Volume Anomaly"
+	case logcore.PatternExample4HighFreqAuthAttack:
+		return "Example4 High Frequency Auth Attack"
+	case logcore.PatternExample5RapidDataTheft:
+		return "Example5 Rapid Data Theft"
+	case logcore.PatternExample6MultiServiceProbing:
+		return "Example6 Multi Service Probing"
+	case logcore.PatternExample7SimultaneousGeoAccess:
+		return "Example7 Simultaneous Geo Access"
+	default:
+		return fmt.Sprintf("Pattern %d", pattern)
+	}
+}
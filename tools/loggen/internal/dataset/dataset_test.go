@@ -0,0 +1,153 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/seccamp-2025-b1/internal/logcore"
+)
+
+func testTemplate() *logcore.DayTemplate {
+	return &logcore.DayTemplate{
+		Date: "2024-08-12",
+		LogSeeds: []logcore.LogSeed{
+			{Timestamp: 3600, EventType: logcore.EventTypeLogin, UserIndex: 0, Pattern: logcore.PatternNormal, Seed: 1},
+			{Timestamp: 36000, EventType: logcore.EventTypeDriveAccess, UserIndex: 1, Pattern: logcore.PatternNormal, Seed: 2},
+			{Timestamp: 36001, EventType: logcore.EventTypeLogin, UserIndex: 2, Pattern: logcore.PatternExample4HighFreqAuthAttack, Seed: 3},
+			{Timestamp: 36060, EventType: logcore.EventTypeDriveAccess, UserIndex: 3, Pattern: logcore.PatternExample5RapidDataTheft, Seed: 4},
+			{Timestamp: 39600, EventType: logcore.EventTypeLogin, UserIndex: 0, Pattern: logcore.PatternExample7SimultaneousGeoAccess, Seed: 5},
+		},
+	}
+}
+
+func TestExpand(t *testing.T) {
+	base := time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC)
+	ds, err := Expand(testTemplate(), base.Add(10*time.Hour), base.Add(11*time.Hour))
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	// The range has the seeds at 10:00:00, 10:00:01 and 10:01:00
+	if len(ds.Logs) != 3 {
+		t.Fatalf("expected 3 logs, got %d", len(ds.Logs))
+	}
+	if len(ds.Labels) != 2 {
+		t.Fatalf("expected 2 labels, got %d", len(ds.Labels))
+	}
+
+	// Labels refer to logs by uniqueQualifier
+	wantPatterns := map[string]uint8{
+		ds.Logs[1].ID.UniqueQualifier: logcore.PatternExample4HighFreqAuthAttack,
+		ds.Logs[2].ID.UniqueQualifier: logcore.PatternExample5RapidDataTheft,
+	}
+	for _, label := range ds.Labels {
+		want, ok := wantPatterns[label.UniqueQualifier]
+		if !ok {
+			t.Errorf("label of unknown log %s", label.UniqueQualifier)
+			continue
+		}
+		if label.Pattern != want {
+			t.Errorf("expected pattern %d of %s, got %d", want, label.UniqueQualifier, label.Pattern)
+		}
+		if label.PatternName != PatternName(want) {
+			t.Errorf("unexpected pattern name %q", label.PatternName)
+		}
+	}
+	if got := ds.Labels[1].Time; !got.Equal(base.Add(10*time.Hour + time.Minute)) {
+		t.Errorf("unexpected label time %s", got)
+	}
+
+	// Logs are the same as generated with the index in the range
+	generator := logcore.NewGenerator(logcore.DefaultConfig())
+	seed := testTemplate().LogSeeds[3]
+	if want := generator.GenerateLogEntry(seed, base, 2).ID.UniqueQualifier; ds.Logs[2].ID.UniqueQualifier != want {
+		t.Errorf("expected uniqueQualifier %s, got %s", want, ds.Logs[2].ID.UniqueQualifier)
+	}
+}
+
+func TestDataset_Write(t *testing.T) {
+	base := time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC)
+	ds, err := Expand(testTemplate(), base, base.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	var logs, labels bytes.Buffer
+	if err := ds.WriteLogs(&logs); err != nil {
+		t.Fatalf("WriteLogs failed: %v", err)
+	}
+	if err := ds.WriteLabels(&labels); err != nil {
+		t.Fatalf("WriteLabels failed: %v", err)
+	}
+
+	qualifiers := map[string]bool{}
+	scanner := bufio.NewScanner(&logs)
+	for scanner.Scan() {
+		var entry logcore.GoogleWorkspaceLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid log line: %v", err)
+		}
+		qualifiers[entry.ID.UniqueQualifier] = true
+	}
+	if len(qualifiers) != 5 {
+		t.Errorf("expected 5 distinct logs, got %d", len(qualifiers))
+	}
+
+	var n int
+	scanner = bufio.NewScanner(&labels)
+	for scanner.Scan() {
+		var label Label
+		if err := json.Unmarshal(scanner.Bytes(), &label); err != nil {
+			t.Fatalf("invalid label line: %v", err)
+		}
+		if !qualifiers[label.UniqueQualifier] {
+			t.Errorf("label of missing log %s", label.UniqueQualifier)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("expected 3 labels, got %d", n)
+	}
+}
+
+func TestPatternName(t *testing.T) {
+	if got := PatternName(logcore.PatternExample7SimultaneousGeoAccess); got != "Example7 Simultaneous Geo Access" {
+		t.Errorf("unexpected name %q", got)
+	}
+	if got := PatternName(42); got != "Pattern 42" {
+		t.Errorf("unexpected name %q", got)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	users := Users(logcore.DefaultConfig())
+	groups := map[string]string{}
+	for _, u := range users {
+		groups[u.Email] = u.Group
+	}
+	for email, want := range map[string]string{
+		"yamada.takeshi@muhaijuku.com":          GroupStaff,
+		"kobayashi.akira@muhaijuku.com":         GroupInstructor,
+		"yoshimura.takako@muhaijuku.com":        GroupStaff,
+		"sato.haruto@muhaijuku.com":             GroupLearner,
+		"taniguchi.keisuke@partner-company.com": GroupExternal,
+		"takano.masaki@muhaijuku.com":           GroupStaff,
+	} {
+		if groups[email] != want {
+			t.Errorf("group of %s: expected %s, got %s", email, want, groups[email])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteUsers(&buf, users[:2]); err != nil {
+		t.Fatalf("WriteUsers failed: %v", err)
+	}
+	expected := `{"email":"yamada.takeshi@muhaijuku.com","role":"ceo","group":"staff"}` + "\n" +
+		`{"email":"suzuki.keiko@muhaijuku.com","role":"cto","group":"staff"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected users:\n%s", buf.String())
+	}
+}
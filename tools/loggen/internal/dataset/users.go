@@ -0,0 +1,55 @@
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/m-mizutani/seccamp-2025-b1/internal/logcore"
+)
+
+// Peer groups of users, compared with each other by behavioral baselines
+const (
+	GroupInstructor = "instructor"
+	GroupStaff      = "staff"
+	GroupLearner    = "learner"
+	GroupExternal   = "external"
+)
+
+// User is a user of the generated logs with the peer group of the role
+type User struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	Group string `json:"group"`
+}
+
+// PeerGroup returns the peer group of a role of logcore.Config.Users. Executives, staff of
+// departments and the attack target accounts work as staff.
+func PeerGroup(role string) string {
+	switch role {
+	case GroupInstructor, GroupLearner, GroupExternal:
+		return role
+	default:
+		return GroupStaff
+	}
+}
+
+// Users returns users of the configuration in order
+func Users(config *logcore.Config) []User {
+	users := make([]User, 0, len(config.Users))
+	for _, u := range config.Users {
+		users = append(users, User{Email: u.Email, Role: u.Role, Group: PeerGroup(u.Role)})
+	}
+	return users
+}
+
+// WriteUsers writes users as JSON lines
+func WriteUsers(w io.Writer, users []User) error {
+	encoder := json.NewEncoder(w)
+	for _, u := range users {
+		if err := encoder.Encode(u); err != nil {
+			return fmt.Errorf("failed to encode user: %w", err)
+		}
+	}
+	return nil
+}
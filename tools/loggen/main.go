@@ -20,6 +20,8 @@ func main() {
 			cmd.GenerateCommand(),
 			cmd.ValidateCommand(),
 			cmd.PreviewCommand(),
+			cmd.LogsCommand(),
+			cmd.UsersCommand(),
 			cmd.StatsCommand(),
 			cmd.CompareCommand(),
 		},